@@ -3,10 +3,22 @@ package main
 import (
 	"api-culinary-review/config"
 	"api-culinary-review/docs"
-	"api-culinary-review/internal/routes"
+	"api-culinary-review/internal/app"
+	"api-culinary-review/internal/repositories"
 	"api-culinary-review/pkg/database"
-	"api-culinary-review/pkg/helper"
-	"log"
+	"api-culinary-review/pkg/logging"
+	"api-culinary-review/pkg/metrics"
+	"api-culinary-review/pkg/tracing"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 )
 
 // @title API Culinary Review
@@ -28,25 +40,120 @@ import (
 // @in header
 // @name Authorization
 func main() {
-	cfg := config.LoadConfig()
-	db := database.ConnectDB(*cfg)
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(logger)
+	logger.Info("configuration loaded", slog.Any("config", cfg))
+
+	if err := run(cfg, logger); err != nil {
+		logger.Error("server failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// run serves the API until the process receives SIGINT or SIGTERM, then lets in-flight
+// requests finish before closing the database and flushing traces.
+func run(cfg *config.Config, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	environment := helper.Getenv("ENVIRONMENT", "development")
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName:  cfg.ServiceName,
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.OTLPEndpoint,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("failed to flush traces", slog.Any("error", err))
+		}
+	}()
+
+	db, err := database.ConnectDB(*cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			logger.Error("failed to close database", slog.Any("error", err))
+		}
+	}()
+	if err := repositories.MigrateTagNames(ctx, db); err != nil {
+		return err
+	}
+	if err := metrics.RegisterDB(db.DB(), cfg.DBName); err != nil {
+		logger.Warn("failed to register database metrics", slog.Any("error", err))
+	}
 
 	//programmatically set swagger info
 	docs.SwaggerInfo.Title = "Culinary Review REST API"
 	docs.SwaggerInfo.Description = "This is REST API Culinary Review."
 	docs.SwaggerInfo.Version = "1.0"
-	docs.SwaggerInfo.Host = helper.Getenv("HOST", "screeching-joanna-arasycorp-919c2cee.koyeb.app")
-	if environment == "development" {
+	docs.SwaggerInfo.Host = cfg.Host
+	if cfg.Env == "development" {
 		docs.SwaggerInfo.Schemes = []string{"http", "https"}
 	} else {
 		docs.SwaggerInfo.Schemes = []string{"https"}
 	}
 
-	r := routes.SetupRouter(db)
+	deps, err := app.NewDependencies(cfg, db, logger)
+	if err != nil {
+		return err
+	}
+	api, err := app.New(cfg, deps)
+	if err != nil {
+		return err
+	}
+
+	var jobs sync.WaitGroup
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		api.RunBackgroundJobs(ctx)
+	}()
+	defer jobs.Wait()
+
+	server := &http.Server{
+		Addr:              cfg.HTTPAddr,
+		Handler:           api.Handler,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting server", slog.String("addr", cfg.HTTPAddr))
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		stop()
+		return fmt.Errorf("run server: %w", err)
+	case <-ctx.Done():
+	}
 
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to run server:", err)
+	logger.Info("shutting down server", slog.Duration("timeout", cfg.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shut down server: %w", err)
 	}
+	logger.Info("server stopped")
+	return nil
 }
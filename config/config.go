@@ -1,50 +1,149 @@
 package config
 
 import (
-	"api-culinary-review/pkg/helper"
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
+	"api-culinary-review/pkg/ratelimit"
+	"log/slog"
+	"strings"
+	"time"
 )
 
+// Config holds the settings of the API. Each field is read, from lowest to highest precedence,
+// from its default tag, the YAML file under its yaml key, the environment variable named by its
+// env tag (including a .env file), and the command line flag named after its yaml key. Fields
+// tagged secret are redacted when the configuration is logged.
 type Config struct {
-	DBHost         string
-	DBUser         string
-	DBPassword     string
-	DBName         string
-	DBPort         string
-	JWTSecret      string
-	SupabaseURL    string
-	SupabaseKey    string
-	SupabaseBucket string
-	CloudinaryURL  string
-	Env            string
+	DBHost         string        `yaml:"db_host" env:"DB_HOST"`
+	DBUser         string        `yaml:"db_user" env:"DB_USER"`
+	DBPassword     string        `yaml:"db_password" env:"DB_PASSWORD" secret:"true"`
+	DBName         string        `yaml:"db_name" env:"DB_NAME"`
+	DBPort         string        `yaml:"db_port" env:"DB_PORT" default:"5432"`
+	JWTSecret      string        `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	AccessTokenTTL time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL" default:"12h"`
+	Env            string        `yaml:"environment" env:"ENVIRONMENT" default:"development"`
+	// Host is the public host name of the API shown in the Swagger documentation.
+	Host string `yaml:"host" env:"HOST" default:"screeching-joanna-arasycorp-919c2cee.koyeb.app"`
+
+	// StorageDriver selects where uploaded images are kept: "cloudinary" or "supabase".
+	StorageDriver  string `yaml:"storage_driver" env:"STORAGE_DRIVER" default:"cloudinary"`
+	CloudinaryURL  string `yaml:"cloudinary_url" env:"CLOUDINARY_URL" secret:"true"`
+	SupabaseURL    string `yaml:"supabase_url" env:"SUPABASE_URL"`
+	SupabaseKey    string `yaml:"supabase_key" env:"SUPABASE_KEY" secret:"true"`
+	SupabaseBucket string `yaml:"supabase_bucket" env:"SUPABASE_BUCKET"`
+
+	// HTTPAddr is the address the server listens on. Requests that are still running after
+	// ShutdownTimeout once the server is asked to stop are abandoned.
+	HTTPAddr              string        `yaml:"http_addr" env:"HTTP_ADDR" default:":8080"`
+	HTTPReadTimeout       time.Duration `yaml:"http_read_timeout" env:"HTTP_READ_TIMEOUT" default:"30s"`
+	HTTPReadHeaderTimeout time.Duration `yaml:"http_read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" default:"5s"`
+	HTTPWriteTimeout      time.Duration `yaml:"http_write_timeout" env:"HTTP_WRITE_TIMEOUT" default:"1m"`
+	HTTPIdleTimeout       time.Duration `yaml:"http_idle_timeout" env:"HTTP_IDLE_TIMEOUT" default:"2m"`
+	ShutdownTimeout       time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"20s"`
+	// ReadinessTimeout bounds the dependency checks of the readiness probe.
+	ReadinessTimeout time.Duration `yaml:"readiness_timeout" env:"READINESS_TIMEOUT" default:"2s"`
+	// TrustedProxies (comma-separated IP addresses or CIDR ranges) are the reverse proxies whose
+	// X-Forwarded-For header names the client. When empty no proxy is trusted and the client IP
+	// used by rate limits and login lockouts is the address of the connection.
+	TrustedProxies string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	// LogLevel is the minimum level of logged records and LogFormat is "json" or "text".
+	LogLevel  slog.Level `yaml:"log_level" env:"LOG_LEVEL" default:"info"`
+	LogFormat string     `yaml:"log_format" env:"LOG_FORMAT" default:"json"`
+	// SlowQueryThreshold is how long a database query may take before it is logged as slow.
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"SLOW_QUERY_THRESHOLD" default:"200ms"`
+
+	// TracingExporter is "none", "stdout" or "otlp". OTLPEndpoint is the URL of the OTLP/HTTP collector,
+	// e.g. http://localhost:4318/v1/traces; when empty the OTEL_EXPORTER_OTLP_* variables of the SDK apply.
+	ServiceName        string  `yaml:"service_name" env:"OTEL_SERVICE_NAME" default:"api-culinary-review"`
+	TracingExporter    string  `yaml:"tracing_exporter" env:"TRACING_EXPORTER" default:"none"`
+	OTLPEndpoint       string  `yaml:"tracing_otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1"`
+
+	// AccountDeletionGracePeriod is how long a deletion request can be cancelled before the account is anonymized.
+	AccountDeletionGracePeriod time.Duration `yaml:"account_deletion_grace_period" env:"ACCOUNT_DELETION_GRACE_PERIOD" default:"720h"`
+
+	// AppBaseURL is the frontend address used to build links in emails.
+	AppBaseURL string `yaml:"app_base_url" env:"APP_BASE_URL" default:"http://localhost:3000"`
+	// MailDriver selects the mailer: "smtp", or "file" to write emails into MailDir.
+	MailDriver            string        `yaml:"mail_driver" env:"MAIL_DRIVER" default:"file"`
+	MailFrom              string        `yaml:"mail_from" env:"MAIL_FROM" default:"Culinary Review <no-reply@culinary-review.local>"`
+	MailDir               string        `yaml:"mail_dir" env:"MAIL_DIR" default:"tmp/mail"`
+	SMTPHost              string        `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort              string        `yaml:"smtp_port" env:"SMTP_PORT" default:"587"`
+	SMTPUsername          string        `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword          string        `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+	VerificationTokenTTL  time.Duration `yaml:"email_verification_token_ttl" env:"EMAIL_VERIFICATION_TOKEN_TTL" default:"48h"`
+	PasswordResetTokenTTL time.Duration `yaml:"password_reset_token_ttl" env:"PASSWORD_RESET_TOKEN_TTL" default:"1h"`
+
+	LoginThrottleWindow     time.Duration `yaml:"login_throttle_window" env:"LOGIN_THROTTLE_WINDOW" default:"1h"`
+	LoginMaxAccountFailures int           `yaml:"login_max_account_failures" env:"LOGIN_MAX_ACCOUNT_FAILURES" default:"5"`
+	LoginMaxIPFailures      int           `yaml:"login_max_ip_failures" env:"LOGIN_MAX_IP_FAILURES" default:"20"`
+	LoginLockoutBase        time.Duration `yaml:"login_lockout_base" env:"LOGIN_LOCKOUT_BASE" default:"30s"`
+	LoginLockoutMax         time.Duration `yaml:"login_lockout_max" env:"LOGIN_LOCKOUT_MAX" default:"15m"`
+
+	TOTPIssuer            string        `yaml:"totp_issuer" env:"TOTP_ISSUER" default:"Culinary Review"`
+	TwoFactorChallengeTTL time.Duration `yaml:"two_factor_challenge_ttl" env:"TWO_FACTOR_CHALLENGE_TTL" default:"5m"`
+
+	// Rate limit policies of the API as a whole, of authentication endpoints and of image uploads,
+	// written as "<limit>/<period>". An empty value disables the limit.
+	RateLimitDefault ratelimit.Policy `yaml:"rate_limit_default" env:"RATE_LIMIT_DEFAULT" default:"300/1m"`
+	RateLimitAuth    ratelimit.Policy `yaml:"rate_limit_auth" env:"RATE_LIMIT_AUTH" default:"10/1m"`
+	RateLimitUpload  ratelimit.Policy `yaml:"rate_limit_upload" env:"RATE_LIMIT_UPLOAD" default:"20/1h"`
+
+	// NutritionDataset is a .csv or .json file of foods replacing the bundled nutrient dataset.
+	NutritionDataset string `yaml:"nutrition_dataset" env:"NUTRITION_DATASET"`
+
+	// UnknownTags decides what happens to tag names of a submitted recipe that match no tag:
+	// "create" adds them as tags pending moderation, "ignore" drops them and "reject" fails the request.
+	UnknownTags string `yaml:"unknown_tags" env:"UNKNOWN_TAGS" default:"create"`
+	// ReviewPhotoLimit is the number of photos a review can carry, 0 to not accept photos.
+	ReviewPhotoLimit int `yaml:"review_photo_limit" env:"REVIEW_PHOTO_LIMIT" default:"5"`
+	// ContentFilterWords (comma-separated) and the rules in ContentFilterFile hide the reviews and
+	// recipes that contain them until a moderator approves them.
+	ContentFilterWords string `yaml:"content_filter_words" env:"CONTENT_FILTER_WORDS"`
+	ContentFilterFile  string `yaml:"content_filter_file" env:"CONTENT_FILTER_FILE"`
+
+	// OAuthRedirectBaseURL is the public address of this API, used for provider callbacks.
+	OAuthRedirectBaseURL string          `yaml:"oauth_redirect_base_url" env:"OAUTH_REDIRECT_BASE_URL" default:"http://localhost:8080"`
+	OAuthProviders       []OAuthProvider `yaml:"oauth_providers"`
 }
 
-func LoadConfig() *Config {
-	// for load godotenv
-	// for env
-	environment := helper.Getenv("ENVIRONMENT", "development")
+// OAuthProvider configures sign-in with an external identity provider.
+type OAuthProvider struct {
+	Name         string   `yaml:"name"`
+	Kind         string   `yaml:"kind"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	IssuerURL    string   `yaml:"issuer_url"`
+	AuthURL      string   `yaml:"auth_url"`
+	TokenURL     string   `yaml:"token_url"`
+	UserInfoURL  string   `yaml:"userinfo_url"`
+	Scopes       []string `yaml:"scopes"`
+}
 
-	if environment == "development" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Fatal("Error loading .env file")
-		}
-	}
+// defaultOAuthProviders holds the well-known settings of supported providers, so only client
+// credentials have to be configured for them.
+var defaultOAuthProviders = map[string]OAuthProvider{
+	"google": {
+		Kind:      "oidc",
+		IssuerURL: "https://accounts.google.com",
+		Scopes:    []string{"openid", "email", "profile"},
+	},
+	"github": {
+		Kind:        "github",
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		Scopes:      []string{"read:user", "user:email"},
+	},
+}
 
-	return &Config{
-		DBHost:         os.Getenv("DB_HOST"),
-		DBUser:         os.Getenv("DB_USER"),
-		DBPassword:     os.Getenv("DB_PASSWORD"),
-		DBName:         os.Getenv("DB_NAME"),
-		DBPort:         os.Getenv("DB_PORT"),
-		JWTSecret:      os.Getenv("JWT_SECRET"),
-		SupabaseURL:    os.Getenv("SUPABASE_URL"),
-		SupabaseKey:    os.Getenv("SUPABASE_KEY"),
-		SupabaseBucket: os.Getenv("SUPABASE_BUCKET"),
-		CloudinaryURL:  os.Getenv("CLOUDINARY_URL"),
-		Env:            os.Getenv("ENVIRONMENT"),
+// TrustedProxyList returns the entries of TrustedProxies, nil when there are none.
+func (c *Config) TrustedProxyList() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
 	}
+	return proxies
 }
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a field of Config that is read from the environment, the YAML file and flags.
+type setting struct {
+	key          string
+	env          string
+	defaultValue string
+	secret       bool
+	value        reflect.Value
+}
+
+// Load reads the configuration from its sources and validates it. args are the command line
+// arguments without the program name: -config names the YAML file, CONFIG_FILE when omitted,
+// and -<yaml key with dashes>, e.g. -http-addr, sets a single field. A .env file in the working
+// directory is loaded when present; it never overrides variables that are already set.
+func Load(args []string) (*Config, error) {
+	cfg := &Config{}
+	settings := settingsOf(cfg)
+
+	flags := flag.NewFlagSet("api", flag.ContinueOnError)
+	configFile := flags.String("config", "", "path of a YAML configuration file (CONFIG_FILE)")
+	flagValues := map[string]string{}
+	for _, s := range settings {
+		key := s.key
+		flags.Func(strings.ReplaceAll(key, "_", "-"), "overrides "+s.env, func(value string) error {
+			flagValues[key] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load .env: %w", err)
+	}
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+
+	applyDefaults(settings)
+	if *configFile != "" {
+		if err := loadFile(cfg, settings, *configFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := set(s.value, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := flagValues[s.key]; ok {
+			if err := set(s.value, value); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", strings.ReplaceAll(s.key, "_", "-"), err)
+			}
+		}
+	}
+
+	if providers := loadOAuthProviders(); providers != nil {
+		cfg.OAuthProviders = providers
+	}
+	for i, provider := range cfg.OAuthProviders {
+		cfg.OAuthProviders[i] = withDefaults(provider)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Default returns the configuration made of the default values only, e.g. as a base for tests.
+// It is not validated: the database and secrets have no default.
+func Default() *Config {
+	cfg := &Config{}
+	applyDefaults(settingsOf(cfg))
+	return cfg
+}
+
+// applyDefaults sets every setting to its default tag. The defaults are constants, so a
+// default that does not parse is a programming error.
+func applyDefaults(settings []setting) {
+	for _, s := range settings {
+		if err := set(s.value, s.defaultValue); err != nil {
+			panic(fmt.Sprintf("config: invalid default of %s: %v", s.env, err))
+		}
+	}
+}
+
+// settingsOf lists the fields of cfg that have an env tag.
+func settingsOf(cfg *Config) []setting {
+	value := reflect.ValueOf(cfg).Elem()
+	fields := value.Type()
+
+	settings := make([]setting, 0, fields.NumField())
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		env := field.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		settings = append(settings, setting{
+			key:          field.Tag.Get("yaml"),
+			env:          env,
+			defaultValue: field.Tag.Get("default"),
+			secret:       field.Tag.Get("secret") == "true",
+			value:        value.Field(i),
+		})
+	}
+	return settings
+}
+
+// loadFile reads the YAML file at path. Unknown keys are rejected so typos do not go unnoticed.
+func loadFile(cfg *Config, settings []setting, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var document map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	for key, node := range document {
+		if key == "oauth_providers" {
+			if err := node.Decode(&cfg.OAuthProviders); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+			continue
+		}
+
+		s, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s: %s must be a single value", path, key)
+		}
+		if err := set(s.value, node.Value); err != nil {
+			return fmt.Errorf("%s: invalid %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// set parses text into the field v. An empty text sets the zero value.
+func set(v reflect.Value, text string) error {
+	if text == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// loadOAuthProviders reads the providers listed in OAUTH_PROVIDERS, e.g. "google,github". Each
+// provider is configured with OAUTH_<NAME>_* variables. It returns nil when OAUTH_PROVIDERS is
+// not set, leaving the providers of the YAML file.
+func loadOAuthProviders() []OAuthProvider {
+	list, ok := os.LookupEnv("OAUTH_PROVIDERS")
+	if !ok {
+		return nil
+	}
+
+	providers := []OAuthProvider{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+		provider := OAuthProvider{
+			Name:         name,
+			Kind:         os.Getenv(prefix + "KIND"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			IssuerURL:    os.Getenv(prefix + "ISSUER_URL"),
+			AuthURL:      os.Getenv(prefix + "AUTH_URL"),
+			TokenURL:     os.Getenv(prefix + "TOKEN_URL"),
+			UserInfoURL:  os.Getenv(prefix + "USERINFO_URL"),
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Split(scopes, ",")
+		}
+
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// withDefaults fills the settings provider leaves empty from the defaults of well-known
+// providers. Any other name is a generic OpenID Connect provider.
+func withDefaults(provider OAuthProvider) OAuthProvider {
+	provider.Name = strings.ToLower(provider.Name)
+	defaults, ok := defaultOAuthProviders[provider.Name]
+	if !ok {
+		defaults = OAuthProvider{Kind: "oidc", Scopes: []string{"openid", "email", "profile"}}
+	}
+
+	if provider.Kind == "" {
+		provider.Kind = defaults.Kind
+	}
+	if provider.IssuerURL == "" {
+		provider.IssuerURL = defaults.IssuerURL
+	}
+	if provider.AuthURL == "" {
+		provider.AuthURL = defaults.AuthURL
+	}
+	if provider.TokenURL == "" {
+		provider.TokenURL = defaults.TokenURL
+	}
+	if provider.UserInfoURL == "" {
+		provider.UserInfoURL = defaults.UserInfoURL
+	}
+	if len(provider.Scopes) == 0 {
+		provider.Scopes = defaults.Scopes
+	}
+	return provider
+}
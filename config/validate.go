@@ -0,0 +1,139 @@
+package config
+
+import (
+	"api-culinary-review/pkg/storage"
+	"api-culinary-review/pkg/tracing"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinJWTSecretLength is the shortest accepted JWT secret, 256 bits for HS256.
+const MinJWTSecretLength = 32
+
+const redacted = "[REDACTED]"
+
+// Validate reports every invalid or missing setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.DBHost != "", "DB_HOST is required")
+	check(c.DBUser != "", "DB_USER is required")
+	check(c.DBName != "", "DB_NAME is required")
+	port, err := strconv.Atoi(c.DBPort)
+	check(err == nil && port > 0 && port <= 65535, "DB_PORT must be a port number, got %q", c.DBPort)
+	check(len(c.JWTSecret) >= MinJWTSecretLength, "JWT_SECRET must be at least %d characters long", MinJWTSecretLength)
+	check(c.HTTPAddr != "", "HTTP_ADDR is required")
+
+	switch c.StorageDriver {
+	case storage.DriverCloudinary:
+		check(c.CloudinaryURL != "", "CLOUDINARY_URL is required by the cloudinary storage driver")
+	case storage.DriverSupabase:
+		check(c.SupabaseURL != "" && c.SupabaseKey != "" && c.SupabaseBucket != "",
+			"SUPABASE_URL, SUPABASE_KEY and SUPABASE_BUCKET are required by the supabase storage driver")
+	default:
+		check(false, "STORAGE_DRIVER must be %q or %q, got %q", storage.DriverCloudinary, storage.DriverSupabase, c.StorageDriver)
+	}
+
+	switch c.MailDriver {
+	case "smtp":
+		check(c.SMTPHost != "", "SMTP_HOST is required by the smtp mail driver")
+	case "file":
+		check(c.MailDir != "", "MAIL_DIR is required by the file mail driver")
+	default:
+		check(false, `MAIL_DRIVER must be "smtp" or "file", got %q`, c.MailDriver)
+	}
+
+	format := strings.ToLower(c.LogFormat)
+	check(format == "json" || format == "text", `LOG_FORMAT must be "json" or "text", got %q`, c.LogFormat)
+
+	switch strings.ToLower(c.TracingExporter) {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		check(false, "TRACING_EXPORTER must be %q, %q or %q, got %q", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, c.TracingExporter)
+	}
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+
+	for _, proxy := range c.TrustedProxyList() {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES must list IP addresses or CIDR ranges, got %q", proxy)
+	}
+
+	switch c.UnknownTags {
+	case "create", "ignore", "reject":
+	default:
+		check(false, `UNKNOWN_TAGS must be "create", "ignore" or "reject", got %q`, c.UnknownTags)
+	}
+	check(c.ReviewPhotoLimit >= 0 && c.ReviewPhotoLimit <= 20, "REVIEW_PHOTO_LIMIT must be between 0 and 20")
+
+	for _, d := range []struct {
+		env   string
+		value time.Duration
+	}{
+		{"ACCESS_TOKEN_TTL", c.AccessTokenTTL},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"READINESS_TIMEOUT", c.ReadinessTimeout},
+		{"EMAIL_VERIFICATION_TOKEN_TTL", c.VerificationTokenTTL},
+		{"PASSWORD_RESET_TOKEN_TTL", c.PasswordResetTokenTTL},
+		{"TWO_FACTOR_CHALLENGE_TTL", c.TwoFactorChallengeTTL},
+		{"LOGIN_THROTTLE_WINDOW", c.LoginThrottleWindow},
+	} {
+		check(d.value > 0, "%s must be positive", d.env)
+	}
+
+	for _, p := range c.OAuthProviders {
+		check(p.Name != "", "every OAuth provider needs a name")
+		check(p.ClientID != "" && p.ClientSecret != "", "OAuth provider %q needs a client ID and a client secret", p.Name)
+		switch p.Kind {
+		case "oidc":
+			check(p.IssuerURL != "", "OAuth provider %q needs an issuer URL", p.Name)
+		case "github":
+		default:
+			check(false, `OAuth provider %q must be of kind "oidc" or "github", got %q`, p.Name, p.Kind)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+// LogValue logs every setting under its YAML key, with the secrets redacted.
+func (c Config) LogValue() slog.Value {
+	settings := settingsOf(&c)
+	attrs := make([]slog.Attr, 0, len(settings)+1)
+	for _, s := range settings {
+		if s.secret && !s.value.IsZero() {
+			attrs = append(attrs, slog.String(s.key, redacted))
+			continue
+		}
+		if stringer, ok := s.value.Interface().(fmt.Stringer); ok {
+			attrs = append(attrs, slog.String(s.key, stringer.String()))
+			continue
+		}
+		attrs = append(attrs, slog.Any(s.key, s.value.Interface()))
+	}
+
+	providers := make([]string, 0, len(c.OAuthProviders))
+	for _, p := range c.OAuthProviders {
+		providers = append(providers, p.Name)
+	}
+	attrs = append(attrs, slog.Any("oauth_providers", providers))
+
+	return slog.GroupValue(attrs...)
+}
+
+// String formats the configuration like LogValue, so printing it does not leak secrets.
+func (c Config) String() string {
+	return c.LogValue().String()
+}
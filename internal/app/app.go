@@ -0,0 +1,162 @@
+// Package app builds the object graph of the API, from repositories to routes, out of its
+// configuration and external dependencies. Production dependencies come from NewDependencies;
+// tests can replace any of them, e.g. with in-memory storage and a test database, and serve
+// App.Handler in-process.
+package app
+
+import (
+	"api-culinary-review/config"
+	"api-culinary-review/internal/controllers"
+	"api-culinary-review/internal/repositories"
+	"api-culinary-review/internal/routes"
+	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/contentfilter"
+	"api-culinary-review/pkg/idgen"
+	"api-culinary-review/pkg/jwt"
+	"api-culinary-review/pkg/mailer"
+	"api-culinary-review/pkg/nutrition"
+	"api-culinary-review/pkg/ratelimit"
+	"api-culinary-review/pkg/storage"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Pinger checks that the database is reachable. *sql.DB implements it.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Dependencies are the services the API relies on.
+type Dependencies struct {
+	Database     Pinger
+	Repositories repositories.Set
+	Storage      storage.Storage
+	Mailer       mailer.Mailer
+	Clock        clock.Clock
+	IDs          idgen.Generator
+	RateLimits   ratelimit.Store
+	Nutrition    *nutrition.Database
+	// ContentFilter hides submitted reviews and recipes that match it until a moderator
+	// approves them.
+	ContentFilter *contentfilter.Filter
+	Logger        *slog.Logger
+}
+
+// App is the assembled API.
+type App struct {
+	// Handler serves every route of the API.
+	Handler http.Handler
+
+	users  usecases.UserUsecase
+	logger *slog.Logger
+}
+
+// New wires the usecases, controllers and routes of the API on top of deps.
+func New(cfg *config.Config, deps Dependencies) (*App, error) {
+	if err := deps.validate(); err != nil {
+		return nil, err
+	}
+	repos := deps.Repositories
+
+	userUc := usecases.NewUserUsecase(repos.Users, repos.Profiles, deps.Storage, deps.Clock, cfg.AccountDeletionGracePeriod, deps.Logger)
+	authUc := usecases.NewAuthUsecase(repos.Users, repos.Profiles, repos.UserTokens, repos.UserIdentities, repos.LoginAttempts, repos.RecoveryCodes,
+		deps.Mailer, newOAuthProviders(cfg), deps.Clock, deps.IDs, usecases.AuthOptions{
+			BaseURL:              cfg.AppBaseURL,
+			VerificationTokenTTL: cfg.VerificationTokenTTL,
+			ResetTokenTTL:        cfg.PasswordResetTokenTTL,
+			LoginThrottle: usecases.LoginThrottle{
+				Window:           cfg.LoginThrottleWindow,
+				AccountThreshold: cfg.LoginMaxAccountFailures,
+				IPThreshold:      cfg.LoginMaxIPFailures,
+				BaseLockout:      cfg.LoginLockoutBase,
+				MaxLockout:       cfg.LoginLockoutMax,
+			},
+			TOTPIssuer:        cfg.TOTPIssuer,
+			RecoveryCodeCount: 10,
+		}, deps.Logger)
+	profileUc := usecases.NewProfileUsecase(repos.Profiles, deps.Storage)
+	tagUc := usecases.NewtagUsecase(repos.Tags, deps.Clock, cfg.UnknownTags)
+	recipeUc := usecases.NewRecipeUsecase(repos.Recipes, repos.Profiles, repos.Reports, repos.Favorites, tagUc, deps.Storage, deps.Nutrition, deps.ContentFilter, deps.Logger)
+	reviewUc := usecases.NewReviewUsecase(repos.Reviews, repos.Recipes, repos.Users, repos.Reports, deps.Storage, deps.Clock, deps.ContentFilter,
+		cfg.ReviewPhotoLimit, deps.Logger)
+	moderationUc := usecases.NewModerationUsecase(repos.Reports, repos.Reviews, repos.Recipes, deps.Clock)
+	favoriteUc := usecases.NewFavoriteUsecase(repos.Favorites, repos.Recipes)
+	nutritionUc := usecases.NewNutritionUsecase(deps.Nutrition)
+
+	tokens := jwt.NewManager(cfg.JWTSecret, cfg.AccessTokenTTL)
+
+	router := routes.SetupRouter(cfg, routes.Handlers{
+		User:       controllers.NewUserController(userUc, authUc, tokens, cfg.TwoFactorChallengeTTL, deps.Logger),
+		Profile:    controllers.NewProfileController(profileUc),
+		Recipe:     controllers.NewRecipeController(recipeUc),
+		Review:     controllers.NewReviewController(reviewUc),
+		Favorite:   controllers.NewFavoriteController(favoriteUc),
+		Tag:        controllers.NewTagController(tagUc),
+		Nutrition:  controllers.NewNutritionController(nutritionUc),
+		Moderation: controllers.NewModerationController(moderationUc),
+		Health: controllers.NewHealthController(map[string]controllers.HealthCheck{
+			"database": deps.Database.PingContext,
+			"storage":  deps.Storage.Ping,
+		}, cfg.ReadinessTimeout, deps.Logger),
+		Tokens:     tokens,
+		Roles:      userUc.GetRole,
+		Active:     userUc.IsActive,
+		IDs:        deps.IDs,
+		RateLimits: deps.RateLimits,
+	}, deps.Logger)
+
+	return &App{
+		Handler: router,
+		users:   userUc,
+		logger:  deps.Logger,
+	}, nil
+}
+
+// RunBackgroundJobs runs the periodic jobs of the API, such as anonymizing accounts whose
+// deletion grace period has passed, until ctx is done.
+func (a *App) RunBackgroundJobs(ctx context.Context) {
+	usecases.RunAccountPurger(ctx, a.users, time.Hour, a.logger)
+}
+
+func (d Dependencies) validate() error {
+	var missing []string
+	repos := d.Repositories
+	for _, dep := range []struct {
+		name string
+		set  bool
+	}{
+		{"Database", d.Database != nil},
+		{"Repositories.Users", repos.Users != nil},
+		{"Repositories.Profiles", repos.Profiles != nil},
+		{"Repositories.UserTokens", repos.UserTokens != nil},
+		{"Repositories.UserIdentities", repos.UserIdentities != nil},
+		{"Repositories.LoginAttempts", repos.LoginAttempts != nil},
+		{"Repositories.RecoveryCodes", repos.RecoveryCodes != nil},
+		{"Repositories.Recipes", repos.Recipes != nil},
+		{"Repositories.Reviews", repos.Reviews != nil},
+		{"Repositories.Tags", repos.Tags != nil},
+		{"Repositories.Favorites", repos.Favorites != nil},
+		{"Repositories.Reports", repos.Reports != nil},
+		{"Storage", d.Storage != nil},
+		{"Mailer", d.Mailer != nil},
+		{"Clock", d.Clock != nil},
+		{"IDs", d.IDs != nil},
+		{"RateLimits", d.RateLimits != nil},
+		{"Nutrition", d.Nutrition != nil},
+		{"ContentFilter", d.ContentFilter != nil},
+		{"Logger", d.Logger != nil},
+	} {
+		if !dep.set {
+			missing = append(missing, dep.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("app: missing dependencies: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package app_test
+
+import (
+	"api-culinary-review/config"
+	"api-culinary-review/internal/app"
+	"api-culinary-review/internal/repositories/memory"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/contentfilter"
+	"api-culinary-review/pkg/idgen"
+	"api-culinary-review/pkg/mailer"
+	"api-culinary-review/pkg/nutrition"
+	"api-culinary-review/pkg/ratelimit"
+	"api-culinary-review/pkg/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type pinger struct{}
+
+func (pinger) PingContext(context.Context) error { return nil }
+
+func newDependencies(t *testing.T) app.Dependencies {
+	filter, err := contentfilter.New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app.Dependencies{
+		Database:      pinger{},
+		Repositories:  memory.NewSet(),
+		Storage:       storage.NewMemory(),
+		Mailer:        mailer.NewMemoryMailer(),
+		Clock:         clock.System{},
+		IDs:           &idgen.Sequence{},
+		RateLimits:    ratelimit.NewMemoryStore(clock.System{}),
+		Nutrition:     nutrition.Default(),
+		ContentFilter: filter,
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func newConfig() *config.Config {
+	cfg := config.Default()
+	cfg.JWTSecret = "0123456789abcdef0123456789abcdef"
+	return cfg
+}
+
+func TestNewRequiresDependencies(t *testing.T) {
+	deps := newDependencies(t)
+	deps.Mailer = nil
+	deps.Repositories.Reviews = nil
+
+	_, err := app.New(newConfig(), deps)
+	if err == nil || !strings.Contains(err.Error(), "Mailer") || !strings.Contains(err.Error(), "Repositories.Reviews") {
+		t.Errorf("New() error = %v, want the missing Mailer and review repository", err)
+	}
+}
+
+// client calls the API in-process and decodes its JSON responses.
+type client struct {
+	t       *testing.T
+	server  *httptest.Server
+	token   string
+	storage *storage.Memory
+}
+
+func (c *client) stored(url string) bool {
+	_, ok := c.storage.File(url)
+	return ok
+}
+
+func (c *client) do(req *http.Request, wantStatus int, out any) {
+	c.t.Helper()
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.server.Client().Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		c.t.Fatalf("%s %s = %d %s, want %d", req.Method, req.URL.Path, resp.StatusCode, body, wantStatus)
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			c.t.Fatalf("%s %s: decode %s: %v", req.Method, req.URL.Path, body, err)
+		}
+	}
+}
+
+func (c *client) json(method, path string, in any, wantStatus int, out any) {
+	c.t.Helper()
+	var body io.Reader
+	if in != nil {
+		data, _ := json.Marshal(in)
+		body = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, c.server.URL+path, body)
+	req.Header.Set("Content-Type", "application/json")
+	c.do(req, wantStatus, out)
+}
+
+func (c *client) multipart(method, path string, fields map[string]string, files map[string]string, wantStatus int, out any) {
+	c.t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		w.WriteField(name, value)
+	}
+	for name, filename := range files {
+		part, _ := w.CreateFormFile(name, filename)
+		part.Write([]byte("image of " + filename))
+	}
+	w.Close()
+
+	req, _ := http.NewRequest(method, c.server.URL+path, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	c.do(req, wantStatus, out)
+}
+
+func TestRateLimitIgnoresForwardedFor(t *testing.T) {
+	cfg := newConfig()
+	cfg.RateLimitAuth = ratelimit.Policy{Limit: 2, Period: time.Minute}
+	a, err := app.New(cfg, newDependencies(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.Handler)
+	defer server.Close()
+
+	// Without trusted proxies a client cannot get a fresh bucket by naming another address.
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/login", strings.NewReader(`{"username":"nobody","password":"wrong password"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("login %d with X-Forwarded-For %s = %d, want %d", i+1, req.Header.Get("X-Forwarded-For"), resp.StatusCode, want)
+		}
+	}
+}
+
+func TestLoginAttemptsIgnoreForwardedFor(t *testing.T) {
+	cfg := newConfig()
+	cfg.RateLimitAuth = ratelimit.Policy{}
+	cfg.LoginMaxIPFailures = 3
+	deps := newDependencies(t)
+	a, err := app.New(cfg, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.Handler)
+	defer server.Close()
+
+	// A client naming another address on each attempt is still locked out by its own address.
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		body := fmt.Sprintf(`{"username":"nobody-%d","password":"wrong password"}`, i)
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("login %d with X-Forwarded-For %s = %d, want %d", i+1, req.Header.Get("X-Forwarded-For"), resp.StatusCode, want)
+		}
+	}
+
+	since := time.Now().Add(-time.Hour)
+	attempts := deps.Repositories.LoginAttempts
+	if n, _, err := attempts.CountFailuresByIP(context.Background(), "127.0.0.1", since); err != nil || n != 3 {
+		t.Errorf("failures recorded for the connection address = %d, %v, want 3", n, err)
+	}
+	if n, _, err := attempts.CountFailuresByIP(context.Background(), "203.0.113.1", since); err != nil || n != 0 {
+		t.Errorf("failures recorded for a forged address = %d, %v, want 0", n, err)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	deps := newDependencies(t)
+	a, err := app.New(newConfig(), deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.Handler)
+	defer server.Close()
+	c := &client{t: t, server: server, storage: deps.Storage.(*storage.Memory)}
+
+	c.json(http.MethodGet, "/readyz", nil, http.StatusOK, nil)
+
+	c.json(http.MethodPost, "/api/register", map[string]string{
+		"username": "alice", "email": "alice@example.com", "password": "correct horse battery",
+	}, http.StatusCreated, nil)
+	if n := len(deps.Mailer.(*mailer.MemoryMailer).Messages()); n != 1 {
+		t.Errorf("registration sent %d emails, want the verification email", n)
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	c.json(http.MethodPost, "/api/login", map[string]string{"username": "alice", "password": "wrong password"}, http.StatusUnauthorized, nil)
+	c.json(http.MethodPost, "/api/login", map[string]string{"username": "alice", "password": "correct horse battery"}, http.StatusOK, &login)
+	if login.Token == "" {
+		t.Fatal("login returned no token")
+	}
+
+	c.json(http.MethodPost, "/api/recipes", nil, http.StatusUnauthorized, nil)
+	c.token = login.Token
+
+	var recipe struct {
+		ID     uint `json:"id"`
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	}
+	c.multipart(http.MethodPost, "/api/recipes", map[string]string{
+		"title":        "Nasi goreng",
+		"description":  "Fried rice",
+		"ingredients":  "rice, egg",
+		"instructions": "Fry the rice",
+		"tag_names":    "[]",
+	}, map[string]string{"images": "nasi-goreng.jpg"}, http.StatusCreated, &recipe)
+	if len(recipe.Images) != 1 || !c.stored(recipe.Images[0].URL) {
+		t.Errorf("recipe images = %+v, want the uploaded image", recipe.Images)
+	}
+
+	var updated struct {
+		Title  string `json:"title"`
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+		Tags []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+	}
+	c.multipart(http.MethodPut, fmt.Sprintf("/api/recipes/%d", recipe.ID), map[string]string{
+		"title":        "Nasi goreng kampung",
+		"description":  "Fried rice",
+		"ingredients":  "rice, egg, anchovies",
+		"instructions": "Fry the rice",
+		"tag_names":    `["Indonesian"]`,
+	}, map[string]string{"images": "kampung.jpg"}, http.StatusOK, &updated)
+	if updated.Title != "Nasi goreng kampung" || len(updated.Images) != 1 || updated.Images[0].URL == recipe.Images[0].URL ||
+		len(updated.Tags) != 1 || updated.Tags[0].Name != "indonesian" {
+		t.Errorf("updated recipe = %+v, want the new title, image and tag", updated)
+	}
+	if c.stored(recipe.Images[0].URL) || !c.stored(updated.Images[0].URL) {
+		t.Errorf("after replacing the image of the recipe %s is stored: %v, want only %s", recipe.Images[0].URL, c.stored(recipe.Images[0].URL), updated.Images[0].URL)
+	}
+
+	// An update without images keeps those of the recipe.
+	var kept struct {
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	}
+	c.multipart(http.MethodPut, fmt.Sprintf("/api/recipes/%d", recipe.ID), map[string]string{
+		"title":        "Nasi goreng kampung",
+		"description":  "Fried rice",
+		"ingredients":  "rice, egg, anchovies",
+		"instructions": "Fry the rice",
+		"tag_names":    `["Indonesian"]`,
+	}, nil, http.StatusOK, &kept)
+	if len(kept.Images) != 1 || kept.Images[0].URL != updated.Images[0].URL || !c.stored(updated.Images[0].URL) {
+		t.Errorf("images after an update without images = %+v, want %s kept", kept.Images, updated.Images[0].URL)
+	}
+
+	var review struct {
+		Data struct {
+			ID       uint   `json:"id"`
+			RecipeID uint   `json:"recipe_id"`
+			Content  string `json:"content"`
+			Photos   []struct {
+				URL string `json:"url"`
+			} `json:"photos"`
+		} `json:"data"`
+	}
+	c.multipart(http.MethodPost, fmt.Sprintf("/api/recipes/%d/reviews", recipe.ID), map[string]string{"content": "Delicious"},
+		map[string]string{"photos": "my-nasi-goreng.jpg"}, http.StatusCreated, &review)
+	if review.Data.RecipeID != recipe.ID || review.Data.Content != "Delicious" || len(review.Data.Photos) != 1 || !c.stored(review.Data.Photos[0].URL) {
+		t.Errorf("review = %+v, want a review of recipe %d with the uploaded photo", review.Data, recipe.ID)
+	}
+
+	c.json(http.MethodPut, fmt.Sprintf("/api/recipes/%d/favorite", recipe.ID), nil, http.StatusOK, nil)
+
+	// Any user can create tags, only moderators can change them.
+	var tag struct {
+		ID uint `json:"id"`
+	}
+	c.json(http.MethodPost, "/api/tags", map[string]string{"name": "Fried rice", "category": "cuisine"}, http.StatusCreated, &tag)
+	c.json(http.MethodPut, fmt.Sprintf("/api/tags/%d", tag.ID), map[string]string{"name": "Fried"}, http.StatusForbidden, nil)
+
+	// Anonymous readers see the recipe, its favorites and its review.
+	c.token = ""
+	var recipes []struct {
+		ID            uint  `json:"id"`
+		FavoriteCount int64 `json:"favorite_count"`
+		IsFavorited   bool  `json:"is_favorited"`
+	}
+	c.json(http.MethodGet, "/api/recipes", nil, http.StatusOK, &recipes)
+	if len(recipes) != 1 || recipes[0].ID != recipe.ID || recipes[0].FavoriteCount != 1 || recipes[0].IsFavorited {
+		t.Errorf("recipes = %+v, want the recipe favorited once", recipes)
+	}
+
+	var reviews struct {
+		Data []struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+	}
+	c.json(http.MethodGet, fmt.Sprintf("/api/recipes/%d/reviews", recipe.ID), nil, http.StatusOK, &reviews)
+	if len(reviews.Data) != 1 || reviews.Data[0].ID != review.Data.ID {
+		t.Errorf("reviews = %+v, want review %d", reviews.Data, review.Data.ID)
+	}
+
+	c.json(http.MethodGet, "/api/recipes/999", nil, http.StatusNotFound, nil)
+
+	// Deleting the recipe removes its images and the photos of its reviews from storage.
+	c.token = login.Token
+	c.json(http.MethodDelete, fmt.Sprintf("/api/recipes/%d", recipe.ID), nil, http.StatusOK, nil)
+	for _, url := range []string{updated.Images[0].URL, review.Data.Photos[0].URL} {
+		if c.stored(url) {
+			t.Errorf("%s is still stored after deleting the recipe", url)
+		}
+	}
+}
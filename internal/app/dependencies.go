@@ -0,0 +1,87 @@
+package app
+
+import (
+	"api-culinary-review/config"
+	"api-culinary-review/internal/repositories"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/contentfilter"
+	"api-culinary-review/pkg/idgen"
+	"api-culinary-review/pkg/mailer"
+	"api-culinary-review/pkg/nutrition"
+	"api-culinary-review/pkg/oauth"
+	"api-culinary-review/pkg/ratelimit"
+	"api-culinary-review/pkg/storage"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// NewDependencies creates the production dependencies described by cfg on top of db.
+func NewDependencies(cfg *config.Config, db *gorm.DB, logger *slog.Logger) (Dependencies, error) {
+	store, err := newStorage(cfg)
+	if err != nil {
+		return Dependencies{}, fmt.Errorf("set up storage: %w", err)
+	}
+
+	nutrients := nutrition.Default()
+	if cfg.NutritionDataset != "" {
+		if nutrients, err = nutrition.LoadFile(cfg.NutritionDataset); err != nil {
+			return Dependencies{}, fmt.Errorf("load nutrient dataset: %w", err)
+		}
+	}
+
+	filter, err := contentfilter.Load(cfg.ContentFilterFile, strings.Split(cfg.ContentFilterWords, ","))
+	if err != nil {
+		return Dependencies{}, fmt.Errorf("load content filter: %w", err)
+	}
+
+	return Dependencies{
+		Database:      db.DB(),
+		Repositories:  repositories.NewGormSet(db),
+		Storage:       store,
+		Mailer:        newMailer(cfg, logger),
+		Clock:         clock.System{},
+		IDs:           idgen.Random{},
+		RateLimits:    ratelimit.NewMemoryStore(clock.System{}),
+		Nutrition:     nutrients,
+		ContentFilter: filter,
+		Logger:        logger,
+	}, nil
+}
+
+func newStorage(cfg *config.Config) (storage.Storage, error) {
+	if cfg.StorageDriver == storage.DriverSupabase {
+		return storage.NewSupabase(cfg.SupabaseURL, cfg.SupabaseKey, cfg.SupabaseBucket), nil
+	}
+	return storage.NewCloudinary(cfg.CloudinaryURL)
+}
+
+func newMailer(cfg *config.Config, logger *slog.Logger) mailer.Mailer {
+	if cfg.MailDriver == "smtp" {
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+	return mailer.NewFileMailer(cfg.MailDir, logger)
+}
+
+func newOAuthProviders(cfg *config.Config) []*oauth.Provider {
+	redirectBase := strings.TrimSuffix(cfg.OAuthRedirectBaseURL, "/")
+
+	providers := make([]*oauth.Provider, 0, len(cfg.OAuthProviders))
+	for _, p := range cfg.OAuthProviders {
+		providers = append(providers, oauth.NewProvider(oauth.Config{
+			Name:         p.Name,
+			Kind:         p.Kind,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  redirectBase + "/api/auth/" + p.Name + "/callback",
+			IssuerURL:    p.IssuerURL,
+			AuthURL:      p.AuthURL,
+			TokenURL:     p.TokenURL,
+			UserInfoURL:  p.UserInfoURL,
+			Scopes:       p.Scopes,
+		}))
+	}
+	return providers
+}
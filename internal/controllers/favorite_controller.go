@@ -1,10 +1,8 @@
 package controllers
 
 import (
-	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/usecases"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,7 +10,7 @@ import (
 // FavoriteController is the interface that defines the methods for handling favorite-related operations.
 type FavoriteController interface {
 	GetByUserID(c *gin.Context)
-	CreateFavorite(c *gin.Context)
+	PutFavorite(c *gin.Context)
 	DeleteFavorite(c *gin.Context)
 }
 
@@ -28,94 +26,79 @@ func NewFavoriteController(favoriteUC usecases.FavoriteUsecase) FavoriteControll
 
 // GetByUserID retrieves favorites for the authenticated user.
 // @Summary Retrieve favorites for the authenticated user
-// @Description Retrieves favorites associated with the authenticated user.
+// @Description Retrieves the recipes the authenticated user favorited, oldest favorite first.
 // @Tags favorites
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer Token"
-// @Success 200 {array} models.Favorite
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Success 200 {array} models.FavoriteResponse
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/favorites [get]
 func (ctrl *favoriteController) GetByUserID(c *gin.Context) {
-	// Extract user ID from context (assuming it's set by a middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "User ID not found in context"})
-		return
-	}
-
-	favorites, err := ctrl.favoriteUsecase.GetByUserID(userID.(uint))
+	favorites, err := ctrl.favoriteUsecase.GetByUserID(c.Request.Context(), c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, favorites)
 }
 
-// CreateFavorite creates a new favorite.
-// @Summary Create a new favorite
-// @Description Creates a new favorite for a user with the specified recipe ID.
+// PutFavorite adds a recipe to the favorites of the authenticated user.
+// @Summary Favorite a recipe
+// @Description Adds the recipe to the favorites of the authenticated user. Favoriting a recipe again changes nothing.
 // @Tags favorites
-// @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer Token"
-// @Param input body models.FavoriteRequest true "Favorite data to create"
-// @Success 201 {object} models.Favorite
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Param id path int true "Recipe ID"
+// @Success 200 {object} models.FavoriteStatus
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
-// @Router /api/favorites [post]
-func (ctrl *favoriteController) CreateFavorite(c *gin.Context) {
-	// Extract user ID from context (assuming it's set by a middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "User ID not found in context"})
+// @Router /api/recipes/{id}/favorite [put]
+func (ctrl *favoriteController) PutFavorite(c *gin.Context) {
+	recipeID, ok := paramID(c, "id")
+	if !ok {
 		return
 	}
 
-	var favoriteInput models.FavoriteRequest
-	if err := c.ShouldBindJSON(&favoriteInput); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	// Create favorite using userID from context
-	favorite, err := ctrl.favoriteUsecase.CreateFavorite(userID.(uint), favoriteInput.RecipeID)
+	status, err := ctrl.favoriteUsecase.AddFavorite(c.Request.Context(), c.GetUint("userID"), recipeID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, favorite)
+	c.JSON(http.StatusOK, status)
 }
 
-// DeleteFavorite deletes a favorite by its ID.
-// @Summary Delete a favorite by ID
-// @Description Deletes a favorite by its ID.
+// DeleteFavorite removes a recipe from the favorites of the authenticated user.
+// @Summary Unfavorite a recipe
+// @Description Removes the recipe from the favorites of the authenticated user, if it is one, even when the recipe is hidden or deleted.
 // @Tags favorites
+// @Produce json
 // @Param Authorization header string true "Bearer Token"
-// @Param id path int true "Favorite ID"
-// @Success 200 {string} string "Favorite deleted successfully"
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Param id path int true "Recipe ID"
+// @Success 200 {object} models.FavoriteStatus
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
-// @Router /api/favorites/{id} [delete]
+// @Router /api/recipes/{id}/favorite [delete]
 func (ctrl *favoriteController) DeleteFavorite(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid ID"})
+	recipeID, ok := paramID(c, "id")
+	if !ok {
 		return
 	}
 
-	err = ctrl.favoriteUsecase.DeleteFavorite(uint(id))
+	status, err := ctrl.favoriteUsecase.RemoveFavorite(c.Request.Context(), c.GetUint("userID"), recipeID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, "Favorite deleted successfully")
+	c.JSON(http.StatusOK, status)
 }
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"api-culinary-review/internal/models"
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheck reports whether a dependency of the service can be used.
+type HealthCheck func(ctx context.Context) error
+
+// HealthController is the interface that defines the liveness and readiness probes.
+type HealthController interface {
+	Healthz(c *gin.Context)
+	Readyz(c *gin.Context)
+}
+
+type healthController struct {
+	checks  map[string]HealthCheck
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+// NewHealthController creates a HealthController whose readiness probe runs checks, each
+// failing when it takes longer than timeout. Failures are logged with logger; the response
+// only names the failing checks.
+func NewHealthController(checks map[string]HealthCheck, timeout time.Duration, logger *slog.Logger) HealthController {
+	return &healthController{
+		checks:  checks,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// Healthz reports that the process is up.
+// @Summary Liveness probe
+// @Description Reports that the process is running. It does not check any dependency.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.HealthResponse
+// @Router /healthz [get]
+func (ctrl *healthController) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, models.HealthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the service can handle requests.
+// @Summary Readiness probe
+// @Description Checks the database and the storage backend. Responds 503 when any of them is unavailable; the cause is logged, not returned.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.HealthResponse
+// @Failure 503 {object} models.HealthResponse
+// @Router /readyz [get]
+func (ctrl *healthController) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), ctrl.timeout)
+	defer cancel()
+
+	names := make([]string, 0, len(ctrl.checks))
+	for name := range ctrl.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Checks run concurrently so a slow dependency does not delay the others
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}(i, ctrl.checks[name])
+	}
+	wg.Wait()
+
+	response := models.HealthResponse{Status: "ok", Checks: make(map[string]string, len(names))}
+	status := http.StatusOK
+	for i, name := range names {
+		if errs[i] != nil {
+			ctrl.logger.ErrorContext(c.Request.Context(), "readiness check failed",
+				slog.String("check", name), slog.Any("error", errs[i]))
+			response.Checks[name] = "unavailable"
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		response.Checks[name] = "ok"
+	}
+
+	c.JSON(status, response)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// bindJSON binds the request body into obj. When the body is malformed or fails validation the
+// error is added to the context and false is returned.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		c.Error(validationError(c, err))
+		return false
+	}
+	return true
+}
+
+// bindForm is bindJSON for form and multipart requests.
+func bindForm(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBind(obj); err != nil {
+		c.Error(validationError(c, err))
+		return false
+	}
+	return true
+}
+
+// bindQuery is bindJSON for query parameters.
+func bindQuery(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindQuery(obj); err != nil {
+		c.Error(validationError(c, err))
+		return false
+	}
+	return true
+}
+
+// validateStruct validates a request model that was not bound by gin.
+func validateStruct(c *gin.Context, obj interface{}) bool {
+	if err := utils.ValidateStruct(obj); err != nil {
+		c.Error(validationError(c, err))
+		return false
+	}
+	return true
+}
+
+// paramID parses the named path parameter as a positive ID.
+func paramID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil || id == 0 {
+		trans := utils.Translator(c.GetHeader("Accept-Language"))
+		c.Error(apperror.Validation(utils.Translate(trans, utils.MsgValidationFailed),
+			apperror.FieldError{Field: name, Message: utils.Translate(trans, utils.MsgInvalidID, name)}))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// queryID parses the named query parameter as a positive ID. It returns 0 when the parameter
+// is absent.
+func queryID(c *gin.Context, name string) (uint, bool) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		trans := utils.Translator(c.GetHeader("Accept-Language"))
+		c.Error(apperror.Validation(utils.Translate(trans, utils.MsgValidationFailed),
+			apperror.FieldError{Field: name, Message: utils.Translate(trans, utils.MsgInvalidID, name)}))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// validationError converts a binding error into a validation error with its messages in the
+// language asked for by the client.
+func validationError(c *gin.Context, err error) error {
+	trans := utils.Translator(c.GetHeader("Accept-Language"))
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return &apperror.Error{Kind: apperror.KindValidation, Message: utils.Translate(trans, utils.MsgMalformedBody), Err: err}
+	}
+	return apperror.Validation(utils.Translate(trans, utils.MsgValidationFailed), utils.FieldErrors(validationErrs, trans)...)
+}
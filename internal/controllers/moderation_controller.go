@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/usecases"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModerationController is the interface that defines the methods for reporting content and
+// moderating it.
+type ModerationController interface {
+	ReportReview(c *gin.Context)
+	ReportRecipe(c *gin.Context)
+	GetReports(c *gin.Context)
+	ResolveReport(c *gin.Context)
+	GetActions(c *gin.Context)
+}
+
+type moderationController struct {
+	moderationUsecase usecases.ModerationUsecase
+}
+
+// NewModerationController creates a new instance of ModerationController.
+func NewModerationController(moderationUsecase usecases.ModerationUsecase) ModerationController {
+	return &moderationController{moderationUsecase: moderationUsecase}
+}
+
+// ReportReview godoc
+// @Summary Report a review
+// @Description Report a review or reply to the moderators, with the reason it breaks the rules. A user can have one pending report on a review.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Review ID"
+// @Param report body models.ReportRequest true "Report"
+// @Success 201 {object} models.Report
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/reviews/{id}/report [post]
+func (ctrl *moderationController) ReportReview(c *gin.Context) {
+	ctrl.report(c, models.ReportTargetReview)
+}
+
+// ReportRecipe godoc
+// @Summary Report a recipe
+// @Description Report a recipe to the moderators, with the reason it breaks the rules. A user can have one pending report on a recipe.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Recipe ID"
+// @Param report body models.ReportRequest true "Report"
+// @Success 201 {object} models.Report
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/recipes/{id}/report [post]
+func (ctrl *moderationController) ReportRecipe(c *gin.Context) {
+	ctrl.report(c, models.ReportTargetRecipe)
+}
+
+func (ctrl *moderationController) report(c *gin.Context, targetType string) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.ReportRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	report, err := ctrl.moderationUsecase.Report(c.Request.Context(), &req, targetType, id, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetReports godoc
+// @Summary Get the moderation queue
+// @Description Get the reports on reviews and recipes with a status, oldest first, each with an excerpt of the reported content. Reports without a reporter_id were filed by the content filter, which hides the content until a moderator approves it; the target of a report on an author response (target_type author_response) is the review it answers. Only moderators can see reports.
+// @Tags moderation
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param status query string false "Status of the reports, pending by default" Enums(pending, approved, rejected)
+// @Param page query int false "Page number, 1 by default" minimum(1)
+// @Param page_size query int false "Reports per page, 20 by default" minimum(1) maximum(100)
+// @Success 200 {object} models.ReportPage
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/moderation/reports [get]
+func (ctrl *moderationController) GetReports(c *gin.Context) {
+	var req models.ReportQueueRequest
+	if !bindQuery(c, &req) {
+		return
+	}
+
+	reports, err := ctrl.moderationUsecase.GetQueue(c.Request.Context(), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// ResolveReport godoc
+// @Summary Resolve a report
+// @Description Approve the reported review or recipe, showing it, or reject it, hiding it from everyone but its author. The decision resolves every pending report on the content and is recorded in the audit trail. A rejection needs a reason, and a report on content that no longer exists cannot be resolved. Only moderators can resolve reports.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Report ID"
+// @Param decision body models.ResolveReportRequest true "Decision"
+// @Success 200 {object} models.ModerationAction
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/moderation/reports/{id}/resolve [post]
+func (ctrl *moderationController) ResolveReport(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.ResolveReportRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	action, err := ctrl.moderationUsecase.Resolve(c.Request.Context(), &req, id, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, action)
+}
+
+// GetActions godoc
+// @Summary Get the moderation audit trail
+// @Description Get a page of the decisions of moderators on reported content and tags, newest first. Only moderators can see the audit trail.
+// @Tags moderation
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param page query int false "Page number, 1 by default" minimum(1)
+// @Param page_size query int false "Decisions per page, 20 by default" minimum(1) maximum(100)
+// @Success 200 {object} models.ModerationActionPage
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/moderation/actions [get]
+func (ctrl *moderationController) GetActions(c *gin.Context) {
+	var page models.PageRequest
+	if !bindQuery(c, &page) {
+		return
+	}
+
+	actions, err := ctrl.moderationUsecase.GetActions(c.Request.Context(), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, actions)
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/usecases"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NutritionController is the interface that defines the methods for the nutrient database.
+type NutritionController interface {
+	SearchFoods(c *gin.Context)
+}
+
+type nutritionController struct {
+	nutritionUsecase usecases.NutritionUsecase
+}
+
+// NewNutritionController creates a new instance of NutritionController.
+func NewNutritionController(nutritionUsecase usecases.NutritionUsecase) NutritionController {
+	return &nutritionController{nutritionUsecase: nutritionUsecase}
+}
+
+// SearchFoods godoc
+// @Summary Search foods
+// @Description Search the nutrient database the nutrition of recipes is estimated from. An ingredient whose name is not matched to the right food can name the food in its food field.
+// @Tags nutrition
+// @Produce json
+// @Param q query string true "Part of the food name"
+// @Success 200 {array} nutrition.Food
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/nutrition/foods [get]
+func (ctrl *nutritionController) SearchFoods(c *gin.Context) {
+	var input models.FoodSearchRequest
+	if !bindQuery(c, &input) {
+		return
+	}
+
+	foods, err := ctrl.nutritionUsecase.SearchFoods(c.Request.Context(), input.Query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, foods)
+}
@@ -3,7 +3,7 @@ package controllers
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/usecases"
-	_ "fmt"
+	"mime/multipart"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,6 +14,7 @@ type ProfileController interface {
 	CreateProfile(c *gin.Context)
 	GetProfileByUserID(c *gin.Context)
 	UpdateProfileByUserID(c *gin.Context)
+	UpdateDietaryPreferences(c *gin.Context)
 }
 
 type profileController struct {
@@ -27,7 +28,7 @@ func NewProfileController(usecase usecases.ProfileUsecase) ProfileController {
 
 // CreateProfile godoc
 // @Summary Create a new profile
-// @Description Create a new user profile
+// @Description Fill in the profile of the authenticated user, which is created empty at registration. Dietary preferences are kept.
 // @Tags profiles
 // @Accept multipart/form-data
 // @Produce json
@@ -36,45 +37,20 @@ func NewProfileController(usecase usecases.ProfileUsecase) ProfileController {
 // @Param bio formData string true "Bio"
 // @Param avatar formData file true "Avatar File"
 // @Success 201 {object} models.Profile
-// @Failure 400 {object} map[string]interface{}
-// @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/profile [post]
 func (ctrl *profileController) CreateProfile(c *gin.Context) {
-	fullName := c.PostForm("fullName")
-	bio := c.PostForm("bio")
-
-	if fullName == "" || bio == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Full name and bio are required"})
-		return
-	}
-
-	fileHeader, err := c.FormFile("avatar")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get avatar file"})
-		return
-	}
-
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	userIDUint, ok := userID.(uint)
+	req, fileHeader, ok := bindProfileForm(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user ID"})
 		return
 	}
 
-	req := &models.ProfileRequest{
-		FullName: fullName,
-		Bio:      bio,
-	}
-
-	profile, err := ctrl.uc.CreateProfile(req, userIDUint, fileHeader)
+	profile, err := ctrl.uc.CreateProfile(c.Request.Context(), req, c.GetUint("userID"), fileHeader)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -89,15 +65,16 @@ func (ctrl *profileController) CreateProfile(c *gin.Context) {
 // @Produce json
 // @Param Authorization header string true "Bearer Token"
 // @Success 200 {object} models.Profile
-// @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/profile/me [get]
 func (ctrl *profileController) GetProfileByUserID(c *gin.Context) {
 	userID := c.GetUint("userID")
-	profile, err := ctrl.uc.GetProfileByUserID(userID)
+	profile, err := ctrl.uc.GetProfileByUserID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -115,47 +92,60 @@ func (ctrl *profileController) GetProfileByUserID(c *gin.Context) {
 // @Param bio formData string true "Bio"
 // @Param avatar formData file true "Avatar File"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/profile [put]
 func (ctrl *profileController) UpdateProfileByUserID(c *gin.Context) {
-	fullName := c.PostForm("fullName")
-	bio := c.PostForm("bio")
-
-	if fullName == "" || bio == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Full name and bio are required"})
+	req, fileHeader, ok := bindProfileForm(c)
+	if !ok {
 		return
 	}
 
-	fileHeader, err := c.FormFile("avatar")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get avatar file"})
+	if err := ctrl.uc.UpdateProfileByID(c.Request.Context(), req, c.GetUint("userID"), fileHeader); err != nil {
+		c.Error(err)
 		return
 	}
 
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	userIDUint, ok := userID.(uint)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user ID"})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
+}
 
-	req := &models.ProfileRequest{
-		FullName: fullName,
-		Bio:      bio,
+// UpdateDietaryPreferences godoc
+// @Summary Update dietary preferences
+// @Description Replace the diets and allergens of the authenticated user. The recipe listing only shows the recipes with all of the diets and none of the allergens to the user, unless apply_preferences is false.
+// @Tags profiles
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param input body models.DietaryPreferencesRequest true "Dietary preferences"
+// @Success 200 {object} models.Profile
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/profile/dietary-preferences [put]
+func (ctrl *profileController) UpdateDietaryPreferences(c *gin.Context) {
+	var req models.DietaryPreferencesRequest
+	if !bindJSON(c, &req) {
+		return
 	}
 
-	err = ctrl.uc.UpdateProfileByID(req, userIDUint, fileHeader)
+	profile, err := ctrl.uc.UpdateDietaryPreferences(c.Request.Context(), &req, c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
+	c.JSON(http.StatusOK, profile)
+}
+
+// bindProfileForm reads the profile fields and avatar from a multipart form.
+func bindProfileForm(c *gin.Context) (*models.ProfileRequest, *multipart.FileHeader, bool) {
+	var req models.ProfileRequest
+	if !bindForm(c, &req) {
+		return nil, nil, false
+	}
+	return &req, req.Avatar, true
 }
@@ -3,11 +3,12 @@ package controllers
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/apperror"
 	"api-culinary-review/pkg/utils"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,14 +24,12 @@ type RecipeController interface {
 
 type recipeController struct {
 	recipeUsecase usecases.RecipeUsecase
-	tagUsecase    usecases.TagUsecase
 }
 
 // NewRecipeController creates a new instance of RecipeController.
-func NewRecipeController(recipeUsecase usecases.RecipeUsecase, tagUsecase usecases.TagUsecase) RecipeController {
+func NewRecipeController(recipeUsecase usecases.RecipeUsecase) RecipeController {
 	return &recipeController{
 		recipeUsecase: recipeUsecase,
-		tagUsecase:    tagUsecase,
 	}
 }
 
@@ -46,80 +45,26 @@ func NewRecipeController(recipeUsecase usecases.RecipeUsecase, tagUsecase usecas
 // @Param ingredients formData string true "Ingredients of the recipe"
 // @Param instructions formData string true "Instructions of the recipe"
 // @Param images formData file true "Images of the recipe"
-// @Param tag_names formData string true "Tag names in JSON array format"
+// @Param tag_names formData string true "Tag names in JSON array format. Unknown names are created as tags pending moderation, ignored or rejected, depending on UNKNOWN_TAGS"
+// @Param ingredient_list formData string false "Structured ingredients in JSON array format, e.g. [{\"name\":\"telur\",\"quantity\":2,\"unit\":\"butir\"}]. Their allergens are added to the recipe and their nutrition estimated; food names the food of /api/nutrition/foods to use when the name does not match it"
+// @Param servings formData int false "Number of servings the nutrition is divided by, 1 by default"
+// @Param allergens formData string false "Allergens in JSON array format" Enums(gluten, dairy, nuts, shellfish, egg, soy)
+// @Param diets formData string false "Diet labels in JSON array format" Enums(vegan, vegetarian, halal, keto)
 // @Success 201 {object} models.Recipe
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/recipes [post]
 func (c *recipeController) CreateRecipe(ctx *gin.Context) {
-	recipeRequest := &models.RecipeRequest{}
-
-	// Extract fields from form-data
-	title := ctx.Request.FormValue("title")
-	description := ctx.Request.FormValue("description")
-	ingredients := ctx.Request.FormValue("ingredients")
-	instructions := ctx.Request.FormValue("instructions")
-	images, _ := ctx.Request.MultipartForm.File["images"]
-
-	// Check if form-data is empty
-	if title == "" || description == "" || ingredients == "" || instructions == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "All fields are required"})
-		return
-	}
-
-	tagNamesStr := ctx.PostForm("tag_names")
-	fmt.Println("Received tag_names:", tagNamesStr) // Debugging log
-
-	var tagNames []string
-	if err := json.Unmarshal([]byte(tagNamesStr), &tagNames); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag_names format"})
-		return
-	}
-
-	// Get tag IDs based on tag names
-	tags, err := c.tagUsecase.GetTagsByNames(tagNames)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	var tagIDs []uint
-	for _, tag := range tags {
-		tagIDs = append(tagIDs, tag.ID)
-	}
-
-	// Upload images to Cloudinary and get URLs
-	var imageUrls []string
-	for _, file := range images {
-		uploadedImage, err := utils.UploadToCloudinary(file)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload image"})
-			return
-		}
-		imageUrls = append(imageUrls, uploadedImage)
-	}
-
-	userID, exists := ctx.Get("userID")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	userIDUint, ok := userID.(uint)
+	recipeRequest, ok := c.bindRecipeForm(ctx)
 	if !ok {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user ID"})
 		return
 	}
 
-	// Populate recipeRequest struct
-	recipeRequest.Title = title
-	recipeRequest.Description = description
-	recipeRequest.Ingredients = ingredients
-	recipeRequest.Instructions = instructions
-	recipeRequest.Images = images
-	recipeRequest.TagIDs = tagIDs
-
-	recipe, err := c.recipeUsecase.CreateRecipe(recipeRequest.Images, recipeRequest, userIDUint)
+	recipe, err := c.recipeUsecase.CreateRecipe(ctx.Request.Context(), recipeRequest.Images, recipeRequest, ctx.GetUint("userID"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
@@ -128,7 +73,7 @@ func (c *recipeController) CreateRecipe(ctx *gin.Context) {
 
 // UpdateRecipe updates an existing recipe.
 // @Summary Update an existing recipe
-// @Description Updates an existing recipe with the provided details.
+// @Description Updates an existing recipe with the provided details. Images sent replace the stored ones, which are deleted; without images the recipe keeps its images.
 // @Tags recipes
 // @Accept multipart/form-data
 // @Produce json
@@ -138,57 +83,34 @@ func (c *recipeController) CreateRecipe(ctx *gin.Context) {
 // @Param description formData string true "Description of the recipe"
 // @Param ingredients formData string true "Ingredients of the recipe"
 // @Param instructions formData string true "Instructions of the recipe"
-// @Param images formData file true "Images of the recipe"
-// @Param tag_names formData string true "Tag names in JSON array format"
+// @Param images formData file false "Images replacing those of the recipe"
+// @Param tag_names formData string true "Tag names in JSON array format. Unknown names are created as tags pending moderation, ignored or rejected, depending on UNKNOWN_TAGS"
+// @Param ingredient_list formData string false "Structured ingredients in JSON array format, e.g. [{\"name\":\"telur\",\"quantity\":2,\"unit\":\"butir\"}]. Their allergens are added to the recipe and their nutrition estimated; food names the food of /api/nutrition/foods to use when the name does not match it"
+// @Param servings formData int false "Number of servings the nutrition is divided by, 1 by default"
+// @Param allergens formData string false "Allergens in JSON array format" Enums(gluten, dairy, nuts, shellfish, egg, soy)
+// @Param diets formData string false "Diet labels in JSON array format" Enums(vegan, vegetarian, halal, keto)
 // @Success 200 {object} models.Recipe
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/recipes/{id} [put]
 func (c *recipeController) UpdateRecipe(ctx *gin.Context) {
-	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	recipeRequest := &models.RecipeRequest{}
-
-	// Extract fields from form-data
-	title := ctx.Request.FormValue("title")
-	description := ctx.Request.FormValue("description")
-	ingredients := ctx.Request.FormValue("ingredients")
-	instructions := ctx.Request.FormValue("instructions")
-	images, _ := ctx.Request.MultipartForm.File["images"]
-
-	// Parsing tag_names
-	tagNamesStr := ctx.PostForm("tag_names")
-	var tagNames []string
-	if err := json.Unmarshal([]byte(tagNamesStr), &tagNames); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag_names format"})
+	id, ok := paramID(ctx, "id")
+	if !ok {
 		return
 	}
 
-	// Get tag IDs based on tag names
-	tags, err := c.tagUsecase.GetTagsByNames(tagNames)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	recipeRequest, ok := c.bindRecipeForm(ctx)
+	if !ok {
 		return
 	}
 
-	var tagIDs []uint
-	for _, tag := range tags {
-		tagIDs = append(tagIDs, tag.ID)
-	}
-
-	// Populate recipeRequest struct
-	recipeRequest.Title = title
-	recipeRequest.Description = description
-	recipeRequest.Ingredients = ingredients
-	recipeRequest.Instructions = instructions
-	recipeRequest.TagIDs = tagIDs
-
-	recipe, err := c.recipeUsecase.UpdateRecipe(uint(id), images, recipeRequest)
+	recipe, err := c.recipeUsecase.UpdateRecipe(ctx.Request.Context(), id, ctx.GetUint("userID"), recipeRequest.Images, recipeRequest)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
@@ -203,18 +125,21 @@ func (c *recipeController) UpdateRecipe(ctx *gin.Context) {
 // @Param Authorization header string true "Bearer Token"
 // @Param id path int true "Recipe ID"
 // @Success 204 {object} nil
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/recipes/{id} [delete]
 func (c *recipeController) DeleteRecipe(ctx *gin.Context) {
-	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	id, ok := paramID(ctx, "id")
+	if !ok {
 		return
 	}
 
-	err = c.recipeUsecase.DeleteRecipe(uint(id))
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.recipeUsecase.DeleteRecipe(ctx.Request.Context(), id, ctx.GetUint("userID")); err != nil {
+		ctx.Error(err)
 		return
 	}
 
@@ -231,19 +156,19 @@ func (c *recipeController) DeleteRecipe(ctx *gin.Context) {
 // @Produce json
 // @Param id path int true "Recipe ID"
 // @Success 200 {object} models.Recipe
-// @Failure 404 {object} map[string]string
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/recipes/{id} [get]
 func (ctrl *recipeController) GetRecipeByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipe ID"})
+	id, ok := paramID(c, "id")
+	if !ok {
 		return
 	}
 
-	recipe, err := ctrl.recipeUsecase.GetRecipeByID(uint(id))
+	recipe, err := ctrl.recipeUsecase.GetRecipeByID(c.Request.Context(), id, c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		c.Error(err)
 		return
 	}
 
@@ -252,17 +177,102 @@ func (ctrl *recipeController) GetRecipeByID(c *gin.Context) {
 
 // GetRecipes retrieves all recipes.
 // @Summary Get all recipes
-// @Description Retrieves all recipes.
+// @Description Retrieves all recipes, optionally without some allergens or with some diet labels. For a signed-in user the dietary preferences of their profile are applied too, unless apply_preferences is false.
 // @Tags recipes
 // @Produce json
+// @Param Authorization header string false "Bearer Token"
+// @Param exclude_allergens query string false "Comma-separated allergens the recipes must not contain, e.g. gluten,nuts"
+// @Param diets query string false "Comma-separated diet labels the recipes must all have, e.g. vegan,halal"
+// @Param apply_preferences query bool false "Apply the dietary preferences of the signed-in user, true by default"
 // @Success 200 {object} []models.Recipe
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/recipes [get]
 func (c *recipeController) GetRecipes(ctx *gin.Context) {
-	recipes, err := c.recipeUsecase.GetRecipes()
+	var filter models.RecipeFilter
+	var err error
+	if filter.ExcludeAllergens, err = models.ParseAllergens(strings.Split(ctx.Query("exclude_allergens"), ",")); err != nil {
+		ctx.Error(apperror.Validation("invalid allergens", apperror.FieldError{Field: "exclude_allergens", Message: err.Error()}))
+		return
+	}
+	if filter.Diets, err = models.ParseDiets(strings.Split(ctx.Query("diets"), ",")); err != nil {
+		ctx.Error(apperror.Validation("invalid diets", apperror.FieldError{Field: "diets", Message: err.Error()}))
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	filter.ViewerID = userID
+	if ctx.Query("apply_preferences") == "false" {
+		userID = 0
+	}
+
+	recipes, err := c.recipeUsecase.GetRecipes(ctx.Request.Context(), filter, userID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, recipes)
 }
+
+// bindRecipeForm reads a recipe from multipart form-data. The tag_names field is a JSON array of
+// tag names, resolved to tags by the usecase once the recipe may be saved. The ingredient_list,
+// allergens and diets fields are optional JSON arrays and servings an optional positive integer.
+func (c *recipeController) bindRecipeForm(ctx *gin.Context) (*models.RecipeRequest, bool) {
+	// Extract fields from form-data
+	recipeRequest := &models.RecipeRequest{
+		Title:        ctx.PostForm("title"),
+		Description:  ctx.PostForm("description"),
+		Ingredients:  ctx.PostForm("ingredients"),
+		Instructions: ctx.PostForm("instructions"),
+	}
+
+	if form, err := ctx.MultipartForm(); err == nil {
+		recipeRequest.Images = form.File["images"]
+	}
+
+	if servings := ctx.PostForm("servings"); servings != "" {
+		n, err := strconv.Atoi(servings)
+		if err != nil || n < 1 {
+			trans := utils.Translator(ctx.GetHeader("Accept-Language"))
+			ctx.Error(apperror.Validation(utils.Translate(trans, utils.MsgValidationFailed),
+				apperror.FieldError{Field: "servings", Message: utils.Translate(trans, utils.MsgInvalidID, "servings")}))
+			return nil, false
+		}
+		recipeRequest.Servings = n
+	}
+
+	for _, field := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"ingredient_list", &recipeRequest.IngredientList},
+		{"allergens", &recipeRequest.Allergens},
+		{"diets", &recipeRequest.Diets},
+	} {
+		if ctx.PostForm(field.name) != "" && !bindFormJSON(ctx, field.name, field.value) {
+			return nil, false
+		}
+	}
+
+	if !validateStruct(ctx, recipeRequest) {
+		return nil, false
+	}
+
+	if !bindFormJSON(ctx, "tag_names", &recipeRequest.TagNames) {
+		return nil, false
+	}
+
+	return recipeRequest, true
+}
+
+// bindFormJSON decodes the JSON array in the named form field into obj.
+func bindFormJSON(ctx *gin.Context, field string, obj interface{}) bool {
+	if err := json.Unmarshal([]byte(ctx.PostForm(field)), obj); err != nil {
+		trans := utils.Translator(ctx.GetHeader("Accept-Language"))
+		ctx.Error(apperror.Validation(utils.Translate(trans, utils.MsgValidationFailed),
+			apperror.FieldError{Field: field, Message: utils.Translate(trans, utils.MsgInvalidJSONArray, field)}))
+		return false
+	}
+	return true
+}
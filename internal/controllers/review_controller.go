@@ -4,7 +4,6 @@ import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/usecases"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +15,15 @@ type ReviewController interface {
 	CreateReview(c *gin.Context)
 	UpdateReviewByID(c *gin.Context)
 	DeleteReviewByID(c *gin.Context)
+	GetRecipeReviews(c *gin.Context)
+	CreateRecipeReview(c *gin.Context)
+	GetUserReviews(c *gin.Context)
+	ReplyToReview(c *gin.Context)
+	SetAuthorResponse(c *gin.Context)
+	DeleteAuthorResponse(c *gin.Context)
+	VoteReview(c *gin.Context)
+	DeleteReviewVote(c *gin.Context)
+	GetRecipePhotos(c *gin.Context)
 }
 
 type reviewController struct {
@@ -31,21 +39,29 @@ func NewReviewController(usecase usecases.ReviewUsecase) ReviewController {
 
 // GetAllReviews godoc
 // @Summary Get all reviews
-// @Description Get a list of all reviews
+// @Description Get a page of all reviews and replies, oldest first
 // @Tags reviews
 // @Accept json
 // @Produce json
-// @Success 200 {array} models.Review
-// @Failure 500 {object} map[string]interface{}
+// @Param page query int false "Page number, 1 by default" minimum(1)
+// @Param page_size query int false "Reviews per page, 20 by default" minimum(1) maximum(100)
+// @Success 200 {object} models.ReviewPage
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/reviews [get]
 func (ctrl *reviewController) GetAllReviews(c *gin.Context) {
-	reviews, err := ctrl.uc.GetAllReviews()
+	var page models.PageRequest
+	if !bindQuery(c, &page) {
+		return
+	}
+
+	reviews, err := ctrl.uc.GetAllReviews(c.Request.Context(), page, c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": reviews})
+	c.JSON(http.StatusOK, reviews)
 }
 
 // GetReviewByID godoc
@@ -55,19 +71,20 @@ func (ctrl *reviewController) GetAllReviews(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Review ID"
-// @Success 200 {object} models.Review
-// @Failure 500 {object} map[string]interface{}
+// @Success 200 {object} models.ReviewResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/reviews/{id} [get]
 func (ctrl *reviewController) GetReviewByID(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	id, ok := paramID(c, "id")
+	if !ok {
 		return
 	}
 
-	review, err := ctrl.uc.GetReviewByID(uint(id))
+	review, err := ctrl.uc.GetReviewByID(c.Request.Context(), id, c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -76,138 +93,399 @@ func (ctrl *reviewController) GetReviewByID(c *gin.Context) {
 
 // CreateReview godoc
 // @Summary Create a new review
-// @Description Create a new review for a recipe
+// @Description Create a new review for a recipe, written by the signed-in user. To attach photos of the dish, send the fields as multipart/form-data instead, with up to REVIEW_PHOTO_LIMIT (5 by default) files in photos. POST /api/recipes/{id}/reviews does the same with the recipe in the path.
 // @Tags reviews
-// @Accept json
+// @Accept json,mpfd
 // @Produce json
 // @Param Authorization header string true "Bearer Token"
 // @Param review body models.ReviewRequest true "Review Request"
-// @Success 200 {object} models.Review
-// @Failure 400 {object} map[string]interface{}
-// @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Success 200 {object} models.ReviewResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/reviews [post]
 func (ctrl *reviewController) CreateReview(c *gin.Context) {
 	var req models.ReviewRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindForm(c, &req) {
 		return
 	}
+	req.UserID = c.GetUint("userID")
 
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	review, err := ctrl.uc.CreateReview(c.Request.Context(), &req)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	userIDUint, ok := userID.(uint)
+
+	c.JSON(http.StatusOK, gin.H{"data": review})
+}
+
+// UpdateReviewByID godoc
+// @Summary Update review by ID
+// @Description Update an existing review by its ID
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path string true "Review ID"
+// @Param review body models.ReviewUpdateRequest true "Review Request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/reviews/{id} [put]
+func (ctrl *reviewController) UpdateReviewByID(c *gin.Context) {
+	id, ok := paramID(c, "id")
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user ID"})
 		return
 	}
 
-	req = models.ReviewRequest{
-		UserID:   userIDUint,
-		RecipeID: req.RecipeID,
-		Content:  req.Content,
+	var req models.ReviewUpdateRequest
+	if !bindJSON(c, &req) {
+		return
 	}
 
-	review, err := ctrl.uc.CreateReview(&req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := ctrl.uc.UpdateReviewByID(c.Request.Context(), &req, id, c.GetUint("userID")); err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": review})
+	c.JSON(http.StatusOK, gin.H{"message": "Review updated successfully"})
 }
 
-// UpdateReviewByID godoc
-// @Summary Update review by ID
-// @Description Update an existing review by its ID
+// DeleteReviewByID godoc
+// @Summary Delete review by ID
+// @Description Delete a review by its ID
 // @Tags reviews
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer Token"
 // @Param id path string true "Review ID"
-// @Param review body models.ReviewRequest true "Review Request"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
-// @Router /api/reviews/{id} [put]
-func (ctrl *reviewController) UpdateReviewByID(c *gin.Context) {
-	var req models.ReviewRequest
+// @Router /api/reviews/{id} [delete]
+func (ctrl *reviewController) DeleteReviewByID(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.uc.DeleteReviewByID(c.Request.Context(), id, c.GetUint("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+}
+
+// GetRecipeReviews godoc
+// @Summary Get the reviews of a recipe
+// @Description Get a page of the reviews of a recipe, each with its author response and the thread of replies to it in the order they were posted. Reviews are sorted by helpfulness (helpful minus unhelpful votes) by default.
+// @Tags reviews
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Param sort query string false "Order of the reviews" Enums(helpful, newest, oldest)
+// @Param page query int false "Page number, 1 by default" minimum(1)
+// @Param page_size query int false "Reviews per page, 20 by default" minimum(1) maximum(100)
+// @Success 200 {object} models.ReviewPage
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/recipes/{id}/reviews [get]
+func (ctrl *reviewController) GetRecipeReviews(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	var req models.RecipeReviewsRequest
+	if !bindQuery(c, &req) {
+		return
+	}
+
+	reviews, err := ctrl.uc.GetRecipeReviews(c.Request.Context(), id, &req, c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reviews)
+}
+
+// CreateRecipeReview godoc
+// @Summary Review a recipe
+// @Description Create a review of the recipe, written by the signed-in user. To attach photos of the dish, send the fields as multipart/form-data instead, with up to REVIEW_PHOTO_LIMIT (5 by default) files in photos.
+// @Tags reviews
+// @Accept json,mpfd
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Recipe ID"
+// @Param review body models.RecipeReviewRequest true "Review"
+// @Success 201 {object} models.ReviewResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/recipes/{id}/reviews [post]
+func (ctrl *reviewController) CreateRecipeReview(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.RecipeReviewRequest
+	if !bindForm(c, &req) {
 		return
 	}
 
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	review, err := ctrl.uc.CreateReview(c.Request.Context(), &models.ReviewRequest{
+		UserID:   c.GetUint("userID"),
+		RecipeID: id,
+		Content:  req.Content,
+		Photos:   req.Photos,
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	userIDUint, ok := userID.(uint)
+	c.JSON(http.StatusCreated, gin.H{"data": review})
+}
+
+// GetUserReviews godoc
+// @Summary Get the reviews of a user
+// @Description Get a page of the reviews and replies a user wrote, newest first
+// @Tags reviews
+// @Produce json
+// @Param id path int true "User ID"
+// @Param page query int false "Page number, 1 by default" minimum(1)
+// @Param page_size query int false "Reviews per page, 20 by default" minimum(1) maximum(100)
+// @Success 200 {object} models.ReviewPage
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/users/{id}/reviews [get]
+func (ctrl *reviewController) GetUserReviews(c *gin.Context) {
+	id, ok := paramID(c, "id")
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse user ID"})
 		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var page models.PageRequest
+	if !bindQuery(c, &page) {
 		return
 	}
 
-	// Ensure the user is updating their own review
-	review, err := ctrl.uc.GetReviewByID(uint(id))
+	reviews, err := ctrl.uc.GetUserReviews(c.Request.Context(), id, page, c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	if review.UserID != userIDUint {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "You can only update your own review"})
+	c.JSON(http.StatusOK, reviews)
+}
+
+// ReplyToReview godoc
+// @Summary Reply to a review
+// @Description Reply to a review or to a reply, up to three levels of replies below a review
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Review ID"
+// @Param reply body models.ReplyRequest true "Reply"
+// @Success 201 {object} models.ReviewResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/reviews/{id}/replies [post]
+func (ctrl *reviewController) ReplyToReview(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
 		return
 	}
 
-	req.UserID = userIDUint
-	req.RecipeID = review.RecipeID
+	var req models.ReplyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
 
-	if err := ctrl.uc.UpdateReviewByID(&req, uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	reply, err := ctrl.uc.ReplyToReview(c.Request.Context(), &req, id, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Review updated successfully"})
+	c.JSON(http.StatusCreated, gin.H{"data": reply})
 }
 
-// DeleteReviewByID godoc
-// @Summary Delete review by ID
-// @Description Delete a review by its ID
+// SetAuthorResponse godoc
+// @Summary Respond to a review
+// @Description Set or replace the response of the author of the recipe to one of its reviews. A response that matches the content filter is left out of the review until a moderator approves it
 // @Tags reviews
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer Token"
-// @Param id path string true "Review ID"
+// @Param id path int true "Review ID"
+// @Param response body models.AuthorResponseRequest true "Author response"
+// @Success 200 {object} models.ReviewResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/reviews/{id}/response [put]
+func (ctrl *reviewController) SetAuthorResponse(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.AuthorResponseRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	review, err := ctrl.uc.SetAuthorResponse(c.Request.Context(), &req, id, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": review})
+}
+
+// DeleteAuthorResponse godoc
+// @Summary Delete the response to a review
+// @Description Delete the response of the author of the recipe to one of its reviews
+// @Tags reviews
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Review ID"
 // @Success 200 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
-// @Router /api/reviews/{id} [delete]
-func (ctrl *reviewController) DeleteReviewByID(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+// @Router /api/reviews/{id}/response [delete]
+func (ctrl *reviewController) DeleteAuthorResponse(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.uc.DeleteAuthorResponse(c.Request.Context(), id, c.GetUint("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Author response deleted successfully"})
+}
+
+// VoteReview godoc
+// @Summary Vote on a review
+// @Description Mark a review or reply as helpful or unhelpful, replacing an earlier vote. Authors cannot vote on their own reviews.
+// @Tags reviews
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Review ID"
+// @Param vote body models.ReviewVoteRequest true "Vote"
+// @Success 200 {object} models.ReviewResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/reviews/{id}/vote [put]
+func (ctrl *reviewController) VoteReview(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.ReviewVoteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	review, err := ctrl.uc.VoteReview(c.Request.Context(), &req, id, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": review})
+}
+
+// DeleteReviewVote godoc
+// @Summary Withdraw a vote on a review
+// @Description Withdraw the vote of the user on a review or reply
+// @Tags reviews
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Review ID"
+// @Success 200 {object} models.ReviewResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/reviews/{id}/vote [delete]
+func (ctrl *reviewController) DeleteReviewVote(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	review, err := ctrl.uc.DeleteReviewVote(c.Request.Context(), id, c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": review})
+}
+
+// GetRecipePhotos godoc
+// @Summary Get the review photos of a recipe
+// @Description Get the photos reviewers posted of the dishes they made from a recipe, newest first
+// @Tags reviews
+// @Produce json
+// @Param id path int true "Recipe ID"
+// @Success 200 {array} models.ReviewPhoto
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/recipes/{id}/photos [get]
+func (ctrl *reviewController) GetRecipePhotos(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
 		return
 	}
 
-	if err := ctrl.uc.DeleteReviewByID(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	photos, err := ctrl.uc.GetRecipePhotos(c.Request.Context(), id, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"data": photos})
 }
@@ -4,7 +4,6 @@ import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/usecases"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,9 +11,14 @@ import (
 // TagController is the interface that defines the methods for handling tag-related operations.
 type TagController interface {
 	GetAllTags(c *gin.Context)
+	GetTag(c *gin.Context)
+	SuggestTags(c *gin.Context)
 	CreateTag(c *gin.Context)
 	UpdateTag(c *gin.Context)
+	MergeTags(c *gin.Context)
 	DeleteTag(c *gin.Context)
+	GetPendingTags(c *gin.Context)
+	ApproveTag(c *gin.Context)
 }
 
 type tagController struct {
@@ -30,27 +34,28 @@ func NewTagController(tagUsecase usecases.TagUsecase) TagController {
 
 // CreateTag creates a new tag.
 // @Summary Create a new tag
-// @Description Creates a new tag with the provided name.
+// @Description Creates a new approved tag with the provided name, category, parent tag and synonyms. The slug is derived from the name. Any signed-in user can create tags; changing, merging and deleting them is reserved to moderators.
 // @Tags tags
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer Token"
 // @Param input body models.TagRequest true "Tag data to create"
 // @Success 201 {object} models.TagResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/tags [post]
 func (ctrl *tagController) CreateTag(c *gin.Context) {
 	var tagInput models.TagRequest
-	if err := c.ShouldBindJSON(&tagInput); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	if !bindJSON(c, &tagInput) {
 		return
 	}
 
-	tag, err := ctrl.tagUsecase.CreateTag(tagInput.Name)
+	tag, err := ctrl.tagUsecase.CreateTag(c.Request.Context(), &tagInput)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -59,19 +64,65 @@ func (ctrl *tagController) CreateTag(c *gin.Context) {
 
 // GetAllTags godoc
 // @Summary Get all tags
-// @Description Get a list of all tags
+// @Description Get the approved tags grouped by category. Each group lists its top-level tags with their sub-tags nested in children; recipe_count counts the recipes tagged with the tag itself.
 // @Tags tags
 // @Accept json
 // @Produce json
-// @Param Authorization header string true "Bearer Token"
-// @Success 200 {array} models.Tag
-// @Failure 500 {object} ErrorResponse
-// @Security ApiKeyAuth
+// @Param category query string false "Only this category" Enums(diet, cuisine, meal_type, ingredient, other)
+// @Success 200 {array} models.TagGroup
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/tags [get]
 func (ctrl *tagController) GetAllTags(c *gin.Context) {
-	tags, err := ctrl.tagUsecase.GetAllTags()
+	groups, err := ctrl.tagUsecase.GetAllTags(c.Request.Context(), c.Query("category"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetTag godoc
+// @Summary Get a tag by slug
+// @Description Get an approved tag with its synonyms, recipe count and approved sub-tags.
+// @Tags tags
+// @Produce json
+// @Param slug path string true "Tag slug"
+// @Success 200 {object} models.TagResponse
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/tags/{slug} [get]
+func (ctrl *tagController) GetTag(c *gin.Context) {
+	tag, err := ctrl.tagUsecase.GetTagBySlug(c.Request.Context(), c.Param("slug"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// SuggestTags godoc
+// @Summary Suggest tags
+// @Description Autocompletes tag names: returns the approved tags whose name or a synonym starts with prefix, ignoring case, the most used first.
+// @Tags tags
+// @Produce json
+// @Param prefix query string true "Start of the tag name"
+// @Param limit query int false "Maximum number of tags, 10 by default" minimum(1) maximum(25)
+// @Success 200 {array} models.TagResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/tags/suggest [get]
+func (ctrl *tagController) SuggestTags(c *gin.Context) {
+	var input models.TagSuggestRequest
+	if !bindQuery(c, &input) {
+		return
+	}
+
+	tags, err := ctrl.tagUsecase.SuggestTags(c.Request.Context(), input.Prefix, input.Limit)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -80,7 +131,7 @@ func (ctrl *tagController) GetAllTags(c *gin.Context) {
 
 // UpdateTag updates an existing tag.
 // @Summary Update an existing tag
-// @Description Updates an existing tag based on the provided data.
+// @Description Replaces the name, category, parent tag and synonyms of a tag. Names are unique ignoring case. Only moderators can update tags; the update is recorded in the moderation audit trail.
 // @Tags tags
 // @Accept json
 // @Produce json
@@ -88,68 +139,148 @@ func (ctrl *tagController) GetAllTags(c *gin.Context) {
 // @Param id path int true "Tag ID to update"
 // @Param input body models.TagRequest true "Updated tag data"
 // @Success 200 {string} string "Tag updated successfully"
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/tags/{id} [put]
 func (ctrl *tagController) UpdateTag(c *gin.Context) {
-	tagID := c.Param("id")
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
 	var tagInput models.TagRequest
-	if err := c.ShouldBindJSON(&tagInput); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	if !bindJSON(c, &tagInput) {
 		return
 	}
 
-	// Convert tag ID to uint
-	id, err := strconv.ParseUint(tagID, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid tag ID"})
+	if err := ctrl.tagUsecase.UpdateTag(c.Request.Context(), id, &tagInput, c.GetUint("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, "Tag updated successfully")
+}
+
+// MergeTags merges tags into another tag.
+// @Summary Merge tags
+// @Description Merges the source tags into the tag, e.g. to fix duplicates like "vegan" and "Vegan". Recipes and sub-tags of the sources move to the tag, their names become synonyms of it and the sources are deleted. Only moderators can merge tags; each merged tag is recorded in the moderation audit trail.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "ID of the tag to merge into"
+// @Param input body models.TagMergeRequest true "Tags to merge"
+// @Success 200 {object} models.TagResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/tags/{id}/merge [post]
+func (ctrl *tagController) MergeTags(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
 		return
 	}
 
-	tag := models.Tag{
-		ID:   uint(id),
-		Name: tagInput.Name,
+	var input models.TagMergeRequest
+	if !bindJSON(c, &input) {
+		return
 	}
 
-	err = ctrl.tagUsecase.UpdateTag(&tag)
+	tag, err := ctrl.tagUsecase.MergeTags(c.Request.Context(), id, input.SourceIDs, c.GetUint("userID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, "Tag updated successfully")
+	c.JSON(http.StatusOK, tag)
 }
 
 // DeleteTag deletes a tag by its ID.
 // @Summary Delete a tag by ID
-// @Description Deletes a tag by its ID.
+// @Description Deletes a tag by its ID. A tag used by recipes is only deleted when replace_with names the tag its recipes move to. Only moderators can delete tags; the deletion is recorded in the moderation audit trail.
 // @Tags tags
 // @Param Authorization header string true "Bearer Token"
 // @Param id path int true "Tag ID to delete"
+// @Param replace_with query int false "ID of the tag that replaces the deleted tag on its recipes"
 // @Success 200 {string} string "Tag deleted successfully"
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/tags/{id} [delete]
 func (ctrl *tagController) DeleteTag(c *gin.Context) {
-	tagID := c.Param("id")
-	id, err := strconv.ParseUint(tagID, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid tag ID"})
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	replaceWith, ok := queryID(c, "replace_with")
+	if !ok {
 		return
 	}
 
-	err = ctrl.tagUsecase.DeleteTag(uint(id))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	if err := ctrl.tagUsecase.DeleteTag(c.Request.Context(), id, replaceWith, c.GetUint("userID")); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, "Tag deleted successfully")
 }
 
-// ErrorResponse represents a JSON structure for error responses.
-type ErrorResponse struct {
-	Error string `json:"error"`
+// GetPendingTags godoc
+// @Summary Get the tags pending moderation
+// @Description Get the tags created from unknown tag names of recipes that wait for moderation, oldest first. Approve them, merge them into another tag or delete them with replace_with. Only moderators can see pending tags.
+// @Tags moderation
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Success 200 {array} models.TagResponse
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/moderation/tags [get]
+func (ctrl *tagController) GetPendingTags(c *gin.Context) {
+	tags, err := ctrl.tagUsecase.GetPendingTags(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// ApproveTag godoc
+// @Summary Approve a pending tag
+// @Description Approves a tag pending moderation so it is listed and suggested. Only moderators can approve tags; the approval is recorded in the moderation audit trail.
+// @Tags moderation
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Tag ID to approve"
+// @Success 200 {object} models.TagResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/moderation/tags/{id}/approve [post]
+func (ctrl *tagController) ApproveTag(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	tag, err := ctrl.tagUsecase.ApproveTag(c.Request.Context(), id, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
 }
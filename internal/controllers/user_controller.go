@@ -3,9 +3,12 @@ package controllers
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/apperror"
 	"api-culinary-review/pkg/jwt"
 	"api-culinary-review/pkg/utils"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,16 +19,38 @@ type UserController interface {
 	GetUserByID(c *gin.Context)
 	Login(c *gin.Context)
 	ChangePassword(c *gin.Context)
+	DeleteAccount(c *gin.Context)
+	CancelAccountDeletion(c *gin.Context)
+	VerifyEmail(c *gin.Context)
+	ResendVerificationEmail(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
+	OAuthLogin(c *gin.Context)
+	OAuthCallback(c *gin.Context)
+	LoginTwoFactor(c *gin.Context)
+	BeginTwoFactorEnrollment(c *gin.Context)
+	ConfirmTwoFactorEnrollment(c *gin.Context)
+	DisableTwoFactor(c *gin.Context)
 }
 
+const oauthStateCookie = "oauth_state"
+
 type userController struct {
-	UserUsecase usecases.UserUsecase
+	UserUsecase  usecases.UserUsecase
+	AuthUsecase  usecases.AuthUsecase
+	tokens       *jwt.Manager
+	challengeTTL time.Duration
+	logger       *slog.Logger
 }
 
 // NewUserController creates a new UserController instance
-func NewUserController(userUC usecases.UserUsecase) UserController {
+func NewUserController(userUC usecases.UserUsecase, authUC usecases.AuthUsecase, tokens *jwt.Manager, challengeTTL time.Duration, logger *slog.Logger) UserController {
 	return &userController{
-		UserUsecase: userUC,
+		UserUsecase:  userUC,
+		AuthUsecase:  authUC,
+		tokens:       tokens,
+		challengeTTL: challengeTTL,
+		logger:       logger,
 	}
 }
 
@@ -37,30 +62,25 @@ func NewUserController(userUC usecases.UserUsecase) UserController {
 // @Produce json
 // @Param user body models.UserRequest true "User Data"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/register [post]
 func (ctrl *userController) Register(c *gin.Context) {
 	var userInput models.UserRequest
-	if err := c.ShouldBindJSON(&userInput); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &userInput) {
 		return
 	}
 
-	existingUser, err := ctrl.UserUsecase.CheckUserEmail(userInput.Email)
+	user, err := ctrl.UserUsecase.CreateUser(c.Request.Context(), userInput.Username, userInput.Password, userInput.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	if existingUser != nil {
-		c.JSON(http.StatusConflict, gin.H{"message": "Email already registered!"})
-	}
-
-	user, err := ctrl.UserUsecase.CreateUser(userInput.Username, userInput.Password, userInput.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	// The account is usable right away, the user can ask for a new link if this one is lost
+	if err := ctrl.AuthUsecase.SendVerificationEmail(c.Request.Context(), user); err != nil {
+		ctrl.logger.ErrorContext(c.Request.Context(), "failed to send verification email",
+			slog.Uint64("user_id", uint64(user.ID)), slog.Any("error", err))
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -77,15 +97,15 @@ func (ctrl *userController) Register(c *gin.Context) {
 // @Produce json
 // @Param Authorization header string true "Bearer Token"
 // @Success 200 {object} map[string]interface{}
-// @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/detail-user [get]
 func (ctrl *userController) GetUserByID(c *gin.Context) {
 	userID := c.GetUint("userID")
-	user, err := ctrl.UserUsecase.GetUserByID(userID)
+	user, err := ctrl.UserUsecase.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"user": user})
@@ -93,37 +113,50 @@ func (ctrl *userController) GetUserByID(c *gin.Context) {
 
 // Login godoc
 // @Summary User login
-// @Description Authenticate user and get a JWT token
+// @Description Authenticate user and get a JWT token. When two-factor authentication is enabled the response holds a challenge_token instead, to be completed at /api/login/2fa.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param login body models.LoginInput true "Login Data"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 429 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/login [post]
 func (ctrl *userController) Login(c *gin.Context) {
 	var input models.LoginInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &input) {
 		return
 	}
 
-	user, err := ctrl.UserUsecase.GetUserByEmailOrUsername(input.Username)
+	// ClientIP honours X-Forwarded-For only from TRUSTED_PROXIES, so lockouts and the recorded
+	// attempts cannot be pinned on a forged address.
+	user, err := ctrl.AuthUsecase.Login(c.Request.Context(), input.Username, input.Password, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	if !utils.CheckPasswordHash(input.Password, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+	ctrl.signIn(c, user)
+}
+
+// signIn responds with an access token for user, or with a challenge token to exchange at
+// /api/login/2fa when the user has two-factor authentication enabled.
+func (ctrl *userController) signIn(c *gin.Context, user *models.User) {
+	if user.TwoFactorEnabled() {
+		challenge, err := ctrl.tokens.GenerateChallengeToken(user.ID, ctrl.challengeTTL)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": challenge})
 		return
 	}
 
-	token, err := jwt.GenerateToken(user.ID)
+	token, err := ctrl.tokens.GenerateToken(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -139,35 +172,354 @@ func (ctrl *userController) Login(c *gin.Context) {
 // @Param Authorization header string true "Bearer Token"
 // @Param password body models.InputChangePassword true "Change Password Data"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} map[string]interface{}
-// @Failure 401 {object} map[string]interface{}
-// @Failure 500 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Security ApiKeyAuth
 // @Router /api/change-password [put]
 func (ctrl *userController) ChangePassword(c *gin.Context) {
 	var input models.InputChangePassword
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if err := ctrl.UserUsecase.ChangePassword(c.Request.Context(), c.GetUint("userID"), input.OldPassword, input.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// DeleteAccount godoc
+// @Summary Delete own account
+// @Description Schedule deletion of the authenticated user's account. After the grace period the account is anonymized: reviews are kept under "deleted user", favorites, profile and images are removed, and recipes are deleted or transferred to another user.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param request body models.DeleteAccountRequest false "What to do with the user's recipes"
+// @Success 202 {object} models.AccountDeletion
+// @Failure 400 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/me [delete]
+func (ctrl *userController) DeleteAccount(c *gin.Context) {
+	var input models.DeleteAccountRequest
+	if c.Request.ContentLength > 0 && !bindJSON(c, &input) {
 		return
 	}
 
 	userID := c.GetUint("userID")
-	user, err := ctrl.UserUsecase.GetUserByID(userID)
+	deletion, err := ctrl.UserUsecase.ScheduleDeletion(c.Request.Context(), userID, &input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	if !utils.CheckPasswordHash(input.OldPassword, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Old password is incorrect"})
+	c.JSON(http.StatusAccepted, deletion)
+}
+
+// CancelAccountDeletion godoc
+// @Summary Cancel account deletion
+// @Description Cancel a scheduled deletion of the authenticated user's account while it is still in its grace period
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/me/deletion [delete]
+func (ctrl *userController) CancelAccountDeletion(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if err := ctrl.UserUsecase.CancelDeletion(c.Request.Context(), userID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm the user's email address with the token from the verification email
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/email/verify [post]
+func (ctrl *userController) VerifyEmail(c *gin.Context) {
+	var input models.VerifyEmailRequest
+	if !bindJSON(c, &input) {
 		return
 	}
 
-	user.Password, _ = utils.HashPassword(input.NewPassword)
-	if err := ctrl.UserUsecase.UpdateUser(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := ctrl.AuthUsecase.VerifyEmail(c.Request.Context(), input.Token); err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmail godoc
+// @Summary Resend verification email
+// @Description Send a new verification link to the authenticated user's email address
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/email/resend-verification [post]
+func (ctrl *userController) ResendVerificationEmail(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if err := ctrl.AuthUsecase.ResendVerificationEmail(c.Request.Context(), userID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset link. The response is the same whether or not the email is registered.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Account email"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/password/forgot [post]
+func (ctrl *userController) ForgotPassword(c *gin.Context) {
+	var input models.ForgotPasswordRequest
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if err := ctrl.AuthUsecase.ForgotPassword(c.Request.Context(), input.Email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password using the token from the password reset email
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/password/reset [post]
+func (ctrl *userController) ResetPassword(c *gin.Context) {
+	var input models.ResetPasswordRequest
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if err := ctrl.AuthUsecase.ResetPassword(c.Request.Context(), input.Token, input.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// OAuthLogin godoc
+// @Summary Sign in with an external provider
+// @Description Redirect to the identity provider (e.g. google, github) to start signing in
+// @Tags users
+// @Param provider path string true "Provider name"
+// @Success 302
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/auth/{provider}/login [get]
+func (ctrl *userController) OAuthLogin(c *gin.Context) {
+	state, err := utils.GenerateToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	authURL, err := ctrl.AuthUsecase.OAuthLoginURL(c.Request.Context(), c.Param("provider"), state)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, 600, "/api/auth", "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OAuthCallback godoc
+// @Summary External provider callback
+// @Description Complete signing in with an identity provider and get a JWT token. The external identity is linked to the existing user with the same verified email, or a new user is created. Users with two-factor authentication get a challenge token to exchange at /api/login/2fa instead.
+// @Tags users
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/auth/{provider}/callback [get]
+func (ctrl *userController) OAuthCallback(c *gin.Context) {
+	state, err := c.Cookie(oauthStateCookie)
+	if err != nil || state == "" || c.Query("state") != state {
+		c.Error(apperror.Validation("Invalid OAuth state",
+			apperror.FieldError{Field: "state", Message: "does not match the login request"}))
+		return
+	}
+	c.SetCookie(oauthStateCookie, "", -1, "/api/auth", "", c.Request.TLS != nil, true)
+
+	code := c.Query("code")
+	if code == "" {
+		c.Error(apperror.Validation("Missing authorization code",
+			apperror.FieldError{Field: "code", Message: "is required"}))
+		return
+	}
+
+	user, err := ctrl.AuthUsecase.LoginWithOAuth(c.Request.Context(), c.Param("provider"), code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	ctrl.signIn(c, user)
+}
+
+// LoginTwoFactor godoc
+// @Summary Complete two-factor login
+// @Description Exchange the challenge token from /api/login and a TOTP or recovery code for a JWT token
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 429 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/login/2fa [post]
+func (ctrl *userController) LoginTwoFactor(c *gin.Context) {
+	var input models.TwoFactorLoginRequest
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	claims, err := ctrl.tokens.ParseChallengeToken(input.ChallengeToken)
+	if err != nil {
+		c.Error(usecases.ErrInvalidChallenge)
+		return
+	}
+
+	user, err := ctrl.AuthUsecase.VerifyTwoFactor(c.Request.Context(), claims.UserID, input.Code, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	token, err := ctrl.tokens.GenerateToken(user.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// BeginTwoFactorEnrollment godoc
+// @Summary Start two-factor enrollment
+// @Description Generate a TOTP secret and the otpauth:// provisioning URI to show as a QR code. Two-factor authentication is enabled after confirming a code.
+// @Tags users
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Success 200 {object} models.TOTPEnrollment
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/2fa/enroll [post]
+func (ctrl *userController) BeginTwoFactorEnrollment(c *gin.Context) {
+	enrollment, err := ctrl.AuthUsecase.BeginTOTPEnrollment(c.Request.Context(), c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactorEnrollment godoc
+// @Summary Confirm two-factor enrollment
+// @Description Enable two-factor authentication with a code from the authenticator app. The response contains single-use recovery codes that are only shown once.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param request body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/2fa/confirm [post]
+func (ctrl *userController) ConfirmTwoFactorEnrollment(c *gin.Context) {
+	var input models.TwoFactorCodeRequest
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	codes, err := ctrl.AuthUsecase.ConfirmTOTPEnrollment(c.Request.Context(), c.GetUint("userID"), input.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication using a current TOTP or recovery code
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param request body models.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Security ApiKeyAuth
+// @Router /api/2fa/disable [post]
+func (ctrl *userController) DisableTwoFactor(c *gin.Context) {
+	var input models.TwoFactorCodeRequest
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	if err := ctrl.AuthUsecase.DisableTOTP(c.Request.Context(), c.GetUint("userID"), input.Code); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
 }
@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"api-culinary-review/pkg/apperror"
+	"log/slog"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorMiddleware renders the last error added with c.Error as problem+json. Handlers report
+// failures by calling c.Error and returning without writing a response. Unexpected errors are
+// logged, as their cause is not shown to clients.
+func ErrorMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		if appErr := apperror.As(err); appErr.Kind == apperror.KindInternal {
+			logger.ErrorContext(c.Request.Context(), "request failed", slog.Any("error", appErr.Err))
+		}
+		RenderError(c, err)
+	}
+}
+
+// RenderError writes err as problem details and aborts the request.
+func RenderError(c *gin.Context, err error) {
+	appErr := apperror.As(err)
+	if appErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+
+	problem := apperror.NewProblem(appErr, c.Request.URL.Path, c.GetString("requestID"))
+	c.Header("Content-Type", apperror.ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
@@ -1,31 +1,68 @@
 package middlewares
 
 import (
+	"api-culinary-review/pkg/apperror"
 	"api-culinary-review/pkg/jwt"
-	"net/http"
+	"context"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func JWTAuthMiddleware() gin.HandlerFunc {
+// ActiveFunc reports whether a user may still use the tokens issued to them.
+type ActiveFunc func(ctx context.Context, userID uint) (bool, error)
+
+// JWTAuthMiddleware rejects requests without a valid token of an active user. Tokens are checked
+// against the user on every request, so the tokens of a deleted account stop working at once.
+func JWTAuthMiddleware(tokens *jwt.Manager, isActive ActiveFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			RenderError(c, apperror.Unauthorized("Authorization header required"))
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := jwt.ParseToken(tokenString)
+		claims, err := tokens.ParseToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			RenderError(c, apperror.Unauthorized("Invalid token"))
+			return
+		}
+		active, err := isActive(c.Request.Context(), claims.UserID)
+		if err != nil {
+			c.Error(err)
 			c.Abort()
 			return
 		}
+		if !active {
+			RenderError(c, apperror.Unauthorized("Invalid token"))
+			return
+		}
 
 		c.Set("userID", claims.UserID)
 		c.Next()
 	}
 }
+
+// OptionalJWTAuthMiddleware sets the userID of requests with a valid token and lets the other
+// requests through anonymously, for routes that are public but personalized for signed-in users.
+// The tokens of inactive users are ignored.
+func OptionalJWTAuthMiddleware(tokens *jwt.Manager, isActive ActiveFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader != "" {
+			if claims, err := tokens.ParseToken(strings.TrimPrefix(authHeader, "Bearer ")); err == nil {
+				active, err := isActive(c.Request.Context(), claims.UserID)
+				if err != nil {
+					c.Error(err)
+					c.Abort()
+					return
+				}
+				if active {
+					c.Set("userID", claims.UserID)
+				}
+			}
+		}
+		c.Next()
+	}
+}
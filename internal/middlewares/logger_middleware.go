@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"api-culinary-review/pkg/apperror"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLogMiddleware logs every request once it has been handled. Server errors are logged at
+// error level and client errors at warn level.
+func AccessLogMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if userID, ok := c.Get("userID"); ok {
+			attrs = append(attrs, slog.Any("user_id", userID))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// RecoveryMiddleware turns a panic in a handler into a logged internal error instead of a
+// dropped connection.
+func RecoveryMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			// A client that went away cannot be answered
+			if err, ok := p.(error); ok && isBrokenPipe(err) {
+				logger.WarnContext(c.Request.Context(), "connection closed by client", slog.Any("error", err))
+				c.Abort()
+				return
+			}
+
+			logger.ErrorContext(c.Request.Context(), "panic while handling request",
+				slog.Any("panic", p), slog.String("stack", string(debug.Stack())))
+			RenderError(c, apperror.Internal(nil))
+		}()
+
+		c.Next()
+	}
+}
+
+func isBrokenPipe(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var syscallErr *os.SyscallError
+	if !errors.As(opErr, &syscallErr) {
+		return false
+	}
+	msg := strings.ToLower(syscallErr.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"api-culinary-review/pkg/metrics"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware records the count and latency of requests by route template and status.
+// Requests that match no route are grouped under "unmatched".
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
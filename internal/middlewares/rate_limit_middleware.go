@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/ratelimit"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware limits requests with a token bucket per client. Authenticated requests
+// are keyed by the userID set by JWTAuthMiddleware, anonymous ones by client IP, and name
+// separates the buckets of different policies. Requests are let through when the store fails.
+func RateLimitMiddleware(store ratelimit.Store, name string, policy ratelimit.Policy, logger *slog.Logger) gin.HandlerFunc {
+	if policy.Disabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		key := name + ":ip:" + c.ClientIP()
+		if userID, ok := c.Get("userID"); ok {
+			key = fmt.Sprintf("%s:user:%v", name, userID)
+		}
+
+		result, err := store.Take(c.Request.Context(), key, policy)
+		if err != nil {
+			logger.WarnContext(c.Request.Context(), "rate limiter unavailable", slog.String("key", key), slog.Any("error", err))
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Period)))
+
+		if !result.Allowed {
+			RenderError(c, apperror.TooManyRequests("Too many requests, please try again later", result.RetryAfter))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,57 @@
+package middlewares_test
+
+import (
+	"api-culinary-review/internal/middlewares"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/ratelimit"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newLimitedRouter serves GET /limited with a limit of two requests a minute per client.
+func newLimitedRouter(t *testing.T, trustedProxies []string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	store := ratelimit.NewMemoryStore(clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	policy := ratelimit.Policy{Limit: 2, Period: time.Minute}
+	router.Use(middlewares.RateLimitMiddleware(store, "test", policy, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	router.GET("/limited", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return router
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		wantCodes      []int
+	}{
+		{"spoofed header from an untrusted client", nil, []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests}},
+		{"header set by a trusted proxy", []string{"192.0.2.1"}, []int{http.StatusNoContent, http.StatusNoContent, http.StatusNoContent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newLimitedRouter(t, tt.trustedProxies)
+			for i, want := range tt.wantCodes {
+				req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+				req.RemoteAddr = "192.0.2.1:4321"
+				req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i+1))
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != want {
+					t.Errorf("request %d with X-Forwarded-For %s: status %d, want %d", i+1, req.Header.Get("X-Forwarded-For"), rec.Code, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"api-culinary-review/pkg/idgen"
+	"api-culinary-review/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware assigns every request an ID, reusing the one sent by the client or a
+// proxy when present, and echoes it in the response. The ID is also added to the request
+// context, so everything logged while handling the request carries it.
+func RequestIDMiddleware(ids idgen.Generator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID, _ = ids.NewID()
+		}
+
+		c.Set("requestID", requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
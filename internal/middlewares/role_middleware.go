@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"api-culinary-review/pkg/apperror"
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleFunc returns the role of a user.
+type RoleFunc func(ctx context.Context, userID uint) (string, error)
+
+// RequireRole only lets users with one of roles through. It must run after JWTAuthMiddleware.
+// The role is looked up on every request, so revoking a role takes effect immediately.
+func RequireRole(roleOf RoleFunc, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := roleOf(c.Request.Context(), c.GetUint("userID"))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		RenderError(c, apperror.Forbidden("you are not allowed to do this"))
+	}
+}
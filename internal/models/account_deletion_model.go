@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	// RecipeActionDelete removes the user's recipes together with the account.
+	RecipeActionDelete = "delete"
+	// RecipeActionTransfer hands the user's recipes over to another account.
+	RecipeActionTransfer = "transfer"
+)
+
+// AccountDeletion is a pending request to delete a user account once its grace period is over.
+type AccountDeletion struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	UserID           uint       `gorm:"unique;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
+	ScheduledFor     time.Time  `gorm:"index;not null" json:"scheduled_for"`
+	RecipeAction     string     `gorm:"size:16;not null" json:"recipe_action"`
+	TransferToUserID *uint      `json:"transfer_to_user_id,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+type DeleteAccountRequest struct {
+	RecipeAction     string `json:"recipe_action" validate:"omitempty,oneof=delete transfer"`
+	TransferToUserID uint   `json:"transfer_to_user_id" validate:"required_if=RecipeAction transfer"`
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Allergens is a set of allergens, stored as a bit mask and written in JSON as a list of names.
+type Allergens uint
+
+const (
+	AllergenGluten Allergens = 1 << iota
+	AllergenDairy
+	AllergenNuts
+	AllergenShellfish
+	AllergenEgg
+	AllergenSoy
+)
+
+// AllergenNames lists the allergen names in the order of their bits.
+var AllergenNames = []string{"gluten", "dairy", "nuts", "shellfish", "egg", "soy"}
+
+// Diets is a set of diet labels, stored as a bit mask and written in JSON as a list of names.
+type Diets uint
+
+const (
+	DietVegan Diets = 1 << iota
+	DietVegetarian
+	DietHalal
+	DietKeto
+)
+
+// DietNames lists the diet names in the order of their bits.
+var DietNames = []string{"vegan", "vegetarian", "halal", "keto"}
+
+// ParseAllergens returns the set of the named allergens.
+func ParseAllergens(names []string) (Allergens, error) {
+	mask, err := parseFlags(names, AllergenNames, "allergen")
+	return Allergens(mask), err
+}
+
+func (a Allergens) Names() []string {
+	return flagNames(uint(a), AllergenNames)
+}
+
+func (a Allergens) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Names())
+}
+
+func (a *Allergens) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	parsed, err := ParseAllergens(names)
+	*a = parsed
+	return err
+}
+
+// ParseDiets returns the set of the named diets.
+func ParseDiets(names []string) (Diets, error) {
+	mask, err := parseFlags(names, DietNames, "diet")
+	return Diets(mask), err
+}
+
+func (d Diets) Names() []string {
+	return flagNames(uint(d), DietNames)
+}
+
+func (d Diets) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Names())
+}
+
+func (d *Diets) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	parsed, err := ParseDiets(names)
+	*d = parsed
+	return err
+}
+
+// RecipeFilter narrows down a recipe listing. Zero fields do not filter.
+type RecipeFilter struct {
+	// ExcludeAllergens drops the recipes containing any of the allergens.
+	ExcludeAllergens Allergens
+	// Diets keeps the recipes labeled with all of the diets.
+	Diets Diets
+	// ViewerID is the user the recipes are listed for: hidden recipes are left out unless they
+	// are theirs.
+	ViewerID uint
+}
+
+type DietaryPreferencesRequest struct {
+	Diets          []string `json:"diets" validate:"max=4,dive,oneof=vegan vegetarian halal keto"`
+	AvoidAllergens []string `json:"avoid_allergens" validate:"max=6,dive,oneof=gluten dairy nuts shellfish egg soy"`
+}
+
+func parseFlags(names, known []string, kind string) (uint, error) {
+	var mask uint
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		i := indexOf(known, name)
+		if i < 0 {
+			return 0, fmt.Errorf("unknown %s %q, must be one of %s", kind, name, strings.Join(known, ", "))
+		}
+		mask |= 1 << i
+	}
+	return mask, nil
+}
+
+func flagNames(mask uint, known []string) []string {
+	names := []string{}
+	for i, name := range known {
+		if mask&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -4,13 +4,22 @@ import "time"
 
 type Favorite struct {
 	ID        uint      `gorm:"primaryKey"`
-	UserID    uint      `gorm:"not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
-	RecipeID  uint      `gorm:"not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"recipe_id"`
+	UserID    uint      `gorm:"not null;unique_index:idx_favorites_user_recipe;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
+	RecipeID  uint      `gorm:"not null;unique_index:idx_favorites_user_recipe;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"recipe_id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Recipe    Recipe    `gorm:"foreignKey:RecipeID" json:"-"`
 }
 
-type FavoriteRequest struct {
-	UserID   uint `json:"user_id" validate:"required"`
-	RecipeID uint `json:"recipe_id" validate:"required"`
+// FavoriteResponse is a recipe in the favorites of a user.
+type FavoriteResponse struct {
+	Recipe      RecipeSummary `json:"recipe"`
+	FavoritedAt time.Time     `json:"favorited_at"`
+}
+
+// FavoriteStatus is whether the caller favorited a recipe and how many users did.
+type FavoriteStatus struct {
+	RecipeID      uint `json:"recipe_id"`
+	IsFavorited   bool `json:"is_favorited"`
+	FavoriteCount int  `json:"favorite_count"`
 }
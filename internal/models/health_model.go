@@ -0,0 +1,8 @@
+package models
+
+// HealthResponse reports the state of the service and, for readiness, of each dependency as
+// "ok" or "unavailable".
+type HealthResponse struct {
+	Status string            `json:"status" example:"ok"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
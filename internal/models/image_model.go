@@ -12,5 +12,5 @@ type Image struct {
 
 type ImageRequest struct {
 	RecipeID uint   `json:"recipe_id" validate:"required"`
-	URL      string `json:"url" validate:"required"`
+	URL      string `json:"url" validate:"required,url"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	LoginResultSuccess         = "success"
+	LoginResultInvalidPassword = "invalid_password"
+	LoginResultUnknownUser     = "unknown_user"
+	LoginResultInvalidCode     = "invalid_two_factor_code"
+	LoginResultLocked          = "locked"
+	// LoginResultTwoFactorRequired is a correct password of an account that still needs its
+	// second factor. It does not end a series of failures like a success does.
+	LoginResultTwoFactorRequired = "two_factor_required"
+)
+
+// LoginAttempt is the audit record of a single password login.
+type LoginAttempt struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     *uint     `gorm:"index"`
+	Identifier string    `gorm:"size:255;index;not null"`
+	IP         string    `gorm:"size:64;index;not null"`
+	Result     string    `gorm:"size:32;not null"`
+	CreatedAt  time.Time `gorm:"index"`
+}
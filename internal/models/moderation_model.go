@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Kinds of content that can be reported. The target of a report on an author response is the
+// review it answers; such reports are only filed by the content filter.
+const (
+	ReportTargetReview         = "review"
+	ReportTargetRecipe         = "recipe"
+	ReportTargetAuthorResponse = "author_response"
+)
+
+// Statuses of a report. A report is approved when the moderator keeps its target visible and
+// rejected when the target is hidden.
+const (
+	ReportStatusPending  = "pending"
+	ReportStatusApproved = "approved"
+	ReportStatusRejected = "rejected"
+)
+
+// Decisions of a moderator on reported content. Approve, update, merge and delete are also the
+// decisions on tags, which are not reported but moderated all the same.
+const (
+	ModerationApprove = "approve"
+	ModerationReject  = "reject"
+	ModerationUpdate  = "update"
+	ModerationMerge   = "merge"
+	ModerationDelete  = "delete"
+)
+
+// ModerationTargetTag is the target type of the decisions of moderators on tags.
+const ModerationTargetTag = "tag"
+
+// Report asks moderators to look at a review or recipe. ReporterID is nil when the content
+// filter flagged it.
+type Report struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	TargetType  string     `gorm:"size:16;not null;index:idx_reports_target" json:"target_type"`
+	TargetID    uint       `gorm:"not null;index:idx_reports_target" json:"target_id"`
+	ReporterID  *uint      `json:"reporter_id"`
+	Reason      string     `gorm:"size:500" json:"reason"`
+	Status      string     `gorm:"size:16;not null;default:'pending';index" json:"status"`
+	ModeratorID *uint      `json:"moderator_id,omitempty"`
+	Resolution  string     `gorm:"size:500" json:"resolution,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	// Excerpt is the start of the reported content, shown in the moderation queue.
+	Excerpt string `gorm:"-" json:"excerpt,omitempty"`
+}
+
+// ModerationAction is an entry of the audit trail of moderator decisions.
+type ModerationAction struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ModeratorID uint      `gorm:"not null;index" json:"moderator_id"`
+	Action      string    `gorm:"size:16;not null" json:"action"`
+	TargetType  string    `gorm:"size:16;not null" json:"target_type"`
+	TargetID    uint      `gorm:"not null" json:"target_id"`
+	Reason      string    `gorm:"size:500" json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ReportRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type ReportQueueRequest struct {
+	Status string `form:"status" validate:"omitempty,oneof=pending approved rejected"`
+	PageRequest
+}
+
+// ReportPage is a page of the moderation queue.
+type ReportPage struct {
+	Data []Report `json:"data"`
+	Meta PageMeta `json:"meta"`
+}
+
+// ModerationActionPage is a page of the audit trail.
+type ModerationActionPage struct {
+	Data []ModerationAction `json:"data"`
+	Meta PageMeta           `json:"meta"`
+}
+
+type ResolveReportRequest struct {
+	Decision string `json:"decision" validate:"required,oneof=approve reject"`
+	Reason   string `json:"reason" validate:"required_if=Decision reject,max=500"`
+}
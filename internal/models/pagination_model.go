@@ -0,0 +1,36 @@
+package models
+
+// DefaultPageSize is the number of items on a page of a listing when the request does not say.
+const DefaultPageSize = 20
+
+// PageRequest selects a page of a listing. Zero values select the first page and the default
+// page size.
+type PageRequest struct {
+	Page     int `form:"page" validate:"omitempty,min=1"`
+	PageSize int `form:"page_size" validate:"omitempty,min=1,max=100"`
+}
+
+// WithDefaults returns the request with the first page and DefaultPageSize for zero values.
+func (p PageRequest) WithDefaults() PageRequest {
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.PageSize <= 0 {
+		p.PageSize = DefaultPageSize
+	}
+	return p
+}
+
+// Offset returns the number of items before the page.
+func (p PageRequest) Offset() int {
+	p = p.WithDefaults()
+	return (p.Page - 1) * p.PageSize
+}
+
+// PageMeta describes the page of a listing that was returned.
+type PageMeta struct {
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
@@ -1,19 +1,26 @@
 package models
 
-import "time"
+import (
+	"mime/multipart"
+	"time"
+)
 
 type Profile struct {
-	ID        uint      `gorm:"primaryKey"`
-	UserID    uint      `gorm:"unique;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
-	FullName  string    `json:"full_name"`
-	Bio       string    `json:"bio"`
-	AvatarURL string    `json:"avatar_url"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
-type ProfileRequest struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"unique;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
 	FullName  string `json:"full_name"`
 	Bio       string `json:"bio"`
 	AvatarURL string `json:"avatar_url"`
+	// Diets and AvoidAllergens filter the recipe listing of the user by default.
+	Diets          Diets     `gorm:"not null;default:0" json:"diets" swaggertype:"array,string"`
+	AvoidAllergens Allergens `gorm:"not null;default:0" json:"avoid_allergens" swaggertype:"array,string"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type ProfileRequest struct {
+	FullName  string                `form:"fullName" json:"full_name" validate:"required,max=100"`
+	Bio       string                `form:"bio" json:"bio" validate:"required,max=500"`
+	AvatarURL string                `form:"-" json:"avatar_url" validate:"omitempty,url"`
+	Avatar    *multipart.FileHeader `form:"avatar" json:"-" validate:"required" swaggerignore:"true"`
 }
@@ -6,31 +6,84 @@ import (
 )
 
 type Recipe struct {
-	ID           uint      `gorm:"primaryKey"`
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	Ingredients  string    `json:"ingredients"`
-	Instructions string    `json:"instructions"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	UserID       uint      `json:"user_id"`
-	User         User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"user"`
-	Tags         []Tag     `gorm:"many2many:recipe_tags;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"tags"`
-	Images       []Image   `gorm:"foreignKey:RecipeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"images"`
-	Reviews      []Review  `gorm:"foreignKey:RecipeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"reviews" swaggerignore:"true"`
+	ID           uint   `gorm:"primaryKey"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Ingredients  string `json:"ingredients"`
+	Instructions string `json:"instructions"`
+	// IngredientList is the structured form of Ingredients, from which allergens are derived.
+	IngredientList []RecipeIngredient `gorm:"foreignKey:RecipeID" json:"ingredient_list"`
+	Allergens      Allergens          `gorm:"not null;default:0" json:"allergens" swaggertype:"array,string"`
+	Diets          Diets              `gorm:"not null;default:0" json:"diets" swaggertype:"array,string"`
+	Servings       int                `gorm:"not null;default:1" json:"servings"`
+	// Nutrition is estimated from IngredientList when the recipe is saved. It is complete when
+	// every ingredient was matched to a food with a known weight.
+	Nutrition           NutritionFacts `gorm:"embedded;embedded_prefix:nutrition_" json:"nutrition"`
+	NutritionPerServing NutritionFacts `gorm:"embedded;embedded_prefix:nutrition_serving_" json:"nutrition_per_serving"`
+	NutritionComplete   bool           `gorm:"not null;default:false" json:"nutrition_complete"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	UserID              uint           `json:"user_id"`
+	User                User           `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"user"`
+	Tags                []Tag          `gorm:"many2many:recipe_tags;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"tags"`
+	Images              []Image        `gorm:"foreignKey:RecipeID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"images"`
+	// Hidden recipes were flagged for moderation and are only shown to their author.
+	Hidden bool `gorm:"not null;default:false" json:"-"`
+	// FavoriteCount is the number of users who favorited the recipe and IsFavorited whether the
+	// user it is shown to is one of them.
+	FavoriteCount int  `gorm:"-" json:"favorite_count"`
+	IsFavorited   bool `gorm:"-" json:"is_favorited"`
+}
+
+// RecipeSummary is a recipe as listed among others, without its ingredients and instructions.
+type RecipeSummary struct {
+	ID            uint      `json:"id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	ImageURL      string    `json:"image_url,omitempty"`
+	UserID        uint      `json:"user_id"`
+	FavoriteCount int       `json:"favorite_count"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type RecipeRequest struct {
-	Title        string                  `json:"title"`
-	Description  string                  `json:"description"`
-	Ingredients  string                  `json:"ingredients"`
-	Instructions string                  `json:"instructions"`
-	Images       []*multipart.FileHeader `json:"images"`
-	ImageURLs    []string                `json:"image_urls"`
-	TagIDs       []uint                  `json:"tag_ids"`
+	Title        string                  `json:"title" validate:"required,max=200"`
+	Description  string                  `json:"description" validate:"required"`
+	Ingredients  string                  `json:"ingredients" validate:"required"`
+	Instructions string                  `json:"instructions" validate:"required"`
+	Images       []*multipart.FileHeader `json:"images" validate:"max=10"`
+	ImageURLs    []string                `json:"image_urls" validate:"dive,url"`
+	TagNames     []string                `json:"tag_names"`
+
+	IngredientList []RecipeIngredient `json:"ingredient_list" validate:"max=100,dive"`
+	Allergens      []string           `json:"allergens" validate:"max=6,dive,oneof=gluten dairy nuts shellfish egg soy"`
+	Diets          []string           `json:"diets" validate:"max=4,dive,oneof=vegan vegetarian halal keto"`
+	Servings       int                `json:"servings" validate:"omitempty,min=1,max=100"`
+}
+
+type RecipeIngredient struct {
+	ID       uint   `gorm:"primaryKey" json:"-"`
+	RecipeID uint   `gorm:"index;not null" json:"-"`
+	Name     string `gorm:"size:100;not null" json:"name" validate:"required,max=100"`
+	// Food names the food of the nutrient database the ingredient is, when its name does not match.
+	Food     string  `gorm:"size:100" json:"food,omitempty" validate:"max=100"`
+	Quantity float64 `json:"quantity" validate:"gte=0"`
+	Unit     string  `gorm:"size:20" json:"unit" validate:"max=20"`
 }
 
 type RecipeTag struct {
 	RecipeID uint `gorm:"index"`
 	TagID    uint `gorm:"index"`
 }
+
+// NutritionFacts are amounts of energy in kcal and of macronutrients in grams.
+type NutritionFacts struct {
+	Calories float64 `json:"calories"`
+	Protein  float64 `json:"protein"`
+	Carbs    float64 `json:"carbs"`
+	Fat      float64 `json:"fat"`
+}
+
+type FoodSearchRequest struct {
+	Query string `form:"q" validate:"required,min=2,max=50"`
+}
@@ -1,31 +1,123 @@
 package models
 
-import "time"
+import (
+	"mime/multipart"
+	"time"
+)
 
 type Review struct {
+	ID       uint `gorm:"primaryKey"`
+	UserID   uint `gorm:"not null;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"user_id"`
+	RecipeID uint `gorm:"not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"recipe_id"`
+	// ParentID is the review or reply this one replies to, nil for a review of the recipe. Depth
+	// is the number of replies between it and the review, 0 for the review itself.
+	ParentID *uint  `gorm:"index" json:"parent_id"`
+	Depth    int    `gorm:"not null;default:0" json:"depth"`
+	Content  string `gorm:"type:text" json:"content"`
+	// AuthorResponse is the answer of the author of the recipe to the review. A hidden response
+	// was flagged for moderation and is left out of the review until a moderator approves it.
+	AuthorResponse       string     `gorm:"type:text" json:"author_response,omitempty"`
+	AuthorResponseAt     *time.Time `json:"author_response_at,omitempty"`
+	AuthorResponseHidden bool       `gorm:"not null;default:false" json:"-"`
+	// HelpfulCount and UnhelpfulCount count the votes on the review.
+	HelpfulCount   int `gorm:"not null;default:0" json:"helpful_count"`
+	UnhelpfulCount int `gorm:"not null;default:0" json:"unhelpful_count"`
+	// Hidden reviews were flagged for moderation and are only shown to their author.
+	Hidden    bool          `gorm:"not null;default:false" json:"-"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	User      User          `gorm:"foreignKey:UserID" json:"user" swaggerignore:"true"`
+	Recipe    Recipe        `gorm:"foreignKey:RecipeID" json:"recipe" swaggerignore:"true"`
+	Photos    []ReviewPhoto `gorm:"foreignKey:ReviewID" json:"photos"`
+	Replies   []Review      `gorm:"-" json:"replies,omitempty" swaggerignore:"true"`
+}
+
+// ReviewPhoto is a photo of the dish a reviewer made. RecipeID is the recipe of the review, so
+// the photos of a recipe can be listed without its reviews.
+type ReviewPhoto struct {
 	ID        uint      `gorm:"primaryKey"`
-	UserID    uint      `gorm:"not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
-	RecipeID  uint      `gorm:"not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"recipe_id"`
-	Content   string    `gorm:"type:text" json:"content"`
+	ReviewID  uint      `gorm:"not null;index" json:"review_id"`
+	RecipeID  uint      `gorm:"not null;index" json:"recipe_id"`
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	URL       string    `json:"url"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	User      User      `gorm:"foreignKey:UserID" json:"user" swaggerignore:"true"`
-	Recipe    Recipe    `gorm:"foreignKey:RecipeID" json:"recipe" swaggerignore:"true"`
+}
+
+// ReviewVote is the vote of a user on whether a review is helpful.
+type ReviewVote struct {
+	ID       uint `gorm:"primaryKey"`
+	ReviewID uint `gorm:"not null;unique_index:idx_review_votes_review_user"`
+	UserID   uint `gorm:"not null;unique_index:idx_review_votes_review_user"`
+	Helpful  bool `gorm:"not null"`
 }
 
 type ReviewRequest struct {
-	UserID   uint   `json:"user_id" validate:"required"`
-	RecipeID uint   `json:"recipe_id" validate:"required"`
-	Content  string `json:"content" validate:"required"`
+	UserID   uint                    `form:"-" json:"-"`
+	RecipeID uint                    `form:"recipe_id" json:"recipe_id" validate:"required"`
+	ParentID *uint                   `form:"-" json:"-"`
+	Depth    int                     `form:"-" json:"-"`
+	Hidden   bool                    `form:"-" json:"-"`
+	Content  string                  `form:"content" json:"content" validate:"required,max=2000"`
+	Photos   []*multipart.FileHeader `form:"photos" json:"-" swaggerignore:"true"`
+	// PhotoURLs are the uploaded Photos, saved with the review.
+	PhotoURLs []string `form:"-" json:"-"`
+}
+
+type ReviewUpdateRequest struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type ReplyRequest struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type AuthorResponseRequest struct {
+	Content string `json:"content" validate:"required,max=2000"`
 }
 
+type ReviewVoteRequest struct {
+	Helpful *bool `json:"helpful" validate:"required"`
+}
+
+// Orders of the reviews of a recipe.
+const (
+	ReviewSortHelpful = "helpful"
+	ReviewSortNewest  = "newest"
+	ReviewSortOldest  = "oldest"
+)
+
+type RecipeReviewsRequest struct {
+	Sort string `form:"sort" validate:"omitempty,oneof=helpful newest oldest"`
+	PageRequest
+}
+
+// RecipeReviewRequest is a review posted to the recipe in the path.
+type RecipeReviewRequest struct {
+	Content string                  `form:"content" json:"content" validate:"required,max=2000"`
+	Photos  []*multipart.FileHeader `form:"photos" json:"-" swaggerignore:"true"`
+}
+
+// ReviewResponse is a review as listed by the API: it names its recipe and author instead of
+// embedding them.
 type ReviewResponse struct {
-	ID        uint         `gorm:"primaryKey"`
-	UserID    uint         `gorm:"not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
-	RecipeID  uint         `gorm:"not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"recipe_id"`
-	Content   string       `gorm:"type:text" json:"content"`
-	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
-	User      UserResponse `gorm:"foreignKey:UserID" json:"user"`
-	Recipe    Recipe       `gorm:"foreignKey:RecipeID" json:"recipe"`
+	ID               uint             `json:"id"`
+	RecipeID         uint             `json:"recipe_id"`
+	ParentID         *uint            `json:"parent_id"`
+	Depth            int              `json:"depth"`
+	Content          string           `json:"content"`
+	AuthorResponse   string           `json:"author_response,omitempty"`
+	AuthorResponseAt *time.Time       `json:"author_response_at,omitempty"`
+	HelpfulCount     int              `json:"helpful_count"`
+	UnhelpfulCount   int              `json:"unhelpful_count"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	User             UserResponse     `json:"user"`
+	Photos           []ReviewPhoto    `json:"photos"`
+	Replies          []ReviewResponse `json:"replies,omitempty"`
+}
+
+// ReviewPage is a page of reviews.
+type ReviewPage struct {
+	Data []ReviewResponse `json:"data"`
+	Meta PageMeta         `json:"meta"`
 }
@@ -2,19 +2,75 @@ package models
 
 import "time"
 
+// Tag categories. Tags created before categories existed are in TagCategoryOther.
+const (
+	TagCategoryDiet       = "diet"
+	TagCategoryCuisine    = "cuisine"
+	TagCategoryMealType   = "meal_type"
+	TagCategoryIngredient = "ingredient"
+	TagCategoryOther      = "other"
+)
+
+// Tag statuses. Tags created from unknown tag names of a recipe are pending until a moderator
+// approves them; only approved tags are listed and suggested.
+const (
+	TagStatusApproved = "approved"
+	TagStatusPending  = "pending"
+)
+
+// TagCategories lists the categories in the order tags are grouped by.
+var TagCategories = []string{TagCategoryDiet, TagCategoryCuisine, TagCategoryMealType, TagCategoryIngredient, TagCategoryOther}
+
 type Tag struct {
-	ID        uint      `gorm:"primaryKey"`
-	Name      string    `gorm:"unique" json:"name"`
-	Recipes   []Recipe  `gorm:"many2many:recipe_tags;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"recipes"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint         `gorm:"primaryKey"`
+	Name      string       `gorm:"unique" json:"name"`
+	Slug      string       `gorm:"size:100" json:"slug"`
+	Category  string       `gorm:"size:32;not null;default:'other'" json:"category"`
+	ParentID  *uint        `gorm:"index" json:"parent_id"`
+	Status    string       `gorm:"size:16;not null;default:'approved'" json:"status"`
+	Synonyms  []TagSynonym `gorm:"foreignKey:TagID" json:"synonyms,omitempty"`
+	Recipes   []Recipe     `gorm:"many2many:recipe_tags;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"recipes"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// TagSynonym is another name a tag can be found by, like "Bakmi" for "Mie".
+type TagSynonym struct {
+	ID    uint   `gorm:"primaryKey" json:"-"`
+	TagID uint   `gorm:"index;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Name  string `gorm:"size:50;unique;not null" json:"name"`
 }
 
 type TagResponse struct {
-	ID   uint   `json:"id"`
-	Name string `json:"name"`
+	ID          uint          `json:"id"`
+	Name        string        `json:"name"`
+	Slug        string        `json:"slug"`
+	Category    string        `json:"category"`
+	ParentID    *uint         `json:"parent_id"`
+	Status      string        `json:"status"`
+	Synonyms    []string      `json:"synonyms"`
+	RecipeCount int           `json:"recipe_count"`
+	Children    []TagResponse `json:"children,omitempty"`
+}
+
+// TagGroup is a category with its tags, as a tree of parent tags and their children.
+type TagGroup struct {
+	Category string        `json:"category"`
+	Tags     []TagResponse `json:"tags"`
 }
 
 type TagRequest struct {
-	Name string `json:"name" validate:"required"`
+	Name     string   `json:"name" validate:"required,max=50"`
+	Category string   `json:"category" validate:"omitempty,oneof=diet cuisine meal_type ingredient other"`
+	ParentID *uint    `json:"parent_id"`
+	Synonyms []string `json:"synonyms" validate:"max=20,dive,required,max=50"`
+}
+
+type TagSuggestRequest struct {
+	Prefix string `form:"prefix" validate:"required,max=50"`
+	Limit  int    `form:"limit" validate:"omitempty,min=1,max=25"`
+}
+
+type TagMergeRequest struct {
+	SourceIDs []uint `json:"source_ids" validate:"required,min=1,max=50,dive,gt=0"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user_id"`
+	Provider  string    `gorm:"size:32;not null;unique_index:idx_user_identities_provider_subject" json:"provider"`
+	Subject   string    `gorm:"size:255;not null;unique_index:idx_user_identities_provider_subject" json:"-"`
+	Email     string    `gorm:"size:255" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
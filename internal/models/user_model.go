@@ -12,16 +12,42 @@ func init() {
 	validate = validator.New()
 }
 
+// DeletedUsername is shown in place of the username of an anonymized account.
+const DeletedUsername = "deleted user"
+
+// Roles of users. Moderators curate shared data like tags; admins can do everything moderators can.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
-	ID        uint       `gorm:"primaryKey"`
-	Username  string     `gorm:"size:255;not null" json:"username"`
-	Email     string     `gorm:"size:255;unique;not null" json:"email" validate:"required,email"`
-	Password  string     `gorm:"size:255;not null" json:"-"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	Profile   Profile    `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"profile"`
-	Reviews   []Review   `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"reviews"`
-	Favorites []Favorite `gorm:"many2many:user_favorites;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"favorites"`
+	ID              uint       `gorm:"primaryKey"`
+	Username        string     `gorm:"size:255;not null" json:"username"`
+	Email           string     `gorm:"size:255;unique;not null" json:"email" validate:"required,email"`
+	Password        string     `gorm:"size:255;not null" json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPSecret      string     `gorm:"size:64" json:"-"`
+	TOTPEnabledAt   *time.Time `json:"two_factor_enabled_at"`
+	TOTPLastStep    int64      `json:"-"`
+	AnonymizedAt    *time.Time `json:"-"`
+	Role            string     `gorm:"size:16;not null;default:'user'" json:"role"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Profile         Profile    `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"profile"`
+	Reviews         []Review   `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"reviews"`
+	Favorites       []Favorite `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"favorites"`
+}
+
+// TwoFactorEnabled reports whether logins require a TOTP or recovery code.
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// IsAnonymized reports whether the account has been deleted and its personal data scrubbed.
+func (u *User) IsAnonymized() bool {
+	return u.AnonymizedAt != nil
 }
 
 func (u *User) Validate() error {
@@ -29,9 +55,9 @@ func (u *User) Validate() error {
 }
 
 type UserRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
-	Email    string `json:"email" binding:"required"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+	Email    string `json:"email" validate:"required,email,max=255"`
 }
 
 type UserResponse struct {
@@ -40,11 +66,38 @@ type UserResponse struct {
 }
 
 type LoginInput struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 type InputChangePassword struct {
-	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required"`
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72,nefield=OldPassword"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=16"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required,max=16"`
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
 }
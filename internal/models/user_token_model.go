@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposePasswordReset = "password_reset"
+)
+
+// UserToken is a single-use, expiring token sent to a user by email. Only the SHA-256 hash of
+// the token is stored.
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;not null;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Purpose   string    `gorm:"size:32;not null"`
+	TokenHash string    `gorm:"size:64;unique;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -2,15 +2,25 @@ package repositories
 
 import (
 	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
 
 	"github.com/jinzhu/gorm"
 )
 
 type FavoriteRepository interface {
-	GetByUserID(userID uint) ([]*models.Favorite, error)
-	Create(favorite *models.Favorite) error
-	FindByID(id uint) (*models.Favorite, error)
-	Delete(id uint) error
+	// GetByUserID returns the favorites of the user by ascending ID, with their recipes and the
+	// images of them. A favorite of a deleted recipe has a zero Recipe.
+	GetByUserID(ctx context.Context, userID uint) ([]*models.Favorite, error)
+	// Add returns the favorite of the user on the recipe, creating it unless it exists.
+	Add(ctx context.Context, userID, recipeID uint) (*models.Favorite, error)
+	// Remove deletes the favorite of the user on the recipe, if any.
+	Remove(ctx context.Context, userID, recipeID uint) error
+	// CountByRecipeIDs returns the number of users who favorited each of the recipes that has
+	// favorites.
+	CountByRecipeIDs(ctx context.Context, recipeIDs []uint) (map[uint]int, error)
+	// FavoritedRecipeIDs returns the recipes among recipeIDs the user favorited.
+	FavoritedRecipeIDs(ctx context.Context, userID uint, recipeIDs []uint) (map[uint]bool, error)
 }
 
 type favoriteRepository struct {
@@ -21,28 +31,66 @@ func NewFavoriteRepository(db *gorm.DB) FavoriteRepository {
 	return &favoriteRepository{DB: db}
 }
 
-func (repo *favoriteRepository) GetByUserID(userID uint) ([]*models.Favorite, error) {
+func (repo *favoriteRepository) GetByUserID(ctx context.Context, userID uint) ([]*models.Favorite, error) {
 	var favorites []*models.Favorite
-	err := repo.DB.Where("user_id = ?", userID).Find(&favorites).Error
+	err := database.WithContext(ctx, repo.DB).Preload("Recipe.Images", orderByID).Where("user_id = ?", userID).Order("id").Find(&favorites).Error
 	if err != nil {
 		return nil, err
 	}
 	return favorites, nil
 }
 
-func (repo *favoriteRepository) Create(favorite *models.Favorite) error {
-	return repo.DB.Create(favorite).Error
+func (repo *favoriteRepository) Add(ctx context.Context, userID, recipeID uint) (*models.Favorite, error) {
+	db := database.WithContext(ctx, repo.DB)
+	favorite := models.Favorite{UserID: userID, RecipeID: recipeID}
+	if err := db.Where("user_id = ? AND recipe_id = ?", userID, recipeID).FirstOrCreate(&favorite).Error; err != nil {
+		// A concurrent request created it between the lookup and the insert.
+		if findErr := db.Where("user_id = ? AND recipe_id = ?", userID, recipeID).First(&favorite).Error; findErr != nil {
+			return nil, err
+		}
+	}
+	return &favorite, nil
+}
+
+func (repo *favoriteRepository) Remove(ctx context.Context, userID, recipeID uint) error {
+	return database.WithContext(ctx, repo.DB).Where("user_id = ? AND recipe_id = ?", userID, recipeID).Delete(&models.Favorite{}).Error
 }
 
-func (repo *favoriteRepository) FindByID(id uint) (*models.Favorite, error) {
-	var favorite models.Favorite
-	err := repo.DB.First(&favorite, id).Error
+func (repo *favoriteRepository) CountByRecipeIDs(ctx context.Context, recipeIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int)
+	if len(recipeIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		RecipeID uint
+		Count    int
+	}
+	err := database.WithContext(ctx, repo.DB).Model(&models.Favorite{}).Select("recipe_id, COUNT(*) AS count").
+		Where("recipe_id IN (?)", recipeIDs).Group("recipe_id").Scan(&rows).Error
 	if err != nil {
 		return nil, err
 	}
-	return &favorite, nil
+	for _, row := range rows {
+		counts[row.RecipeID] = row.Count
+	}
+	return counts, nil
 }
 
-func (repo *favoriteRepository) Delete(id uint) error {
-	return repo.DB.Delete(&models.Favorite{}, id).Error
+func (repo *favoriteRepository) FavoritedRecipeIDs(ctx context.Context, userID uint, recipeIDs []uint) (map[uint]bool, error) {
+	favorited := make(map[uint]bool)
+	if userID == 0 || len(recipeIDs) == 0 {
+		return favorited, nil
+	}
+
+	var ids []uint
+	err := database.WithContext(ctx, repo.DB).Model(&models.Favorite{}).Where("user_id = ? AND recipe_id IN (?)", userID, recipeIDs).
+		Pluck("recipe_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		favorited[id] = true
+	}
+	return favorited, nil
 }
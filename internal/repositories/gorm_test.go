@@ -0,0 +1,121 @@
+package repositories_test
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"api-culinary-review/internal/repositories/repotest"
+	"api-culinary-review/pkg/database"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func TestGormRepositories(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.Set {
+		db, err := gorm.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		// Every connection to :memory: opens its own database.
+		db.DB().SetMaxOpenConns(1)
+
+		if err := database.Migrate(db); err != nil {
+			t.Fatal(err)
+		}
+		if err := repositories.MigrateTagNames(context.Background(), db); err != nil {
+			t.Fatal(err)
+		}
+		return repositories.NewGormSet(db)
+	})
+}
+
+func TestMigrateTagNamesMergesNamesDifferingByCase(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.DB().SetMaxOpenConns(1)
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := repositories.MigrateTagNames(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tags of a database migrated before names were unique ignoring case.
+	for _, statement := range []string{
+		"DROP INDEX idx_tags_name_lower",
+		"DROP INDEX idx_tag_synonyms_name_lower",
+		"INSERT INTO users (id, username, email, password) VALUES (1, 'alice', 'alice@example.com', 'hash')",
+		"INSERT INTO recipes (id, title, user_id) VALUES (1, 'salad', 1), (2, 'tofu', 1)",
+		"INSERT INTO tags (id, name, slug, category, status) VALUES (1, 'Vegan', 'vegan', 'diet', 'approved'), " +
+			"(2, 'vegan', 'vegan-2', 'diet', 'approved'), (3, 'VEGAN', 'vegan-3', 'diet', 'pending')",
+		"INSERT INTO tags (id, name, slug, category, status, parent_id) VALUES (4, 'raw vegan', 'raw-vegan', 'diet', 'approved', 2)",
+		"INSERT INTO tag_synonyms (tag_id, name) VALUES (2, 'plant-based'), (3, 'Plant-Based'), (4, 'Vegan'), (4, 'raw')",
+		"INSERT INTO recipe_tags (recipe_id, tag_id) VALUES (1, 1), (1, 2), (2, 3)",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repositories.MigrateTagNames(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	repos := repositories.NewGormSet(db)
+	tags, err := repos.Tags.GetAllTags(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tag := range tags {
+		entry := fmt.Sprintf("%d %s", tag.ID, tag.Name)
+		if tag.ParentID != nil {
+			entry += fmt.Sprintf(" below %d", *tag.ParentID)
+		}
+		for _, synonym := range tag.Synonyms {
+			entry += " aka " + synonym.Name
+		}
+		got = append(got, entry)
+	}
+	if want := []string{"1 Vegan aka plant-based", "4 raw vegan below 1 aka raw"}; strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("tags after MigrateTagNames = %q, want %q", got, want)
+	}
+
+	counts, err := repos.Tags.CountRecipes(ctx)
+	if err != nil || len(counts) != 1 || counts[1] != 2 {
+		t.Errorf("CountRecipes after MigrateTagNames = %v, %v, want both recipes tagged Vegan once", counts, err)
+	}
+
+	if err := repos.Tags.Create(ctx, &models.Tag{Name: "VEGAN", Slug: "vegan-4"}); err == nil {
+		t.Error("Create accepted a tag name differing by case after MigrateTagNames")
+	}
+
+	// Once the indexes exist the migration does not run again.
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			if err := repositories.MigrateTagNames(ctx, db); err != nil {
+				t.Fatal(err)
+			}
+		}
+		actions, total, err := repos.Reports.FindActions(ctx, models.PageRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var merged []string
+		for _, action := range actions {
+			merged = append(merged, fmt.Sprintf("%s %d by %d", action.Action, action.TargetID, action.ModeratorID))
+		}
+		if want := []string{"merge 3 by 0", "merge 2 by 0"}; total != 2 || strings.Join(merged, ", ") != strings.Join(want, ", ") {
+			t.Errorf("audit trail after MigrateTagNames ran %d times = %q, want %q", i+1, merged, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type LoginAttemptRepository interface {
+	Create(ctx context.Context, attempt *models.LoginAttempt) error
+	// CountFailuresByUser counts the failures of the account after since and after its last
+	// successful login, and returns the time of the last of them.
+	CountFailuresByUser(ctx context.Context, userID uint, since time.Time) (int, time.Time, error)
+	// CountFailuresByIdentifier is CountFailuresByUser for attempts naming no known account.
+	CountFailuresByIdentifier(ctx context.Context, identifier string, since time.Time) (int, time.Time, error)
+	// CountFailuresByIP counts the failures from the IP after since. Successful logins do not
+	// reset it, or signing in to an account of one's own would clear it.
+	CountFailuresByIP(ctx context.Context, ip string, since time.Time) (int, time.Time, error)
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	return database.WithContext(ctx, r.db).Create(attempt).Error
+}
+
+func (r *loginAttemptRepository) CountFailuresByUser(ctx context.Context, userID uint, since time.Time) (int, time.Time, error) {
+	return r.countFailures(ctx, "user_id", userID, since, true)
+}
+
+func (r *loginAttemptRepository) CountFailuresByIdentifier(ctx context.Context, identifier string, since time.Time) (int, time.Time, error) {
+	return r.countFailures(ctx, "identifier", identifier, since, true)
+}
+
+func (r *loginAttemptRepository) CountFailuresByIP(ctx context.Context, ip string, since time.Time) (int, time.Time, error) {
+	return r.countFailures(ctx, "ip", ip, since, false)
+}
+
+// countFailures returns the number of failed attempts for the key after since, and after the
+// last successful login for it when sinceSuccess is set, together with the time of the most
+// recent of those failures.
+func (r *loginAttemptRepository) countFailures(ctx context.Context, column string, value interface{}, since time.Time, sinceSuccess bool) (int, time.Time, error) {
+	db := database.WithContext(ctx, r.db)
+	if sinceSuccess {
+		var lastSuccess models.LoginAttempt
+		err := db.Where(column+" = ? AND result = ? AND created_at > ?", value, models.LoginResultSuccess, since).
+			Order("created_at DESC").First(&lastSuccess).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return 0, time.Time{}, err
+		}
+		if err == nil {
+			since = lastSuccess.CreatedAt
+		}
+	}
+
+	failures := db.Model(&models.LoginAttempt{}).
+		Where(column+" = ? AND result IN (?) AND created_at > ?", value,
+			[]string{models.LoginResultInvalidPassword, models.LoginResultUnknownUser, models.LoginResultInvalidCode}, since)
+
+	var count int
+	if err := failures.Count(&count).Error; err != nil || count == 0 {
+		return 0, time.Time{}, err
+	}
+
+	var last models.LoginAttempt
+	if err := failures.Order("created_at DESC").First(&last).Error; err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return count, last.CreatedAt, nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+)
+
+type favoriteRepository struct {
+	s *Store
+}
+
+func NewFavoriteRepository(s *Store) repositories.FavoriteRepository {
+	return &favoriteRepository{s: s}
+}
+
+func (r *favoriteRepository) GetByUserID(_ context.Context, userID uint) ([]*models.Favorite, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	rows := r.s.favorites.all(func(f models.Favorite) bool { return f.UserID == userID })
+	favorites := make([]*models.Favorite, 0, len(rows))
+	for i := range rows {
+		if recipe, ok := r.s.recipes.get(rows[i].RecipeID); ok {
+			recipe.Images = r.s.images.all(func(img models.Image) bool { return img.RecipeID == recipe.ID })
+			rows[i].Recipe = recipe
+		}
+		favorites = append(favorites, &rows[i])
+	}
+	return favorites, nil
+}
+
+func (r *favoriteRepository) Add(_ context.Context, userID, recipeID uint) (*models.Favorite, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if favorite, ok := r.s.favorite(userID, recipeID); ok {
+		return &favorite, nil
+	}
+	favorite := models.Favorite{UserID: userID, RecipeID: recipeID}
+	stamp(&favorite.CreatedAt, &favorite.UpdatedAt)
+	favorite.ID = r.s.favorites.id(0)
+	r.s.favorites.set(favorite.ID, favorite)
+	return &favorite, nil
+}
+
+func (r *favoriteRepository) Remove(_ context.Context, userID, recipeID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.favorites.deleteWhere(func(f models.Favorite) bool { return f.UserID == userID && f.RecipeID == recipeID })
+	return nil
+}
+
+func (r *favoriteRepository) CountByRecipeIDs(_ context.Context, recipeIDs []uint) (map[uint]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	wanted := idSet(recipeIDs)
+	counts := make(map[uint]int)
+	for _, favorite := range r.s.favorites.all(func(f models.Favorite) bool { return wanted[f.RecipeID] }) {
+		counts[favorite.RecipeID]++
+	}
+	return counts, nil
+}
+
+func (r *favoriteRepository) FavoritedRecipeIDs(_ context.Context, userID uint, recipeIDs []uint) (map[uint]bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	wanted := idSet(recipeIDs)
+	favorited := make(map[uint]bool)
+	for _, favorite := range r.s.favorites.all(func(f models.Favorite) bool { return f.UserID == userID && wanted[f.RecipeID] }) {
+		favorited[favorite.RecipeID] = true
+	}
+	return favorited, nil
+}
+
+// favorite returns the favorite of the user on the recipe, if any.
+func (s *Store) favorite(userID, recipeID uint) (models.Favorite, bool) {
+	return s.favorites.first(func(f models.Favorite) bool { return f.UserID == userID && f.RecipeID == recipeID })
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"time"
+)
+
+type loginAttemptRepository struct {
+	s *Store
+}
+
+func NewLoginAttemptRepository(s *Store) repositories.LoginAttemptRepository {
+	return &loginAttemptRepository{s: s}
+}
+
+func (r *loginAttemptRepository) Create(_ context.Context, attempt *models.LoginAttempt) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stamp(&attempt.CreatedAt, nil)
+	attempt.ID = r.s.loginAttempts.id(attempt.ID)
+	r.s.loginAttempts.set(attempt.ID, *attempt)
+	return nil
+}
+
+func (r *loginAttemptRepository) CountFailuresByUser(_ context.Context, userID uint, since time.Time) (int, time.Time, error) {
+	return r.countFailures(func(a models.LoginAttempt) bool { return a.UserID != nil && *a.UserID == userID }, since, true)
+}
+
+func (r *loginAttemptRepository) CountFailuresByIdentifier(_ context.Context, identifier string, since time.Time) (int, time.Time, error) {
+	return r.countFailures(func(a models.LoginAttempt) bool { return a.Identifier == identifier }, since, true)
+}
+
+func (r *loginAttemptRepository) CountFailuresByIP(_ context.Context, ip string, since time.Time) (int, time.Time, error) {
+	return r.countFailures(func(a models.LoginAttempt) bool { return a.IP == ip }, since, false)
+}
+
+// countFailures returns the number of failed attempts for the key after since, and after the
+// last successful login for it when sinceSuccess is set, together with the time of the most
+// recent of those failures.
+func (r *loginAttemptRepository) countFailures(key func(models.LoginAttempt) bool, since time.Time, sinceSuccess bool) (int, time.Time, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	attempts := r.s.loginAttempts.all(key)
+	for _, a := range attempts {
+		if sinceSuccess && a.Result == models.LoginResultSuccess && a.CreatedAt.After(since) {
+			since = a.CreatedAt
+		}
+	}
+
+	var count int
+	var last time.Time
+	for _, a := range attempts {
+		switch a.Result {
+		case models.LoginResultInvalidPassword, models.LoginResultUnknownUser, models.LoginResultInvalidCode:
+		default:
+			continue
+		}
+		if !a.CreatedAt.After(since) {
+			continue
+		}
+		count++
+		if a.CreatedAt.After(last) {
+			last = a.CreatedAt
+		}
+	}
+	return count, last, nil
+}
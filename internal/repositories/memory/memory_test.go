@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"api-culinary-review/internal/repositories"
+	"api-culinary-review/internal/repositories/memory"
+	"api-culinary-review/internal/repositories/repotest"
+	"testing"
+)
+
+func TestRepositories(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.Set {
+		return memory.NewSet()
+	})
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+type profileRepository struct {
+	s *Store
+}
+
+func NewProfileRepository(s *Store) repositories.ProfileRepository {
+	return &profileRepository{s: s}
+}
+
+func (r *profileRepository) CreateProfile(_ context.Context, profile *models.Profile) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkProfileUser(profile); err != nil {
+		return err
+	}
+	stamp(&profile.CreatedAt, &profile.UpdatedAt)
+	profile.ID = r.s.profiles.id(profile.ID)
+	r.s.profiles.set(profile.ID, *profile)
+	return nil
+}
+
+func (r *profileRepository) GetProfileByUserID(_ context.Context, userID uint) (*models.Profile, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	profile, ok := r.s.profiles.first(func(p models.Profile) bool { return p.UserID == userID })
+	if !ok {
+		return &models.Profile{}, gorm.ErrRecordNotFound
+	}
+	return &profile, nil
+}
+
+func (r *profileRepository) UpdateProfile(_ context.Context, profile *models.Profile) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkProfileUser(profile); err != nil {
+		return err
+	}
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = now()
+	}
+	profile.UpdatedAt = now()
+	profile.ID = r.s.profiles.id(profile.ID)
+	r.s.profiles.set(profile.ID, *profile)
+	return nil
+}
+
+// checkProfileUser enforces the unique index on profiles.user_id.
+func (s *Store) checkProfileUser(profile *models.Profile) error {
+	if _, ok := s.profiles.first(func(p models.Profile) bool {
+		return p.UserID == profile.UserID && p.ID != profile.ID
+	}); ok {
+		return duplicate("profiles", "user_id", profile.UserID)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+type recipeRepository struct {
+	s *Store
+}
+
+func NewRecipeRepository(s *Store) repositories.RecipeRepository {
+	return &recipeRepository{s: s}
+}
+
+func (r *recipeRepository) CreateRecipe(_ context.Context, recipe *models.Recipe) (*models.Recipe, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stamp(&recipe.CreatedAt, &recipe.UpdatedAt)
+	return recipe, r.s.saveRecipe(recipe)
+}
+
+func (r *recipeRepository) GetRecipeByID(_ context.Context, id uint) (*models.Recipe, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	recipe, ok := r.s.recipes.get(id)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	r.s.loadRecipe(&recipe)
+	if user, ok := r.s.users.get(recipe.UserID); ok {
+		recipe.User = r.s.withProfile(user)
+	}
+	return &recipe, nil
+}
+
+func (r *recipeRepository) GetRecipesByIDs(_ context.Context, ids []uint) ([]models.Recipe, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	set := idSet(ids)
+	return r.s.recipes.all(func(rc models.Recipe) bool { return set[rc.ID] }), nil
+}
+
+func (r *recipeRepository) GetRecipes(_ context.Context, filter models.RecipeFilter) ([]*models.Recipe, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	rows := r.s.recipes.all(func(rc models.Recipe) bool {
+		return rc.Allergens&filter.ExcludeAllergens == 0 && rc.Diets&filter.Diets == filter.Diets &&
+			(!rc.Hidden || rc.UserID == filter.ViewerID)
+	})
+	recipes := make([]*models.Recipe, 0, len(rows))
+	for i := range rows {
+		r.s.loadRecipe(&rows[i])
+		recipes = append(recipes, &rows[i])
+	}
+	return recipes, nil
+}
+
+func (r *recipeRepository) UpdateRecipe(_ context.Context, recipe *models.Recipe) (*models.Recipe, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if recipe.CreatedAt.IsZero() {
+		recipe.CreatedAt = now()
+	}
+	recipe.UpdatedAt = now()
+	return recipe, r.s.saveRecipe(recipe)
+}
+
+func (r *recipeRepository) ReplaceRecipe(_ context.Context, recipe *models.Recipe, tagIDs []uint) (*models.Recipe, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.recipeIngredients.deleteWhere(func(i models.RecipeIngredient) bool { return i.RecipeID == recipe.ID })
+	r.s.images.deleteWhere(func(i models.Image) bool { return i.RecipeID == recipe.ID })
+	r.s.deleteRecipeTags(func(rt models.RecipeTag) bool { return rt.RecipeID == recipe.ID })
+	for _, tagID := range tagIDs {
+		r.s.recipeTags = append(r.s.recipeTags, models.RecipeTag{RecipeID: recipe.ID, TagID: tagID})
+	}
+
+	for i := range recipe.IngredientList {
+		recipe.IngredientList[i].ID = 0
+	}
+	for i := range recipe.Images {
+		recipe.Images[i].ID = 0
+	}
+	recipe.Tags = nil
+	recipe.UpdatedAt = now()
+	if err := r.s.saveRecipe(recipe); err != nil {
+		return nil, err
+	}
+	recipe.Tags = r.s.tags.all(func(t models.Tag) bool { return r.s.recipeHasTag(recipe.ID, t.ID) })
+	return recipe, nil
+}
+
+func (r *recipeRepository) DeleteRecipe(_ context.Context, id uint) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.deleteRecipes(map[uint]bool{id: true}), nil
+}
+
+func (r *recipeRepository) CreateRecipeTag(_ context.Context, recipeId uint, tagId uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.recipeTags = append(r.s.recipeTags, models.RecipeTag{RecipeID: recipeId, TagID: tagId})
+	return nil
+}
+
+func (r *recipeRepository) RecipeTagExists(_ context.Context, tagId uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, ok := r.s.tags.get(tagId)
+	return ok, nil
+}
+
+// saveRecipe stores the recipe together with its images, ingredients and tags, like gorm saves
+// associations: new ones are created, existing ones are saved and the tags are linked to the recipe.
+func (s *Store) saveRecipe(recipe *models.Recipe) error {
+	for i := range recipe.Tags {
+		if err := s.checkTag(&recipe.Tags[i], nil); err != nil {
+			return err
+		}
+	}
+
+	if recipe.ID == 0 && recipe.Servings == 0 {
+		recipe.Servings = 1
+	}
+	recipe.ID = s.recipes.id(recipe.ID)
+	row := *recipe
+	row.User = models.User{}
+	row.Tags = nil
+	row.Images = nil
+	row.IngredientList = nil
+	s.recipes.set(recipe.ID, row)
+
+	for i := range recipe.IngredientList {
+		ingredient := &recipe.IngredientList[i]
+		ingredient.RecipeID = recipe.ID
+		ingredient.ID = s.recipeIngredients.id(ingredient.ID)
+		s.recipeIngredients.set(ingredient.ID, *ingredient)
+	}
+
+	for i := range recipe.Images {
+		image := &recipe.Images[i]
+		image.RecipeID = recipe.ID
+		stamp(&image.CreatedAt, &image.UpdatedAt)
+		image.ID = s.images.id(image.ID)
+		s.images.set(image.ID, *image)
+	}
+
+	for i := range recipe.Tags {
+		tag := &recipe.Tags[i]
+		stamp(&tag.CreatedAt, &tag.UpdatedAt)
+		s.saveTag(tag)
+		if !s.recipeHasTag(recipe.ID, tag.ID) {
+			s.recipeTags = append(s.recipeTags, models.RecipeTag{RecipeID: recipe.ID, TagID: tag.ID})
+		}
+	}
+	return nil
+}
+
+// loadRecipe loads the tags, images and ingredients of recipe.
+func (s *Store) loadRecipe(recipe *models.Recipe) {
+	recipe.Tags = s.tags.all(func(t models.Tag) bool { return s.recipeHasTag(recipe.ID, t.ID) })
+	recipe.Images = s.images.all(func(i models.Image) bool { return i.RecipeID == recipe.ID })
+	recipe.IngredientList = s.recipeIngredients.all(func(i models.RecipeIngredient) bool { return i.RecipeID == recipe.ID })
+}
+
+func (s *Store) recipeHasTag(recipeID, tagID uint) bool {
+	for _, rt := range s.recipeTags {
+		if rt.RecipeID == recipeID && rt.TagID == tagID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) deleteRecipeTags(match func(models.RecipeTag) bool) {
+	kept := s.recipeTags[:0]
+	for _, rt := range s.recipeTags {
+		if !match(rt) {
+			kept = append(kept, rt)
+		}
+	}
+	s.recipeTags = kept
+}
+
+// withProfile returns user with its profile loaded.
+func (s *Store) withProfile(user models.User) models.User {
+	user.Profile, _ = s.profiles.first(func(p models.Profile) bool { return p.UserID == user.ID })
+	return user
+}
+
+// deleteRecipes deletes the recipes in recipeIDs with the rows that refer to them and returns the
+// URLs of their images and review photos.
+func (s *Store) deleteRecipes(recipeIDs map[uint]bool) []string {
+	var imageURLs []string
+	for _, image := range s.images.all(func(i models.Image) bool { return recipeIDs[i.RecipeID] }) {
+		imageURLs = append(imageURLs, image.URL)
+	}
+	for _, photo := range s.reviewPhotos.all(func(p models.ReviewPhoto) bool { return recipeIDs[p.RecipeID] }) {
+		imageURLs = append(imageURLs, photo.URL)
+	}
+
+	s.reviewVotes.deleteWhere(func(v models.ReviewVote) bool {
+		review, ok := s.reviews.get(v.ReviewID)
+		return ok && recipeIDs[review.RecipeID]
+	})
+	s.reports.deleteWhere(func(r models.Report) bool {
+		if r.TargetType == models.ReportTargetRecipe {
+			return recipeIDs[r.TargetID]
+		}
+		review, ok := s.reviews.get(r.TargetID)
+		return ok && recipeIDs[review.RecipeID]
+	})
+	s.images.deleteWhere(func(i models.Image) bool { return recipeIDs[i.RecipeID] })
+	s.recipeIngredients.deleteWhere(func(i models.RecipeIngredient) bool { return recipeIDs[i.RecipeID] })
+	s.deleteRecipeTags(func(rt models.RecipeTag) bool { return recipeIDs[rt.RecipeID] })
+	s.reviewPhotos.deleteWhere(func(p models.ReviewPhoto) bool { return recipeIDs[p.RecipeID] })
+	s.reviews.deleteWhere(func(rv models.Review) bool { return recipeIDs[rv.RecipeID] })
+	s.favorites.deleteWhere(func(f models.Favorite) bool { return recipeIDs[f.RecipeID] })
+	s.recipes.deleteWhere(func(rc models.Recipe) bool { return recipeIDs[rc.ID] })
+	return imageURLs
+}
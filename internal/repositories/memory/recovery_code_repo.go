@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"time"
+)
+
+type recoveryCodeRepository struct {
+	s *Store
+}
+
+func NewRecoveryCodeRepository(s *Store) repositories.RecoveryCodeRepository {
+	return &recoveryCodeRepository{s: s}
+}
+
+func (r *recoveryCodeRepository) ReplaceForUser(_ context.Context, userID uint, codeHashes []string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.recoveryCodes.deleteWhere(func(c models.RecoveryCode) bool { return c.UserID == userID })
+	for _, hash := range codeHashes {
+		code := models.RecoveryCode{UserID: userID, CodeHash: hash}
+		stamp(&code.CreatedAt, nil)
+		code.ID = r.s.recoveryCodes.id(0)
+		r.s.recoveryCodes.set(code.ID, code)
+	}
+	return nil
+}
+
+func (r *recoveryCodeRepository) Use(_ context.Context, userID uint, codeHash string, usedAt time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	codes := r.s.recoveryCodes.all(func(c models.RecoveryCode) bool {
+		return c.UserID == userID && c.CodeHash == codeHash && c.UsedAt == nil
+	})
+	for _, code := range codes {
+		used := usedAt
+		code.UsedAt = &used
+		r.s.recoveryCodes.set(code.ID, code)
+	}
+	return len(codes) > 0, nil
+}
+
+func (r *recoveryCodeRepository) DeleteForUser(_ context.Context, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.recoveryCodes.deleteWhere(func(c models.RecoveryCode) bool { return c.UserID == userID })
+	return nil
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+type reportRepository struct {
+	s *Store
+}
+
+func NewReportRepository(s *Store) repositories.ReportRepository {
+	return &reportRepository{s: s}
+}
+
+func (r *reportRepository) Create(_ context.Context, report *models.Report) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if report.Status == "" {
+		report.Status = models.ReportStatusPending
+	}
+	stamp(&report.CreatedAt, nil)
+	report.ID = r.s.reports.id(report.ID)
+	r.s.reports.set(report.ID, *report)
+	return nil
+}
+
+func (r *reportRepository) FindByID(_ context.Context, id uint) (*models.Report, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	report, ok := r.s.reports.get(id)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) FindByStatus(_ context.Context, status string, page models.PageRequest) ([]models.Report, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	reports := r.s.reports.all(func(rp models.Report) bool { return rp.Status == status })
+	return pageOf(reports, page), len(reports), nil
+}
+
+func (r *reportRepository) FindPending(_ context.Context, targetType string, targetID uint) ([]models.Report, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.reports.all(pendingOn(targetType, targetID)), nil
+}
+
+func (r *reportRepository) Resolve(_ context.Context, action *models.ModerationAction) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	status, hidden := models.ReportStatusApproved, false
+	if action.Action == models.ModerationReject {
+		status, hidden = models.ReportStatusRejected, true
+	}
+
+	switch action.TargetType {
+	case models.ReportTargetReview:
+		if review, ok := r.s.reviews.get(action.TargetID); ok {
+			review.Hidden = hidden
+			r.s.reviews.set(review.ID, review)
+		}
+	case models.ReportTargetRecipe:
+		if recipe, ok := r.s.recipes.get(action.TargetID); ok {
+			recipe.Hidden = hidden
+			r.s.recipes.set(recipe.ID, recipe)
+		}
+	case models.ReportTargetAuthorResponse:
+		if review, ok := r.s.reviews.get(action.TargetID); ok {
+			review.AuthorResponseHidden = hidden
+			r.s.reviews.set(review.ID, review)
+		}
+	default:
+		return fmt.Errorf("unknown report target %q", action.TargetType)
+	}
+
+	stamp(&action.CreatedAt, nil)
+	for _, report := range r.s.reports.all(pendingOn(action.TargetType, action.TargetID)) {
+		resolvedAt := action.CreatedAt
+		moderatorID := action.ModeratorID
+		report.Status = status
+		report.ModeratorID = &moderatorID
+		report.Resolution = action.Reason
+		report.ResolvedAt = &resolvedAt
+		r.s.reports.set(report.ID, report)
+	}
+
+	r.s.addModerationAction(action)
+	return nil
+}
+
+// addModerationAction records action in the audit trail.
+func (s *Store) addModerationAction(action *models.ModerationAction) {
+	stamp(&action.CreatedAt, nil)
+	action.ID = s.moderationActions.id(action.ID)
+	s.moderationActions.set(action.ID, *action)
+}
+
+func (r *reportRepository) FindActions(_ context.Context, page models.PageRequest) ([]models.ModerationAction, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	actions := r.s.moderationActions.all(nil)
+	for i, j := 0, len(actions)-1; i < j; i, j = i+1, j-1 {
+		actions[i], actions[j] = actions[j], actions[i]
+	}
+	return pageOf(actions, page), len(actions), nil
+}
+
+func pendingOn(targetType string, targetID uint) func(models.Report) bool {
+	return func(rp models.Report) bool {
+		return rp.TargetType == targetType && rp.TargetID == targetID && rp.Status == models.ReportStatusPending
+	}
+}
@@ -0,0 +1,288 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"sort"
+	"time"
+)
+
+type reviewRepository struct {
+	s *Store
+}
+
+func NewReviewRepository(s *Store) repositories.ReviewRepository {
+	return &reviewRepository{s: s}
+}
+
+func (r *reviewRepository) FindAll(_ context.Context, viewerID uint, page models.PageRequest) ([]models.Review, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	all := r.s.reviews.all(func(rv models.Review) bool { return r.s.reviewVisible(rv, viewerID) })
+	reviews := pageOf(all, page)
+	for i := range reviews {
+		if user, ok := r.s.users.get(reviews[i].UserID); ok {
+			reviews[i].User = r.s.withProfile(user)
+		}
+		if recipe, ok := r.s.recipes.get(reviews[i].RecipeID); ok {
+			recipe.User, _ = r.s.users.get(recipe.UserID)
+			reviews[i].Recipe = recipe
+		}
+		r.s.loadReviewPhotos(&reviews[i])
+	}
+	return reviews, len(all), nil
+}
+
+func (r *reviewRepository) FindByID(_ context.Context, id uint) (*models.Review, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	review, ok := r.s.reviews.get(id)
+	if !ok {
+		return nil, nil
+	}
+	review.User, _ = r.s.users.get(review.UserID)
+	r.s.loadReviewPhotos(&review)
+	return &review, nil
+}
+
+func (r *reviewRepository) FindByIDs(_ context.Context, ids []uint) ([]models.Review, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	set := idSet(ids)
+	return r.s.reviews.all(func(rv models.Review) bool { return set[rv.ID] }), nil
+}
+
+func (r *reviewRepository) FindByRecipeID(_ context.Context, recipeID, viewerID uint, order string, page models.PageRequest) ([]models.Review, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	all := r.s.reviews.all(func(rv models.Review) bool {
+		return rv.RecipeID == recipeID && rv.ParentID == nil && r.s.reviewVisible(rv, viewerID)
+	})
+	switch order {
+	case models.ReviewSortNewest:
+		sort.SliceStable(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+	case models.ReviewSortOldest:
+	default:
+		sort.SliceStable(all, func(i, j int) bool {
+			a, b := all[i], all[j]
+			if sa, sb := a.HelpfulCount-a.UnhelpfulCount, b.HelpfulCount-b.UnhelpfulCount; sa != sb {
+				return sa > sb
+			}
+			if a.HelpfulCount != b.HelpfulCount {
+				return a.HelpfulCount > b.HelpfulCount
+			}
+			return a.ID > b.ID
+		})
+	}
+	reviews := pageOf(all, page)
+
+	parents := make([]uint, 0, len(reviews))
+	for _, review := range reviews {
+		parents = append(parents, review.ID)
+	}
+	for len(parents) > 0 {
+		ids := idSet(parents)
+		replies := r.s.reviews.all(func(rv models.Review) bool {
+			return rv.ParentID != nil && ids[*rv.ParentID] && r.s.reviewVisible(rv, viewerID)
+		})
+		parents = parents[:0]
+		for _, reply := range replies {
+			parents = append(parents, reply.ID)
+		}
+		reviews = append(reviews, replies...)
+	}
+
+	for i := range reviews {
+		if user, ok := r.s.users.get(reviews[i].UserID); ok {
+			reviews[i].User = r.s.withProfile(user)
+		}
+		r.s.loadReviewPhotos(&reviews[i])
+	}
+	return reviews, len(all), nil
+}
+
+func (r *reviewRepository) FindByUserID(_ context.Context, userID, viewerID uint, page models.PageRequest) ([]models.Review, int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	all := r.s.reviews.all(func(rv models.Review) bool { return rv.UserID == userID && r.s.reviewVisible(rv, viewerID) })
+	sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+	reviews := pageOf(all, page)
+	user, _ := r.s.users.get(userID)
+	user = r.s.withProfile(user)
+	for i := range reviews {
+		reviews[i].User = user
+		r.s.loadReviewPhotos(&reviews[i])
+	}
+	return reviews, len(all), nil
+}
+
+// reviewVisible reports whether review is shown to viewerID: it is not hidden and its recipe is
+// not hidden, unless viewerID wrote them.
+func (s *Store) reviewVisible(review models.Review, viewerID uint) bool {
+	recipe, _ := s.recipes.get(review.RecipeID)
+	return (!review.Hidden || review.UserID == viewerID) && (!recipe.Hidden || recipe.UserID == viewerID)
+}
+
+func (r *reviewRepository) Create(_ context.Context, req *models.ReviewRequest) (*models.Review, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	review := models.Review{
+		UserID:   req.UserID,
+		RecipeID: req.RecipeID,
+		ParentID: req.ParentID,
+		Depth:    req.Depth,
+		Hidden:   req.Hidden,
+		Content:  req.Content,
+	}
+	stamp(&review.CreatedAt, &review.UpdatedAt)
+	review.ID = r.s.reviews.id(0)
+	r.s.reviews.set(review.ID, review)
+	for _, url := range req.PhotoURLs {
+		photo := models.ReviewPhoto{ReviewID: review.ID, RecipeID: review.RecipeID, UserID: review.UserID, URL: url}
+		stamp(&photo.CreatedAt, nil)
+		photo.ID = r.s.reviewPhotos.id(0)
+		r.s.reviewPhotos.set(photo.ID, photo)
+		review.Photos = append(review.Photos, photo)
+	}
+	return &review, nil
+}
+
+// UpdateReviewByID updates the non-zero fields of review, like gorm's Updates with a struct.
+func (r *reviewRepository) UpdateReviewByID(_ context.Context, review *models.Review, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row, ok := r.s.reviews.get(id)
+	if !ok {
+		return nil
+	}
+	if review.UserID != 0 {
+		row.UserID = review.UserID
+	}
+	if review.RecipeID != 0 {
+		row.RecipeID = review.RecipeID
+	}
+	if review.Content != "" {
+		row.Content = review.Content
+	}
+	if review.Hidden {
+		row.Hidden = true
+	}
+	if !review.CreatedAt.IsZero() {
+		row.CreatedAt = review.CreatedAt
+	}
+	row.UpdatedAt = now()
+	r.s.reviews.set(id, row)
+	return nil
+}
+
+func (r *reviewRepository) DeleteReviewByID(_ context.Context, id uint) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	ids := map[uint]bool{id: true}
+	for parents := []uint{id}; len(parents) > 0; {
+		var replies []uint
+		for _, reply := range r.s.reviews.all(func(rv models.Review) bool { return rv.ParentID != nil && ids[*rv.ParentID] && !ids[rv.ID] }) {
+			ids[reply.ID] = true
+			replies = append(replies, reply.ID)
+		}
+		parents = replies
+	}
+
+	var photoURLs []string
+	for _, photo := range r.s.reviewPhotos.all(func(p models.ReviewPhoto) bool { return ids[p.ReviewID] }) {
+		photoURLs = append(photoURLs, photo.URL)
+	}
+	r.s.reviewPhotos.deleteWhere(func(p models.ReviewPhoto) bool { return ids[p.ReviewID] })
+	r.s.reviewVotes.deleteWhere(func(v models.ReviewVote) bool { return ids[v.ReviewID] })
+	r.s.reports.deleteWhere(func(rp models.Report) bool { return rp.TargetType != models.ReportTargetRecipe && ids[rp.TargetID] })
+	r.s.reviews.deleteWhere(func(rv models.Review) bool { return ids[rv.ID] })
+	return photoURLs, nil
+}
+
+func (r *reviewRepository) FindPhotosByRecipeID(_ context.Context, recipeID, viewerID uint) ([]models.ReviewPhoto, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	photos := r.s.reviewPhotos.all(func(p models.ReviewPhoto) bool {
+		review, _ := r.s.reviews.get(p.ReviewID)
+		return p.RecipeID == recipeID && (!review.Hidden || review.UserID == viewerID)
+	})
+	for i, j := 0, len(photos)-1; i < j; i, j = i+1, j-1 {
+		photos[i], photos[j] = photos[j], photos[i]
+	}
+	return photos, nil
+}
+
+func (s *Store) loadReviewPhotos(review *models.Review) {
+	review.Photos = s.reviewPhotos.all(func(p models.ReviewPhoto) bool { return p.ReviewID == review.ID })
+}
+
+func (r *reviewRepository) SetAuthorResponse(_ context.Context, id uint, response string, at *time.Time, hidden bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	row, ok := r.s.reviews.get(id)
+	if !ok {
+		return nil
+	}
+	row.AuthorResponse = response
+	row.AuthorResponseAt = at
+	row.AuthorResponseHidden = hidden
+	r.s.reviews.set(id, row)
+	if response == "" {
+		r.s.reports.deleteWhere(func(rp models.Report) bool {
+			return rp.TargetType == models.ReportTargetAuthorResponse && rp.TargetID == id
+		})
+	}
+	return nil
+}
+
+func (r *reviewRepository) SaveVote(_ context.Context, vote *models.ReviewVote) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if existing, ok := r.s.reviewVotes.first(func(v models.ReviewVote) bool {
+		return v.ReviewID == vote.ReviewID && v.UserID == vote.UserID
+	}); ok {
+		vote.ID = existing.ID
+	}
+	vote.ID = r.s.reviewVotes.id(vote.ID)
+	r.s.reviewVotes.set(vote.ID, *vote)
+	r.s.countVotes(vote.ReviewID)
+	return nil
+}
+
+func (r *reviewRepository) DeleteVote(_ context.Context, reviewID, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.reviewVotes.deleteWhere(func(v models.ReviewVote) bool { return v.ReviewID == reviewID && v.UserID == userID })
+	r.s.countVotes(reviewID)
+	return nil
+}
+
+// countVotes stores the number of helpful and unhelpful votes on the review in its counts.
+func (s *Store) countVotes(reviewID uint) {
+	row, ok := s.reviews.get(reviewID)
+	if !ok {
+		return
+	}
+	row.HelpfulCount, row.UnhelpfulCount = 0, 0
+	for _, vote := range s.reviewVotes.all(func(v models.ReviewVote) bool { return v.ReviewID == reviewID }) {
+		if vote.Helpful {
+			row.HelpfulCount++
+		} else {
+			row.UnhelpfulCount++
+		}
+	}
+	s.reviews.set(reviewID, row)
+}
@@ -0,0 +1,169 @@
+// Package memory implements the repositories in memory, for fast tests of the usecases. The
+// repositories of a Store share its tables, so associations are loaded like with gorm, and
+// they satisfy the same contract as the gorm repositories (see package repotest).
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Store holds the tables shared by the repositories created from it.
+type Store struct {
+	mu sync.Mutex
+
+	users             table[models.User]
+	profiles          table[models.Profile]
+	accountDeletions  table[models.AccountDeletion]
+	userTokens        table[models.UserToken]
+	userIdentities    table[models.UserIdentity]
+	loginAttempts     table[models.LoginAttempt]
+	recoveryCodes     table[models.RecoveryCode]
+	recipes           table[models.Recipe]
+	images            table[models.Image]
+	recipeIngredients table[models.RecipeIngredient]
+	tags              table[models.Tag]
+	tagSynonyms       table[models.TagSynonym]
+	reviews           table[models.Review]
+	reviewVotes       table[models.ReviewVote]
+	reviewPhotos      table[models.ReviewPhoto]
+	favorites         table[models.Favorite]
+	reports           table[models.Report]
+	moderationActions table[models.ModerationAction]
+	recipeTags        []models.RecipeTag
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+// NewSet returns every repository, backed by a new Store.
+func NewSet() repositories.Set {
+	s := NewStore()
+	return repositories.Set{
+		Users:          NewUserRepository(s),
+		Profiles:       NewProfileRepository(s),
+		UserTokens:     NewUserTokenRepository(s),
+		UserIdentities: NewUserIdentityRepository(s),
+		LoginAttempts:  NewLoginAttemptRepository(s),
+		RecoveryCodes:  NewRecoveryCodeRepository(s),
+		Recipes:        NewRecipeRepository(s),
+		Reviews:        NewReviewRepository(s),
+		Tags:           NewTagRepository(s),
+		Favorites:      NewFavoriteRepository(s),
+		Reports:        NewReportRepository(s),
+	}
+}
+
+// table is a set of rows keyed by their auto-incremented primary key.
+type table[T any] struct {
+	rows   map[uint]T
+	lastID uint
+}
+
+// id returns id, or allocates the next ID when id is 0 like an auto-incremented column.
+func (t *table[T]) id(id uint) uint {
+	if id == 0 {
+		t.lastID++
+		return t.lastID
+	}
+	if id > t.lastID {
+		t.lastID = id
+	}
+	return id
+}
+
+// set stores row under id, which must come from t.id.
+func (t *table[T]) set(id uint, row T) {
+	if t.rows == nil {
+		t.rows = make(map[uint]T)
+	}
+	t.rows[id] = row
+}
+
+func (t *table[T]) get(id uint) (T, bool) {
+	row, ok := t.rows[id]
+	return row, ok
+}
+
+func (t *table[T]) delete(id uint) {
+	delete(t.rows, id)
+}
+
+// all returns the rows matching keep, or every row when keep is nil, by ascending ID.
+func (t *table[T]) all(keep func(T) bool) []T {
+	ids := make([]uint, 0, len(t.rows))
+	for id := range t.rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	rows := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if row := t.rows[id]; keep == nil || keep(row) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// first returns the matching row with the lowest ID.
+func (t *table[T]) first(keep func(T) bool) (T, bool) {
+	rows := t.all(keep)
+	if len(rows) == 0 {
+		var zero T
+		return zero, false
+	}
+	return rows[0], true
+}
+
+// deleteWhere removes the rows matching keep.
+func (t *table[T]) deleteWhere(keep func(T) bool) {
+	for id, row := range t.rows {
+		if keep(row) {
+			delete(t.rows, id)
+		}
+	}
+}
+
+// now is the time of gorm timestamps, so both implementations can be controlled the same way.
+func now() time.Time {
+	return gorm.NowFunc()
+}
+
+// stamp sets the timestamps of a created row like gorm: only the ones that are still zero.
+func stamp(createdAt, updatedAt *time.Time) {
+	t := now()
+	if createdAt != nil && createdAt.IsZero() {
+		*createdAt = t
+	}
+	if updatedAt != nil && updatedAt.IsZero() {
+		*updatedAt = t
+	}
+}
+
+func duplicate(table, column string, value interface{}) error {
+	return fmt.Errorf("memory: duplicate %s.%s %v", table, column, value)
+}
+
+// idSet returns ids as a set, like the list of an IN condition.
+func idSet(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// pageOf returns the rows on the page, like OFFSET and LIMIT.
+func pageOf[T any](rows []T, page models.PageRequest) []T {
+	start := min(page.Offset(), len(rows))
+	end := min(start+page.WithDefaults().PageSize, len(rows))
+	return rows[start:end]
+}
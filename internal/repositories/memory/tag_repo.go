@@ -0,0 +1,250 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"sort"
+	"strings"
+)
+
+type tagRepository struct {
+	s *Store
+}
+
+func NewTagRepository(s *Store) repositories.TagRepository {
+	return &tagRepository{s: s}
+}
+
+func (r *tagRepository) Create(_ context.Context, tag *models.Tag) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkTag(tag, nil); err != nil {
+		return err
+	}
+	stamp(&tag.CreatedAt, &tag.UpdatedAt)
+	r.s.saveTag(tag)
+	return nil
+}
+
+func (r *tagRepository) GetAllTags(_ context.Context) ([]models.Tag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	tags := r.s.tags.all(nil)
+	for i := range tags {
+		tags[i].Synonyms = r.s.tagSynonyms.all(func(sy models.TagSynonym) bool { return sy.TagID == tags[i].ID })
+	}
+	return tags, nil
+}
+
+func (r *tagRepository) GetTagsByNames(_ context.Context, names []string) ([]models.Tag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[strings.ToLower(name)] = true
+	}
+	ids := map[uint]bool{}
+	for _, synonym := range r.s.tagSynonyms.all(func(sy models.TagSynonym) bool { return wanted[strings.ToLower(sy.Name)] }) {
+		ids[synonym.TagID] = true
+	}
+	return r.s.tags.all(func(t models.Tag) bool { return wanted[strings.ToLower(t.Name)] || ids[t.ID] }), nil
+}
+
+func (r *tagRepository) CountRecipes(_ context.Context) (map[uint]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.countTagRecipes(), nil
+}
+
+// countTagRecipes returns the number of recipes shown to everyone of each tag that has any.
+func (s *Store) countTagRecipes() map[uint]int {
+	seen := map[models.RecipeTag]bool{}
+	counts := make(map[uint]int)
+	for _, rt := range s.recipeTags {
+		if recipe, ok := s.recipes.get(rt.RecipeID); !ok || recipe.Hidden || seen[rt] {
+			continue
+		}
+		seen[rt] = true
+		counts[rt.TagID]++
+	}
+	return counts
+}
+
+func (r *tagRepository) Suggest(_ context.Context, prefix string, limit int) ([]models.Tag, map[uint]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	prefix = strings.ToLower(prefix)
+	matches := func(name string) bool { return strings.HasPrefix(strings.ToLower(name), prefix) }
+	ids := map[uint]bool{}
+	for _, synonym := range r.s.tagSynonyms.all(func(sy models.TagSynonym) bool { return matches(sy.Name) }) {
+		ids[synonym.TagID] = true
+	}
+	tags := r.s.tags.all(func(t models.Tag) bool { return t.Status == models.TagStatusApproved && (matches(t.Name) || ids[t.ID]) })
+
+	counts := r.s.countTagRecipes()
+	sort.SliceStable(tags, func(i, j int) bool {
+		if counts[tags[i].ID] != counts[tags[j].ID] {
+			return counts[tags[i].ID] > counts[tags[j].ID]
+		}
+		return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name)
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	for i := range tags {
+		tags[i].Synonyms = r.s.tagSynonyms.all(func(sy models.TagSynonym) bool { return sy.TagID == tags[i].ID })
+	}
+	return tags, counts, nil
+}
+
+func (r *tagRepository) Update(_ context.Context, tag *models.Tag, action *models.ModerationAction) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkTag(tag, nil); err != nil {
+		return err
+	}
+	r.s.replaceTag(tag)
+	r.s.addModerationAction(action)
+	return nil
+}
+
+func (r *tagRepository) Approve(_ context.Context, id uint, action *models.ModerationAction) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if tag, ok := r.s.tags.get(id); ok {
+		tag.Status = models.TagStatusApproved
+		r.s.tags.set(id, tag)
+	}
+	r.s.addModerationAction(action)
+	return nil
+}
+
+func (r *tagRepository) Merge(_ context.Context, target *models.Tag, sourceIDs []uint, actions []models.ModerationAction) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sources := make(map[uint]bool, len(sourceIDs))
+	for _, id := range sourceIDs {
+		sources[id] = true
+	}
+	if err := r.s.checkTag(target, sources); err != nil {
+		return err
+	}
+
+	var merged []models.RecipeTag
+	for _, rt := range r.s.recipeTags {
+		if sources[rt.TagID] && !r.s.recipeHasTag(rt.RecipeID, target.ID) {
+			merged = append(merged, models.RecipeTag{RecipeID: rt.RecipeID, TagID: target.ID})
+		}
+	}
+	r.s.deleteRecipeTags(func(rt models.RecipeTag) bool { return sources[rt.TagID] })
+	for _, rt := range merged {
+		if !r.s.recipeHasTag(rt.RecipeID, rt.TagID) {
+			r.s.recipeTags = append(r.s.recipeTags, rt)
+		}
+	}
+
+	for _, child := range r.s.tags.all(func(t models.Tag) bool {
+		return t.ParentID != nil && sources[*t.ParentID] && t.ID != target.ID
+	}) {
+		parentID := target.ID
+		child.ParentID = &parentID
+		r.s.tags.set(child.ID, child)
+	}
+	r.s.tagSynonyms.deleteWhere(func(sy models.TagSynonym) bool { return sources[sy.TagID] })
+	r.s.tags.deleteWhere(func(t models.Tag) bool { return sources[t.ID] })
+	r.s.replaceTag(target)
+	for i := range actions {
+		r.s.addModerationAction(&actions[i])
+	}
+	return nil
+}
+
+func (r *tagRepository) Delete(_ context.Context, id uint, action *models.ModerationAction) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, rt := range r.s.recipeTags {
+		if _, ok := r.s.recipes.get(rt.RecipeID); ok && rt.TagID == id {
+			return repositories.ErrTagInUse
+		}
+	}
+
+	r.s.deleteRecipeTags(func(rt models.RecipeTag) bool { return rt.TagID == id })
+	r.s.tagSynonyms.deleteWhere(func(sy models.TagSynonym) bool { return sy.TagID == id })
+	for _, child := range r.s.tags.all(func(t models.Tag) bool { return t.ParentID != nil && *t.ParentID == id }) {
+		child.ParentID = nil
+		r.s.tags.set(child.ID, child)
+	}
+	r.s.tags.delete(id)
+	r.s.addModerationAction(action)
+	return nil
+}
+
+// checkTag enforces the unique indexes on tags.name and tag_synonyms.name, which ignore case,
+// and on tags.slug, ignoring the tags in skip and their synonyms.
+func (s *Store) checkTag(tag *models.Tag, skip map[uint]bool) error {
+	others := func(id uint) bool { return id != tag.ID && !skip[id] }
+	if _, ok := s.tags.first(func(t models.Tag) bool { return strings.EqualFold(t.Name, tag.Name) && others(t.ID) }); ok {
+		return duplicate("tags", "name", tag.Name)
+	}
+	if _, ok := s.tags.first(func(t models.Tag) bool { return t.Slug == tag.Slug && others(t.ID) }); ok {
+		return duplicate("tags", "slug", tag.Slug)
+	}
+	for _, synonym := range tag.Synonyms {
+		if _, ok := s.tagSynonyms.first(func(sy models.TagSynonym) bool {
+			return strings.EqualFold(sy.Name, synonym.Name) && others(sy.TagID)
+		}); ok {
+			return duplicate("tag_synonyms", "name", synonym.Name)
+		}
+	}
+	return nil
+}
+
+// saveTag stores the tag and creates its new synonyms, like gorm saves associations. New tags
+// without a category or status get the column defaults.
+func (s *Store) saveTag(tag *models.Tag) {
+	if tag.ID == 0 && tag.Category == "" {
+		tag.Category = models.TagCategoryOther
+	}
+	if tag.ID == 0 && tag.Status == "" {
+		tag.Status = models.TagStatusApproved
+	}
+	tag.ID = s.tags.id(tag.ID)
+	s.tags.set(tag.ID, tagRow(tag))
+	for i := range tag.Synonyms {
+		synonym := &tag.Synonyms[i]
+		synonym.TagID = tag.ID
+		synonym.ID = s.tagSynonyms.id(synonym.ID)
+		s.tagSynonyms.set(synonym.ID, *synonym)
+	}
+}
+
+// replaceTag saves an existing tag and replaces its synonyms.
+func (s *Store) replaceTag(tag *models.Tag) {
+	if tag.CreatedAt.IsZero() {
+		tag.CreatedAt = now()
+	}
+	tag.UpdatedAt = now()
+	s.tagSynonyms.deleteWhere(func(sy models.TagSynonym) bool { return sy.TagID == tag.ID })
+	for i := range tag.Synonyms {
+		tag.Synonyms[i].ID = 0
+	}
+	s.saveTag(tag)
+}
+
+// tagRow returns the columns of tag, without its associations.
+func tagRow(tag *models.Tag) models.Tag {
+	row := *tag
+	row.Synonyms = nil
+	row.Recipes = nil
+	return row
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+)
+
+type userIdentityRepository struct {
+	s *Store
+}
+
+func NewUserIdentityRepository(s *Store) repositories.UserIdentityRepository {
+	return &userIdentityRepository{s: s}
+}
+
+func (r *userIdentityRepository) FindByProviderSubject(_ context.Context, provider, subject string) (*models.UserIdentity, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	identity, ok := r.s.userIdentities.first(func(i models.UserIdentity) bool {
+		return i.Provider == provider && i.Subject == subject
+	})
+	if !ok {
+		return nil, nil
+	}
+	return &identity, nil
+}
+
+func (r *userIdentityRepository) Create(_ context.Context, identity *models.UserIdentity) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.userIdentities.first(func(i models.UserIdentity) bool {
+		return i.Provider == identity.Provider && i.Subject == identity.Subject
+	}); ok {
+		return duplicate("user_identities", "provider_subject", identity.Provider+"/"+identity.Subject)
+	}
+	stamp(&identity.CreatedAt, &identity.UpdatedAt)
+	identity.ID = r.s.userIdentities.id(identity.ID)
+	r.s.userIdentities.set(identity.ID, *identity)
+	return nil
+}
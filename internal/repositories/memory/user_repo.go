@@ -0,0 +1,230 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type userRepository struct {
+	s *Store
+}
+
+func NewUserRepository(s *Store) repositories.UserRepository {
+	return &userRepository{s: s}
+}
+
+func (r *userRepository) Create(_ context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkUserEmail(user.ID, user.Email); err != nil {
+		return err
+	}
+	stamp(&user.CreatedAt, &user.UpdatedAt)
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	user.ID = r.s.users.id(user.ID)
+	r.s.users.set(user.ID, userRow(user))
+	return nil
+}
+
+func (r *userRepository) FindByID(_ context.Context, id uint) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users.get(id)
+	if !ok {
+		return &models.User{}, gorm.ErrRecordNotFound
+	}
+	user.Profile, _ = r.s.profiles.first(func(p models.Profile) bool { return p.UserID == id })
+	user.Reviews = r.s.reviews.all(func(rv models.Review) bool { return rv.UserID == id })
+	user.Favorites = r.s.favorites.all(func(f models.Favorite) bool { return f.UserID == id })
+	return &user, nil
+}
+
+func (r *userRepository) Update(_ context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := r.s.checkUserEmail(user.ID, user.Email); err != nil {
+		return err
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now()
+	}
+	user.UpdatedAt = now()
+	user.ID = r.s.users.id(user.ID)
+	r.s.users.set(user.ID, userRow(user))
+	return nil
+}
+
+func (r *userRepository) GetUserByEmailOrUsername(_ context.Context, emailOrUsername string) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users.first(func(u models.User) bool {
+		return u.Email == emailOrUsername || u.Username == emailOrUsername
+	})
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (r *userRepository) CheckUserEmail(_ context.Context, email string) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.users.first(func(u models.User) bool { return u.Email == email })
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Delete(_ context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.users.delete(id)
+	return nil
+}
+
+func (r *userRepository) SaveDeletionRequest(_ context.Context, deletion *models.AccountDeletion) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.accountDeletions.first(func(d models.AccountDeletion) bool {
+		return d.UserID == deletion.UserID && d.ID != deletion.ID
+	}); ok {
+		return duplicate("account_deletions", "user_id", deletion.UserID)
+	}
+	if deletion.CreatedAt.IsZero() {
+		deletion.CreatedAt = now()
+	}
+	deletion.UpdatedAt = now()
+	deletion.ID = r.s.accountDeletions.id(deletion.ID)
+	r.s.accountDeletions.set(deletion.ID, *deletion)
+	return nil
+}
+
+func (r *userRepository) FindDeletionRequest(_ context.Context, userID uint) (*models.AccountDeletion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	deletion, ok := r.s.accountDeletions.first(func(d models.AccountDeletion) bool {
+		return d.UserID == userID && d.CompletedAt == nil
+	})
+	if !ok {
+		return nil, nil
+	}
+	return &deletion, nil
+}
+
+func (r *userRepository) DeleteDeletionRequest(_ context.Context, userID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.accountDeletions.deleteWhere(func(d models.AccountDeletion) bool {
+		return d.UserID == userID && d.CompletedAt == nil
+	})
+	return nil
+}
+
+func (r *userRepository) FindDueDeletionRequests(_ context.Context, before time.Time) ([]models.AccountDeletion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.accountDeletions.all(func(d models.AccountDeletion) bool {
+		return d.CompletedAt == nil && !d.ScheduledFor.After(before)
+	}), nil
+}
+
+// Anonymize follows the gorm implementation: reviews are kept, favorites, the profile, tokens,
+// identities and recovery codes are removed and recipes are transferred or deleted with their images, tags, reviews and favorites.
+func (r *userRepository) Anonymize(_ context.Context, deletion *models.AccountDeletion) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	s := r.s
+	userID := deletion.UserID
+	var imageURLs []string
+
+	switch deletion.RecipeAction {
+	case models.RecipeActionTransfer:
+		for _, recipe := range s.recipes.all(func(rc models.Recipe) bool { return rc.UserID == userID }) {
+			recipe.UserID = *deletion.TransferToUserID
+			s.recipes.set(recipe.ID, recipe)
+		}
+	default:
+		recipeIDs := map[uint]bool{}
+		for _, recipe := range s.recipes.all(func(rc models.Recipe) bool { return rc.UserID == userID }) {
+			recipeIDs[recipe.ID] = true
+		}
+		imageURLs = append(imageURLs, s.deleteRecipes(recipeIDs)...)
+	}
+
+	s.favorites.deleteWhere(func(f models.Favorite) bool { return f.UserID == userID })
+	s.userTokens.deleteWhere(func(t models.UserToken) bool { return t.UserID == userID })
+	s.userIdentities.deleteWhere(func(i models.UserIdentity) bool { return i.UserID == userID })
+	s.recoveryCodes.deleteWhere(func(c models.RecoveryCode) bool { return c.UserID == userID })
+	if user, ok := s.users.get(userID); ok {
+		username, email := strings.ToLower(user.Username), strings.ToLower(user.Email)
+		s.loginAttempts.deleteWhere(func(a models.LoginAttempt) bool {
+			return (a.UserID != nil && *a.UserID == userID) || a.Identifier == username || a.Identifier == email
+		})
+	}
+
+	if profile, ok := s.profiles.first(func(p models.Profile) bool { return p.UserID == userID }); ok {
+		if profile.AvatarURL != "" {
+			imageURLs = append(imageURLs, profile.AvatarURL)
+		}
+		s.profiles.delete(profile.ID)
+	}
+
+	t := now()
+	if user, ok := s.users.get(userID); ok {
+		user.Username = models.DeletedUsername
+		user.Email = fmt.Sprintf("deleted-%d@users.invalid", userID)
+		user.Password = ""
+		user.EmailVerifiedAt = nil
+		user.TOTPSecret = ""
+		user.TOTPEnabledAt = nil
+		user.TOTPLastStep = 0
+		user.AnonymizedAt = &t
+		user.UpdatedAt = t
+		s.users.set(userID, user)
+	}
+
+	deletion.CompletedAt = &t
+	deletion.UpdatedAt = t
+	if deletion.ID != 0 {
+		s.accountDeletions.set(deletion.ID, *deletion)
+	}
+
+	return imageURLs, nil
+}
+
+// checkUserEmail enforces the unique index on users.email.
+func (s *Store) checkUserEmail(id uint, email string) error {
+	if _, ok := s.users.first(func(u models.User) bool { return u.Email == email && u.ID != id }); ok {
+		return duplicate("users", "email", email)
+	}
+	return nil
+}
+
+// userRow returns the columns of user, without its associations.
+func userRow(user *models.User) models.User {
+	row := *user
+	row.Profile = models.Profile{}
+	row.Reviews = nil
+	row.Favorites = nil
+	return row
+}
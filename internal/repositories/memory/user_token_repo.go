@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type userTokenRepository struct {
+	s *Store
+}
+
+func NewUserTokenRepository(s *Store) repositories.UserTokenRepository {
+	return &userTokenRepository{s: s}
+}
+
+func (r *userTokenRepository) Create(_ context.Context, token *models.UserToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.userTokens.first(func(t models.UserToken) bool { return t.TokenHash == token.TokenHash }); ok {
+		return duplicate("user_tokens", "token_hash", token.TokenHash)
+	}
+	stamp(&token.CreatedAt, nil)
+	token.ID = r.s.userTokens.id(token.ID)
+	r.s.userTokens.set(token.ID, *token)
+	return nil
+}
+
+func (r *userTokenRepository) FindValid(_ context.Context, purpose, tokenHash string, now time.Time) (*models.UserToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	token, ok := r.s.userTokens.first(func(t models.UserToken) bool {
+		return t.Purpose == purpose && t.TokenHash == tokenHash && t.UsedAt == nil && t.ExpiresAt.After(now)
+	})
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (r *userTokenRepository) MarkUsed(_ context.Context, id uint, usedAt time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	token, ok := r.s.userTokens.get(id)
+	if !ok || token.UsedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	token.UsedAt = &usedAt
+	r.s.userTokens.set(id, token)
+	return nil
+}
+
+func (r *userTokenRepository) InvalidateForUser(_ context.Context, userID uint, purpose string, now time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, token := range r.s.userTokens.all(func(t models.UserToken) bool {
+		return t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil
+	}) {
+		usedAt := now
+		token.UsedAt = &usedAt
+		r.s.userTokens.set(token.ID, token)
+	}
+	return nil
+}
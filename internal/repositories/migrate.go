@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"api-culinary-review/internal/models"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// MigrateTagNames merges the tags whose names differ only by case, created before names were
+// unique ignoring case, into the oldest of them and drops the synonyms that repeat a tag name or
+// an older synonym. Unique indexes on the lower-cased names then keep them apart.
+//
+// The migration runs once: it does nothing when the indexes exist. Each merge is recorded in the
+// audit trail with moderator ID 0, as no moderator decided it.
+func MigrateTagNames(ctx context.Context, db *gorm.DB) error {
+	if db.Dialect().HasIndex("tags", "idx_tags_name_lower") && db.Dialect().HasIndex("tag_synonyms", "idx_tag_synonyms_name_lower") {
+		return nil
+	}
+
+	repo := NewTagRepository(db)
+	tags, err := repo.GetAllTags(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate tag names: %w", err)
+	}
+	var names []string
+	byName := map[string][]models.Tag{}
+	for _, tag := range tags {
+		name := strings.ToLower(tag.Name)
+		if byName[name] == nil {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], tag)
+	}
+	for _, name := range names {
+		if same := byName[name]; len(same) > 1 {
+			if err := mergeTagNames(ctx, repo, same[0], same[1:]); err != nil {
+				return fmt.Errorf("migrate tag names: merge tags into %q: %w", same[0].Name, err)
+			}
+		}
+	}
+
+	for _, statement := range []string{
+		"DELETE FROM tag_synonyms WHERE LOWER(name) IN (SELECT LOWER(name) FROM tags)",
+		"DELETE FROM tag_synonyms WHERE id NOT IN (SELECT MIN(id) FROM tag_synonyms GROUP BY LOWER(name))",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name_lower ON tags (LOWER(name))",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_tag_synonyms_name_lower ON tag_synonyms (LOWER(name))",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("migrate tag names: %w", err)
+		}
+	}
+	return nil
+}
+
+// mergeTagNames merges the sources into target with TagRepository.Merge. Target gets the
+// synonyms of the sources it lacks and, when below a source, takes its place in the hierarchy.
+func mergeTagNames(ctx context.Context, repo TagRepository, target models.Tag, sources []models.Tag) error {
+	known := map[string]bool{strings.ToLower(target.Name): true}
+	for _, synonym := range target.Synonyms {
+		known[strings.ToLower(synonym.Name)] = true
+	}
+	merged := map[uint]models.Tag{}
+	ids := make([]uint, 0, len(sources))
+	actions := make([]models.ModerationAction, 0, len(sources))
+	for _, source := range sources {
+		for _, synonym := range source.Synonyms {
+			if !known[strings.ToLower(synonym.Name)] {
+				known[strings.ToLower(synonym.Name)] = true
+				target.Synonyms = append(target.Synonyms, models.TagSynonym{Name: synonym.Name})
+			}
+		}
+		merged[source.ID] = source
+		ids = append(ids, source.ID)
+		actions = append(actions, models.ModerationAction{
+			Action:     models.ModerationMerge,
+			TargetType: models.ModerationTargetTag,
+			TargetID:   source.ID,
+			Reason:     fmt.Sprintf("merged tag %q into tag %q (ID %d), whose name differs only by case", source.Name, target.Name, target.ID),
+			CreatedAt:  time.Now(),
+		})
+	}
+	for i := 0; target.ParentID != nil && i <= len(sources); i++ {
+		source, ok := merged[*target.ParentID]
+		if !ok {
+			break
+		}
+		target.ParentID = source.ParentID
+	}
+	return repo.Merge(ctx, &target, ids, actions)
+}
@@ -4,14 +4,16 @@ package repositories
 
 import (
 	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
 
 	"github.com/jinzhu/gorm"
 )
 
 type ProfileRepository interface {
-	CreateProfile(profile *models.Profile) error
-	GetProfileByUserID(userID uint) (*models.Profile, error)
-	UpdateProfile(profile *models.Profile) error
+	CreateProfile(ctx context.Context, profile *models.Profile) error
+	GetProfileByUserID(ctx context.Context, userID uint) (*models.Profile, error)
+	UpdateProfile(ctx context.Context, profile *models.Profile) error
 }
 
 type profileRepository struct {
@@ -22,16 +24,16 @@ func NewProfileRepository(db *gorm.DB) ProfileRepository {
 	return &profileRepository{db: db}
 }
 
-func (r *profileRepository) CreateProfile(profile *models.Profile) error {
-	return r.db.Create(profile).Error
+func (r *profileRepository) CreateProfile(ctx context.Context, profile *models.Profile) error {
+	return database.WithContext(ctx, r.db).Create(profile).Error
 }
 
-func (r *profileRepository) GetProfileByUserID(userID uint) (*models.Profile, error) {
+func (r *profileRepository) GetProfileByUserID(ctx context.Context, userID uint) (*models.Profile, error) {
 	var profile models.Profile
-	err := r.db.Where("user_id = ?", userID).First(&profile).Error
+	err := database.WithContext(ctx, r.db).Where("user_id = ?", userID).First(&profile).Error
 	return &profile, err
 }
 
-func (r *profileRepository) UpdateProfile(profile *models.Profile) error {
-	return r.db.Save(profile).Error
+func (r *profileRepository) UpdateProfile(ctx context.Context, profile *models.Profile) error {
+	return database.WithContext(ctx, r.db).Save(profile).Error
 }
@@ -2,20 +2,29 @@ package repositories
 
 import (
 	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
 
 	"github.com/jinzhu/gorm"
 )
 
 type RecipeRepository interface {
-	CreateRecipe(recipe *models.Recipe) (*models.Recipe, error)
-	GetRecipeByID(id uint) (*models.Recipe, error)
-	GetRecipes() ([]*models.Recipe, error)
-	UpdateRecipe(recipe *models.Recipe) (*models.Recipe, error)
-	DeleteRecipe(id uint) error
-	CreateRecipeTag(recipeId uint, tagId uint) error
-	RecipeTagExists(tagId uint) (bool, error)
-	DeleteRecipeTagsByRecipeID(recipeID uint) error
-	DeleteRecipeImages(recipeID uint) error
+	CreateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error)
+	GetRecipeByID(ctx context.Context, id uint) (*models.Recipe, error)
+	// GetRecipesByIDs returns the recipes with an ID in ids, without their associations, by
+	// ascending ID. IDs of missing recipes are skipped.
+	GetRecipesByIDs(ctx context.Context, ids []uint) ([]models.Recipe, error)
+	GetRecipes(ctx context.Context, filter models.RecipeFilter) ([]*models.Recipe, error)
+	UpdateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error)
+	// ReplaceRecipe saves recipe with its ingredients, images and the tags in tagIDs in place of
+	// the stored ones, in a single transaction.
+	ReplaceRecipe(ctx context.Context, recipe *models.Recipe, tagIDs []uint) (*models.Recipe, error)
+	// DeleteRecipe deletes the recipe with its ingredients, tags, images, favorites, reviews and
+	// the reports on it and its reviews in a single transaction. The URLs of its images and of the
+	// photos of its reviews are returned so they can be removed from storage.
+	DeleteRecipe(ctx context.Context, id uint) ([]string, error)
+	CreateRecipeTag(ctx context.Context, recipeId uint, tagId uint) error
+	RecipeTagExists(ctx context.Context, tagId uint) (bool, error)
 }
 
 type recipeRepository struct {
@@ -26,17 +35,17 @@ func NewRecipeRepository(db *gorm.DB) RecipeRepository {
 	return &recipeRepository{db: db}
 }
 
-func (r *recipeRepository) CreateRecipe(recipe *models.Recipe) (*models.Recipe, error) {
-	err := r.db.Create(recipe).Error
+func (r *recipeRepository) CreateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error) {
+	err := database.WithContext(ctx, r.db).Create(recipe).Error
 	return recipe, err
 }
 
-func (r *recipeRepository) GetRecipeByID(id uint) (*models.Recipe, error) {
+func (r *recipeRepository) GetRecipeByID(ctx context.Context, id uint) (*models.Recipe, error) {
 	var recipe models.Recipe
-	err := r.db.Preload("User.Profile").
+	err := database.WithContext(ctx, r.db).Preload("User.Profile").
 		Preload("Tags").
 		Preload("Images").
-		Preload("Reviews.User.Profile").
+		Preload("IngredientList", orderByID).
 		First(&recipe, id).Error
 	if err != nil {
 		return nil, err
@@ -44,42 +53,134 @@ func (r *recipeRepository) GetRecipeByID(id uint) (*models.Recipe, error) {
 	return &recipe, nil
 }
 
-func (r *recipeRepository) GetRecipes() ([]*models.Recipe, error) {
+func (r *recipeRepository) GetRecipesByIDs(ctx context.Context, ids []uint) ([]models.Recipe, error) {
+	var recipes []models.Recipe
+	err := database.WithContext(ctx, r.db).Where("id IN (?)", ids).Order("id").Find(&recipes).Error
+	return recipes, err
+}
+
+// GetRecipes returns the recipes matching filter.
+func (r *recipeRepository) GetRecipes(ctx context.Context, filter models.RecipeFilter) ([]*models.Recipe, error) {
+	db := database.WithContext(ctx, r.db)
+	if filter.ExcludeAllergens != 0 {
+		db = db.Where("(allergens & ?) = 0", uint(filter.ExcludeAllergens))
+	}
+	if filter.Diets != 0 {
+		db = db.Where("(diets & ?) = ?", uint(filter.Diets), uint(filter.Diets))
+	}
+	db = db.Where("hidden = ? OR user_id = ?", false, filter.ViewerID)
+
 	var recipes []*models.Recipe
-	err := r.db.Preload("Tags").Preload("Images").Find(&recipes).Error
+	err := db.Preload("Tags").Preload("Images").Preload("IngredientList", orderByID).Order("id").Find(&recipes).Error
 	return recipes, err
 }
 
-func (r *recipeRepository) UpdateRecipe(recipe *models.Recipe) (*models.Recipe, error) {
-	err := r.db.Save(recipe).Error
+func (r *recipeRepository) UpdateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error) {
+	err := database.WithContext(ctx, r.db).Save(recipe).Error
 	return recipe, err
 }
 
-func (r *recipeRepository) DeleteRecipe(id uint) error {
-	return r.db.Delete(&models.Recipe{}, id).Error
+func (r *recipeRepository) ReplaceRecipe(ctx context.Context, recipe *models.Recipe, tagIDs []uint) (*models.Recipe, error) {
+	err := transaction(database.WithContext(ctx, r.db), func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.RecipeIngredient{}, &models.Image{}, &models.RecipeTag{}} {
+			if err := tx.Where("recipe_id = ?", recipe.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		for _, tagID := range tagIDs {
+			if err := tx.Create(&models.RecipeTag{RecipeID: recipe.ID, TagID: tagID}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range recipe.IngredientList {
+			recipe.IngredientList[i].ID = 0
+		}
+		for i := range recipe.Images {
+			recipe.Images[i].ID = 0
+		}
+		// Saving the loaded tags would link them again
+		recipe.Tags = nil
+		if err := tx.Save(recipe).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN (?)", tagIDs).Order("id").Find(&recipe.Tags).Error
+	})
+	return recipe, err
 }
 
-func (r *recipeRepository) CreateRecipeTag(recipeId uint, tagId uint) error {
+func (r *recipeRepository) DeleteRecipe(ctx context.Context, id uint) ([]string, error) {
+	var imageURLs []string
+	err := transaction(database.WithContext(ctx, r.db), func(tx *gorm.DB) error {
+		var err error
+		imageURLs, err = deleteRecipes(tx, []uint{id})
+		return err
+	})
+	return imageURLs, err
+}
+
+func (r *recipeRepository) CreateRecipeTag(ctx context.Context, recipeId uint, tagId uint) error {
 	recipeTag := &models.RecipeTag{
 		RecipeID: recipeId,
 		TagID:    tagId,
 	}
-	return r.db.Create(recipeTag).Error
+	return database.WithContext(ctx, r.db).Create(recipeTag).Error
 }
 
-func (r *recipeRepository) RecipeTagExists(tagId uint) (bool, error) {
+func (r *recipeRepository) RecipeTagExists(ctx context.Context, tagId uint) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.Tag{}).Where("id = ?", tagId).Count(&count).Error
+	err := database.WithContext(ctx, r.db).Model(&models.Tag{}).Where("id = ?", tagId).Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func (r *recipeRepository) DeleteRecipeTagsByRecipeID(recipeID uint) error {
-	return r.db.Where("recipe_id = ?", recipeID).Delete(&models.RecipeTag{}).Error
+func orderByID(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
 }
 
-func (r *recipeRepository) DeleteRecipeImages(recipeID uint) error {
-	return r.db.Where("recipe_id = ?", recipeID).Delete(&models.Image{}).Error
+// deleteRecipes deletes the recipes in recipeIDs, a list or a subquery of IDs, with the rows that
+// refer to them. gorm does not create foreign keys, so nothing is deleted by the database. The
+// URLs of the deleted images and review photos are returned so they can be removed from storage.
+func deleteRecipes(tx *gorm.DB, recipeIDs interface{}) ([]string, error) {
+	var imageURLs []string
+
+	var images []models.Image
+	if err := tx.Where("recipe_id IN (?)", recipeIDs).Order("id").Find(&images).Error; err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		imageURLs = append(imageURLs, image.URL)
+	}
+
+	var photos []models.ReviewPhoto
+	if err := tx.Where("recipe_id IN (?)", recipeIDs).Order("id").Find(&photos).Error; err != nil {
+		return nil, err
+	}
+	for _, photo := range photos {
+		imageURLs = append(imageURLs, photo.URL)
+	}
+
+	reviewIDs := tx.Model(&models.Review{}).Select("id").Where("recipe_id IN (?)", recipeIDs).QueryExpr()
+	if err := tx.Where("review_id IN (?)", reviewIDs).Delete(&models.ReviewVote{}).Error; err != nil {
+		return nil, err
+	}
+	for _, targetType := range []string{models.ReportTargetReview, models.ReportTargetAuthorResponse} {
+		if err := deleteReports(tx, targetType, reviewIDs); err != nil {
+			return nil, err
+		}
+	}
+	if err := deleteReports(tx, models.ReportTargetRecipe, recipeIDs); err != nil {
+		return nil, err
+	}
+	for _, model := range []interface{}{&models.Image{}, &models.RecipeIngredient{}, &models.RecipeTag{}, &models.ReviewPhoto{},
+		&models.Review{}, &models.Favorite{}} {
+		if err := tx.Where("recipe_id IN (?)", recipeIDs).Delete(model).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Where("id IN (?)", recipeIDs).Delete(&models.Recipe{}).Error; err != nil {
+		return nil, err
+	}
+	return imageURLs, nil
 }
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceForUser(ctx context.Context, userID uint, codeHashes []string) error
+	Use(ctx context.Context, userID uint, codeHash string, usedAt time.Time) (bool, error)
+	DeleteForUser(ctx context.Context, userID uint) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+// ReplaceForUser removes all recovery codes of the user and stores the new ones.
+func (r *recoveryCodeRepository) ReplaceForUser(ctx context.Context, userID uint, codeHashes []string) error {
+	return transaction(database.WithContext(ctx, r.db), func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, hash := range codeHashes {
+			if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: hash}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Use consumes an unused recovery code, reporting whether one matched.
+func (r *recoveryCodeRepository) Use(ctx context.Context, userID uint, codeHash string, usedAt time.Time) (bool, error) {
+	res := database.WithContext(ctx, r.db).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", usedAt)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *recoveryCodeRepository) DeleteForUser(ctx context.Context, userID uint) error {
+	return database.WithContext(ctx, r.db).Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
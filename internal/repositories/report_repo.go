@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+type ReportRepository interface {
+	Create(ctx context.Context, report *models.Report) error
+	// FindByID returns gorm.ErrRecordNotFound when there is no report with the ID.
+	FindByID(ctx context.Context, id uint) (*models.Report, error)
+	// FindByStatus returns the page of the reports with the status by ascending ID, with the number
+	// of reports with the status.
+	FindByStatus(ctx context.Context, status string, page models.PageRequest) ([]models.Report, int, error)
+	// FindPending returns the pending reports on a review or recipe by ascending ID.
+	FindPending(ctx context.Context, targetType string, targetID uint) ([]models.Report, error)
+	// Resolve closes the pending reports on the target of action with its decision, shows the
+	// target when it is approved and hides it when it is rejected, and records action in the audit
+	// trail, in one transaction.
+	Resolve(ctx context.Context, action *models.ModerationAction) error
+	// FindActions returns the page of the audit trail, newest first, with the number of entries.
+	FindActions(ctx context.Context, page models.PageRequest) ([]models.ModerationAction, int, error)
+}
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+func (r *reportRepository) Create(ctx context.Context, report *models.Report) error {
+	return database.WithContext(ctx, r.db).Create(report).Error
+}
+
+func (r *reportRepository) FindByID(ctx context.Context, id uint) (*models.Report, error) {
+	var report models.Report
+	if err := database.WithContext(ctx, r.db).First(&report, id).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) FindByStatus(ctx context.Context, status string, page models.PageRequest) ([]models.Report, int, error) {
+	db := database.WithContext(ctx, r.db).Model(&models.Report{}).Where("status = ?", status)
+	var total int
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var reports []models.Report
+	err := db.Order("id").Scopes(pageOf(page)).Find(&reports).Error
+	return reports, total, err
+}
+
+func (r *reportRepository) FindPending(ctx context.Context, targetType string, targetID uint) ([]models.Report, error) {
+	var reports []models.Report
+	err := database.WithContext(ctx, r.db).Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReportStatusPending).
+		Order("id").Find(&reports).Error
+	return reports, err
+}
+
+func (r *reportRepository) Resolve(ctx context.Context, action *models.ModerationAction) error {
+	status, hidden := models.ReportStatusApproved, false
+	if action.Action == models.ModerationReject {
+		status, hidden = models.ReportStatusRejected, true
+	}
+
+	var target interface{}
+	column := "hidden"
+	switch action.TargetType {
+	case models.ReportTargetReview:
+		target = &models.Review{}
+	case models.ReportTargetRecipe:
+		target = &models.Recipe{}
+	case models.ReportTargetAuthorResponse:
+		target, column = &models.Review{}, "author_response_hidden"
+	default:
+		return fmt.Errorf("unknown report target %q", action.TargetType)
+	}
+
+	return transaction(database.WithContext(ctx, r.db), func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", action.TargetType, action.TargetID, models.ReportStatusPending).
+			UpdateColumns(map[string]interface{}{
+				"status":       status,
+				"moderator_id": action.ModeratorID,
+				"resolution":   action.Reason,
+				"resolved_at":  action.CreatedAt,
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(target).Where("id = ?", action.TargetID).UpdateColumn(column, hidden).Error; err != nil {
+			return err
+		}
+		return tx.Create(action).Error
+	})
+}
+
+func (r *reportRepository) FindActions(ctx context.Context, page models.PageRequest) ([]models.ModerationAction, int, error) {
+	db := database.WithContext(ctx, r.db).Model(&models.ModerationAction{})
+	var total int
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var actions []models.ModerationAction
+	err := db.Order("id DESC").Scopes(pageOf(page)).Find(&actions).Error
+	return actions, total, err
+}
+
+// deleteReports deletes the reports on the targets of targetType with an ID in targetIDs.
+func deleteReports(tx *gorm.DB, targetType string, targetIDs interface{}) error {
+	return tx.Where("target_type = ? AND target_id IN (?)", targetType, targetIDs).Delete(&models.Report{}).Error
+}
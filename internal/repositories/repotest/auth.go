@@ -0,0 +1,189 @@
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+func testUserTokens(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	now := time.Now()
+	alice := createUser(t, repos, "alice")
+
+	token := &models.UserToken{UserID: alice.ID, Purpose: models.TokenPurposeVerifyEmail, TokenHash: "hash-1", ExpiresAt: now.Add(time.Hour)}
+	expired := &models.UserToken{UserID: alice.ID, Purpose: models.TokenPurposeVerifyEmail, TokenHash: "hash-2", ExpiresAt: now.Add(-time.Minute)}
+	for _, tok := range []*models.UserToken{token, expired} {
+		if err := repos.UserTokens.Create(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.UserTokens.Create(ctx, &models.UserToken{UserID: alice.ID, Purpose: models.TokenPurposePasswordReset, TokenHash: "hash-1", ExpiresAt: now}); err == nil {
+		t.Error("Create accepted a duplicate token hash")
+	}
+
+	found, err := repos.UserTokens.FindValid(ctx, models.TokenPurposeVerifyEmail, "hash-1", now)
+	if err != nil || found == nil || found.ID != token.ID {
+		t.Fatalf("FindValid = %v, %v, want the token", found, err)
+	}
+	for _, tc := range []struct{ purpose, hash string }{
+		{models.TokenPurposePasswordReset, "hash-1"},
+		{models.TokenPurposeVerifyEmail, "hash-2"},
+		{models.TokenPurposeVerifyEmail, "missing"},
+	} {
+		if found, err := repos.UserTokens.FindValid(ctx, tc.purpose, tc.hash, now); found != nil || err != nil {
+			t.Errorf("FindValid(%s, %s) = %v, %v, want nil, nil", tc.purpose, tc.hash, found, err)
+		}
+	}
+
+	if err := repos.UserTokens.MarkUsed(ctx, token.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.UserTokens.MarkUsed(ctx, token.ID, now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("MarkUsed of a used token: err = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if found, _ := repos.UserTokens.FindValid(ctx, models.TokenPurposeVerifyEmail, "hash-1", now); found != nil {
+		t.Errorf("FindValid of a used token = %v, want nil", found)
+	}
+
+	reset := &models.UserToken{UserID: alice.ID, Purpose: models.TokenPurposePasswordReset, TokenHash: "hash-3", ExpiresAt: now.Add(time.Hour)}
+	verify := &models.UserToken{UserID: alice.ID, Purpose: models.TokenPurposeVerifyEmail, TokenHash: "hash-4", ExpiresAt: now.Add(time.Hour)}
+	for _, tok := range []*models.UserToken{reset, verify} {
+		if err := repos.UserTokens.Create(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.UserTokens.InvalidateForUser(ctx, alice.ID, models.TokenPurposePasswordReset, now); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := repos.UserTokens.FindValid(ctx, models.TokenPurposePasswordReset, "hash-3", now); found != nil {
+		t.Errorf("FindValid of an invalidated token = %v, want nil", found)
+	}
+	if found, _ := repos.UserTokens.FindValid(ctx, models.TokenPurposeVerifyEmail, "hash-4", now); found == nil {
+		t.Error("InvalidateForUser invalidated a token of another purpose")
+	}
+}
+
+func testUserIdentities(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+
+	if found, err := repos.UserIdentities.FindByProviderSubject(ctx, "google", "123"); found != nil || err != nil {
+		t.Fatalf("FindByProviderSubject of a missing identity = %v, %v, want nil, nil", found, err)
+	}
+
+	identity := &models.UserIdentity{UserID: alice.ID, Provider: "google", Subject: "123", Email: alice.Email}
+	if err := repos.UserIdentities.Create(ctx, identity); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.UserIdentities.Create(ctx, &models.UserIdentity{UserID: alice.ID, Provider: "google", Subject: "123"}); err == nil {
+		t.Error("Create accepted a duplicate provider subject")
+	}
+	if err := repos.UserIdentities.Create(ctx, &models.UserIdentity{UserID: alice.ID, Provider: "github", Subject: "123"}); err != nil {
+		t.Errorf("Create of the same subject at another provider: %v", err)
+	}
+
+	found, err := repos.UserIdentities.FindByProviderSubject(ctx, "google", "123")
+	if err != nil || found == nil || found.ID != identity.ID || found.UserID != alice.ID {
+		t.Errorf("FindByProviderSubject = %v, %v, want the identity", found, err)
+	}
+}
+
+func testLoginAttempts(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+	alice := createUser(t, repos, "alice")
+
+	attempts := []struct {
+		identifier string
+		ip         string
+		result     string
+		after      time.Duration
+	}{
+		{"alice", "10.0.0.1", models.LoginResultInvalidPassword, time.Minute},
+		{"alice", "10.0.0.1", models.LoginResultSuccess, 2 * time.Minute},
+		{"alice@example.com", "10.0.0.1", models.LoginResultInvalidPassword, 3 * time.Minute},
+		{"alice", "10.0.0.2", models.LoginResultInvalidCode, 4 * time.Minute},
+		{"alice", "10.0.0.2", models.LoginResultLocked, 5 * time.Minute},
+		{"alice", "10.0.0.1", models.LoginResultTwoFactorRequired, 6 * time.Minute},
+		{"ghost", "10.0.0.1", models.LoginResultUnknownUser, 7 * time.Minute},
+	}
+	for _, a := range attempts {
+		attempt := &models.LoginAttempt{Identifier: a.identifier, IP: a.ip, Result: a.result, CreatedAt: start.Add(a.after)}
+		if a.identifier != "ghost" {
+			attempt.UserID = &alice.ID
+		}
+		if err := repos.LoginAttempts.Create(ctx, attempt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, last, err := repos.LoginAttempts.CountFailuresByUser(ctx, alice.ID, start)
+	if err != nil || count != 2 || !last.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("CountFailuresByUser = %d, %v, %v, want the 2 failures after the success, by email or username", count, last, err)
+	}
+	count, last, err = repos.LoginAttempts.CountFailuresByIdentifier(ctx, "ghost", start)
+	if err != nil || count != 1 || !last.Equal(start.Add(7*time.Minute)) {
+		t.Errorf("CountFailuresByIdentifier = %d, %v, %v, want the unknown user", count, last, err)
+	}
+	count, last, err = repos.LoginAttempts.CountFailuresByIP(ctx, "10.0.0.1", start)
+	if err != nil || count != 3 || !last.Equal(start.Add(7*time.Minute)) {
+		t.Errorf("CountFailuresByIP = %d, %v, %v, want the 3 failures, including the one before the success", count, last, err)
+	}
+	count, last, err = repos.LoginAttempts.CountFailuresByIP(ctx, "10.0.0.2", start)
+	if err != nil || count != 1 || !last.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("CountFailuresByIP = %d, %v, %v, want the invalid code", count, last, err)
+	}
+	count, _, err = repos.LoginAttempts.CountFailuresByIP(ctx, "10.0.0.1", start.Add(5*time.Minute))
+	if err != nil || count != 1 {
+		t.Errorf("CountFailuresByIP since a later time = %d, %v, want 1", count, err)
+	}
+	count, last, err = repos.LoginAttempts.CountFailuresByIP(ctx, "10.0.0.3", start)
+	if err != nil || count != 0 || !last.IsZero() {
+		t.Errorf("CountFailuresByIP of an unknown IP = %d, %v, %v, want 0", count, last, err)
+	}
+}
+
+func testRecoveryCodes(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	now := time.Now()
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+
+	if err := repos.RecoveryCodes.ReplaceForUser(ctx, alice.ID, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.RecoveryCodes.ReplaceForUser(ctx, bob.ID, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	use := func(userID uint, hash string, want bool) {
+		t.Helper()
+		if used, err := repos.RecoveryCodes.Use(ctx, userID, hash, now); err != nil || used != want {
+			t.Errorf("Use(%d, %s) = %v, %v, want %v", userID, hash, used, err, want)
+		}
+	}
+	use(alice.ID, "a", true)
+	use(alice.ID, "a", false)
+	use(bob.ID, "a", true)
+	use(alice.ID, "c", false)
+
+	if err := repos.RecoveryCodes.ReplaceForUser(ctx, alice.ID, []string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+	use(alice.ID, "b", false)
+	use(alice.ID, "c", true)
+
+	if err := repos.RecoveryCodes.ReplaceForUser(ctx, alice.ID, []string{"d"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.RecoveryCodes.DeleteForUser(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	use(alice.ID, "d", false)
+}
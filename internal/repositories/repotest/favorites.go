@@ -0,0 +1,75 @@
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"testing"
+)
+
+func testFavorites(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+	soup := createRecipe(t, repos, alice.ID, "soup")
+	stew := createRecipe(t, repos, alice.ID, "stew")
+
+	if found, err := repos.Favorites.GetByUserID(ctx, bob.ID); err != nil || len(found) != 0 {
+		t.Fatalf("GetByUserID of a user without favorites = %v, %v, want none", found, err)
+	}
+
+	var favorites []*models.Favorite
+	for _, f := range []struct{ userID, recipeID uint }{{bob.ID, soup.ID}, {bob.ID, stew.ID}, {alice.ID, soup.ID}} {
+		favorite, err := repos.Favorites.Add(ctx, f.userID, f.recipeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if favorite.ID == 0 || favorite.CreatedAt.IsZero() || favorite.UserID != f.userID || favorite.RecipeID != f.recipeID {
+			t.Fatalf("Add = %+v, want a new favorite with its ID and timestamps", favorite)
+		}
+		favorites = append(favorites, favorite)
+	}
+
+	found, err := repos.Favorites.GetByUserID(ctx, bob.ID)
+	if err != nil || len(found) != 2 || found[0].RecipeID != soup.ID || found[1].RecipeID != stew.ID {
+		t.Fatalf("GetByUserID = %v, %v, want the favorites of bob", found, err)
+	}
+	if found[0].Recipe.Title != "soup" || len(found[0].Recipe.Images) != 1 || found[0].Recipe.Images[0].URL != soup.Images[0].URL {
+		t.Errorf("GetByUserID loaded the recipe %+v, want soup with its image", found[0].Recipe)
+	}
+
+	counts, err := repos.Favorites.CountByRecipeIDs(ctx, []uint{soup.ID, stew.ID, stew.ID + 100})
+	if err != nil || len(counts) != 2 || counts[soup.ID] != 2 || counts[stew.ID] != 1 {
+		t.Errorf("CountByRecipeIDs = %v, %v, want 2 for soup and 1 for stew", counts, err)
+	}
+	favorited, err := repos.Favorites.FavoritedRecipeIDs(ctx, alice.ID, []uint{soup.ID, stew.ID})
+	if err != nil || len(favorited) != 1 || !favorited[soup.ID] {
+		t.Errorf("FavoritedRecipeIDs of alice = %v, %v, want only soup", favorited, err)
+	}
+
+	again, err := repos.Favorites.Add(ctx, alice.ID, soup.ID)
+	if err != nil || again.ID != favorites[2].ID {
+		t.Errorf("Add of an existing favorite = %+v, %v, want the favorite of alice", again, err)
+	}
+	added, err := repos.Favorites.Add(ctx, alice.ID, stew.ID)
+	if err != nil || added.ID == 0 || added.UserID != alice.ID || added.RecipeID != stew.ID || added.CreatedAt.IsZero() {
+		t.Fatalf("Add = %+v, %v, want a new favorite of alice on stew", added, err)
+	}
+	if err := repos.Favorites.Remove(ctx, alice.ID, stew.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Favorites.Remove(ctx, alice.ID, stew.ID); err != nil {
+		t.Errorf("Remove of a missing favorite: err = %v, want nil", err)
+	}
+	if found, _ := repos.Favorites.GetByUserID(ctx, alice.ID); len(found) != 1 || found[0].ID != favorites[2].ID {
+		t.Errorf("GetByUserID of alice after Add and Remove = %v, want only soup", found)
+	}
+
+	if err := repos.Favorites.Remove(ctx, bob.ID, soup.ID); err != nil {
+		t.Fatal(err)
+	}
+	found, err = repos.Favorites.GetByUserID(ctx, bob.ID)
+	if err != nil || len(found) != 1 || found[0].RecipeID != stew.ID {
+		t.Errorf("GetByUserID of bob after Remove = %v, %v, want only stew", found, err)
+	}
+}
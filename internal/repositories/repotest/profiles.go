@@ -0,0 +1,46 @@
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func testProfiles(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+
+	if _, err := repos.Profiles.GetProfileByUserID(ctx, alice.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("GetProfileByUserID without a profile: err = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	profile := &models.Profile{UserID: alice.ID, FullName: "Alice", Bio: "cook"}
+	if err := repos.Profiles.CreateProfile(ctx, profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.ID == 0 || profile.CreatedAt.IsZero() {
+		t.Fatalf("CreateProfile did not set the ID and timestamps: %+v", profile)
+	}
+	if err := repos.Profiles.CreateProfile(ctx, &models.Profile{UserID: alice.ID}); err == nil {
+		t.Error("CreateProfile accepted a second profile for the user")
+	}
+
+	profile.Bio = "chef"
+	profile.Diets = models.DietVegan | models.DietHalal
+	profile.AvoidAllergens = models.AllergenNuts
+	if err := repos.Profiles.UpdateProfile(ctx, profile); err != nil {
+		t.Fatal(err)
+	}
+	found, err := repos.Profiles.GetProfileByUserID(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != profile.ID || found.FullName != "Alice" || found.Bio != "chef" ||
+		found.Diets != profile.Diets || found.AvoidAllergens != models.AllergenNuts {
+		t.Errorf("GetProfileByUserID after UpdateProfile = %+v", found)
+	}
+}
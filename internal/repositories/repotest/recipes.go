@@ -0,0 +1,222 @@
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func testRecipes(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+	if err := repos.Profiles.CreateProfile(ctx, &models.Profile{UserID: alice.ID, FullName: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Profiles.CreateProfile(ctx, &models.Profile{UserID: bob.ID, FullName: "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repos.Recipes.GetRecipeByID(ctx, 1); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("GetRecipeByID of a missing recipe: err = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	soup := createRecipe(t, repos, alice.ID, "soup", newTag("vegan"), newTag("quick"))
+	if soup.ID == 0 || soup.CreatedAt.IsZero() || soup.Images[0].ID == 0 || soup.Images[0].RecipeID != soup.ID {
+		t.Fatalf("CreateRecipe did not save the recipe and its images: %+v", soup)
+	}
+	for _, tag := range soup.Tags {
+		if tag.ID == 0 {
+			t.Fatalf("CreateRecipe did not create tag %s", tag.Name)
+		}
+	}
+	vegan := soup.Tags[0]
+	stew := createRecipe(t, repos, bob.ID, "stew", vegan)
+
+	recipe, err := repos.Recipes.GetRecipeByID(ctx, soup.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipe.Title != "soup" || recipe.User.ID != alice.ID || recipe.User.Profile.FullName != "Alice" {
+		t.Errorf("GetRecipeByID = %+v, want soup by alice with her profile", recipe)
+	}
+	if !equal(tagNames(recipe.Tags), []string{"quick", "vegan"}) || len(recipe.Images) != 1 || recipe.Images[0].URL != soup.Images[0].URL {
+		t.Errorf("GetRecipeByID has tags %v and images %+v", tagNames(recipe.Tags), recipe.Images)
+	}
+
+	recipes, err := repos.Recipes.GetRecipes(ctx, models.RecipeFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipes) != 2 || recipes[0].ID != soup.ID || recipes[1].ID != stew.ID {
+		t.Fatalf("GetRecipes returned %d recipes, want soup and stew", len(recipes))
+	}
+	if !equal(tagNames(recipes[1].Tags), []string{"vegan"}) || len(recipes[1].Images) != 1 {
+		t.Errorf("GetRecipes loaded tags %v and images %+v for stew", tagNames(recipes[1].Tags), recipes[1].Images)
+	}
+
+	recipe.Title = "tomato soup"
+	recipe.Tags = append(recipe.Tags, newTag("hot"))
+	recipe.Images = append(recipe.Images, models.Image{URL: "https://img.example.com/soup-2.jpg"})
+	if _, err := repos.Recipes.UpdateRecipe(ctx, recipe); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := repos.Recipes.GetRecipeByID(ctx, soup.ID)
+	if updated.Title != "tomato soup" || !updated.CreatedAt.Equal(soup.CreatedAt) {
+		t.Errorf("GetRecipeByID after UpdateRecipe = %+v", updated)
+	}
+	if !equal(tagNames(updated.Tags), []string{"hot", "quick", "vegan"}) || len(updated.Images) != 2 {
+		t.Errorf("after UpdateRecipe the recipe has tags %v and images %+v", tagNames(updated.Tags), updated.Images)
+	}
+
+	if exists, err := repos.Recipes.RecipeTagExists(ctx, vegan.ID); err != nil || !exists {
+		t.Errorf("RecipeTagExists of a tag = %v, %v, want true", exists, err)
+	}
+	if exists, err := repos.Recipes.RecipeTagExists(ctx, vegan.ID+100); err != nil || exists {
+		t.Errorf("RecipeTagExists of a missing tag = %v, %v, want false", exists, err)
+	}
+
+	// The loaded tags, images and ingredients are replaced, not merged.
+	updated.Title = "soup"
+	updated.Images = []models.Image{{URL: "https://img.example.com/soup-3.jpg"}}
+	updated.IngredientList = []models.RecipeIngredient{{Name: "tomato", Quantity: 4}}
+	replaced, err := repos.Recipes.ReplaceRecipe(ctx, updated, []uint{vegan.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(tagNames(replaced.Tags), []string{"vegan"}) {
+		t.Errorf("ReplaceRecipe returned tags %v, want vegan", tagNames(replaced.Tags))
+	}
+	updated, _ = repos.Recipes.GetRecipeByID(ctx, soup.ID)
+	if updated.Title != "soup" || !equal(tagNames(updated.Tags), []string{"vegan"}) ||
+		len(updated.Images) != 1 || updated.Images[0].URL != "https://img.example.com/soup-3.jpg" ||
+		len(updated.IngredientList) != 1 || updated.IngredientList[0].Name != "tomato" {
+		t.Errorf("after ReplaceRecipe the recipe is %q with tags %v, images %+v and ingredients %+v",
+			updated.Title, tagNames(updated.Tags), updated.Images, updated.IngredientList)
+	}
+	other, _ := repos.Recipes.GetRecipeByID(ctx, stew.ID)
+	if !equal(tagNames(other.Tags), []string{"vegan"}) || len(other.Images) != 1 {
+		t.Errorf("changing soup changed stew: tags %v and images %+v", tagNames(other.Tags), other.Images)
+	}
+
+	// Deleting a recipe deletes its reviews with their votes and photos, and its favorites.
+	review, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: alice.ID, RecipeID: stew.ID, Content: "hearty",
+		PhotoURLs: []string{"https://img.example.com/stew-review.jpg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Reviews.SaveVote(ctx, &models.ReviewVote{ReviewID: review.ID, UserID: bob.ID, Helpful: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []*models.User{alice, bob} {
+		if _, err := repos.Favorites.Add(ctx, user.ID, stew.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repos.Favorites.Add(ctx, bob.ID, soup.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	urls, err := repos.Recipes.DeleteRecipe(ctx, stew.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{other.Images[0].URL, "https://img.example.com/stew-review.jpg"}; !equal(urls, want) {
+		t.Errorf("DeleteRecipe returned %v, want the image of stew and the photo of its review %v", urls, want)
+	}
+	if _, err := repos.Recipes.GetRecipeByID(ctx, stew.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetRecipeByID after DeleteRecipe: err = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if found, err := repos.Reviews.FindByID(ctx, review.ID); err != nil || found != nil {
+		t.Errorf("FindByID of a review of the deleted recipe = %+v, %v, want nil", found, err)
+	}
+	if photos, err := repos.Reviews.FindPhotosByRecipeID(ctx, stew.ID, 0); err != nil || len(photos) != 0 {
+		t.Errorf("FindPhotosByRecipeID of the deleted recipe = %+v, %v, want none", photos, err)
+	}
+	if counts, err := repos.Favorites.CountByRecipeIDs(ctx, []uint{soup.ID, stew.ID}); err != nil || len(counts) != 1 || counts[soup.ID] != 1 {
+		t.Errorf("CountByRecipeIDs after DeleteRecipe = %v, %v, want only the favorite of soup", counts, err)
+	}
+	if kept, err := repos.Recipes.GetRecipeByID(ctx, soup.ID); err != nil || len(kept.Images) != 1 || len(kept.IngredientList) != 1 {
+		t.Errorf("deleting stew changed soup: %+v, %v", kept, err)
+	}
+}
+
+func testRecipeLabels(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+
+	salad := &models.Recipe{Title: "salad", UserID: alice.ID, Diets: models.DietVegan | models.DietHalal,
+		IngredientList: []models.RecipeIngredient{{Name: "lettuce", Quantity: 1, Unit: "head"}, {Name: "tofu", Food: "tahu", Quantity: 200, Unit: "g"}},
+		Allergens:      models.AllergenSoy, Servings: 2,
+		Nutrition:           models.NutritionFacts{Calories: 170, Protein: 16.2, Carbs: 4.6, Fat: 9.6},
+		NutritionPerServing: models.NutritionFacts{Calories: 85, Protein: 8.1, Carbs: 2.3, Fat: 4.8}}
+	cake := &models.Recipe{Title: "cake", UserID: alice.ID, Diets: models.DietVegetarian | models.DietHalal,
+		Allergens: models.AllergenGluten | models.AllergenEgg | models.AllergenDairy}
+	plain := &models.Recipe{Title: "rice", UserID: alice.ID}
+	for _, recipe := range []*models.Recipe{salad, cake, plain} {
+		if _, err := repos.Recipes.CreateRecipe(ctx, recipe); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := repos.Recipes.GetRecipeByID(ctx, salad.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Allergens != models.AllergenSoy || found.Diets != salad.Diets {
+		t.Errorf("GetRecipeByID has allergens %v and diets %v, want soy and vegan, halal", found.Allergens.Names(), found.Diets.Names())
+	}
+	if len(found.IngredientList) != 2 || found.IngredientList[0].Name != "lettuce" || found.IngredientList[1].Quantity != 200 ||
+		found.IngredientList[1].Unit != "g" || found.IngredientList[1].RecipeID != salad.ID {
+		t.Errorf("GetRecipeByID has ingredients %+v, want lettuce and tofu in order", found.IngredientList)
+	}
+	if found.IngredientList[1].Food != "tahu" || found.Servings != 2 || found.Nutrition != salad.Nutrition ||
+		found.NutritionPerServing != salad.NutritionPerServing || found.NutritionComplete {
+		t.Errorf("GetRecipeByID has food %q, %d servings and nutrition %+v per %+v, complete %v",
+			found.IngredientList[1].Food, found.Servings, found.Nutrition, found.NutritionPerServing, found.NutritionComplete)
+	}
+	if rice, _ := repos.Recipes.GetRecipeByID(ctx, plain.ID); rice.Servings != 1 {
+		t.Errorf("a recipe created without servings has %d, want 1", rice.Servings)
+	}
+
+	titles := func(filter models.RecipeFilter) []string {
+		t.Helper()
+		recipes, err := repos.Recipes.GetRecipes(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, recipe := range recipes {
+			titles = append(titles, recipe.Title)
+		}
+		return titles
+	}
+	for _, tc := range []struct {
+		filter models.RecipeFilter
+		want   []string
+	}{
+		{models.RecipeFilter{}, []string{"salad", "cake", "rice"}},
+		{models.RecipeFilter{ExcludeAllergens: models.AllergenGluten}, []string{"salad", "rice"}},
+		{models.RecipeFilter{ExcludeAllergens: models.AllergenSoy | models.AllergenEgg}, []string{"rice"}},
+		{models.RecipeFilter{Diets: models.DietHalal}, []string{"salad", "cake"}},
+		{models.RecipeFilter{Diets: models.DietHalal | models.DietVegetarian}, []string{"cake"}},
+		{models.RecipeFilter{Diets: models.DietHalal, ExcludeAllergens: models.AllergenDairy}, []string{"salad"}},
+	} {
+		if got := titles(tc.filter); !equal(got, tc.want) {
+			t.Errorf("GetRecipes(%+v) = %v, want %v", tc.filter, got, tc.want)
+		}
+	}
+
+	found.IngredientList = []models.RecipeIngredient{{Name: "cucumber"}}
+	if _, err := repos.Recipes.ReplaceRecipe(ctx, found, nil); err != nil {
+		t.Fatal(err)
+	}
+	found, _ = repos.Recipes.GetRecipeByID(ctx, salad.ID)
+	if len(found.IngredientList) != 1 || found.IngredientList[0].Name != "cucumber" {
+		t.Errorf("after replacing the ingredients the recipe has %+v, want cucumber", found.IngredientList)
+	}
+}
@@ -0,0 +1,187 @@
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+func testReports(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+	carol := createUser(t, repos, "carol")
+
+	soup := createRecipe(t, repos, alice.ID, "soup")
+	spam := &models.Recipe{Title: "spam", UserID: bob.ID, Hidden: true}
+	if _, err := repos.Recipes.CreateRecipe(ctx, spam); err != nil {
+		t.Fatal(err)
+	}
+	review, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: bob.ID, RecipeID: soup.ID, Content: "buy now", Hidden: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := repos.Reviews.FindByID(ctx, review.ID); found == nil || !found.Hidden {
+		t.Fatalf("FindByID of a review created hidden = %+v", found)
+	}
+
+	titles := func(viewerID uint) []string {
+		t.Helper()
+		recipes, err := repos.Recipes.GetRecipes(ctx, models.RecipeFilter{ViewerID: viewerID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, recipe := range recipes {
+			titles = append(titles, recipe.Title)
+		}
+		return titles
+	}
+	if got := titles(0); !equal(got, []string{"soup"}) {
+		t.Errorf("GetRecipes for anyone = %v, want the hidden recipe left out", got)
+	}
+	if got := titles(bob.ID); !equal(got, []string{"soup", "spam"}) {
+		t.Errorf("GetRecipes for the author of the hidden recipe = %v, want it too", got)
+	}
+
+	onSpam, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: carol.ID, RecipeID: spam.ID, Content: "tasty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewIDs := func(reviews []models.Review, _ int, err error) []uint {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []uint{}
+		for _, review := range reviews {
+			ids = append(ids, review.ID)
+		}
+		return ids
+	}
+	visibleTests := []struct {
+		name string
+		got  []uint
+		want []uint
+	}{
+		{"FindAll for anyone", reviewIDs(repos.Reviews.FindAll(ctx, 0, models.PageRequest{})), []uint{}},
+		{"FindAll for the author of the hidden review and recipe", reviewIDs(repos.Reviews.FindAll(ctx, bob.ID, models.PageRequest{})), []uint{review.ID, onSpam.ID}},
+		{"FindAll for the author of a review of the hidden recipe", reviewIDs(repos.Reviews.FindAll(ctx, carol.ID, models.PageRequest{})), []uint{}},
+		{"FindByUserID for anyone", reviewIDs(repos.Reviews.FindByUserID(ctx, carol.ID, 0, models.PageRequest{})), []uint{}},
+		{"FindByUserID for the author of the hidden recipe", reviewIDs(repos.Reviews.FindByUserID(ctx, carol.ID, bob.ID, models.PageRequest{})), []uint{onSpam.ID}},
+	}
+	for _, tt := range visibleTests {
+		if !equal(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if _, err := repos.Reports.FindByID(ctx, 1); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("FindByID of a missing report: err = %v, want gorm.ErrRecordNotFound", err)
+	}
+	reports := []*models.Report{
+		{TargetType: models.ReportTargetReview, TargetID: review.ID, Reason: "matched the content filter: buy now"},
+		{TargetType: models.ReportTargetReview, TargetID: review.ID, ReporterID: &alice.ID, Reason: "advertising"},
+		{TargetType: models.ReportTargetRecipe, TargetID: spam.ID, ReporterID: &carol.ID, Reason: "not a recipe"},
+		{TargetType: models.ReportTargetRecipe, TargetID: soup.ID, ReporterID: &carol.ID, Reason: "too salty"},
+	}
+	for _, report := range reports {
+		if err := repos.Reports.Create(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, err := repos.Reports.FindByID(ctx, reports[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Status != models.ReportStatusPending || *found.ReporterID != alice.ID || found.Reason != "advertising" || found.CreatedAt.IsZero() {
+		t.Errorf("FindByID = %+v, want the pending report of alice", found)
+	}
+	if pending, _ := repos.Reports.FindPending(ctx, models.ReportTargetReview, review.ID); len(pending) != 2 || pending[0].ReporterID != nil {
+		t.Errorf("FindPending on the review = %+v, want the filter flag and the report of alice", pending)
+	}
+
+	at := time.Now().UTC().Truncate(time.Second)
+	approve := &models.ModerationAction{ModeratorID: carol.ID, Action: models.ModerationApprove, TargetType: models.ReportTargetReview,
+		TargetID: review.ID, Reason: "a real review", CreatedAt: at}
+	if err := repos.Reports.Resolve(ctx, approve); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := repos.Reviews.FindByID(ctx, review.ID); found.Hidden {
+		t.Error("an approved review is still hidden")
+	}
+	if pending, _ := repos.Reports.FindPending(ctx, models.ReportTargetReview, review.ID); len(pending) != 0 {
+		t.Errorf("FindPending after approving the review = %+v, want none", pending)
+	}
+	approved, _, _ := repos.Reports.FindByStatus(ctx, models.ReportStatusApproved, models.PageRequest{})
+	if len(approved) != 2 || approved[1].ID != reports[1].ID || *approved[1].ModeratorID != carol.ID ||
+		approved[1].Resolution != "a real review" || approved[1].ResolvedAt == nil || !approved[1].ResolvedAt.Equal(at) {
+		t.Errorf("FindByStatus(approved) = %+v, want both reports on the review resolved by carol", approved)
+	}
+
+	reject := &models.ModerationAction{ModeratorID: carol.ID, Action: models.ModerationReject, TargetType: models.ReportTargetRecipe,
+		TargetID: soup.ID, Reason: "copied", CreatedAt: at}
+	if err := repos.Reports.Resolve(ctx, reject); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(0); len(got) != 0 {
+		t.Errorf("GetRecipes after rejecting soup = %v, want no recipes", got)
+	}
+	if got := reviewIDs(repos.Reviews.FindAll(ctx, 0, models.PageRequest{})); len(got) != 0 {
+		t.Errorf("FindAll after rejecting soup = %v, want its approved review left out", got)
+	}
+	if got := reviewIDs(repos.Reviews.FindAll(ctx, alice.ID, models.PageRequest{})); !equal(got, []uint{review.ID}) {
+		t.Errorf("FindAll for the author of soup = %v, want its review", got)
+	}
+	if pending, _, _ := repos.Reports.FindByStatus(ctx, models.ReportStatusPending, models.PageRequest{}); len(pending) != 1 || pending[0].ID != reports[2].ID {
+		t.Errorf("FindByStatus(pending) = %+v, want only the report on spam", pending)
+	}
+	if rejected, _, _ := repos.Reports.FindByStatus(ctx, models.ReportStatusRejected, models.PageRequest{}); len(rejected) != 1 || rejected[0].ID != reports[3].ID {
+		t.Errorf("FindByStatus(rejected) = %+v, want the report on soup", rejected)
+	}
+
+	actions, _, err := repos.Reports.FindActions(ctx, models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 2 || actions[0].ID != reject.ID || actions[1].ID != approve.ID || actions[1].Reason != "a real review" ||
+		actions[0].TargetType != models.ReportTargetRecipe || actions[0].TargetID != soup.ID || actions[0].ModeratorID != carol.ID {
+		t.Errorf("FindActions = %+v, want the rejection of soup and the approval of the review", actions)
+	}
+
+	// Deleting a recipe or a review deletes the reports on it, its reviews and their replies.
+	reply, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: carol.ID, RecipeID: soup.ID, ParentID: &review.ID, Depth: 1, Content: "spam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, report := range []*models.Report{
+		{TargetType: models.ReportTargetReview, TargetID: onSpam.ID, ReporterID: &alice.ID, Reason: "rude"},
+		{TargetType: models.ReportTargetReview, TargetID: reply.ID, ReporterID: &alice.ID, Reason: "spam"},
+	} {
+		if err := repos.Reports.Create(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repos.Recipes.DeleteRecipe(ctx, spam.ID); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _, _ := repos.Reports.FindByStatus(ctx, models.ReportStatusPending, models.PageRequest{}); len(pending) != 1 || pending[0].TargetID != reply.ID {
+		t.Errorf("FindByStatus(pending) after deleting spam = %+v, want only the report on the reply", pending)
+	}
+	if _, err := repos.Reviews.DeleteReviewByID(ctx, review.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []string{models.ReportStatusPending, models.ReportStatusApproved} {
+		if left, _, _ := repos.Reports.FindByStatus(ctx, status, models.PageRequest{}); len(left) != 0 {
+			t.Errorf("FindByStatus(%s) after deleting the review = %+v, want none", status, left)
+		}
+	}
+	if rejected, _, _ := repos.Reports.FindByStatus(ctx, models.ReportStatusRejected, models.PageRequest{}); len(rejected) != 1 || rejected[0].ID != reports[3].ID {
+		t.Errorf("FindByStatus(rejected) after deleting the review = %+v, want the report on soup", rejected)
+	}
+}
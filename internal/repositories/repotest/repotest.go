@@ -0,0 +1,99 @@
+// Package repotest is the contract of the repositories. Every implementation of repositories.Set
+// must pass Run, so the in-memory repositories behave like the database ones.
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// NewSet returns the repositories under test, backed by empty storage.
+type NewSet func(t *testing.T) repositories.Set
+
+// Run runs the contract of every repository against the sets returned by newSet.
+func Run(t *testing.T, newSet NewSet) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repos repositories.Set)
+	}{
+		{"Users", testUsers},
+		{"AccountDeletions", testAccountDeletions},
+		{"Profiles", testProfiles},
+		{"UserTokens", testUserTokens},
+		{"UserIdentities", testUserIdentities},
+		{"LoginAttempts", testLoginAttempts},
+		{"RecoveryCodes", testRecoveryCodes},
+		{"Recipes", testRecipes},
+		{"RecipeLabels", testRecipeLabels},
+		{"Reviews", testReviews},
+		{"ReviewThreads", testReviewThreads},
+		{"ReviewPhotos", testReviewPhotos},
+		{"ReviewPages", testReviewPages},
+		{"Reports", testReports},
+		{"Tags", testTags},
+		{"TagMerge", testTagMerge},
+		{"TagSuggestions", testTagSuggestions},
+		{"Favorites", testFavorites},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newSet(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, repos repositories.Set, name string) *models.User {
+	t.Helper()
+	user := &models.User{Username: name, Email: name + "@example.com", Password: "hash"}
+	if err := repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return user
+}
+
+func createRecipe(t *testing.T, repos repositories.Set, userID uint, title string, tags ...models.Tag) *models.Recipe {
+	t.Helper()
+	recipe := &models.Recipe{
+		Title:        title,
+		Description:  "description of " + title,
+		Ingredients:  "ingredients",
+		Instructions: "instructions",
+		UserID:       userID,
+		Images:       []models.Image{{URL: fmt.Sprintf("https://img.example.com/%s.jpg", title)}},
+		Tags:         tags,
+	}
+	if _, err := repos.Recipes.CreateRecipe(context.Background(), recipe); err != nil {
+		t.Fatalf("create recipe %s: %v", title, err)
+	}
+	return recipe
+}
+
+func newTag(name string) models.Tag {
+	return models.Tag{Name: name, Slug: name, Category: models.TagCategoryDiet}
+}
+
+// tagNames returns the sorted names of tags, as the order of preloaded tags is unspecified.
+func tagNames(tags []models.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equal[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,393 @@
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testReviews(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+	if err := repos.Profiles.CreateProfile(ctx, &models.Profile{UserID: bob.ID, FullName: "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	recipe := createRecipe(t, repos, alice.ID, "soup")
+
+	if review, err := repos.Reviews.FindByID(ctx, 1); review != nil || err != nil {
+		t.Fatalf("FindByID of a missing review = %v, %v, want nil, nil", review, err)
+	}
+
+	review, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: bob.ID, RecipeID: recipe.ID, Content: "great"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.ID == 0 || review.CreatedAt.IsZero() || review.UserID != bob.ID || review.RecipeID != recipe.ID || review.Content != "great" {
+		t.Fatalf("Create = %+v", review)
+	}
+
+	reviews, total, err := repos.Reviews.FindAll(ctx, 0, models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(reviews) != 1 || reviews[0].User.Profile.FullName != "Bob" || reviews[0].Recipe.Title != "soup" || reviews[0].Recipe.User.Username != "alice" {
+		t.Errorf("FindAll = %+v, want the review with its author and recipe", reviews)
+	}
+
+	reply, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: bob.ID, RecipeID: recipe.ID, ParentID: &review.ID, Depth: 1, Content: "thanks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: alice.ID, RecipeID: recipe.ID, Content: "mine"}); err != nil {
+		t.Fatal(err)
+	}
+	byBob, total, err := repos.Reviews.FindByUserID(ctx, bob.ID, 0, models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(byBob) != 2 || byBob[0].ID != reply.ID || byBob[1].ID != review.ID || byBob[0].User.Profile.FullName != "Bob" {
+		t.Errorf("FindByUserID = %+v, want the reply and the review of bob, newest first, with their author", byBob)
+	}
+	if none, total, err := repos.Reviews.FindByUserID(ctx, bob.ID+100, 0, models.PageRequest{}); err != nil || len(none) != 0 || total != 0 {
+		t.Errorf("FindByUserID of a user without reviews = %v, %d, %v, want none", none, total, err)
+	}
+	if updated, err := repos.Reviews.FindByID(ctx, review.ID); err != nil || updated == nil || updated.User.Username != "bob" {
+		t.Errorf("FindByID = %+v, %v, want the review with its author", updated, err)
+	}
+
+	if err := repos.Reviews.UpdateReviewByID(ctx, &models.Review{Content: "even better"}, review.ID); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := repos.Reviews.FindByID(ctx, review.ID)
+	if err != nil || updated == nil {
+		t.Fatalf("FindByID = %v, %v", updated, err)
+	}
+	if updated.Content != "even better" || updated.UserID != bob.ID || updated.RecipeID != recipe.ID || updated.UpdatedAt.Before(review.UpdatedAt) {
+		t.Errorf("after UpdateReviewByID with only the content: %+v", updated)
+	}
+
+	if urls, err := repos.Reviews.DeleteReviewByID(ctx, review.ID); err != nil || len(urls) != 0 {
+		t.Fatalf("DeleteReviewByID of a review without photos = %v, %v", urls, err)
+	}
+	if found, err := repos.Reviews.FindByID(ctx, review.ID); found != nil || err != nil {
+		t.Errorf("FindByID after DeleteReviewByID = %v, %v, want nil, nil", found, err)
+	}
+}
+
+func testReviewThreads(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+	carol := createUser(t, repos, "carol")
+	if err := repos.Profiles.CreateProfile(ctx, &models.Profile{UserID: bob.ID, FullName: "Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	soup := createRecipe(t, repos, alice.ID, "soup")
+	stew := createRecipe(t, repos, alice.ID, "stew")
+
+	create := func(userID, recipeID uint, parent *models.Review, content string) *models.Review {
+		t.Helper()
+		req := &models.ReviewRequest{UserID: userID, RecipeID: recipeID, Content: content}
+		if parent != nil {
+			req.ParentID, req.Depth = &parent.ID, parent.Depth+1
+		}
+		review, err := repos.Reviews.Create(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return review
+	}
+	review := create(bob.ID, soup.ID, nil, "great")
+	reply := create(carol.ID, soup.ID, review, "agreed")
+	nested := create(bob.ID, soup.ID, reply, "thanks")
+	other := create(carol.ID, stew.ID, nil, "too salty")
+
+	reviews, total, err := repos.Reviews.FindByRecipeID(ctx, soup.ID, 0, models.ReviewSortOldest, models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(reviews) != 3 || reviews[0].ID != review.ID || reviews[1].ID != reply.ID || reviews[2].ID != nested.ID {
+		t.Fatalf("FindByRecipeID = %+v, want the review and the replies to it", reviews)
+	}
+	if reviews[0].ParentID != nil || *reviews[2].ParentID != reply.ID || reviews[2].Depth != 2 || reviews[0].User.Profile.FullName != "Bob" {
+		t.Errorf("FindByRecipeID has parents %v, %v, depth %d and author %+v", reviews[0].ParentID, reviews[2].ParentID, reviews[2].Depth, reviews[0].User)
+	}
+
+	counts := func(id uint) (int, int) {
+		t.Helper()
+		found, err := repos.Reviews.FindByID(ctx, id)
+		if err != nil || found == nil {
+			t.Fatalf("FindByID = %v, %v", found, err)
+		}
+		return found.HelpfulCount, found.UnhelpfulCount
+	}
+	for _, vote := range []models.ReviewVote{
+		{ReviewID: review.ID, UserID: alice.ID, Helpful: true},
+		{ReviewID: review.ID, UserID: carol.ID, Helpful: true},
+		{ReviewID: other.ID, UserID: bob.ID, Helpful: false},
+	} {
+		if err := repos.Reviews.SaveVote(ctx, &vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if helpful, unhelpful := counts(review.ID); helpful != 2 || unhelpful != 0 {
+		t.Errorf("after two helpful votes the review counts %d helpful and %d unhelpful", helpful, unhelpful)
+	}
+	if err := repos.Reviews.SaveVote(ctx, &models.ReviewVote{ReviewID: review.ID, UserID: carol.ID, Helpful: false}); err != nil {
+		t.Fatal(err)
+	}
+	if helpful, unhelpful := counts(review.ID); helpful != 1 || unhelpful != 1 {
+		t.Errorf("after changing a vote the review counts %d helpful and %d unhelpful, want 1 and 1", helpful, unhelpful)
+	}
+	if err := repos.Reviews.DeleteVote(ctx, review.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if helpful, unhelpful := counts(review.ID); helpful != 0 || unhelpful != 1 {
+		t.Errorf("after deleting a vote the review counts %d helpful and %d unhelpful, want 0 and 1", helpful, unhelpful)
+	}
+
+	// The first votes of a user sent at once make a single vote.
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vote := models.ReviewVote{ReviewID: review.ID, UserID: alice.ID, Helpful: true}
+			if err := repos.Reviews.SaveVote(ctx, &vote); err != nil {
+				errs <- err
+			} else if vote.ID == 0 {
+				errs <- fmt.Errorf("SaveVote left the vote without an ID")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent SaveVote: %v", err)
+	}
+	if helpful, unhelpful := counts(review.ID); helpful != 1 || unhelpful != 1 {
+		t.Errorf("after concurrent votes of a user the review counts %d helpful and %d unhelpful, want 1 and 1", helpful, unhelpful)
+	}
+	if err := repos.Reviews.DeleteVote(ctx, review.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	at := review.CreatedAt.Add(time.Hour).UTC()
+	if err := repos.Reviews.SetAuthorResponse(ctx, review.ID, "thank you", &at, false); err != nil {
+		t.Fatal(err)
+	}
+	found, _ := repos.Reviews.FindByID(ctx, review.ID)
+	if found.AuthorResponse != "thank you" || found.AuthorResponseAt == nil || !found.AuthorResponseAt.Equal(at) || found.Content != "great" {
+		t.Errorf("after SetAuthorResponse the review is %+v", found)
+	}
+
+	// A hidden response is shown again when a moderator approves it, and clearing it deletes the
+	// reports on it.
+	if err := repos.Reviews.SetAuthorResponse(ctx, review.ID, "buy now", &at, true); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ = repos.Reviews.FindByID(ctx, review.ID); !found.AuthorResponseHidden || found.AuthorResponse != "buy now" {
+		t.Errorf("after SetAuthorResponse of a hidden response the review is %+v", found)
+	}
+	flag := &models.Report{TargetType: models.ReportTargetAuthorResponse, TargetID: review.ID, Reason: "matched the content filter: buy"}
+	if err := repos.Reports.Create(ctx, flag); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Reports.Resolve(ctx, &models.ModerationAction{ModeratorID: alice.ID, Action: models.ModerationApprove,
+		TargetType: models.ReportTargetAuthorResponse, TargetID: review.ID, CreatedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ = repos.Reviews.FindByID(ctx, review.ID); found.AuthorResponseHidden || found.Hidden {
+		t.Errorf("after approving the author response the review is %+v", found)
+	}
+	if err := repos.Reviews.SetAuthorResponse(ctx, review.ID, "", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ = repos.Reviews.FindByID(ctx, review.ID); found.AuthorResponse != "" || found.AuthorResponseAt != nil {
+		t.Errorf("after clearing the author response the review is %+v", found)
+	}
+	if approved, _, _ := repos.Reports.FindByStatus(ctx, models.ReportStatusApproved, models.PageRequest{}); len(approved) != 0 {
+		t.Errorf("after clearing the author response the reports on it are %+v, want none", approved)
+	}
+
+	if _, err := repos.Reviews.DeleteReviewByID(ctx, review.ID); err != nil {
+		t.Fatal(err)
+	}
+	if reviews, _, _ := repos.Reviews.FindByRecipeID(ctx, soup.ID, 0, models.ReviewSortOldest, models.PageRequest{}); len(reviews) != 0 {
+		t.Errorf("after deleting the review its recipe has %+v, want the replies deleted too", reviews)
+	}
+	if err := repos.Reviews.SaveVote(ctx, &models.ReviewVote{ReviewID: other.ID, UserID: alice.ID, Helpful: true}); err != nil {
+		t.Fatal(err)
+	}
+	if helpful, unhelpful := counts(other.ID); helpful != 1 || unhelpful != 1 {
+		t.Errorf("deleting a review changed the votes on another: %d helpful and %d unhelpful", helpful, unhelpful)
+	}
+}
+
+func testReviewPhotos(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+	soup := createRecipe(t, repos, alice.ID, "soup")
+	stew := createRecipe(t, repos, alice.ID, "stew")
+
+	url := func(name string) string { return "https://img.example.com/" + name }
+	review, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: bob.ID, RecipeID: soup.ID, Content: "made it",
+		PhotoURLs: []string{url("soup-1.jpg"), url("soup-2.jpg")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: alice.ID, RecipeID: soup.ID, ParentID: &review.ID, Depth: 1, Content: "looks good",
+		PhotoURLs: []string{url("soup-3.jpg")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: bob.ID, RecipeID: stew.ID, Content: "made it too",
+		PhotoURLs: []string{url("stew.jpg")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var photos []models.ReviewPhoto
+	for _, r := range []*models.Review{review, reply, other} {
+		photos = append(photos, r.Photos...)
+	}
+	if len(photos) != 4 || photos[0].ID == 0 || photos[3].ID == 0 || photos[0].CreatedAt.IsZero() || photos[1].URL != url("soup-2.jpg") ||
+		photos[0].ReviewID != review.ID || photos[2].UserID != alice.ID || photos[3].RecipeID != stew.ID {
+		t.Fatalf("Create did not save the photos: %+v", photos)
+	}
+
+	found, err := repos.Reviews.FindByID(ctx, review.ID)
+	if err != nil || found == nil {
+		t.Fatalf("FindByID = %v, %v", found, err)
+	}
+	if len(found.Photos) != 2 || found.Photos[0].URL != photos[0].URL || found.Photos[1].URL != photos[1].URL {
+		t.Errorf("FindByID has photos %+v, want soup-1 and soup-2", found.Photos)
+	}
+	reviews, _, _ := repos.Reviews.FindByRecipeID(ctx, soup.ID, 0, models.ReviewSortOldest, models.PageRequest{})
+	if len(reviews) != 2 || len(reviews[0].Photos) != 2 || len(reviews[1].Photos) != 1 {
+		t.Errorf("FindByRecipeID = %+v, want the review and the reply with their photos", reviews)
+	}
+
+	gallery, err := repos.Reviews.FindPhotosByRecipeID(ctx, soup.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, p := range gallery {
+		urls = append(urls, p.URL)
+	}
+	if !equal(urls, []string{photos[2].URL, photos[1].URL, photos[0].URL}) || gallery[0].UserID != alice.ID || gallery[2].ReviewID != review.ID {
+		t.Errorf("FindPhotosByRecipeID = %+v, want the photos of soup newest first", gallery)
+	}
+
+	if err := repos.Reviews.UpdateReviewByID(ctx, &models.Review{Hidden: true}, reply.ID); err != nil {
+		t.Fatal(err)
+	}
+	if gallery, _ := repos.Reviews.FindPhotosByRecipeID(ctx, soup.ID, 0); len(gallery) != 2 {
+		t.Errorf("FindPhotosByRecipeID for anyone = %+v, want the photos of the hidden reply left out", gallery)
+	}
+	if gallery, _ := repos.Reviews.FindPhotosByRecipeID(ctx, soup.ID, alice.ID); len(gallery) != 3 {
+		t.Errorf("FindPhotosByRecipeID for the author of the hidden reply = %+v, want its photo too", gallery)
+	}
+
+	urls, err = repos.Reviews.DeleteReviewByID(ctx, review.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(urls, []string{photos[0].URL, photos[1].URL, photos[2].URL}) {
+		t.Errorf("DeleteReviewByID returned %v, want the photos of the review and its reply", urls)
+	}
+	if gallery, _ := repos.Reviews.FindPhotosByRecipeID(ctx, soup.ID, alice.ID); len(gallery) != 0 {
+		t.Errorf("after deleting the review the gallery of soup is %+v, want it empty", gallery)
+	}
+	if gallery, _ := repos.Reviews.FindPhotosByRecipeID(ctx, stew.ID, 0); len(gallery) != 1 {
+		t.Errorf("deleting a review of soup changed the gallery of stew: %+v", gallery)
+	}
+}
+
+func testReviewPages(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+	carol := createUser(t, repos, "carol")
+	soup := createRecipe(t, repos, alice.ID, "soup")
+
+	create := func(userID uint, parent *models.Review, hidden bool) *models.Review {
+		t.Helper()
+		req := &models.ReviewRequest{UserID: userID, RecipeID: soup.ID, Content: "review", Hidden: hidden}
+		if parent != nil {
+			req.ParentID, req.Depth = &parent.ID, parent.Depth+1
+		}
+		review, err := repos.Reviews.Create(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return review
+	}
+	first := create(bob.ID, nil, false)
+	second := create(carol.ID, nil, false)
+	third := create(bob.ID, nil, false)
+	hidden := create(carol.ID, nil, true)
+	reply := create(alice.ID, second, false)
+	hiddenReply := create(bob.ID, second, true)
+	nested := create(carol.ID, reply, false)
+	underHidden := create(alice.ID, hiddenReply, false)
+	for _, vote := range []models.ReviewVote{
+		{ReviewID: second.ID, UserID: alice.ID, Helpful: true},
+		{ReviewID: second.ID, UserID: bob.ID, Helpful: true},
+		{ReviewID: third.ID, UserID: alice.ID, Helpful: true},
+		{ReviewID: third.ID, UserID: carol.ID, Helpful: false},
+		{ReviewID: first.ID, UserID: alice.ID, Helpful: false},
+	} {
+		if err := repos.Reviews.SaveVote(ctx, &vote); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(reviews []models.Review) []uint {
+		ids := []uint{}
+		for _, review := range reviews {
+			ids = append(ids, review.ID)
+		}
+		return ids
+	}
+	tests := []struct {
+		name      string
+		viewerID  uint
+		sort      string
+		page      models.PageRequest
+		want      []uint
+		wantTotal int
+	}{
+		{"most helpful", 0, models.ReviewSortHelpful, models.PageRequest{}, []uint{second.ID, third.ID, first.ID, reply.ID, nested.ID}, 3},
+		{"newest", 0, models.ReviewSortNewest, models.PageRequest{PageSize: 2}, []uint{third.ID, second.ID, reply.ID, nested.ID}, 3},
+		{"oldest, second page", 0, models.ReviewSortOldest, models.PageRequest{Page: 2, PageSize: 2}, []uint{third.ID}, 3},
+		{"past the last page", 0, models.ReviewSortOldest, models.PageRequest{Page: 3, PageSize: 2}, []uint{}, 3},
+		{"for the author of the hidden review", carol.ID, models.ReviewSortNewest, models.PageRequest{PageSize: 1}, []uint{hidden.ID}, 4},
+		{"for the author of the hidden reply", bob.ID, models.ReviewSortOldest, models.PageRequest{Page: 2, PageSize: 1},
+			[]uint{second.ID, reply.ID, hiddenReply.ID, nested.ID, underHidden.ID}, 3},
+	}
+	for _, tt := range tests {
+		reviews, total, err := repos.Reviews.FindByRecipeID(ctx, soup.ID, tt.viewerID, tt.sort, tt.page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(reviews); !equal(got, tt.want) || total != tt.wantTotal {
+			t.Errorf("FindByRecipeID %s = %v, %d, want %v, %d", tt.name, got, total, tt.want, tt.wantTotal)
+		}
+	}
+
+	if reviews, total, err := repos.Reviews.FindAll(ctx, 0, models.PageRequest{Page: 2, PageSize: 2}); err != nil || !equal(ids(reviews), []uint{third.ID, reply.ID}) || total != 6 {
+		t.Errorf("FindAll of the second page = %v, %d, %v, want %v, 6", ids(reviews), total, err, []uint{third.ID, reply.ID})
+	}
+	if reviews, total, err := repos.Reviews.FindByUserID(ctx, bob.ID, bob.ID, models.PageRequest{Page: 2, PageSize: 2}); err != nil || !equal(ids(reviews), []uint{first.ID}) || total != 3 {
+		t.Errorf("FindByUserID of the second page = %v, %d, %v, want %v, 3", ids(reviews), total, err, []uint{first.ID})
+	}
+}
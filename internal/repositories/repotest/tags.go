@@ -0,0 +1,276 @@
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func testTags(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+
+	asian := &models.Tag{Name: "Asian", Slug: "asian", Category: models.TagCategoryCuisine}
+	if err := repos.Tags.Create(ctx, asian); err != nil {
+		t.Fatal(err)
+	}
+	indonesian := &models.Tag{Name: "Indonesian", Slug: "indonesian", Category: models.TagCategoryCuisine, ParentID: &asian.ID,
+		Synonyms: []models.TagSynonym{{Name: "Nusantara"}}}
+	quick := &models.Tag{Name: "quick", Slug: "quick"}
+	spicy := &models.Tag{Name: "spicy", Slug: "spicy", Status: models.TagStatusPending}
+	for _, tag := range []*models.Tag{indonesian, quick, spicy} {
+		if err := repos.Tags.Create(ctx, tag); err != nil {
+			t.Fatal(err)
+		}
+		if tag.ID == 0 || tag.CreatedAt.IsZero() {
+			t.Fatalf("Create did not set the ID and timestamps: %+v", tag)
+		}
+	}
+	if quick.Category != models.TagCategoryOther {
+		t.Errorf("Create without a category set category %q, want %q", quick.Category, models.TagCategoryOther)
+	}
+	if quick.Status != models.TagStatusApproved {
+		t.Errorf("Create without a status set status %q, want %q", quick.Status, models.TagStatusApproved)
+	}
+
+	for _, dup := range []models.Tag{
+		{Name: "Asian", Slug: "asian-2"},
+		{Name: "ASIAN", Slug: "asian-3"},
+		{Name: "Asian food", Slug: "asian"},
+		{Name: "Javanese", Slug: "javanese", Synonyms: []models.TagSynonym{{Name: "Nusantara"}}},
+		{Name: "Javanese", Slug: "javanese", Synonyms: []models.TagSynonym{{Name: "nusantara"}}},
+	} {
+		if err := repos.Tags.Create(ctx, &dup); err == nil {
+			t.Errorf("Create accepted the duplicate %+v", dup)
+		}
+	}
+
+	all, err := repos.Tags.GetAllTags(ctx)
+	if err != nil || !equal(tagNames(all), []string{"Asian", "Indonesian", "quick", "spicy"}) {
+		t.Fatalf("GetAllTags = %v, %v", tagNames(all), err)
+	}
+	for _, tag := range all {
+		if tag.Name == "spicy" && tag.Status != models.TagStatusPending {
+			t.Errorf("GetAllTags returned status %q for a pending tag", tag.Status)
+		}
+		if tag.Name != "Indonesian" {
+			continue
+		}
+		if tag.Slug != "indonesian" || tag.Category != models.TagCategoryCuisine || tag.ParentID == nil || *tag.ParentID != asian.ID {
+			t.Errorf("GetAllTags returned %+v, want the slug, category and parent", tag)
+		}
+		if len(tag.Synonyms) != 1 || tag.Synonyms[0].Name != "Nusantara" {
+			t.Errorf("GetAllTags returned synonyms %+v, want Nusantara", tag.Synonyms)
+		}
+	}
+
+	found, err := repos.Tags.GetTagsByNames(ctx, []string{"QUICK", "nusantara", "missing"})
+	if err != nil || !equal(tagNames(found), []string{"Indonesian", "quick"}) {
+		t.Errorf("GetTagsByNames = %v, %v, want Indonesian by its synonym and quick, ignoring case", tagNames(found), err)
+	}
+
+	alice := createUser(t, repos, "alice")
+	indonesian.Name = "Indonesia"
+	indonesian.Slug = "indonesia"
+	indonesian.Synonyms = []models.TagSynonym{{Name: "Nusantara"}, {Name: "Indo"}}
+	if err := repos.Tags.Update(ctx, indonesian, tagAction(alice.ID, models.ModerationUpdate, indonesian.ID)); err != nil {
+		t.Fatal(err)
+	}
+	found, _ = repos.Tags.GetTagsByNames(ctx, []string{"Indo"})
+	if !equal(tagNames(found), []string{"Indonesia"}) {
+		t.Errorf("GetTagsByNames of a new synonym = %v, want Indonesia", tagNames(found))
+	}
+	indonesian.Synonyms = nil
+	if err := repos.Tags.Update(ctx, indonesian, tagAction(alice.ID, models.ModerationUpdate, indonesian.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ = repos.Tags.GetTagsByNames(ctx, []string{"Nusantara", "Indo"}); len(found) != 0 {
+		t.Errorf("GetTagsByNames of removed synonyms = %v, want none", tagNames(found))
+	}
+	quick.Name = "Asian"
+	if err := repos.Tags.Update(ctx, quick, tagAction(alice.ID, models.ModerationUpdate, quick.ID)); err == nil {
+		t.Error("Update accepted a duplicate name")
+	}
+	if err := repos.Tags.Approve(ctx, spicy.ID, tagAction(alice.ID, models.ModerationApprove, spicy.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ = repos.Tags.GetTagsByNames(ctx, []string{"spicy"}); len(found) != 1 || found[0].Status != models.TagStatusApproved ||
+		found[0].Name != "spicy" {
+		t.Errorf("GetTagsByNames after approving = %+v, want spicy approved", found)
+	}
+
+	soup := createRecipe(t, repos, alice.ID, "soup", *indonesian)
+	createRecipe(t, repos, alice.ID, "rice", *indonesian)
+	if _, err := repos.Recipes.DeleteRecipe(ctx, soup.ID); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := repos.Tags.CountRecipes(ctx)
+	if err != nil || len(counts) != 1 || counts[indonesian.ID] != 1 {
+		t.Errorf("CountRecipes = %v, %v, want 1 recipe of Indonesia", counts, err)
+	}
+	if err := repos.Tags.Delete(ctx, indonesian.ID, tagAction(alice.ID, models.ModerationDelete, indonesian.ID)); !errors.Is(err, repositories.ErrTagInUse) {
+		t.Errorf("Delete of a tag in use: err = %v, want repositories.ErrTagInUse", err)
+	}
+
+	if err := repos.Tags.Delete(ctx, asian.ID, tagAction(alice.ID, models.ModerationDelete, asian.ID)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{fmt.Sprintf("delete tag %d", asian.ID), fmt.Sprintf("approve tag %d", spicy.ID),
+		fmt.Sprintf("update tag %d", indonesian.ID), fmt.Sprintf("update tag %d", indonesian.ID)}
+	if got := actionLog(t, repos); !equal(got, want) {
+		t.Errorf("FindActions after Update, Approve and Delete = %v, want %v without the failed update and deletion", got, want)
+	}
+	all, err = repos.Tags.GetAllTags(ctx)
+	if err != nil || !equal(tagNames(all), []string{"Indonesia", "quick", "spicy"}) {
+		t.Fatalf("GetAllTags after Delete = %v, %v, want Indonesia, quick and spicy", tagNames(all), err)
+	}
+	for _, tag := range all {
+		if tag.ParentID != nil {
+			t.Errorf("tag %s still has the deleted parent", tag.Name)
+		}
+	}
+}
+
+func testTagMerge(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+
+	vegan := &models.Tag{Name: "Vegan", Slug: "vegan", Category: models.TagCategoryDiet}
+	duplicate := &models.Tag{Name: "Veganism", Slug: "veganism", Category: models.TagCategoryDiet, Synonyms: []models.TagSynonym{{Name: "plant-based"}}}
+	raw := &models.Tag{Name: "raw vegan", Slug: "raw-vegan", Category: models.TagCategoryDiet}
+	for _, tag := range []*models.Tag{vegan, duplicate} {
+		if err := repos.Tags.Create(ctx, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw.ParentID = &duplicate.ID
+	if err := repos.Tags.Create(ctx, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	salad := createRecipe(t, repos, alice.ID, "salad", *vegan, *duplicate)
+	tofu := createRecipe(t, repos, alice.ID, "tofu", *duplicate)
+
+	vegan.Synonyms = []models.TagSynonym{{Name: "plant-based"}}
+	actions := []models.ModerationAction{*tagAction(alice.ID, models.ModerationMerge, duplicate.ID)}
+	if err := repos.Tags.Merge(ctx, vegan, []uint{duplicate.ID}, actions); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := actionLog(t, repos), []string{fmt.Sprintf("merge tag %d", duplicate.ID)}; !equal(got, want) {
+		t.Errorf("FindActions after Merge = %v, want %v", got, want)
+	}
+
+	for _, recipe := range []*models.Recipe{salad, tofu} {
+		found, err := repos.Recipes.GetRecipeByID(ctx, recipe.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(tagNames(found.Tags), []string{"Vegan"}) {
+			t.Errorf("recipe %s has tags %v after Merge, want only Vegan", recipe.Title, tagNames(found.Tags))
+		}
+	}
+	counts, err := repos.Tags.CountRecipes(ctx)
+	if err != nil || counts[vegan.ID] != 2 || counts[duplicate.ID] != 0 {
+		t.Errorf("CountRecipes after Merge = %v, %v, want 2 recipes of Vegan", counts, err)
+	}
+
+	all, err := repos.Tags.GetAllTags(ctx)
+	if err != nil || !equal(tagNames(all), []string{"Vegan", "raw vegan"}) {
+		t.Fatalf("GetAllTags after Merge = %v, %v, want the source deleted", tagNames(all), err)
+	}
+	for _, tag := range all {
+		if tag.ID == raw.ID && (tag.ParentID == nil || *tag.ParentID != vegan.ID) {
+			t.Errorf("sub-tag of the source has parent %v after Merge, want Vegan", tag.ParentID)
+		}
+	}
+	found, err := repos.Tags.GetTagsByNames(ctx, []string{"Plant-Based"})
+	if err != nil || !equal(tagNames(found), []string{"Vegan"}) {
+		t.Errorf("GetTagsByNames of the moved synonym = %v, %v, want Vegan", tagNames(found), err)
+	}
+}
+
+func tagAction(moderatorID uint, decision string, tagID uint) *models.ModerationAction {
+	return &models.ModerationAction{ModeratorID: moderatorID, Action: decision, TargetType: models.ModerationTargetTag, TargetID: tagID}
+}
+
+// actionLog returns the audit trail as "action target id" entries, newest first.
+func actionLog(t *testing.T, repos repositories.Set) []string {
+	t.Helper()
+	actions, _, err := repos.Reports.FindActions(context.Background(), models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log []string
+	for _, action := range actions {
+		if action.CreatedAt.IsZero() {
+			t.Errorf("action %d has no time", action.ID)
+		}
+		log = append(log, fmt.Sprintf("%s %s %d", action.Action, action.TargetType, action.TargetID))
+	}
+	return log
+}
+
+func testTagSuggestions(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice")
+
+	tags := map[string]*models.Tag{}
+	for _, tag := range []models.Tag{
+		{Name: "Sambal", Slug: "sambal", Synonyms: []models.TagSynonym{{Name: "Sambel"}}},
+		{Name: "Salad", Slug: "salad"},
+		{Name: "Sate", Slug: "sate"},
+		{Name: "Soto", Slug: "soto"},
+		{Name: "Sayur", Slug: "sayur", Status: models.TagStatusPending},
+		{Name: "50% off", Slug: "50-off"},
+		{Name: "50x off", Slug: "50x-off"},
+	} {
+		tag := tag
+		if err := repos.Tags.Create(ctx, &tag); err != nil {
+			t.Fatal(err)
+		}
+		tags[tag.Name] = &tag
+	}
+	createRecipe(t, repos, alice.ID, "soto ayam", *tags["Soto"])
+	createRecipe(t, repos, alice.ID, "soto betawi", *tags["Soto"], *tags["Sate"])
+	createRecipe(t, repos, alice.ID, "salad buah", *tags["Salad"])
+	hidden := &models.Recipe{Title: "sate spam", UserID: alice.ID, Hidden: true, Tags: []models.Tag{*tags["Sate"]}}
+	if _, err := repos.Recipes.CreateRecipe(ctx, hidden); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := repos.Tags.CountRecipes(ctx)
+	if err != nil || counts[tags["Sate"].ID] != 1 || counts[tags["Soto"].ID] != 2 {
+		t.Errorf("CountRecipes = %v, %v, want the hidden recipe left out", counts, err)
+	}
+
+	tests := []struct {
+		prefix     string
+		limit      int
+		want       []string
+		wantCounts []int
+	}{
+		{"s", 3, []string{"Soto", "Salad", "Sate"}, []int{2, 1, 1}},
+		{"SAMBE", 10, []string{"Sambal"}, []int{0}},
+		{"say", 10, []string{}, []int{}},
+		{"50%", 10, []string{"50% off"}, []int{0}},
+		{"x", 10, []string{}, []int{}},
+	}
+	for _, tt := range tests {
+		found, counts, err := repos.Tags.Suggest(ctx, tt.prefix, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, gotCounts := []string{}, []int{}
+		for _, tag := range found {
+			got, gotCounts = append(got, tag.Name), append(gotCounts, counts[tag.ID])
+		}
+		if !equal(got, tt.want) || !equal(gotCounts, tt.wantCounts) {
+			t.Errorf("Suggest(%q, %d) = %v with counts %v, want %v with counts %v", tt.prefix, tt.limit, got, gotCounts, tt.want, tt.wantCounts)
+		}
+	}
+	if found, _, _ := repos.Tags.Suggest(ctx, "sambe", 1); len(found) != 1 || len(found[0].Synonyms) != 1 || found[0].Synonyms[0].Name != "Sambel" {
+		t.Errorf("Suggest returned %+v, want Sambal with its synonym", found)
+	}
+}
@@ -0,0 +1,246 @@
+package repotest
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+func testUsers(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+
+	alice := createUser(t, repos, "alice")
+	if alice.ID == 0 || alice.CreatedAt.IsZero() || alice.UpdatedAt.IsZero() {
+		t.Fatalf("Create did not set the ID and timestamps: %+v", alice)
+	}
+	if alice.Role != models.RoleUser {
+		t.Errorf("Create without a role set role %q, want %q", alice.Role, models.RoleUser)
+	}
+	if err := repos.Users.Create(ctx, &models.User{Username: "other", Email: alice.Email, Password: "hash"}); err == nil {
+		t.Error("Create accepted a duplicate email")
+	}
+
+	if _, err := repos.Users.FindByID(ctx, alice.ID+100); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByID of a missing user: err = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	if err := repos.Profiles.CreateProfile(ctx, &models.Profile{UserID: alice.ID, FullName: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	recipe := createRecipe(t, repos, alice.ID, "soup")
+	if _, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: alice.ID, RecipeID: recipe.ID, Content: "tasty"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Favorites.Add(ctx, alice.ID, recipe.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repos.Users.FindByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Email != alice.Email || found.Profile.FullName != "Alice" || len(found.Reviews) != 1 || len(found.Favorites) != 1 {
+		t.Errorf("FindByID = %+v, want the user with its profile, review and favorite", found)
+	}
+
+	for _, key := range []string{"alice", "alice@example.com"} {
+		user, err := repos.Users.GetUserByEmailOrUsername(ctx, key)
+		if err != nil || user == nil || user.ID != alice.ID {
+			t.Errorf("GetUserByEmailOrUsername(%q) = %v, %v, want alice", key, user, err)
+		}
+	}
+	if user, err := repos.Users.GetUserByEmailOrUsername(ctx, "nobody"); user != nil || err != nil {
+		t.Errorf("GetUserByEmailOrUsername of a missing user = %v, %v, want nil, nil", user, err)
+	}
+	if user, err := repos.Users.CheckUserEmail(ctx, "alice@example.com"); err != nil || user == nil || user.ID != alice.ID {
+		t.Errorf("CheckUserEmail = %v, %v, want alice", user, err)
+	}
+	if user, err := repos.Users.CheckUserEmail(ctx, "alice"); user != nil || err != nil {
+		t.Errorf("CheckUserEmail of a username = %v, %v, want nil, nil", user, err)
+	}
+
+	createdAt := alice.CreatedAt
+	alice.Username = "alice2"
+	if err := repos.Users.Update(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	found, _ = repos.Users.FindByID(ctx, alice.ID)
+	if found.Username != "alice2" || !found.CreatedAt.Equal(createdAt) {
+		t.Errorf("after Update: %+v", found)
+	}
+
+	bob := createUser(t, repos, "bob")
+	bob.Email = alice.Email
+	if err := repos.Users.Update(ctx, bob); err == nil {
+		t.Error("Update accepted a duplicate email")
+	}
+
+	if err := repos.Users.Delete(ctx, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.FindByID(ctx, bob.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByID after Delete: err = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func testAccountDeletions(t *testing.T, repos repositories.Set) {
+	ctx := context.Background()
+	now := time.Now()
+
+	alice := createUser(t, repos, "alice")
+	bob := createUser(t, repos, "bob")
+	carol := createUser(t, repos, "carol")
+
+	if deletion, err := repos.Users.FindDeletionRequest(ctx, alice.ID); deletion != nil || err != nil {
+		t.Fatalf("FindDeletionRequest without a request = %v, %v, want nil, nil", deletion, err)
+	}
+
+	due := &models.AccountDeletion{UserID: alice.ID, ScheduledFor: now.Add(-time.Hour), RecipeAction: models.RecipeActionDelete}
+	later := &models.AccountDeletion{UserID: carol.ID, ScheduledFor: now.Add(time.Hour), RecipeAction: models.RecipeActionDelete}
+	for _, deletion := range []*models.AccountDeletion{due, later} {
+		if err := repos.Users.SaveDeletionRequest(ctx, deletion); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deletion, err := repos.Users.FindDeletionRequest(ctx, alice.ID)
+	if err != nil || deletion == nil || deletion.ID != due.ID {
+		t.Fatalf("FindDeletionRequest = %v, %v, want the request of alice", deletion, err)
+	}
+
+	dues, err := repos.Users.FindDueDeletionRequests(ctx, now)
+	if err != nil || len(dues) != 1 || dues[0].UserID != alice.ID {
+		t.Fatalf("FindDueDeletionRequests = %v, %v, want only the request of alice", dues, err)
+	}
+
+	if err := repos.Users.DeleteDeletionRequest(ctx, carol.ID); err != nil {
+		t.Fatal(err)
+	}
+	if deletion, _ := repos.Users.FindDeletionRequest(ctx, carol.ID); deletion != nil {
+		t.Errorf("FindDeletionRequest after DeleteDeletionRequest = %v, want nil", deletion)
+	}
+
+	// Alice deletes her account with her recipes, which were reviewed and favorited by bob.
+	if err := repos.Profiles.CreateProfile(ctx, &models.Profile{UserID: alice.ID, AvatarURL: "https://img.example.com/avatar.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	recipe := createRecipe(t, repos, alice.ID, "cake", newTag("dessert"))
+	kept := createRecipe(t, repos, bob.ID, "bread")
+	for _, recipeID := range []uint{recipe.ID, kept.ID} {
+		if _, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: bob.ID, RecipeID: recipeID, Content: "nice",
+			PhotoURLs: []string{fmt.Sprintf("https://img.example.com/made-%d.jpg", recipeID)}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repos.Favorites.Add(ctx, bob.ID, recipeID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aliceReview, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: alice.ID, RecipeID: kept.ID, Content: "mine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Her two-factor secret and verified email are cleared.
+	verifiedAt := now.Add(-time.Hour)
+	alice.EmailVerifiedAt, alice.TOTPSecret, alice.TOTPEnabledAt, alice.TOTPLastStep = &verifiedAt, "JBSWY3DPEHPK3PXP", &verifiedAt, 42
+	if err := repos.Users.Update(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	// Her tokens, linked identities and recovery codes go with her account.
+	if err := repos.UserTokens.Create(ctx, &models.UserToken{UserID: alice.ID, Purpose: models.TokenPurposePasswordReset, TokenHash: "alice-reset",
+		ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.UserIdentities.Create(ctx, &models.UserIdentity{UserID: alice.ID, Provider: "google", Subject: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.RecoveryCodes.ReplaceForUser(ctx, alice.ID, []string{"alice-code"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// So do the login attempts naming her, by username or email, and bob's attempts stay.
+	for _, attempt := range []models.LoginAttempt{
+		{UserID: &alice.ID, Identifier: "alice", IP: "192.0.2.1", Result: models.LoginResultInvalidPassword},
+		{Identifier: "alice@example.com", IP: "192.0.2.2", Result: models.LoginResultUnknownUser},
+		{UserID: &bob.ID, Identifier: "bob", IP: "192.0.2.1", Result: models.LoginResultInvalidPassword},
+	} {
+		if err := repos.LoginAttempts.Create(ctx, &attempt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	urls, err := repos.Users.Anonymize(ctx, deletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(urls, []string{recipe.Images[0].URL, fmt.Sprintf("https://img.example.com/made-%d.jpg", recipe.ID), "https://img.example.com/avatar.jpg"}) {
+		t.Errorf("Anonymize returned %v, want the recipe image, the photo of its review and the avatar", urls)
+	}
+	if deletion.CompletedAt == nil {
+		t.Error("Anonymize did not complete the request")
+	}
+
+	user, err := repos.Users.FindByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsAnonymized() || user.Username != models.DeletedUsername || user.Email == alice.Email || user.Password != "" {
+		t.Errorf("user after Anonymize = %+v", user)
+	}
+	if user.EmailVerifiedAt != nil || user.TOTPSecret != "" || user.TOTPEnabledAt != nil || user.TOTPLastStep != 0 {
+		t.Errorf("user after Anonymize kept its credentials: verified at %v, TOTP secret %q enabled at %v, last step %d",
+			user.EmailVerifiedAt, user.TOTPSecret, user.TOTPEnabledAt, user.TOTPLastStep)
+	}
+	if user.Profile.ID != 0 {
+		t.Errorf("profile after Anonymize = %+v, want none", user.Profile)
+	}
+	if token, _ := repos.UserTokens.FindValid(ctx, models.TokenPurposePasswordReset, "alice-reset", now); token != nil {
+		t.Errorf("FindValid after Anonymize = %+v, want nil", token)
+	}
+	if identity, _ := repos.UserIdentities.FindByProviderSubject(ctx, "google", "alice"); identity != nil {
+		t.Errorf("FindByProviderSubject after Anonymize = %+v, want nil", identity)
+	}
+	if used, _ := repos.RecoveryCodes.Use(ctx, alice.ID, "alice-code", now); used {
+		t.Error("Use of a recovery code after Anonymize = true, want false")
+	}
+	if n, _, err := repos.LoginAttempts.CountFailuresByIP(ctx, "192.0.2.1", now.Add(-time.Hour)); err != nil || n != 1 {
+		t.Errorf("failures from the IP of alice after Anonymize = %d, %v, want only the one of bob", n, err)
+	}
+	if n, _, err := repos.LoginAttempts.CountFailuresByIP(ctx, "192.0.2.2", now.Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("failures naming the email of alice after Anonymize = %d, %v, want none", n, err)
+	}
+	if len(user.Reviews) != 1 || user.Reviews[0].ID != aliceReview.ID {
+		t.Errorf("reviews after Anonymize = %+v, want the review of alice on bread", user.Reviews)
+	}
+	if _, err := repos.Recipes.GetRecipeByID(ctx, recipe.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetRecipeByID of a deleted recipe: err = %v, want gorm.ErrRecordNotFound", err)
+	}
+	bobUser, _ := repos.Users.FindByID(ctx, bob.ID)
+	if len(bobUser.Reviews) != 1 || bobUser.Reviews[0].RecipeID != kept.ID || len(bobUser.Favorites) != 1 || bobUser.Favorites[0].RecipeID != kept.ID {
+		t.Errorf("bob after Anonymize has reviews %+v and favorites %+v, want only the ones of bread", bobUser.Reviews, bobUser.Favorites)
+	}
+	if deletion, _ := repos.Users.FindDeletionRequest(ctx, alice.ID); deletion != nil {
+		t.Errorf("FindDeletionRequest of a completed request = %v, want nil", deletion)
+	}
+
+	// Bob hands his recipes over to carol.
+	transfer := &models.AccountDeletion{UserID: bob.ID, ScheduledFor: now, RecipeAction: models.RecipeActionTransfer, TransferToUserID: &carol.ID}
+	if err := repos.Users.SaveDeletionRequest(ctx, transfer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.Anonymize(ctx, transfer); err != nil {
+		t.Fatal(err)
+	}
+	transferred, err := repos.Recipes.GetRecipeByID(ctx, kept.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transferred.UserID != carol.ID || len(transferred.Images) != 1 {
+		t.Errorf("recipe after the transfer = %+v, want it owned by carol with its image", transferred)
+	}
+}
@@ -2,16 +2,46 @@ package repositories
 
 import (
 	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
 
 type ReviewRepository interface {
-	FindAll() ([]models.Review, error)
-	FindByID(id uint) (*models.Review, error)
-	Create(req *models.ReviewRequest) (*models.Review, error)
-	UpdateReviewByID(review *models.Review, id uint) error
-	DeleteReviewByID(id uint) error
+	// FindAll returns the page of the reviews and replies shown to viewerID, by ascending ID, and
+	// the number of them on all pages. Hidden ones and those of hidden recipes are left out unless
+	// viewerID wrote them. A viewerID of 0 is an anonymous viewer.
+	FindAll(ctx context.Context, viewerID uint, page models.PageRequest) ([]models.Review, int, error)
+	FindByID(ctx context.Context, id uint) (*models.Review, error)
+	// FindByRecipeID returns the page of the reviews of the recipe shown to viewerID in the order
+	// of sort, one of the models.ReviewSort orders, and the number of them on all pages. The
+	// replies to them that are shown to viewerID follow, by ascending ID; replies to hidden
+	// replies are left out.
+	FindByRecipeID(ctx context.Context, recipeID, viewerID uint, sort string, page models.PageRequest) ([]models.Review, int, error)
+	// FindByUserID returns the page of the reviews and replies written by the user that are shown
+	// to viewerID, as FindAll does, newest first, and the number of them on all pages.
+	// FindByIDs returns the reviews and replies with an ID in ids, without their associations, by
+	// ascending ID. IDs of missing reviews are skipped.
+	FindByIDs(ctx context.Context, ids []uint) ([]models.Review, error)
+	FindByUserID(ctx context.Context, userID, viewerID uint, page models.PageRequest) ([]models.Review, int, error)
+	// Create saves the review with a photo for each of req.PhotoURLs in one transaction.
+	Create(ctx context.Context, req *models.ReviewRequest) (*models.Review, error)
+	UpdateReviewByID(ctx context.Context, review *models.Review, id uint) error
+	// DeleteReviewByID deletes the review with the replies to it and the votes on, photos of and
+	// reports on them. The URLs of the photos are returned so they can be removed from storage.
+	DeleteReviewByID(ctx context.Context, id uint) ([]string, error)
+	// FindPhotosByRecipeID returns the photos of the reviews and replies of the recipe that are
+	// shown to viewerID, newest first.
+	FindPhotosByRecipeID(ctx context.Context, recipeID, viewerID uint) ([]models.ReviewPhoto, error)
+	// SetAuthorResponse sets the response of the recipe author to the review, hidden or not, or
+	// clears it with the reports on it when response is empty.
+	SetAuthorResponse(ctx context.Context, id uint, response string, at *time.Time, hidden bool) error
+	// SaveVote creates or replaces the vote of the user on the review and recounts its votes.
+	SaveVote(ctx context.Context, vote *models.ReviewVote) error
+	// DeleteVote deletes the vote of the user on the review, if any, and recounts its votes.
+	DeleteVote(ctx context.Context, reviewID, userID uint) error
 }
 
 type reviewRepository struct {
@@ -24,15 +54,21 @@ func NewReviewRepository(db *gorm.DB) ReviewRepository {
 	}
 }
 
-func (repo *reviewRepository) FindAll() ([]models.Review, error) {
+func (repo *reviewRepository) FindAll(ctx context.Context, viewerID uint, page models.PageRequest) ([]models.Review, int, error) {
+	db := database.WithContext(ctx, repo.db).Model(&models.Review{}).Scopes(visibleReviews(viewerID))
+	var total int
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 	var reviews []models.Review
-	err := repo.db.Preload("User.Profile").Preload("Recipe.User").Find(&reviews).Error
-	return reviews, err
+	err := db.Preload("User.Profile").Preload("Recipe.User").Preload("Photos", orderByID).
+		Order("id").Scopes(pageOf(page)).Find(&reviews).Error
+	return reviews, total, err
 }
 
-func (repo *reviewRepository) FindByID(id uint) (*models.Review, error) {
+func (repo *reviewRepository) FindByID(ctx context.Context, id uint) (*models.Review, error) {
 	var review models.Review
-	if err := repo.db.First(&review, id).Error; err != nil {
+	if err := database.WithContext(ctx, repo.db).Preload("User").Preload("Photos", orderByID).First(&review, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -41,23 +77,211 @@ func (repo *reviewRepository) FindByID(id uint) (*models.Review, error) {
 	return &review, nil
 }
 
-func (repo *reviewRepository) Create(req *models.ReviewRequest) (*models.Review, error) {
+func (repo *reviewRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Review, error) {
+	var reviews []models.Review
+	err := database.WithContext(ctx, repo.db).Where("id IN (?)", ids).Order("id").Find(&reviews).Error
+	return reviews, err
+}
+
+func (repo *reviewRepository) FindByRecipeID(ctx context.Context, recipeID, viewerID uint, sort string, page models.PageRequest) ([]models.Review, int, error) {
+	db := database.WithContext(ctx, repo.db).Model(&models.Review{}).Scopes(visibleReviews(viewerID)).
+		Preload("User.Profile").Preload("Photos", orderByID)
+	var total int
+	reviewsOf := db.Where("recipe_id = ? AND parent_id IS NULL", recipeID)
+	if err := reviewsOf.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var reviews []models.Review
+	if err := reviewsOf.Order(reviewOrder(sort)).Scopes(pageOf(page)).Find(&reviews).Error; err != nil {
+		return nil, 0, err
+	}
+
+	parents := make([]uint, 0, len(reviews))
+	for _, review := range reviews {
+		parents = append(parents, review.ID)
+	}
+	for len(parents) > 0 {
+		var replies []models.Review
+		if err := db.Where("parent_id IN (?)", parents).Order("id").Find(&replies).Error; err != nil {
+			return nil, 0, err
+		}
+		parents = parents[:0]
+		for _, reply := range replies {
+			parents = append(parents, reply.ID)
+		}
+		reviews = append(reviews, replies...)
+	}
+	return reviews, total, nil
+}
+
+// reviewOrder returns the ORDER BY of the reviews of a recipe in the order of sort. The most
+// helpful reviews have the highest helpful minus unhelpful votes, then the most helpful votes.
+func reviewOrder(sort string) string {
+	switch sort {
+	case models.ReviewSortNewest:
+		return "id DESC"
+	case models.ReviewSortOldest:
+		return "id"
+	default:
+		return "helpful_count - unhelpful_count DESC, helpful_count DESC, id DESC"
+	}
+}
+
+func (repo *reviewRepository) FindByUserID(ctx context.Context, userID, viewerID uint, page models.PageRequest) ([]models.Review, int, error) {
+	db := database.WithContext(ctx, repo.db).Model(&models.Review{}).Scopes(visibleReviews(viewerID)).Where("user_id = ?", userID)
+	var total int
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var reviews []models.Review
+	err := db.Preload("User.Profile").Preload("Photos", orderByID).Order("id DESC").Scopes(pageOf(page)).Find(&reviews).Error
+	return reviews, total, err
+}
+
+// pageOf selects the rows on the page.
+func pageOf(page models.PageRequest) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Offset(page.Offset()).Limit(page.WithDefaults().PageSize)
+	}
+}
+
+// visibleReviews selects the reviews shown to viewerID: those that are not hidden, of recipes
+// that are not hidden, unless viewerID wrote them.
+func visibleReviews(viewerID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("hidden = ? OR user_id = ?", false, viewerID).
+			Where("recipe_id IN (SELECT id FROM recipes WHERE hidden = ? OR user_id = ?)", false, viewerID)
+	}
+}
+
+func (repo *reviewRepository) Create(ctx context.Context, req *models.ReviewRequest) (*models.Review, error) {
 	review := models.Review{
 		UserID:   req.UserID,
 		RecipeID: req.RecipeID,
+		ParentID: req.ParentID,
+		Depth:    req.Depth,
+		Hidden:   req.Hidden,
 		Content:  req.Content,
 	}
-	err := repo.db.Create(&review).Error
+	err := transaction(database.WithContext(ctx, repo.db), func(tx *gorm.DB) error {
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		for _, url := range req.PhotoURLs {
+			photo := models.ReviewPhoto{ReviewID: review.ID, RecipeID: review.RecipeID, UserID: review.UserID, URL: url}
+			if err := tx.Create(&photo).Error; err != nil {
+				return err
+			}
+			review.Photos = append(review.Photos, photo)
+		}
+		return nil
+	})
 	return &review, err
 }
 
-func (repo *reviewRepository) UpdateReviewByID(review *models.Review, id uint) error {
-	return repo.db.Model(&models.Review{}).Where("id = ?", id).Updates(review).Error
+func (repo *reviewRepository) UpdateReviewByID(ctx context.Context, review *models.Review, id uint) error {
+	return database.WithContext(ctx, repo.db).Model(&models.Review{}).Where("id = ?", id).Updates(review).Error
+}
+
+func (repo *reviewRepository) DeleteReviewByID(ctx context.Context, id uint) ([]string, error) {
+	var photoURLs []string
+	err := transaction(database.WithContext(ctx, repo.db), func(tx *gorm.DB) error {
+		ids := []uint{id}
+		for parents := ids; len(parents) > 0; {
+			var replies []uint
+			if err := tx.Model(&models.Review{}).Where("parent_id IN (?)", parents).Pluck("id", &replies).Error; err != nil {
+				return err
+			}
+			ids = append(ids, replies...)
+			parents = replies
+		}
+
+		if err := tx.Model(&models.ReviewPhoto{}).Where("review_id IN (?)", ids).Order("id").Pluck("url", &photoURLs).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.ReviewVote{}, &models.ReviewPhoto{}} {
+			if err := tx.Where("review_id IN (?)", ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		for _, targetType := range []string{models.ReportTargetReview, models.ReportTargetAuthorResponse} {
+			if err := deleteReports(tx, targetType, ids); err != nil {
+				return err
+			}
+		}
+		return tx.Where("id IN (?)", ids).Delete(&models.Review{}).Error
+	})
+	return photoURLs, err
+}
+
+func (repo *reviewRepository) FindPhotosByRecipeID(ctx context.Context, recipeID, viewerID uint) ([]models.ReviewPhoto, error) {
+	var photos []models.ReviewPhoto
+	err := database.WithContext(ctx, repo.db).Where("recipe_id = ?", recipeID).
+		Where("review_id IN (SELECT id FROM reviews WHERE hidden = ? OR user_id = ?)", false, viewerID).Order("id DESC").Find(&photos).Error
+	return photos, err
+}
+
+func (repo *reviewRepository) SetAuthorResponse(ctx context.Context, id uint, response string, at *time.Time, hidden bool) error {
+	return transaction(database.WithContext(ctx, repo.db), func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Review{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+			"author_response":        response,
+			"author_response_at":     at,
+			"author_response_hidden": hidden,
+		}).Error; err != nil {
+			return err
+		}
+		if response != "" {
+			return nil
+		}
+		return deleteReports(tx, models.ReportTargetAuthorResponse, []uint{id})
+	})
+}
+
+func (repo *reviewRepository) SaveVote(ctx context.Context, vote *models.ReviewVote) error {
+	return transaction(database.WithContext(ctx, repo.db), func(tx *gorm.DB) error {
+		if err := lockReview(tx, vote.ReviewID); err != nil {
+			return err
+		}
+		// An upsert, so concurrent first votes of the user do not both try to insert a vote.
+		if err := tx.Exec("INSERT INTO review_votes (review_id, user_id, helpful) VALUES (?, ?, ?) "+
+			"ON CONFLICT (review_id, user_id) DO UPDATE SET helpful = excluded.helpful",
+			vote.ReviewID, vote.UserID, vote.Helpful).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("review_id = ? AND user_id = ?", vote.ReviewID, vote.UserID).First(vote).Error; err != nil {
+			return err
+		}
+		return countVotes(tx, vote.ReviewID)
+	})
 }
 
-func (repo *reviewRepository) DeleteReviewByID(id uint) error {
-	if err := repo.db.Delete(&models.Review{}, id).Error; err != nil {
-		return err
+func (repo *reviewRepository) DeleteVote(ctx context.Context, reviewID, userID uint) error {
+	return transaction(database.WithContext(ctx, repo.db), func(tx *gorm.DB) error {
+		if err := lockReview(tx, reviewID); err != nil {
+			return err
+		}
+		if err := tx.Where("review_id = ? AND user_id = ?", reviewID, userID).Delete(&models.ReviewVote{}).Error; err != nil {
+			return err
+		}
+		return countVotes(tx, reviewID)
+	})
+}
+
+// lockReview locks the row of the review until the end of the transaction, so concurrent votes
+// on it are counted one after the other and no count misses a vote.
+func lockReview(tx *gorm.DB, reviewID uint) error {
+	return tx.Exec("UPDATE reviews SET helpful_count = helpful_count WHERE id = ?", reviewID).Error
+}
+
+// countVotes stores the number of helpful and unhelpful votes on the review in its counts.
+func countVotes(tx *gorm.DB, reviewID uint) error {
+	counts := map[string]interface{}{}
+	for column, helpful := range map[string]bool{"helpful_count": true, "unhelpful_count": false} {
+		var n int
+		if err := tx.Model(&models.ReviewVote{}).Where("review_id = ? AND helpful = ?", reviewID, helpful).Count(&n).Error; err != nil {
+			return err
+		}
+		counts[column] = n
 	}
-	return nil
+	return tx.Model(&models.Review{}).Where("id = ?", reviewID).UpdateColumns(counts).Error
 }
@@ -0,0 +1,35 @@
+package repositories
+
+import "github.com/jinzhu/gorm"
+
+// Set holds one implementation of every repository, all backed by the same storage.
+type Set struct {
+	Users          UserRepository
+	Profiles       ProfileRepository
+	UserTokens     UserTokenRepository
+	UserIdentities UserIdentityRepository
+	LoginAttempts  LoginAttemptRepository
+	RecoveryCodes  RecoveryCodeRepository
+	Recipes        RecipeRepository
+	Reviews        ReviewRepository
+	Tags           TagRepository
+	Favorites      FavoriteRepository
+	Reports        ReportRepository
+}
+
+// NewGormSet returns the repositories backed by db.
+func NewGormSet(db *gorm.DB) Set {
+	return Set{
+		Users:          NewUserRepository(db),
+		Profiles:       NewProfileRepository(db),
+		UserTokens:     NewUserTokenRepository(db),
+		UserIdentities: NewUserIdentityRepository(db),
+		LoginAttempts:  NewLoginAttemptRepository(db),
+		RecoveryCodes:  NewRecoveryCodeRepository(db),
+		Recipes:        NewRecipeRepository(db),
+		Reviews:        NewReviewRepository(db),
+		Tags:           NewTagRepository(db),
+		Favorites:      NewFavoriteRepository(db),
+		Reports:        NewReportRepository(db),
+	}
+}
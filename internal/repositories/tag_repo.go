@@ -2,16 +2,31 @@ package repositories
 
 import (
 	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
+	"errors"
+	"strings"
 
 	"github.com/jinzhu/gorm"
 )
 
+// ErrTagInUse is returned by TagRepository.Delete when recipes are still tagged with the tag.
+var ErrTagInUse = errors.New("tag is in use")
+
 type TagRepository interface {
-	Create(tag *models.Tag) error
-	GetAllTags() ([]models.Tag, error)
-	GetTagsByNames(names []string) ([]models.Tag, error)
-	Update(tag *models.Tag) error
-	Delete(id uint) error
+	Create(ctx context.Context, tag *models.Tag) error
+	GetAllTags(ctx context.Context) ([]models.Tag, error)
+	GetTagsByNames(ctx context.Context, names []string) ([]models.Tag, error)
+	CountRecipes(ctx context.Context) (map[uint]int, error)
+	// Suggest returns at most limit approved tags with a name or synonym that starts with prefix,
+	// ignoring case, with their synonyms and the number of recipes of each, most used first.
+	Suggest(ctx context.Context, prefix string, limit int) ([]models.Tag, map[uint]int, error)
+	// Update, Approve, Merge and Delete record the decisions of the moderator in the audit trail
+	// in the same transaction as the change.
+	Update(ctx context.Context, tag *models.Tag, action *models.ModerationAction) error
+	Approve(ctx context.Context, id uint, action *models.ModerationAction) error
+	Merge(ctx context.Context, target *models.Tag, sourceIDs []uint, actions []models.ModerationAction) error
+	Delete(ctx context.Context, id uint, action *models.ModerationAction) error
 }
 
 type tagRepository struct {
@@ -22,30 +37,196 @@ func NewTagRepository(db *gorm.DB) TagRepository {
 	return &tagRepository{DB: db}
 }
 
-func (repo *tagRepository) Create(tag *models.Tag) error {
-	return repo.DB.Create(tag).Error
+func (repo *tagRepository) Create(ctx context.Context, tag *models.Tag) error {
+	return database.WithContext(ctx, repo.DB).Create(tag).Error
 }
 
-func (repo *tagRepository) GetAllTags() ([]models.Tag, error) {
+// GetAllTags returns every tag with its synonyms.
+func (repo *tagRepository) GetAllTags(ctx context.Context) ([]models.Tag, error) {
 	var tags []models.Tag
-	if err := repo.DB.Find(&tags).Error; err != nil {
+	if err := database.WithContext(ctx, repo.DB).Preload("Synonyms").Order("id").Find(&tags).Error; err != nil {
 		return nil, err
 	}
 	return tags, nil
 }
 
-func (r *tagRepository) GetTagsByNames(names []string) ([]models.Tag, error) {
+// GetTagsByNames returns the tags named or known by a synonym in names, ignoring case.
+func (r *tagRepository) GetTagsByNames(ctx context.Context, names []string) ([]models.Tag, error) {
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+	}
+
 	var tags []models.Tag
-	if err := r.DB.Where("name IN (?)", names).Find(&tags).Error; err != nil {
+	synonyms := database.WithContext(ctx, r.DB).Model(&models.TagSynonym{}).Select("tag_id").Where("LOWER(name) IN (?)", lower).QueryExpr()
+	if err := database.WithContext(ctx, r.DB).Where("LOWER(name) IN (?) OR id IN (?)", lower, synonyms).Order("id").Find(&tags).Error; err != nil {
 		return nil, err
 	}
 	return tags, nil
 }
 
-func (repo *tagRepository) Update(tag *models.Tag) error {
-	return repo.DB.Save(tag).Error
+// CountRecipes returns the number of recipes shown to everyone of each tag that has any.
+func (r *tagRepository) CountRecipes(ctx context.Context) (map[uint]int, error) {
+	rows, err := database.WithContext(ctx, r.DB).Table("recipe_tags").
+		Select("recipe_tags.tag_id, COUNT(DISTINCT recipe_tags.recipe_id)").
+		Joins("JOIN recipes ON recipes.id = recipe_tags.recipe_id AND recipes.hidden = ?", false).
+		Group("recipe_tags.tag_id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[uint]int)
+	for rows.Next() {
+		var tagID uint
+		var count int
+		if err := rows.Scan(&tagID, &count); err != nil {
+			return nil, err
+		}
+		counts[tagID] = count
+	}
+	return counts, rows.Err()
+}
+
+// Suggest counts the recipes like CountRecipes and breaks ties by name.
+func (r *tagRepository) Suggest(ctx context.Context, prefix string, limit int) ([]models.Tag, map[uint]int, error) {
+	db := database.WithContext(ctx, r.DB)
+	pattern := likePrefix(strings.ToLower(prefix))
+	synonyms := db.Model(&models.TagSynonym{}).Select("tag_id").Where(`LOWER(name) LIKE ? ESCAPE '\'`, pattern).QueryExpr()
+	rows, err := db.Table("tags").
+		Select("tags.id, COUNT(DISTINCT recipes.id) AS recipe_count").
+		Joins("LEFT JOIN recipe_tags ON recipe_tags.tag_id = tags.id").
+		Joins("LEFT JOIN recipes ON recipes.id = recipe_tags.recipe_id AND recipes.hidden = ?", false).
+		Where("tags.status = ?", models.TagStatusApproved).
+		Where(`LOWER(tags.name) LIKE ? ESCAPE '\' OR tags.id IN (?)`, pattern, synonyms).
+		Group("tags.id, tags.name").Order("recipe_count DESC, LOWER(tags.name), tags.id").Limit(limit).Rows()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint
+	counts := make(map[uint]int)
+	for rows.Next() {
+		var id uint
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		counts[id] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var found []models.Tag
+	if err := db.Preload("Synonyms", orderByID).Where("id IN (?)", ids).Find(&found).Error; err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[uint]models.Tag, len(found))
+	for _, tag := range found {
+		byID[tag.ID] = tag
+	}
+	tags := make([]models.Tag, 0, len(ids))
+	for _, id := range ids {
+		tags = append(tags, byID[id])
+	}
+	return tags, counts, nil
+}
+
+// likePrefix returns the LIKE pattern, with '\' as the escape character, of the strings that
+// start with prefix.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
+// Update saves the tag and replaces its synonyms.
+func (repo *tagRepository) Update(ctx context.Context, tag *models.Tag, action *models.ModerationAction) error {
+	return transaction(database.WithContext(ctx, repo.DB), func(tx *gorm.DB) error {
+		if err := saveTag(tx, tag); err != nil {
+			return err
+		}
+		return tx.Create(action).Error
+	})
+}
+
+// Approve makes the pending tag visible.
+func (repo *tagRepository) Approve(ctx context.Context, id uint, action *models.ModerationAction) error {
+	return transaction(database.WithContext(ctx, repo.DB), func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Tag{}).Where("id = ?", id).UpdateColumn("status", models.TagStatusApproved).Error; err != nil {
+			return err
+		}
+		return tx.Create(action).Error
+	})
 }
 
-func (repo *tagRepository) Delete(id uint) error {
-	return repo.DB.Delete(&models.Tag{}, id).Error
+// Merge moves the recipes and sub-tags of the source tags to target, deletes the sources and
+// saves target with its synonyms. Recipes tagged with several of the tags keep a single tag.
+func (repo *tagRepository) Merge(ctx context.Context, target *models.Tag, sourceIDs []uint, actions []models.ModerationAction) error {
+	return transaction(database.WithContext(ctx, repo.DB), func(tx *gorm.DB) error {
+		for i := range actions {
+			if err := tx.Create(&actions[i]).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("INSERT INTO recipe_tags (recipe_id, tag_id) SELECT DISTINCT recipe_id, ? FROM recipe_tags "+
+			"WHERE tag_id IN (?) AND recipe_id NOT IN (SELECT recipe_id FROM recipe_tags WHERE tag_id = ?)",
+			target.ID, sourceIDs, target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id IN (?)", sourceIDs).Delete(&models.RecipeTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Tag{}).Where("parent_id IN (?) AND id <> ?", sourceIDs, target.ID).
+			UpdateColumn("parent_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id IN (?)", sourceIDs).Delete(&models.TagSynonym{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN (?)", sourceIDs).Delete(&models.Tag{}).Error; err != nil {
+			return err
+		}
+		return saveTag(tx, target)
+	})
+}
+
+// Delete removes the tag with its synonyms, failing with ErrTagInUse while recipes are tagged
+// with it. Its children become top-level tags.
+func (repo *tagRepository) Delete(ctx context.Context, id uint, action *models.ModerationAction) error {
+	return transaction(database.WithContext(ctx, repo.DB), func(tx *gorm.DB) error {
+		var uses int
+		if err := tx.Table("recipe_tags").Joins("JOIN recipes ON recipes.id = recipe_tags.recipe_id").
+			Where("recipe_tags.tag_id = ?", id).Count(&uses).Error; err != nil {
+			return err
+		}
+		if uses > 0 {
+			return ErrTagInUse
+		}
+
+		for _, model := range []interface{}{&models.RecipeTag{}, &models.TagSynonym{}} {
+			if err := tx.Where("tag_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Tag{}).Where("parent_id = ?", id).UpdateColumn("parent_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Tag{}, id).Error; err != nil {
+			return err
+		}
+		return tx.Create(action).Error
+	})
+}
+
+// saveTag saves the tag and replaces its synonyms.
+func saveTag(tx *gorm.DB, tag *models.Tag) error {
+	if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.TagSynonym{}).Error; err != nil {
+		return err
+	}
+	for i := range tag.Synonyms {
+		tag.Synonyms[i].ID = 0
+	}
+	return tx.Save(tag).Error
 }
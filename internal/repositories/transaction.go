@@ -0,0 +1,26 @@
+package repositories
+
+import "github.com/jinzhu/gorm"
+
+// transaction runs fn inside a database transaction, committing when fn succeeds and rolling
+// back when it returns an error or panics.
+func transaction(db *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
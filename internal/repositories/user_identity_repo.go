@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+type UserIdentityRepository interface {
+	FindByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	Create(ctx context.Context, identity *models.UserIdentity) error
+}
+
+type userIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewUserIdentityRepository(db *gorm.DB) UserIdentityRepository {
+	return &userIdentityRepository{db: db}
+}
+
+func (r *userIdentityRepository) FindByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := database.WithContext(ctx, r.db).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *userIdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	return database.WithContext(ctx, r.db).Create(identity).Error
+}
@@ -2,17 +2,27 @@ package repositories
 
 import (
 	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
 
 type UserRepository interface {
-	Create(user *models.User) error
-	FindByID(id uint) (*models.User, error)
-	Update(user *models.User) error
-	GetUserByEmailOrUsername(emailOrUsername string) (*models.User, error)
-	CheckUserEmail(email string) (*models.User, error)
-	Delete(id uint) error
+	Create(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, id uint) (*models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	GetUserByEmailOrUsername(ctx context.Context, emailOrUsername string) (*models.User, error)
+	CheckUserEmail(ctx context.Context, email string) (*models.User, error)
+	Delete(ctx context.Context, id uint) error
+	SaveDeletionRequest(ctx context.Context, deletion *models.AccountDeletion) error
+	FindDeletionRequest(ctx context.Context, userID uint) (*models.AccountDeletion, error)
+	DeleteDeletionRequest(ctx context.Context, userID uint) error
+	FindDueDeletionRequests(ctx context.Context, before time.Time) ([]models.AccountDeletion, error)
+	Anonymize(ctx context.Context, deletion *models.AccountDeletion) ([]string, error)
 }
 
 type userRepository struct {
@@ -23,29 +33,34 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{DB: db}
 }
 
-func (r *userRepository) Create(user *models.User) error {
-	return r.DB.Create(user).Error
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return database.WithContext(ctx, r.DB).Create(user).Error
 }
 
-func (r *userRepository) FindByID(id uint) (*models.User, error) {
+func (r *userRepository) FindByID(ctx context.Context, id uint) (*models.User, error) {
 	var user models.User
-	err := r.DB.Preload("Profile").Preload("Reviews").Preload("Favorites").First(&user, id).Error
+	err := database.WithContext(ctx, r.DB).Preload("Profile").Preload("Reviews").Preload("Favorites").First(&user, id).Error
 	return &user, err
 }
 
-func (r *userRepository) Update(user *models.User) error {
-	return r.DB.Save(user).Error
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	return database.WithContext(ctx, r.DB).Save(user).Error
 }
 
-func (r *userRepository) GetUserByEmailOrUsername(emailOrUsername string) (*models.User, error) {
+func (r *userRepository) GetUserByEmailOrUsername(ctx context.Context, emailOrUsername string) (*models.User, error) {
 	var user models.User
-	err := r.DB.Where("email = ? OR username = ?", emailOrUsername, emailOrUsername).First(&user).Error
-	return &user, err
+	if err := database.WithContext(ctx, r.DB).Where("email = ? OR username = ?", emailOrUsername, emailOrUsername).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
 }
 
-func (repo *userRepository) CheckUserEmail(email string) (*models.User, error) {
+func (repo *userRepository) CheckUserEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	if err := repo.DB.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := database.WithContext(ctx, repo.DB).Where("email = ?", email).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -54,6 +69,115 @@ func (repo *userRepository) CheckUserEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) Delete(id uint) error {
-	return r.DB.Delete(&models.User{}, id).Error
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
+	return database.WithContext(ctx, r.DB).Delete(&models.User{}, id).Error
+}
+
+func (r *userRepository) SaveDeletionRequest(ctx context.Context, deletion *models.AccountDeletion) error {
+	return database.WithContext(ctx, r.DB).Save(deletion).Error
+}
+
+func (r *userRepository) FindDeletionRequest(ctx context.Context, userID uint) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	if err := database.WithContext(ctx, r.DB).Where("user_id = ? AND completed_at IS NULL", userID).First(&deletion).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+func (r *userRepository) DeleteDeletionRequest(ctx context.Context, userID uint) error {
+	return database.WithContext(ctx, r.DB).Where("user_id = ? AND completed_at IS NULL", userID).Delete(&models.AccountDeletion{}).Error
+}
+
+func (r *userRepository) FindDueDeletionRequests(ctx context.Context, before time.Time) ([]models.AccountDeletion, error) {
+	var deletions []models.AccountDeletion
+	err := database.WithContext(ctx, r.DB).Where("completed_at IS NULL AND scheduled_for <= ?", before).Find(&deletions).Error
+	return deletions, err
+}
+
+// Anonymize scrubs the personal data of the user in a single transaction. Reviews are kept and
+// attributed to the anonymized account, favorites, the profile, tokens, linked identities,
+// recovery codes and login attempts are removed, the password and two-factor secret are cleared
+// and recipes are transferred or deleted according to the deletion request. The URLs of images that are no longer referenced are returned so they
+// can be removed from storage.
+func (r *userRepository) Anonymize(ctx context.Context, deletion *models.AccountDeletion) ([]string, error) {
+	var imageURLs []string
+
+	err := transaction(database.WithContext(ctx, r.DB), func(tx *gorm.DB) error {
+		userID := deletion.UserID
+
+		switch deletion.RecipeAction {
+		case models.RecipeActionTransfer:
+			if err := tx.Model(&models.Recipe{}).Where("user_id = ?", userID).
+				Update("user_id", *deletion.TransferToUserID).Error; err != nil {
+				return err
+			}
+		default:
+			recipeIDs := tx.Model(&models.Recipe{}).Select("id").Where("user_id = ?", userID).QueryExpr()
+			urls, err := deleteRecipes(tx, recipeIDs)
+			if err != nil {
+				return err
+			}
+			imageURLs = append(imageURLs, urls...)
+		}
+
+		for _, model := range []interface{}{&models.Favorite{}, &models.UserToken{}, &models.UserIdentity{}, &models.RecoveryCode{}} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		// Attempts are recorded under the lowercased name or email that was typed, including the
+		// ones that named the account while it was locked out or did not match it.
+		if err := tx.Where("user_id = ? OR identifier IN (?)", userID, loginIdentifiers(&user)).Delete(&models.LoginAttempt{}).Error; err != nil {
+			return err
+		}
+
+		var profile models.Profile
+		err := tx.Where("user_id = ?", userID).First(&profile).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if err == nil {
+			if profile.AvatarURL != "" {
+				imageURLs = append(imageURLs, profile.AvatarURL)
+			}
+			if err := tx.Delete(&profile).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"username":          models.DeletedUsername,
+			"email":             fmt.Sprintf("deleted-%d@users.invalid", userID),
+			"password":          "",
+			"email_verified_at": nil,
+			"totp_secret":       "",
+			"totp_enabled_at":   nil,
+			"totp_last_step":    0,
+			"anonymized_at":     now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(deletion).Update("completed_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imageURLs, nil
+}
+
+// loginIdentifiers returns the identifiers login attempts naming the user are recorded under.
+func loginIdentifiers(user *models.User) []string {
+	return []string{strings.ToLower(user.Username), strings.ToLower(user.Email)}
 }
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/database"
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *models.UserToken) error
+	FindValid(ctx context.Context, purpose, tokenHash string, now time.Time) (*models.UserToken, error)
+	MarkUsed(ctx context.Context, id uint, usedAt time.Time) error
+	InvalidateForUser(ctx context.Context, userID uint, purpose string, now time.Time) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	return database.WithContext(ctx, r.db).Create(token).Error
+}
+
+// FindValid returns the unused, unexpired token with the given hash, or nil when there is none.
+func (r *userTokenRepository) FindValid(ctx context.Context, purpose, tokenHash string, now time.Time) (*models.UserToken, error) {
+	var token models.UserToken
+	err := database.WithContext(ctx, r.db).Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", purpose, tokenHash, now).
+		First(&token).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes the token. It fails with gorm.ErrRecordNotFound when the token was already
+// used, so two concurrent requests cannot both redeem it.
+func (r *userTokenRepository) MarkUsed(ctx context.Context, id uint, usedAt time.Time) error {
+	res := database.WithContext(ctx, r.db).Model(&models.UserToken{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", usedAt)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// InvalidateForUser marks every outstanding token of the purpose as used.
+func (r *userTokenRepository) InvalidateForUser(ctx context.Context, userID uint, purpose string, now time.Time) error {
+	return database.WithContext(ctx, r.db).Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", now).Error
+}
@@ -1,25 +1,62 @@
 package routes
 
 import (
+	"api-culinary-review/config"
 	"api-culinary-review/internal/controllers"
 	"api-culinary-review/internal/middlewares"
-	"api-culinary-review/internal/repositories"
-	"api-culinary-review/internal/usecases"
+	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/idgen"
+	"api-culinary-review/pkg/jwt"
+	"api-culinary-review/pkg/metrics"
+	"api-culinary-review/pkg/ratelimit"
+	"api-culinary-review/pkg/utils"
+	"log/slog"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/jinzhu/gorm"
+	"github.com/gin-gonic/gin/binding"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRouter(db *gorm.DB) *gin.Engine {
-	router := gin.Default()
+// Handlers are the controllers and services the routes are served by.
+type Handlers struct {
+	User       controllers.UserController
+	Profile    controllers.ProfileController
+	Recipe     controllers.RecipeController
+	Review     controllers.ReviewController
+	Favorite   controllers.FavoriteController
+	Tag        controllers.TagController
+	Nutrition  controllers.NutritionController
+	Moderation controllers.ModerationController
+	Health     controllers.HealthController
+
+	Tokens     *jwt.Manager
+	Roles      middlewares.RoleFunc
+	Active     middlewares.ActiveFunc
+	IDs        idgen.Generator
+	RateLimits ratelimit.Store
+}
+
+// SetupRouter registers the middlewares and routes of the API.
+func SetupRouter(cfg *config.Config, h Handlers, logger *slog.Logger) *gin.Engine {
+	if cfg.Env != "development" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	// Only the configured proxies may name the client in X-Forwarded-For, so ClientIP, which keys
+	// the rate limits and login lockouts, cannot be forged. The configuration is validated at load.
+	if err := router.SetTrustedProxies(cfg.TrustedProxyList()); err != nil {
+		logger.Error("invalid trusted proxies, trusting none", slog.Any("error", err))
+		_ = router.SetTrustedProxies(nil)
+	}
+	router.Use(middlewares.RequestIDMiddleware(h.IDs), middlewares.TracingMiddleware(), middlewares.AccessLogMiddleware(logger), middlewares.MetricsMiddleware(), middlewares.RecoveryMiddleware(logger))
 
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowAllOrigins = true
-	corsConfig.AllowHeaders = []string{"Content-Type", "X-XSRF-TOKEN", "Accept", "Origin", "X-Requested-With", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Content-Type", "X-XSRF-TOKEN", "Accept", "Origin", "X-Requested-With", "Authorization", middlewares.RequestIDHeader, "traceparent", "tracestate"}
+	corsConfig.ExposeHeaders = []string{middlewares.RequestIDHeader, "Retry-After"}
 
 	// To be able to send tokens to the server.
 	corsConfig.AllowCredentials = true
@@ -27,69 +64,96 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	corsConfig.AddAllowMethods("OPTIONS")
 
 	router.Use(cors.New(corsConfig))
+	router.Use(middlewares.ErrorMiddleware(logger))
 
-	profileRepo := repositories.NewProfileRepository(db)
-	profileUc := usecases.NewProfileUsecase(profileRepo)
-	profileCtrl := controllers.NewProfileController(profileUc)
-	userRepo := repositories.NewUserRepository(db)
-	userUc := usecases.NewUserUsecase(userRepo, profileRepo)
-	userCtrl := controllers.NewUserController(userUc)
-
-	tagRepo := repositories.NewTagRepository(db)
-	tagUc := usecases.NewtagUsecase(tagRepo)
-	tagCtrl := controllers.NewTagController(tagUc)
-
-	recipeRepo := repositories.NewRecipeRepository(db)
-	recipeUc := usecases.NewRecipeUsecase(recipeRepo)
-	recipeCtrl := controllers.NewRecipeController(recipeUc, tagUc)
+	// Validate request bodies with the shared validator and its translated messages
+	binding.Validator = utils.StructValidator{}
 
-	reviewRepo := repositories.NewReviewRepository(db)
-	reviewUc := usecases.NewReviewUsecase(reviewRepo)
-	reviewCtrl := controllers.NewReviewController(reviewUc)
+	defaultLimit := middlewares.RateLimitMiddleware(h.RateLimits, "default", cfg.RateLimitDefault, logger)
+	authLimit := middlewares.RateLimitMiddleware(h.RateLimits, "auth", cfg.RateLimitAuth, logger)
+	uploadLimit := middlewares.RateLimitMiddleware(h.RateLimits, "upload", cfg.RateLimitUpload, logger)
 
-	favoriteRepo := repositories.NewFavoriteRepository(db)
-	favoriteUc := usecases.NewFavoriteUsecase(favoriteRepo)
-	favoriteCtrl := controllers.NewFavoriteController(favoriteUc)
+	moderator := middlewares.RequireRole(h.Roles, models.RoleModerator, models.RoleAdmin)
 
 	authGroup := router.Group("/api")
-	authGroup.Use(middlewares.JWTAuthMiddleware())
+	authGroup.Use(middlewares.JWTAuthMiddleware(h.Tokens, h.Active), defaultLimit)
 	{
-		authGroup.GET("/detail-user", userCtrl.GetUserByID)
-		authGroup.PUT("/change-password", userCtrl.ChangePassword)
-
-		authGroup.POST("/profile", profileCtrl.CreateProfile)
-		authGroup.GET("/profile/me", profileCtrl.GetProfileByUserID)
-		authGroup.PUT("/profile", profileCtrl.UpdateProfileByUserID)
-
-		authGroup.POST("/recipes", recipeCtrl.CreateRecipe)
-		authGroup.PUT("/recipes/:id", recipeCtrl.UpdateRecipe)
-		authGroup.DELETE("/recipes/:id", recipeCtrl.DeleteRecipe)
-
-		authGroup.POST("/reviews", reviewCtrl.CreateReview)
-		authGroup.PUT("/reviews/:id", reviewCtrl.UpdateReviewByID)
-		authGroup.DELETE("/reviews/:id", reviewCtrl.DeleteReviewByID)
-
-		authGroup.POST("/favorites", favoriteCtrl.CreateFavorite)
-		authGroup.GET("/favorites", favoriteCtrl.GetByUserID)
-		authGroup.DELETE("/favorites/:id", favoriteCtrl.DeleteFavorite)
-
-		authGroup.GET("/tags", tagCtrl.GetAllTags)
-		authGroup.POST("tags", tagCtrl.CreateTag)
-		authGroup.PUT("/tags/:id", tagCtrl.UpdateTag)
-		authGroup.DELETE("/tags/:id", tagCtrl.DeleteTag)
+		authGroup.GET("/detail-user", h.User.GetUserByID)
+		authGroup.PUT("/change-password", h.User.ChangePassword)
+		authGroup.DELETE("/me", h.User.DeleteAccount)
+		authGroup.DELETE("/me/deletion", h.User.CancelAccountDeletion)
+		authGroup.POST("/email/resend-verification", authLimit, h.User.ResendVerificationEmail)
+		authGroup.POST("/2fa/enroll", h.User.BeginTwoFactorEnrollment)
+		authGroup.POST("/2fa/confirm", authLimit, h.User.ConfirmTwoFactorEnrollment)
+		authGroup.POST("/2fa/disable", authLimit, h.User.DisableTwoFactor)
+
+		authGroup.POST("/profile", uploadLimit, h.Profile.CreateProfile)
+		authGroup.GET("/profile/me", h.Profile.GetProfileByUserID)
+		authGroup.PUT("/profile", uploadLimit, h.Profile.UpdateProfileByUserID)
+		authGroup.PUT("/profile/dietary-preferences", h.Profile.UpdateDietaryPreferences)
+
+		authGroup.POST("/recipes", uploadLimit, h.Recipe.CreateRecipe)
+		authGroup.PUT("/recipes/:id", uploadLimit, h.Recipe.UpdateRecipe)
+		authGroup.DELETE("/recipes/:id", h.Recipe.DeleteRecipe)
+		authGroup.POST("/recipes/:id/reviews", uploadLimit, h.Review.CreateRecipeReview)
+		authGroup.POST("/recipes/:id/report", h.Moderation.ReportRecipe)
+		authGroup.PUT("/recipes/:id/favorite", h.Favorite.PutFavorite)
+		authGroup.DELETE("/recipes/:id/favorite", h.Favorite.DeleteFavorite)
+
+		authGroup.POST("/reviews", uploadLimit, h.Review.CreateReview)
+		authGroup.PUT("/reviews/:id", h.Review.UpdateReviewByID)
+		authGroup.DELETE("/reviews/:id", h.Review.DeleteReviewByID)
+		authGroup.POST("/reviews/:id/replies", h.Review.ReplyToReview)
+		authGroup.PUT("/reviews/:id/response", h.Review.SetAuthorResponse)
+		authGroup.DELETE("/reviews/:id/response", h.Review.DeleteAuthorResponse)
+		authGroup.PUT("/reviews/:id/vote", h.Review.VoteReview)
+		authGroup.DELETE("/reviews/:id/vote", h.Review.DeleteReviewVote)
+		authGroup.POST("/reviews/:id/report", h.Moderation.ReportReview)
+
+		authGroup.GET("/favorites", h.Favorite.GetByUserID)
+
+		authGroup.POST("/tags", h.Tag.CreateTag)
+		authGroup.PUT("/tags/:id", moderator, h.Tag.UpdateTag)
+		authGroup.POST("/tags/:id/merge", moderator, h.Tag.MergeTags)
+		authGroup.DELETE("/tags/:id", moderator, h.Tag.DeleteTag)
+
+		authGroup.GET("/moderation/tags", moderator, h.Tag.GetPendingTags)
+		authGroup.POST("/moderation/tags/:id/approve", moderator, h.Tag.ApproveTag)
+		authGroup.GET("/moderation/reports", moderator, h.Moderation.GetReports)
+		authGroup.POST("/moderation/reports/:id/resolve", moderator, h.Moderation.ResolveReport)
+		authGroup.GET("/moderation/actions", moderator, h.Moderation.GetActions)
 	}
 
 	publicGroup := router.Group("/api")
+	publicGroup.Use(defaultLimit)
 	{
-		publicGroup.GET("/recipes", recipeCtrl.GetRecipes)
-		publicGroup.GET("/recipes/:id", recipeCtrl.GetRecipeByID)
-		publicGroup.GET("/reviews", reviewCtrl.GetAllReviews)
-		publicGroup.GET("/reviews/:id", reviewCtrl.GetReviewByID)
-		publicGroup.POST("/register", userCtrl.Register)
-		publicGroup.POST("/login", userCtrl.Login)
+		// Signed-in users also see their own content that is hidden by moderation.
+		optionalAuth := middlewares.OptionalJWTAuthMiddleware(h.Tokens, h.Active)
+		publicGroup.GET("/recipes", optionalAuth, h.Recipe.GetRecipes)
+		publicGroup.GET("/recipes/:id", optionalAuth, h.Recipe.GetRecipeByID)
+		publicGroup.GET("/recipes/:id/reviews", optionalAuth, h.Review.GetRecipeReviews)
+		publicGroup.GET("/recipes/:id/photos", optionalAuth, h.Review.GetRecipePhotos)
+		publicGroup.GET("/reviews", optionalAuth, h.Review.GetAllReviews)
+		publicGroup.GET("/reviews/:id", optionalAuth, h.Review.GetReviewByID)
+		publicGroup.GET("/users/:id/reviews", optionalAuth, h.Review.GetUserReviews)
+		publicGroup.GET("/tags", h.Tag.GetAllTags)
+		publicGroup.GET("/tags/suggest", h.Tag.SuggestTags)
+		publicGroup.GET("/tags/:slug", h.Tag.GetTag)
+		publicGroup.GET("/nutrition/foods", h.Nutrition.SearchFoods)
+		publicGroup.POST("/register", authLimit, h.User.Register)
+		publicGroup.POST("/login", authLimit, h.User.Login)
+		publicGroup.POST("/login/2fa", authLimit, h.User.LoginTwoFactor)
+		publicGroup.POST("/email/verify", authLimit, h.User.VerifyEmail)
+		publicGroup.POST("/password/forgot", authLimit, h.User.ForgotPassword)
+		publicGroup.POST("/password/reset", authLimit, h.User.ResetPassword)
+		publicGroup.GET("/auth/:provider/login", h.User.OAuthLogin)
+		publicGroup.GET("/auth/:provider/callback", h.User.OAuthCallback)
 	}
 
+	router.GET("/healthz", h.Health.Healthz)
+	router.GET("/readyz", h.Health.Readyz)
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	return router
 }
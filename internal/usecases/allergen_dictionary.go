@@ -0,0 +1,91 @@
+package usecases
+
+import (
+	"api-culinary-review/internal/models"
+	"strings"
+)
+
+// ingredientAllergens maps ingredient names, in English and Indonesian, to their allergens.
+// Names mapped to no allergen stop a shorter name from matching, like "coconut milk" for "milk".
+var ingredientAllergens = map[string]models.Allergens{
+	// Gluten
+	"flour": models.AllergenGluten, "wheat": models.AllergenGluten, "bread": models.AllergenGluten,
+	"breadcrumbs": models.AllergenGluten, "pasta": models.AllergenGluten, "spaghetti": models.AllergenGluten,
+	"noodles": models.AllergenGluten, "barley": models.AllergenGluten, "rye": models.AllergenGluten,
+	"semolina": models.AllergenGluten, "couscous": models.AllergenGluten, "seitan": models.AllergenGluten,
+	"tepung terigu": models.AllergenGluten, "terigu": models.AllergenGluten, "roti": models.AllergenGluten,
+	"tepung roti": models.AllergenGluten, "mie": models.AllergenGluten,
+	"bakmi": models.AllergenGluten, "gandum": models.AllergenGluten,
+	"rice flour": 0, "tepung beras": 0, "tepung ketan": 0, "tepung tapioka": 0, "tepung maizena": 0,
+	"rice noodles": 0, "bihun": 0, "soun": 0, "kwetiau": 0,
+
+	// Dairy
+	"milk": models.AllergenDairy, "butter": models.AllergenDairy, "cheese": models.AllergenDairy,
+	"cream": models.AllergenDairy, "yogurt": models.AllergenDairy, "ghee": models.AllergenDairy,
+	"susu": models.AllergenDairy, "mentega": models.AllergenDairy, "keju": models.AllergenDairy,
+	"krim": models.AllergenDairy, "yoghurt": models.AllergenDairy,
+	"coconut milk": 0, "coconut cream": 0, "santan": 0, "almond milk": models.AllergenNuts,
+	"soy milk": models.AllergenSoy, "susu kedelai": models.AllergenSoy, "peanut butter": models.AllergenNuts,
+	"selai kacang": models.AllergenNuts,
+
+	// Nuts
+	"peanut": models.AllergenNuts, "peanuts": models.AllergenNuts, "almond": models.AllergenNuts,
+	"almonds": models.AllergenNuts, "cashew": models.AllergenNuts, "cashews": models.AllergenNuts,
+	"walnut": models.AllergenNuts, "walnuts": models.AllergenNuts, "hazelnut": models.AllergenNuts,
+	"pecan": models.AllergenNuts, "pistachio": models.AllergenNuts, "macadamia": models.AllergenNuts,
+	"kacang": models.AllergenNuts, "kacang tanah": models.AllergenNuts, "kacang mete": models.AllergenNuts,
+	"kemiri": models.AllergenNuts, "bumbu kacang": models.AllergenNuts,
+	"kacang panjang": 0, "kacang hijau": 0, "kacang merah": 0, "buncis": 0,
+	"kacang kedelai": models.AllergenSoy,
+
+	// Shellfish
+	"shrimp": models.AllergenShellfish, "prawn": models.AllergenShellfish, "prawns": models.AllergenShellfish,
+	"crab": models.AllergenShellfish, "lobster": models.AllergenShellfish, "clam": models.AllergenShellfish,
+	"clams": models.AllergenShellfish, "mussel": models.AllergenShellfish, "mussels": models.AllergenShellfish,
+	"oyster": models.AllergenShellfish, "oysters": models.AllergenShellfish, "scallop": models.AllergenShellfish,
+	"squid": models.AllergenShellfish, "udang": models.AllergenShellfish, "ebi": models.AllergenShellfish,
+	"terasi": models.AllergenShellfish, "kepiting": models.AllergenShellfish, "rajungan": models.AllergenShellfish,
+	"kerang": models.AllergenShellfish, "tiram": models.AllergenShellfish, "cumi": models.AllergenShellfish,
+	"cumi-cumi": models.AllergenShellfish, "oyster sauce": models.AllergenShellfish, "saus tiram": models.AllergenShellfish,
+
+	// Egg
+	"egg": models.AllergenEgg, "eggs": models.AllergenEgg, "mayonnaise": models.AllergenEgg,
+	"telur": models.AllergenEgg, "telor": models.AllergenEgg, "mayones": models.AllergenEgg,
+
+	// Soy
+	"soy": models.AllergenSoy, "soybean": models.AllergenSoy, "soybeans": models.AllergenSoy,
+	"soy sauce": models.AllergenSoy | models.AllergenGluten, "tofu": models.AllergenSoy,
+	"tempeh": models.AllergenSoy, "miso": models.AllergenSoy, "edamame": models.AllergenSoy,
+	"kedelai": models.AllergenSoy, "kecap": models.AllergenSoy, "kecap manis": models.AllergenSoy,
+	"kecap asin": models.AllergenSoy, "tahu": models.AllergenSoy, "tempe": models.AllergenSoy,
+	"tauco": models.AllergenSoy,
+}
+
+// maxIngredientWords is the number of words of the longest name in ingredientAllergens.
+const maxIngredientWords = 2
+
+// deriveAllergens returns the allergens of the ingredients found in ingredientAllergens. At each
+// word of an ingredient name the longest known name wins, so "coconut milk" does not count as milk.
+func deriveAllergens(ingredients []models.RecipeIngredient) models.Allergens {
+	var allergens models.Allergens
+	for _, ingredient := range ingredients {
+		words := strings.FieldsFunc(strings.ToLower(ingredient.Name), func(r rune) bool {
+			return !(r == '-' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+		})
+		for i := 0; i < len(words); {
+			matched := 1
+			for n := maxIngredientWords; n >= 1; n-- {
+				if i+n > len(words) {
+					continue
+				}
+				if a, ok := ingredientAllergens[strings.Join(words[i:i+n], " ")]; ok {
+					allergens |= a
+					matched = n
+					break
+				}
+			}
+			i += matched
+		}
+	}
+	return allergens
+}
@@ -0,0 +1,687 @@
+package usecases
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/idgen"
+	"api-culinary-review/pkg/mailer"
+	"api-culinary-review/pkg/metrics"
+	"api-culinary-review/pkg/oauth"
+	"api-culinary-review/pkg/totp"
+	"api-culinary-review/pkg/tracing"
+	"api-culinary-review/pkg/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = apperror.Validation("token is invalid or has expired",
+		apperror.FieldError{Field: "token", Message: "is invalid or has expired"})
+	ErrEmailAlreadyVerified = apperror.Conflict("email is already verified")
+	ErrUnknownProvider      = apperror.NotFound("unknown identity provider")
+	ErrUnverifiedEmail      = apperror.Unauthorized("the identity provider has not verified this email address")
+	ErrUnverifiedAccount    = apperror.Conflict("an account with this email address exists; verify its email before signing in with a provider")
+	ErrInvalidCredentials   = apperror.Unauthorized("Invalid email or password")
+	ErrInvalidChallenge     = apperror.Unauthorized("Invalid or expired challenge token")
+
+	ErrTwoFactorAlreadyEnabled = apperror.Conflict("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = apperror.Validation("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled    = apperror.Validation("two-factor enrollment has not been started")
+	ErrInvalidTwoFactorCode    = apperror.Validation("invalid two-factor code",
+		apperror.FieldError{Field: "code", Message: "is invalid"})
+)
+
+// recoveryCodeEncoding spells recovery codes with lowercase letters and digits only.
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// dummyPasswordHash is compared against when a login names an unknown user, so the response
+// takes as long as for a wrong password and cannot be used to discover accounts.
+var dummyPasswordHash, _ = utils.HashPassword("not-the-password-of-any-account")
+
+// loginLocked is returned while an account or client IP is locked out after too many failed
+// login attempts.
+func loginLocked(retryAfter time.Duration) error {
+	return apperror.TooManyRequests("too many failed login attempts, try again later", retryAfter)
+}
+
+type AuthUsecase interface {
+	Login(ctx context.Context, identifier, password, ip string) (*models.User, error)
+	SendVerificationEmail(ctx context.Context, user *models.User) error
+	ResendVerificationEmail(ctx context.Context, userID uint) error
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	OAuthLoginURL(ctx context.Context, provider, state string) (string, error)
+	LoginWithOAuth(ctx context.Context, provider, code string) (*models.User, error)
+	BeginTOTPEnrollment(ctx context.Context, userID uint) (*models.TOTPEnrollment, error)
+	ConfirmTOTPEnrollment(ctx context.Context, userID uint, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID uint, code string) error
+	VerifyTwoFactor(ctx context.Context, userID uint, code, ip string) (*models.User, error)
+}
+
+// AuthOptions configures the links and token lifetimes used in account emails and the
+// protection of password logins.
+type AuthOptions struct {
+	BaseURL              string
+	VerificationTokenTTL time.Duration
+	ResetTokenTTL        time.Duration
+	LoginThrottle        LoginThrottle
+	TOTPIssuer           string
+	RecoveryCodeCount    int
+}
+
+// LoginThrottle configures lockouts after failed logins. Once the failures of an account since
+// its last successful login, or the failures from an IP, reach the threshold, further attempts
+// are refused for BaseLockout, doubling with every additional failure up to MaxLockout.
+// Failures older than Window are forgotten.
+type LoginThrottle struct {
+	Window           time.Duration
+	AccountThreshold int
+	IPThreshold      int
+	BaseLockout      time.Duration
+	MaxLockout       time.Duration
+}
+
+type authUsecase struct {
+	userRepo     repositories.UserRepository
+	profileRepo  repositories.ProfileRepository
+	tokenRepo    repositories.UserTokenRepository
+	identityRepo repositories.UserIdentityRepository
+	attemptRepo  repositories.LoginAttemptRepository
+	recoveryRepo repositories.RecoveryCodeRepository
+	mailer       mailer.Mailer
+	providers    map[string]*oauth.Provider
+	clock        clock.Clock
+	ids          idgen.Generator
+	opts         AuthOptions
+	logger       *slog.Logger
+}
+
+func NewAuthUsecase(
+	userRepo repositories.UserRepository,
+	profileRepo repositories.ProfileRepository,
+	tokenRepo repositories.UserTokenRepository,
+	identityRepo repositories.UserIdentityRepository,
+	attemptRepo repositories.LoginAttemptRepository,
+	recoveryRepo repositories.RecoveryCodeRepository,
+	m mailer.Mailer,
+	providers []*oauth.Provider,
+	clock clock.Clock,
+	ids idgen.Generator,
+	opts AuthOptions,
+	logger *slog.Logger,
+) AuthUsecase {
+	byName := make(map[string]*oauth.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+
+	return &authUsecase{
+		userRepo:     userRepo,
+		profileRepo:  profileRepo,
+		tokenRepo:    tokenRepo,
+		identityRepo: identityRepo,
+		attemptRepo:  attemptRepo,
+		recoveryRepo: recoveryRepo,
+		mailer:       m,
+		providers:    byName,
+		clock:        clock,
+		ids:          ids,
+		opts:         opts,
+		logger:       logger,
+	}
+}
+
+// Login checks the password of the user with the given email or username. Every attempt is
+// recorded, and attempts are refused with a too many requests error while the account or the
+// client IP is locked out. Unknown users and wrong passwords both yield ErrInvalidCredentials.
+// ip must come from the connection or a trusted proxy, never from a header the client controls.
+func (uc *authUsecase) Login(ctx context.Context, identifier, password, ip string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.Login")
+	defer span.End()
+
+	key := strings.ToLower(strings.TrimSpace(identifier))
+	if len(key) > 255 {
+		key = key[:255]
+	}
+
+	user, err := uc.userRepo.GetUserByEmailOrUsername(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+	// The failures of an account are counted whether it is named by its email or its username.
+	var userID *uint
+	if user != nil && !user.IsAnonymized() {
+		userID = &user.ID
+	}
+
+	retryAfter, err := uc.lockout(ctx, userID, key, ip)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		uc.recordAttempt(ctx, userID, key, ip, models.LoginResultLocked)
+		return nil, loginLocked(retryAfter)
+	}
+
+	if userID == nil {
+		utils.CheckPasswordHash(password, dummyPasswordHash)
+		uc.recordAttempt(ctx, nil, key, ip, models.LoginResultUnknownUser)
+		return nil, ErrInvalidCredentials
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		uc.recordAttempt(ctx, userID, key, ip, models.LoginResultInvalidPassword)
+		return nil, ErrInvalidCredentials
+	}
+
+	// Only a complete login ends a series of failures, so knowing the password is not enough to
+	// reset the count of wrong two-factor codes.
+	result := models.LoginResultSuccess
+	if user.TwoFactorEnabled() {
+		result = models.LoginResultTwoFactorRequired
+	}
+	uc.recordAttempt(ctx, userID, key, ip, result)
+	return user, nil
+}
+
+// BeginTOTPEnrollment generates a new TOTP secret for the user. Two-factor authentication is
+// only enabled once a code from the authenticator app is confirmed.
+func (uc *authUsecase) BeginTOTPEnrollment(ctx context.Context, userID uint) (*models.TOTPEnrollment, error) {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.BeginTOTPEnrollment")
+	defer span.End()
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := uc.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(uc.opts.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication after checking a code generated from
+// the pending secret, and returns freshly generated recovery codes. They are only shown once.
+func (uc *authUsecase) ConfirmTOTPEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.ConfirmTOTPEnrollment")
+	defer span.End()
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, uc.clock.Now(), 1)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes(uc.opts.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.recoveryRepo.ReplaceForUser(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+
+	now := uc.clock.Now()
+	user.TOTPEnabledAt = &now
+	user.TOTPLastStep = step
+	if err := uc.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off, which requires a current TOTP or recovery code.
+func (uc *authUsecase) DisableTOTP(ctx context.Context, userID uint, code string) error {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.DisableTOTP")
+	defer span.End()
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	ok, err := uc.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	if err := uc.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	return uc.recoveryRepo.DeleteForUser(ctx, user.ID)
+}
+
+// VerifyTwoFactor completes a two-factor login with a TOTP or recovery code. Wrong codes count
+// towards a lockout of the account like wrong passwords do.
+func (uc *authUsecase) VerifyTwoFactor(ctx context.Context, userID uint, code, ip string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.VerifyTwoFactor")
+	defer span.End()
+
+	key := fmt.Sprintf("2fa:%d", userID)
+
+	retryAfter, err := uc.lockout(ctx, &userID, key, ip)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		uc.recordAttempt(ctx, &userID, key, ip, models.LoginResultLocked)
+		return nil, loginLocked(retryAfter)
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	ok, err := uc.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		uc.recordAttempt(ctx, &userID, key, ip, models.LoginResultInvalidCode)
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	uc.recordAttempt(ctx, &userID, key, ip, models.LoginResultSuccess)
+	return user, nil
+}
+
+// checkSecondFactor accepts a TOTP code that has not been used before, or consumes a recovery code.
+func (uc *authUsecase) checkSecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	if step, ok := totp.Validate(user.TOTPSecret, code, uc.clock.Now(), 1); ok {
+		if step <= user.TOTPLastStep {
+			return false, nil
+		}
+		user.TOTPLastStep = step
+		return true, uc.userRepo.Update(ctx, user)
+	}
+
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	return uc.recoveryRepo.Use(ctx, user.ID, utils.HashToken(normalized), uc.clock.Now())
+}
+
+// generateRecoveryCodes returns n codes formatted as xxxxx-xxxxx and their hashes. The hash is
+// taken without the dash so codes are accepted however they are typed.
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := recoveryCodeEncoding.EncodeToString(b)[:10]
+		code := raw[:5] + "-" + raw[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// lockout returns how long logins for the account or from the IP are still refused. Attempts
+// naming no account are counted by identifier, so unknown accounts lock out like known ones.
+func (uc *authUsecase) lockout(ctx context.Context, userID *uint, identifier, ip string) (time.Duration, error) {
+	throttle := uc.opts.LoginThrottle
+	now := uc.clock.Now()
+	since := now.Add(-throttle.Window)
+
+	var accountFailures int
+	var accountLast time.Time
+	var err error
+	if userID != nil {
+		accountFailures, accountLast, err = uc.attemptRepo.CountFailuresByUser(ctx, *userID, since)
+	} else {
+		accountFailures, accountLast, err = uc.attemptRepo.CountFailuresByIdentifier(ctx, identifier, since)
+	}
+	if err != nil {
+		return 0, err
+	}
+	ipFailures, ipLast, err := uc.attemptRepo.CountFailuresByIP(ctx, ip, since)
+	if err != nil {
+		return 0, err
+	}
+
+	retryAfter := throttle.remaining(accountFailures, throttle.AccountThreshold, accountLast, now)
+	if ipRetry := throttle.remaining(ipFailures, throttle.IPThreshold, ipLast, now); ipRetry > retryAfter {
+		retryAfter = ipRetry
+	}
+	return retryAfter, nil
+}
+
+func (t LoginThrottle) remaining(failures, threshold int, lastFailure, now time.Time) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+
+	lockout := time.Duration(float64(t.BaseLockout) * math.Pow(2, float64(failures-threshold)))
+	if lockout > t.MaxLockout || lockout <= 0 {
+		lockout = t.MaxLockout
+	}
+
+	if remaining := lastFailure.Add(lockout).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func (uc *authUsecase) recordAttempt(ctx context.Context, userID *uint, identifier, ip, result string) {
+	if result != models.LoginResultSuccess {
+		metrics.LoginFailed(result)
+	}
+
+	attempt := &models.LoginAttempt{
+		UserID:     userID,
+		Identifier: identifier,
+		IP:         ip,
+		Result:     result,
+	}
+	if err := uc.attemptRepo.Create(ctx, attempt); err != nil {
+		uc.logger.ErrorContext(ctx, "failed to record login attempt",
+			slog.String("identifier", identifier), slog.Any("error", err))
+	}
+}
+
+func (uc *authUsecase) SendVerificationEmail(ctx context.Context, user *models.User) error {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.SendVerificationEmail")
+	defer span.End()
+
+	return uc.sendTokenEmail(ctx, user, models.TokenPurposeVerifyEmail, uc.opts.VerificationTokenTTL,
+		"Verify your email", "verify_email.html", "/verify-email")
+}
+
+func (uc *authUsecase) ResendVerificationEmail(ctx context.Context, userID uint) error {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.ResendVerificationEmail")
+	defer span.End()
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	return uc.SendVerificationEmail(ctx, user)
+}
+
+func (uc *authUsecase) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.VerifyEmail")
+	defer span.End()
+
+	userToken, err := uc.redeem(ctx, models.TokenPurposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userToken.UserID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt == nil {
+		now := uc.clock.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return uc.userRepo.Update(ctx, user)
+}
+
+// ForgotPassword emails a reset link when the address belongs to an account. It succeeds
+// silently for unknown addresses so the endpoint cannot be used to discover accounts.
+func (uc *authUsecase) ForgotPassword(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.ForgotPassword")
+	defer span.End()
+
+	user, err := uc.userRepo.CheckUserEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.IsAnonymized() {
+		return nil
+	}
+
+	return uc.sendTokenEmail(ctx, user, models.TokenPurposePasswordReset, uc.opts.ResetTokenTTL,
+		"Reset your password", "reset_password.html", "/reset-password")
+}
+
+func (uc *authUsecase) ResetPassword(ctx context.Context, token, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.ResetPassword")
+	defer span.End()
+
+	userToken, err := uc.redeem(ctx, models.TokenPurposePasswordReset, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	user.Password, err = utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	// Receiving the reset link proves ownership of the address.
+	if user.EmailVerifiedAt == nil {
+		now := uc.clock.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := uc.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	return uc.tokenRepo.InvalidateForUser(ctx, user.ID, models.TokenPurposePasswordReset, uc.clock.Now())
+}
+
+func (uc *authUsecase) OAuthLoginURL(ctx context.Context, provider, state string) (string, error) {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.OAuthLoginURL")
+	defer span.End()
+
+	p, ok := uc.providers[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+	return p.AuthCodeURL(ctx, state)
+}
+
+// LoginWithOAuth completes a sign-in with an external provider. A known identity signs in its
+// linked user; otherwise the identity is linked to the user with the same email, or a new user
+// is created. Linking by email is only done for addresses both the provider and the account
+// have verified: whoever registered an unverified address may not own it, and would keep
+// signing in with their password after the link.
+func (uc *authUsecase) LoginWithOAuth(ctx context.Context, provider, code string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.LoginWithOAuth")
+	defer span.End()
+
+	p, ok := uc.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	external, err := p.Exchange(ctx, code)
+	if err != nil {
+		if errors.Is(err, oauth.ErrNoEmail) {
+			return nil, apperror.Unauthorized(err.Error())
+		}
+		return nil, &apperror.Error{Kind: apperror.KindUnauthorized, Message: "failed to sign in with " + provider, Err: err}
+	}
+
+	identity, err := uc.identityRepo.FindByProviderSubject(ctx, provider, external.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		user, err := uc.userRepo.FindByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, notFound(err, "user")
+		}
+		if user.IsAnonymized() {
+			return nil, ErrInvalidCredentials
+		}
+		return user, nil
+	}
+
+	if !external.EmailVerified {
+		return nil, ErrUnverifiedEmail
+	}
+
+	user, err := uc.userRepo.CheckUserEmail(ctx, external.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if user, err = uc.createOAuthUser(ctx, external); err != nil {
+			return nil, err
+		}
+	} else if user.EmailVerifiedAt == nil {
+		return nil, ErrUnverifiedAccount
+	}
+
+	err = uc.identityRepo.Create(ctx, &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  external.Subject,
+		Email:    external.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (uc *authUsecase) createOAuthUser(ctx context.Context, external *oauth.Identity) (*models.User, error) {
+	// The account has no usable password until the user resets it
+	randomPassword, err := uc.ids.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(randomPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	username := strings.SplitN(external.Email, "@", 2)[0]
+	fullName := external.Name
+	if fullName == "" {
+		fullName = username
+	}
+
+	now := uc.clock.Now()
+	user := &models.User{
+		Username:        username,
+		Email:           external.Email,
+		Password:        hashedPassword,
+		EmailVerifiedAt: &now,
+	}
+	if err := uc.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err := uc.profileRepo.CreateProfile(ctx, &models.Profile{UserID: user.ID, FullName: fullName}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (uc *authUsecase) sendTokenEmail(ctx context.Context, user *models.User, purpose string, ttl time.Duration, subject, template, path string) error {
+	now := uc.clock.Now()
+	if err := uc.tokenRepo.InvalidateForUser(ctx, user.ID, purpose, now); err != nil {
+		return err
+	}
+
+	token, err := uc.ids.NewToken()
+	if err != nil {
+		return err
+	}
+
+	userToken := &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := uc.tokenRepo.Create(ctx, userToken); err != nil {
+		return err
+	}
+
+	html, err := mailer.Render(template, map[string]interface{}{
+		"Username":  user.Username,
+		"Link":      fmt.Sprintf("%s%s?token=%s", uc.opts.BaseURL, path, url.QueryEscape(token)),
+		"ExpiresIn": ttl.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return uc.mailer.Send(mailer.Message{To: user.Email, Subject: subject, HTML: html})
+}
+
+func (uc *authUsecase) redeem(ctx context.Context, purpose, token string) (*models.UserToken, error) {
+	now := uc.clock.Now()
+	userToken, err := uc.tokenRepo.FindValid(ctx, purpose, utils.HashToken(token), now)
+	if err != nil {
+		return nil, err
+	}
+	if userToken == nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := uc.tokenRepo.MarkUsed(ctx, userToken.ID, now); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return userToken, nil
+}
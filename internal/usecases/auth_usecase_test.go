@@ -0,0 +1,257 @@
+package usecases_test
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"api-culinary-review/internal/repositories/memory"
+	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/idgen"
+	"api-culinary-review/pkg/mailer"
+	"api-culinary-review/pkg/oauth"
+	"api-culinary-review/pkg/totp"
+	"api-culinary-review/pkg/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider is an OpenID Connect provider signing in the identity registered for each code.
+type fakeProvider struct {
+	server     *httptest.Server
+	identities map[string]map[string]interface{}
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	p := &fakeProvider{identities: map[string]map[string]interface{}{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"userinfo_endpoint":      p.server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"access_token": r.Form.Get("code"), "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := p.identities[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+		if !ok {
+			http.Error(w, "unknown token", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(identity)
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// code returns an authorization code signing in the given identity.
+func (p *fakeProvider) code(subject, email string, verified bool) string {
+	code := "code-" + subject + "-" + email
+	p.identities[code] = map[string]interface{}{"sub": subject, "email": email, "email_verified": verified, "name": subject}
+	return code
+}
+
+var defaultThrottle = usecases.LoginThrottle{
+	Window:           time.Hour,
+	AccountThreshold: 5,
+	IPThreshold:      20,
+	BaseLockout:      time.Minute,
+	MaxLockout:       time.Hour,
+}
+
+func newAuthUsecase(repos repositories.Set, clk clock.Clock, throttle usecases.LoginThrottle, providers ...*oauth.Provider) usecases.AuthUsecase {
+	return usecases.NewAuthUsecase(repos.Users, repos.Profiles, repos.UserTokens, repos.UserIdentities, repos.LoginAttempts, repos.RecoveryCodes,
+		mailer.NewMemoryMailer(), providers, clk, &idgen.Sequence{}, usecases.AuthOptions{
+			LoginThrottle:     throttle,
+			TOTPIssuer:        "test",
+			RecoveryCodeCount: 10,
+		}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// createUser creates an active user signing in with password.
+func createUser(t *testing.T, repos repositories.Set, name, password string) *models.User {
+	t.Helper()
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Username: name, Email: name + "@example.com", Password: hash}
+	if err := repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func isKind(err error, kind apperror.Kind) bool {
+	var appErr *apperror.Error
+	return errors.As(err, &appErr) && appErr.Kind == kind
+}
+
+func TestLoginWithOAuth(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewSet()
+	idp := newFakeProvider(t)
+	auth := newAuthUsecase(repos, clock.System{}, defaultThrottle, oauth.NewProvider(oauth.Config{Name: "fake", IssuerURL: idp.server.URL}))
+
+	verifiedAt := time.Now()
+	verified := &models.User{Username: "verified", Email: "verified@example.com", Password: "hash", EmailVerifiedAt: &verifiedAt}
+	unverified := &models.User{Username: "unverified", Email: "unverified@example.com", Password: "hash"}
+	for _, user := range []*models.User{verified, unverified} {
+		if err := repos.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newUser, err := auth.LoginWithOAuth(ctx, "fake", idp.code("new", "new@example.com", true))
+	if err != nil || newUser.Email != "new@example.com" || newUser.EmailVerifiedAt == nil {
+		t.Fatalf("LoginWithOAuth of a new identity = %+v, %v, want a new verified user", newUser, err)
+	}
+	if again, err := auth.LoginWithOAuth(ctx, "fake", idp.code("new", "new@example.com", true)); err != nil || again.ID != newUser.ID {
+		t.Errorf("LoginWithOAuth of a linked identity = %+v, %v, want user %d", again, err, newUser.ID)
+	}
+
+	if linked, err := auth.LoginWithOAuth(ctx, "fake", idp.code("v", verified.Email, true)); err != nil || linked.ID != verified.ID {
+		t.Errorf("LoginWithOAuth with the email of a verified account = %+v, %v, want user %d", linked, err, verified.ID)
+	}
+
+	if _, err := auth.LoginWithOAuth(ctx, "fake", idp.code("u", unverified.Email, true)); !errors.Is(err, usecases.ErrUnverifiedAccount) {
+		t.Errorf("LoginWithOAuth with the email of an unverified account: err = %v, want ErrUnverifiedAccount", err)
+	}
+	if identity, _ := repos.UserIdentities.FindByProviderSubject(ctx, "fake", "u"); identity != nil {
+		t.Errorf("an unverified account was linked to %+v", identity)
+	}
+	if user, _ := repos.Users.FindByID(ctx, unverified.ID); user.EmailVerifiedAt != nil {
+		t.Error("LoginWithOAuth verified the email of an unverified account")
+	}
+
+	if _, err := auth.LoginWithOAuth(ctx, "fake", idp.code("x", "x@example.com", false)); !errors.Is(err, usecases.ErrUnverifiedEmail) {
+		t.Errorf("LoginWithOAuth with an email the provider has not verified: err = %v, want ErrUnverifiedEmail", err)
+	}
+
+	anonymizedAt := time.Now()
+	newUser.AnonymizedAt = &anonymizedAt
+	if err := repos.Users.Update(ctx, newUser); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.LoginWithOAuth(ctx, "fake", idp.code("new", "new@example.com", true)); !errors.Is(err, usecases.ErrInvalidCredentials) {
+		t.Errorf("LoginWithOAuth of the identity of an anonymized user: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	if _, err := auth.LoginWithOAuth(ctx, "other", "code"); !errors.Is(err, usecases.ErrUnknownProvider) {
+		t.Errorf("LoginWithOAuth with an unknown provider: err = %v, want ErrUnknownProvider", err)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("account", func(t *testing.T) {
+		repos := memory.NewSet()
+		auth := newAuthUsecase(repos, clock.System{}, usecases.LoginThrottle{
+			Window: time.Hour, AccountThreshold: 3, IPThreshold: 100, BaseLockout: time.Minute, MaxLockout: time.Hour,
+		})
+		alice := createUser(t, repos, "alice", "secret")
+
+		// Failures by username and by email lock out the same account, from any IP.
+		for i, identifier := range []string{"alice", alice.Email, "alice"} {
+			if _, err := auth.Login(ctx, identifier, "wrong", fmt.Sprintf("10.0.0.%d", i)); !errors.Is(err, usecases.ErrInvalidCredentials) {
+				t.Fatalf("Login %d with a wrong password: err = %v, want ErrInvalidCredentials", i, err)
+			}
+		}
+		if _, err := auth.Login(ctx, alice.Email, "secret", "10.0.1.1"); !isKind(err, apperror.KindTooManyRequests) {
+			t.Errorf("Login of a locked account: err = %v, want too many requests", err)
+		}
+
+		bob := createUser(t, repos, "bob", "secret")
+		if user, err := auth.Login(ctx, "bob", "secret", "10.0.1.1"); err != nil || user.ID != bob.ID {
+			t.Errorf("Login of another account = %+v, %v, want bob", user, err)
+		}
+	})
+
+	t.Run("ip", func(t *testing.T) {
+		repos := memory.NewSet()
+		auth := newAuthUsecase(repos, clock.System{}, usecases.LoginThrottle{
+			Window: time.Hour, AccountThreshold: 100, IPThreshold: 3, BaseLockout: time.Minute, MaxLockout: time.Hour,
+		})
+		createUser(t, repos, "alice", "secret")
+		createUser(t, repos, "mallory", "mine")
+
+		// Signing in to an account of one's own between guesses does not reset the IP.
+		for _, login := range []struct{ identifier, password string }{
+			{"alice", "guess-1"}, {"alice", "guess-2"}, {"mallory", "mine"}, {"alice", "guess-3"},
+		} {
+			auth.Login(ctx, login.identifier, login.password, "10.0.0.1")
+		}
+		if _, err := auth.Login(ctx, "mallory", "mine", "10.0.0.1"); !isKind(err, apperror.KindTooManyRequests) {
+			t.Errorf("Login from a locked IP: err = %v, want too many requests", err)
+		}
+		if _, err := auth.Login(ctx, "alice", "secret", "10.0.0.2"); err != nil {
+			t.Errorf("Login from another IP: err = %v, want nil", err)
+		}
+	})
+}
+
+func TestVerifyTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewSet()
+	clk := clock.NewFake(time.Now())
+	auth := newAuthUsecase(repos, clk, defaultThrottle)
+	alice := createUser(t, repos, "alice", "secret")
+
+	enrollment, err := auth.BeginTOTPEnrollment(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := func() string {
+		c, err := totp.Code(enrollment.Secret, totp.Step(clk.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	recoveryCodes, err := auth.ConfirmTOTPEnrollment(ctx, alice.ID, code())
+	if err != nil || len(recoveryCodes) != 10 {
+		t.Fatalf("ConfirmTOTPEnrollment = %v, %v, want 10 recovery codes", recoveryCodes, err)
+	}
+
+	if user, err := auth.Login(ctx, "alice", "secret", "10.0.0.1"); err != nil || !user.TwoFactorEnabled() {
+		t.Fatalf("Login = %+v, %v, want alice with two-factor authentication", user, err)
+	}
+
+	// The code used to confirm the enrollment cannot be used again, even within the skew window.
+	if _, err := auth.VerifyTwoFactor(ctx, alice.ID, code(), "10.0.0.1"); !errors.Is(err, usecases.ErrInvalidTwoFactorCode) {
+		t.Errorf("VerifyTwoFactor with the enrollment code: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+
+	clk.Advance(totp.Period * time.Second)
+	current := code()
+	if user, err := auth.VerifyTwoFactor(ctx, alice.ID, current, "10.0.0.1"); err != nil || user.ID != alice.ID {
+		t.Fatalf("VerifyTwoFactor with a new code = %+v, %v, want alice", user, err)
+	}
+	if _, err := auth.VerifyTwoFactor(ctx, alice.ID, current, "10.0.0.1"); !errors.Is(err, usecases.ErrInvalidTwoFactorCode) {
+		t.Errorf("VerifyTwoFactor with a replayed code: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+
+	recovery := strings.ToUpper(recoveryCodes[0])
+	if _, err := auth.VerifyTwoFactor(ctx, alice.ID, recovery, "10.0.0.1"); err != nil {
+		t.Errorf("VerifyTwoFactor with a recovery code: err = %v, want nil", err)
+	}
+	if _, err := auth.VerifyTwoFactor(ctx, alice.ID, recovery, "10.0.0.1"); !errors.Is(err, usecases.ErrInvalidTwoFactorCode) {
+		t.Errorf("VerifyTwoFactor with a used recovery code: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+}
@@ -0,0 +1,17 @@
+package usecases
+
+import (
+	"api-culinary-review/pkg/apperror"
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// notFound turns a missing record into a NotFound error naming the resource and passes any
+// other error through.
+func notFound(err error, resource string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.NotFound(resource + " not found")
+	}
+	return err
+}
@@ -3,51 +3,140 @@ package usecases
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/repositories"
-	"time"
+	"api-culinary-review/pkg/tracing"
+	"context"
 )
 
 type FavoriteUsecase interface {
-	GetByUserID(userID uint) ([]*models.Favorite, error)
-	CreateFavorite(userID, recipeID uint) (*models.Favorite, error)
-	DeleteFavorite(id uint) error
+	// GetByUserID returns the recipes the user favorited, oldest favorite first.
+	GetByUserID(ctx context.Context, userID uint) ([]models.FavoriteResponse, error)
+	// AddFavorite favorites the recipe for the user. Favoriting it again changes nothing.
+	AddFavorite(ctx context.Context, userID, recipeID uint) (*models.FavoriteStatus, error)
+	// RemoveFavorite removes the recipe from the favorites of the user, if it is one, even when the
+	// recipe is hidden from the user or deleted.
+	RemoveFavorite(ctx context.Context, userID, recipeID uint) (*models.FavoriteStatus, error)
 }
 
 type favoriteUsecase struct {
 	FavoriteRepository repositories.FavoriteRepository
+	recipeRepository   repositories.RecipeRepository
 }
 
-func NewFavoriteUsecase(favoriteRepo repositories.FavoriteRepository) FavoriteUsecase {
+func NewFavoriteUsecase(favoriteRepo repositories.FavoriteRepository, recipeRepo repositories.RecipeRepository) FavoriteUsecase {
 	return &favoriteUsecase{
 		FavoriteRepository: favoriteRepo,
+		recipeRepository:   recipeRepo,
 	}
 }
 
-func (uc *favoriteUsecase) GetByUserID(userID uint) ([]*models.Favorite, error) {
-	favorites, err := uc.FavoriteRepository.GetByUserID(userID)
+func (uc *favoriteUsecase) GetByUserID(ctx context.Context, userID uint) ([]models.FavoriteResponse, error) {
+	ctx, span := tracing.Start(ctx, "FavoriteUsecase.GetByUserID")
+	defer span.End()
+
+	favorites, err := uc.FavoriteRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	recipeIDs := make([]uint, 0, len(favorites))
+	for _, favorite := range favorites {
+		recipeIDs = append(recipeIDs, favorite.RecipeID)
+	}
+	counts, err := uc.FavoriteRepository.CountByRecipeIDs(ctx, recipeIDs)
 	if err != nil {
 		return nil, err
 	}
-	return favorites, nil
+
+	responses := make([]models.FavoriteResponse, 0, len(favorites))
+	for _, favorite := range favorites {
+		recipe := favorite.Recipe
+		if recipe.ID == 0 || !visibleTo(recipe.Hidden, recipe.UserID, userID) {
+			continue
+		}
+		recipe.FavoriteCount = counts[recipe.ID]
+		responses = append(responses, models.FavoriteResponse{Recipe: recipeSummary(recipe), FavoritedAt: favorite.CreatedAt})
+	}
+	return responses, nil
 }
 
-func (uc *favoriteUsecase) CreateFavorite(userID, recipeID uint) (*models.Favorite, error) {
-	favorite := &models.Favorite{
-		UserID:    userID,
-		RecipeID:  recipeID,
-		CreatedAt: time.Now(),
+func (uc *favoriteUsecase) AddFavorite(ctx context.Context, userID, recipeID uint) (*models.FavoriteStatus, error) {
+	ctx, span := tracing.Start(ctx, "FavoriteUsecase.AddFavorite")
+	defer span.End()
+
+	if err := uc.checkRecipe(ctx, userID, recipeID); err != nil {
+		return nil, err
+	}
+	if _, err := uc.FavoriteRepository.Add(ctx, userID, recipeID); err != nil {
+		return nil, err
 	}
+	return uc.status(ctx, userID, recipeID)
+}
+
+func (uc *favoriteUsecase) RemoveFavorite(ctx context.Context, userID, recipeID uint) (*models.FavoriteStatus, error) {
+	ctx, span := tracing.Start(ctx, "FavoriteUsecase.RemoveFavorite")
+	defer span.End()
 
-	err := uc.FavoriteRepository.Create(favorite)
+	if err := uc.FavoriteRepository.Remove(ctx, userID, recipeID); err != nil {
+		return nil, err
+	}
+	return uc.status(ctx, userID, recipeID)
+}
+
+// checkRecipe returns a NotFound error unless the recipe exists and is shown to the user.
+func (uc *favoriteUsecase) checkRecipe(ctx context.Context, userID, recipeID uint) error {
+	recipe, err := uc.recipeRepository.GetRecipeByID(ctx, recipeID)
 	if err != nil {
+		return notFound(err, "recipe")
+	}
+	if !visibleTo(recipe.Hidden, recipe.UserID, userID) {
+		return ErrRecipeNotFound
+	}
+	return nil
+}
+
+func (uc *favoriteUsecase) status(ctx context.Context, userID, recipeID uint) (*models.FavoriteStatus, error) {
+	recipe := &models.Recipe{ID: recipeID}
+	if err := loadFavorites(ctx, uc.FavoriteRepository, userID, []*models.Recipe{recipe}); err != nil {
 		return nil, err
 	}
+	return &models.FavoriteStatus{RecipeID: recipeID, IsFavorited: recipe.IsFavorited, FavoriteCount: recipe.FavoriteCount}, nil
+}
 
-	return favorite, nil
+// loadFavorites sets the favorite count of recipes and whether the user, 0 for an anonymous
+// viewer, favorited them.
+func loadFavorites(ctx context.Context, favorites repositories.FavoriteRepository, userID uint, recipes []*models.Recipe) error {
+	recipeIDs := make([]uint, 0, len(recipes))
+	for _, recipe := range recipes {
+		recipeIDs = append(recipeIDs, recipe.ID)
+	}
+
+	counts, err := favorites.CountByRecipeIDs(ctx, recipeIDs)
+	if err != nil {
+		return err
+	}
+	favorited, err := favorites.FavoritedRecipeIDs(ctx, userID, recipeIDs)
+	if err != nil {
+		return err
+	}
+	for _, recipe := range recipes {
+		recipe.FavoriteCount = counts[recipe.ID]
+		recipe.IsFavorited = favorited[recipe.ID]
+	}
+	return nil
 }
 
-func (uc *favoriteUsecase) DeleteFavorite(id uint) error {
-	if id == 0 {
-		return nil
+// recipeSummary returns recipe as listed among others, with its first image.
+func recipeSummary(recipe models.Recipe) models.RecipeSummary {
+	summary := models.RecipeSummary{
+		ID:            recipe.ID,
+		Title:         recipe.Title,
+		Description:   recipe.Description,
+		UserID:        recipe.UserID,
+		FavoriteCount: recipe.FavoriteCount,
+		CreatedAt:     recipe.CreatedAt,
+	}
+	if len(recipe.Images) > 0 {
+		summary.ImageURL = recipe.Images[0].URL
 	}
-	return uc.FavoriteRepository.Delete(id)
+	return summary
 }
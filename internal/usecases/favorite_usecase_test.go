@@ -0,0 +1,90 @@
+package usecases_test
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories/memory"
+	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/nutrition"
+	"api-culinary-review/pkg/storage"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestFavoriteStatus(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewSet()
+	favorites := usecases.NewFavoriteUsecase(repos.Favorites, repos.Recipes)
+	recipes := usecases.NewRecipeUsecase(repos.Recipes, repos.Profiles, repos.Reports, repos.Favorites,
+		usecases.NewtagUsecase(repos.Tags, clock.System{}, usecases.UnknownTagsCreate), storage.NewMemory(),
+		nutrition.Default(), nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	alice := createUser(t, repos, "alice", "secret-password")
+	bob := createUser(t, repos, "bob", "secret-password")
+	carol := createUser(t, repos, "carol", "secret-password")
+	soup, err := repos.Recipes.CreateRecipe(ctx, &models.Recipe{Title: "soup", Ingredients: "water", Instructions: "boil", UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, userID := range []uint{bob.ID, carol.ID, bob.ID} {
+		if _, err := favorites.AddFavorite(ctx, userID, soup.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		viewerID      uint
+		wantFavorited bool
+	}{
+		{"anonymous viewer", 0, false},
+		{"viewer who favorited it", bob.ID, true},
+		{"author who did not favorite it", alice.ID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipe, err := recipes.GetRecipeByID(ctx, soup.ID, tt.viewerID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recipe.IsFavorited != tt.wantFavorited || recipe.FavoriteCount != 2 {
+				t.Errorf("GetRecipeByID: is_favorited %v and favorite_count %d, want %v and 2", recipe.IsFavorited, recipe.FavoriteCount, tt.wantFavorited)
+			}
+
+			listed, err := recipes.GetRecipes(ctx, models.RecipeFilter{ViewerID: tt.viewerID}, tt.viewerID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(listed) != 1 || listed[0].IsFavorited != tt.wantFavorited || listed[0].FavoriteCount != 2 {
+				t.Errorf("GetRecipes = %+v, want soup with is_favorited %v and favorite_count 2", listed, tt.wantFavorited)
+			}
+		})
+	}
+
+	// A hidden or deleted recipe can still be removed from the favorites.
+	soup.Hidden = true
+	if _, err := repos.Recipes.UpdateRecipe(ctx, soup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := favorites.AddFavorite(ctx, carol.ID, soup.ID); !isKind(err, apperror.KindNotFound) {
+		t.Errorf("AddFavorite of a hidden recipe: err = %v, want NotFound", err)
+	}
+	status, err := favorites.RemoveFavorite(ctx, bob.ID, soup.ID)
+	if err != nil {
+		t.Fatalf("RemoveFavorite of a hidden recipe: %v", err)
+	}
+	if status.IsFavorited || status.FavoriteCount != 1 {
+		t.Errorf("RemoveFavorite of a hidden recipe = %+v, want it unfavorited with 1 favorite left", status)
+	}
+
+	if _, err := repos.Recipes.DeleteRecipe(ctx, soup.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := favorites.RemoveFavorite(ctx, carol.ID, soup.ID); err != nil || status.IsFavorited || status.FavoriteCount != 0 {
+		t.Errorf("RemoveFavorite of a deleted recipe = %+v, %v, want it unfavorited", status, err)
+	}
+}
@@ -0,0 +1,277 @@
+package usecases
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/contentfilter"
+	"api-culinary-review/pkg/tracing"
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrAlreadyReported = apperror.Conflict("you have already reported this content")
+	ErrReportResolved  = apperror.Conflict("report has already been resolved")
+)
+
+// excerptLength is the number of characters of reported content shown in the moderation queue.
+const excerptLength = 120
+
+type ModerationUsecase interface {
+	// Report files a report of the user on a review or recipe.
+	Report(ctx context.Context, req *models.ReportRequest, targetType string, targetID, userID uint) (*models.Report, error)
+	// GetQueue returns a page of the reports with the status of req, pending when it is empty,
+	// oldest first.
+	GetQueue(ctx context.Context, req *models.ReportQueueRequest) (*models.ReportPage, error)
+	// Resolve approves or rejects the target of the report, closing every pending report on it. It
+	// returns a NotFound error when the target no longer exists.
+	Resolve(ctx context.Context, req *models.ResolveReportRequest, id, moderatorID uint) (*models.ModerationAction, error)
+	// GetActions returns a page of the audit trail of moderator decisions, newest first.
+	GetActions(ctx context.Context, page models.PageRequest) (*models.ModerationActionPage, error)
+}
+
+type moderationUsecase struct {
+	reports    repositories.ReportRepository
+	reviewRepo repositories.ReviewRepository
+	recipeRepo repositories.RecipeRepository
+	clock      clock.Clock
+}
+
+func NewModerationUsecase(reports repositories.ReportRepository, reviewRepo repositories.ReviewRepository, recipeRepo repositories.RecipeRepository,
+	clock clock.Clock) ModerationUsecase {
+	return &moderationUsecase{
+		reports:    reports,
+		reviewRepo: reviewRepo,
+		recipeRepo: recipeRepo,
+		clock:      clock,
+	}
+}
+
+func (uc *moderationUsecase) Report(ctx context.Context, req *models.ReportRequest, targetType string, targetID, userID uint) (*models.Report, error) {
+	ctx, span := tracing.Start(ctx, "ModerationUsecase.Report")
+	defer span.End()
+
+	_, authorID, hidden, err := uc.target(ctx, targetType, targetID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err != nil || !visibleTo(hidden, authorID, userID) {
+		return nil, apperror.NotFound(targetType + " not found")
+	}
+
+	pending, err := uc.reports.FindPending(ctx, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	for _, report := range pending {
+		if report.ReporterID != nil && *report.ReporterID == userID {
+			return nil, ErrAlreadyReported
+		}
+	}
+
+	report := &models.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		ReporterID: &userID,
+		Reason:     req.Reason,
+		Status:     models.ReportStatusPending,
+	}
+	if err := uc.reports.Create(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (uc *moderationUsecase) GetQueue(ctx context.Context, req *models.ReportQueueRequest) (*models.ReportPage, error) {
+	ctx, span := tracing.Start(ctx, "ModerationUsecase.GetQueue")
+	defer span.End()
+
+	status := req.Status
+	if status == "" {
+		status = models.ReportStatusPending
+	}
+	page := req.PageRequest.WithDefaults()
+	reports, total, err := uc.reports.FindByStatus(ctx, status, page)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.loadExcerpts(ctx, reports); err != nil {
+		return nil, err
+	}
+	if reports == nil {
+		reports = []models.Report{}
+	}
+	return &models.ReportPage{Data: reports, Meta: pageMeta(page, total)}, nil
+}
+
+// loadExcerpts sets the excerpts of the content the reports are on, loading the reviews and the
+// recipes with one query each. Reports on deleted content get no excerpt.
+func (uc *moderationUsecase) loadExcerpts(ctx context.Context, reports []models.Report) error {
+	var reviewIDs, recipeIDs []uint
+	for _, report := range reports {
+		if report.TargetType == models.ReportTargetRecipe {
+			recipeIDs = append(recipeIDs, report.TargetID)
+		} else {
+			reviewIDs = append(reviewIDs, report.TargetID)
+		}
+	}
+
+	reviews := make(map[uint]models.Review, len(reviewIDs))
+	if len(reviewIDs) > 0 {
+		rows, err := uc.reviewRepo.FindByIDs(ctx, reviewIDs)
+		if err != nil {
+			return err
+		}
+		for _, review := range rows {
+			reviews[review.ID] = review
+		}
+	}
+	titles := make(map[uint]string, len(recipeIDs))
+	if len(recipeIDs) > 0 {
+		rows, err := uc.recipeRepo.GetRecipesByIDs(ctx, recipeIDs)
+		if err != nil {
+			return err
+		}
+		for _, recipe := range rows {
+			titles[recipe.ID] = recipe.Title
+		}
+	}
+
+	for i, report := range reports {
+		var content string
+		switch report.TargetType {
+		case models.ReportTargetRecipe:
+			content = titles[report.TargetID]
+		case models.ReportTargetAuthorResponse:
+			content = reviews[report.TargetID].AuthorResponse
+		default:
+			content = reviews[report.TargetID].Content
+		}
+		reports[i].Excerpt = truncate(content, excerptLength)
+	}
+	return nil
+}
+
+func (uc *moderationUsecase) Resolve(ctx context.Context, req *models.ResolveReportRequest, id, moderatorID uint) (*models.ModerationAction, error) {
+	ctx, span := tracing.Start(ctx, "ModerationUsecase.Resolve")
+	defer span.End()
+
+	report, err := uc.reports.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, "report")
+	}
+	if report.Status != models.ReportStatusPending {
+		return nil, ErrReportResolved
+	}
+	// A decision on content that no longer exists would only leave a false entry in the audit trail
+	if _, _, _, err := uc.target(ctx, report.TargetType, report.TargetID); err != nil {
+		return nil, notFound(err, report.TargetType)
+	}
+
+	action := &models.ModerationAction{
+		ModeratorID: moderatorID,
+		Action:      req.Decision,
+		TargetType:  report.TargetType,
+		TargetID:    report.TargetID,
+		Reason:      req.Reason,
+		CreatedAt:   uc.clock.Now(),
+	}
+	if err := uc.reports.Resolve(ctx, action); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+func (uc *moderationUsecase) GetActions(ctx context.Context, page models.PageRequest) (*models.ModerationActionPage, error) {
+	ctx, span := tracing.Start(ctx, "ModerationUsecase.GetActions")
+	defer span.End()
+
+	page = page.WithDefaults()
+	actions, total, err := uc.reports.FindActions(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+	if actions == nil {
+		actions = []models.ModerationAction{}
+	}
+	return &models.ModerationActionPage{Data: actions, Meta: pageMeta(page, total)}, nil
+}
+
+// target returns the text shown for the review, author response or recipe, its author and
+// whether it is hidden, or gorm.ErrRecordNotFound when it does not exist.
+func (uc *moderationUsecase) target(ctx context.Context, targetType string, targetID uint) (string, uint, bool, error) {
+	if targetType == models.ReportTargetReview || targetType == models.ReportTargetAuthorResponse {
+		review, err := uc.reviewRepo.FindByID(ctx, targetID)
+		if err != nil {
+			return "", 0, false, err
+		}
+		if review == nil {
+			return "", 0, false, gorm.ErrRecordNotFound
+		}
+		if targetType == models.ReportTargetReview {
+			return review.Content, review.UserID, review.Hidden, nil
+		}
+		if review.AuthorResponse == "" {
+			return "", 0, false, gorm.ErrRecordNotFound
+		}
+		recipe, err := uc.recipeRepo.GetRecipeByID(ctx, review.RecipeID)
+		if err != nil {
+			return "", 0, false, err
+		}
+		return review.AuthorResponse, recipe.UserID, review.AuthorResponseHidden, nil
+	}
+
+	recipe, err := uc.recipeRepo.GetRecipeByID(ctx, targetID)
+	if err != nil {
+		return "", 0, false, err
+	}
+	return recipe.Title, recipe.UserID, recipe.Hidden, nil
+}
+
+// contentScreen runs submitted content through the content filter and files a report for
+// moderators on content that matches it.
+type contentScreen struct {
+	filter  *contentfilter.Filter
+	reports repositories.ReportRepository
+	logger  *slog.Logger
+}
+
+// match returns the rule of the filter that texts match, if any.
+func (s contentScreen) match(texts ...string) (string, bool) {
+	return s.filter.Match(texts...)
+}
+
+// flag files a report on the review or recipe that matched rule. The content is already saved
+// hidden, so a failure is only logged.
+func (s contentScreen) flag(ctx context.Context, targetType string, targetID uint, rule string) {
+	err := s.reports.Create(ctx, &models.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     "matched the content filter: " + rule,
+		Status:     models.ReportStatusPending,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to report filtered content",
+			slog.String("target_type", targetType), slog.Uint64("target_id", uint64(targetID)), slog.Any("error", err))
+	}
+}
+
+// visibleTo reports whether content by authorID, hidden or not, is shown to viewerID. Hidden
+// content is only shown to its author, who is not told that it is hidden.
+func visibleTo(hidden bool, authorID, viewerID uint) bool {
+	return !hidden || (viewerID != 0 && authorID == viewerID)
+}
+
+// truncate shortens s to at most n characters, ending it with "…" when it was cut.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
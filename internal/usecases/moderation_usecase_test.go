@@ -0,0 +1,106 @@
+package usecases_test
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories/memory"
+	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestResolveReportOnMissingTarget(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewSet()
+	uc := usecases.NewModerationUsecase(repos.Reports, repos.Reviews, repos.Recipes, clock.System{})
+	moderator := createUser(t, repos, "moderator", "secret-password")
+
+	for _, targetType := range []string{models.ReportTargetRecipe, models.ReportTargetReview} {
+		t.Run(targetType, func(t *testing.T) {
+			report := &models.Report{TargetType: targetType, TargetID: 999, Reason: "spam", Status: models.ReportStatusPending}
+			if err := repos.Reports.Create(ctx, report); err != nil {
+				t.Fatal(err)
+			}
+			req := &models.ResolveReportRequest{Decision: models.ModerationReject, Reason: "spam"}
+			if _, err := uc.Resolve(ctx, req, report.ID, moderator.ID); !isKind(err, apperror.KindNotFound) {
+				t.Fatalf("Resolve() error = %v, want a not found error", err)
+			}
+		})
+	}
+
+	actions, _, err := repos.Reports.FindActions(ctx, models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Errorf("FindActions = %+v, want no decisions recorded", actions)
+	}
+}
+
+func TestModerationQueuePages(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewSet()
+	uc := usecases.NewModerationUsecase(repos.Reports, repos.Reviews, repos.Recipes, clock.System{})
+	alice := createUser(t, repos, "alice", "secret-password")
+
+	soup, err := repos.Recipes.CreateRecipe(ctx, &models.Recipe{Title: "soup", Ingredients: "water", Instructions: "boil", UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := repos.Reviews.Create(ctx, &models.ReviewRequest{UserID: alice.ID, RecipeID: soup.ID, Content: "too salty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, report := range []*models.Report{
+		{TargetType: models.ReportTargetRecipe, TargetID: soup.ID, Reason: "copied"},
+		{TargetType: models.ReportTargetReview, TargetID: review.ID, Reason: "rude"},
+		{TargetType: models.ReportTargetRecipe, TargetID: 999, Reason: "spam"},
+	} {
+		if err := repos.Reports.Create(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		page         models.PageRequest
+		wantExcerpts []string
+		wantMeta     models.PageMeta
+	}{
+		{"default page", models.PageRequest{}, []string{"soup", "too salty", ""}, models.PageMeta{Page: 1, PageSize: 20, Total: 3, TotalPages: 1}},
+		{"first page of two", models.PageRequest{Page: 1, PageSize: 2}, []string{"soup", "too salty"}, models.PageMeta{Page: 1, PageSize: 2, Total: 3, TotalPages: 2}},
+		{"last page", models.PageRequest{Page: 2, PageSize: 2}, []string{""}, models.PageMeta{Page: 2, PageSize: 2, Total: 3, TotalPages: 2}},
+		{"past the last page", models.PageRequest{Page: 3, PageSize: 2}, []string{}, models.PageMeta{Page: 3, PageSize: 2, Total: 3, TotalPages: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue, err := uc.GetQueue(ctx, &models.ReportQueueRequest{PageRequest: tt.page})
+			if err != nil {
+				t.Fatal(err)
+			}
+			excerpts := []string{}
+			for _, report := range queue.Data {
+				excerpts = append(excerpts, report.Excerpt)
+			}
+			if !slices.Equal(excerpts, tt.wantExcerpts) || queue.Meta != tt.wantMeta {
+				t.Errorf("GetQueue() = %q, %+v, want %q, %+v", excerpts, queue.Meta, tt.wantExcerpts, tt.wantMeta)
+			}
+		})
+	}
+
+	if _, err := uc.Resolve(ctx, &models.ResolveReportRequest{Decision: models.ModerationApprove}, 1, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.Resolve(ctx, &models.ResolveReportRequest{Decision: models.ModerationApprove}, 2, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := uc.GetActions(ctx, models.PageRequest{PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions.Data) != 1 || actions.Data[0].TargetType != models.ReportTargetReview || actions.Meta.Total != 2 || actions.Meta.TotalPages != 2 {
+		t.Errorf("GetActions() = %+v, want the newest of two decisions", actions)
+	}
+}
@@ -0,0 +1,37 @@
+package usecases
+
+import (
+	"api-culinary-review/pkg/nutrition"
+	"api-culinary-review/pkg/tracing"
+	"context"
+)
+
+const maxFoodResults = 20
+
+type NutritionUsecase interface {
+	SearchFoods(ctx context.Context, query string) ([]nutrition.Food, error)
+}
+
+type nutritionUsecase struct {
+	nutrients *nutrition.Database
+}
+
+func NewNutritionUsecase(nutrients *nutrition.Database) NutritionUsecase {
+	return &nutritionUsecase{nutrients: nutrients}
+}
+
+// SearchFoods returns the foods of the nutrient database whose name or an alias contains query,
+// which recipe ingredients can name as their food.
+func (uc *nutritionUsecase) SearchFoods(ctx context.Context, query string) ([]nutrition.Food, error) {
+	_, span := tracing.Start(ctx, "NutritionUsecase.SearchFoods")
+	defer span.End()
+
+	foods := uc.nutrients.Search(query)
+	if len(foods) > maxFoodResults {
+		foods = foods[:maxFoodResults]
+	}
+	if foods == nil {
+		foods = []nutrition.Food{}
+	}
+	return foods, nil
+}
@@ -3,55 +3,89 @@ package usecases
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/repositories"
-	"api-culinary-review/pkg/utils"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/storage"
+	"api-culinary-review/pkg/tracing"
+	"context"
+	"errors"
 	"mime/multipart"
+
+	"github.com/jinzhu/gorm"
 )
 
 type ProfileUsecase interface {
-	CreateProfile(req *models.ProfileRequest, userID uint, file *multipart.FileHeader) (*models.Profile, error)
-	GetProfileByUserID(userID uint) (*models.Profile, error)
-	UpdateProfileByID(req *models.ProfileRequest, userID uint, file *multipart.FileHeader) error
+	// CreateProfile fills in the profile of the user, which is created empty at registration, or
+	// creates it if the user has none. Dietary preferences are kept.
+	CreateProfile(ctx context.Context, req *models.ProfileRequest, userID uint, file *multipart.FileHeader) (*models.Profile, error)
+	GetProfileByUserID(ctx context.Context, userID uint) (*models.Profile, error)
+	UpdateProfileByID(ctx context.Context, req *models.ProfileRequest, userID uint, file *multipart.FileHeader) error
+	UpdateDietaryPreferences(ctx context.Context, req *models.DietaryPreferencesRequest, userID uint) (*models.Profile, error)
 }
 
 type profileUsecase struct {
-	repo repositories.ProfileRepository
+	repo    repositories.ProfileRepository
+	storage storage.Storage
 }
 
-func NewProfileUsecase(repo repositories.ProfileRepository) ProfileUsecase {
-	return &profileUsecase{repo: repo}
+func NewProfileUsecase(repo repositories.ProfileRepository, storage storage.Storage) ProfileUsecase {
+	return &profileUsecase{repo: repo, storage: storage}
 }
 
-func (uc *profileUsecase) CreateProfile(req *models.ProfileRequest, userID uint, file *multipart.FileHeader) (*models.Profile, error) {
-	avatarURL, err := utils.UploadToCloudinary(file)
+func (uc *profileUsecase) CreateProfile(ctx context.Context, req *models.ProfileRequest, userID uint, file *multipart.FileHeader) (*models.Profile, error) {
+	ctx, span := tracing.Start(ctx, "ProfileUsecase.CreateProfile")
+	defer span.End()
+
+	profile, err := uc.repo.GetProfileByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	exists := err == nil
+
+	avatarURL, err := uc.storage.Upload(ctx, file)
 	if err != nil {
 		return nil, err
 	}
 
-	profile := &models.Profile{
-		UserID:    userID,
-		FullName:  req.FullName,
-		Bio:       req.Bio,
-		AvatarURL: avatarURL,
+	if !exists {
+		profile = &models.Profile{UserID: userID}
 	}
+	profile.FullName = req.FullName
+	profile.Bio = req.Bio
+	profile.AvatarURL = avatarURL
 
-	if err := uc.repo.CreateProfile(profile); err != nil {
+	if exists {
+		err = uc.repo.UpdateProfile(ctx, profile)
+	} else {
+		err = uc.repo.CreateProfile(ctx, profile)
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	return profile, nil
 }
 
-func (uc *profileUsecase) GetProfileByUserID(userID uint) (*models.Profile, error) {
-	return uc.repo.GetProfileByUserID(userID)
+func (uc *profileUsecase) GetProfileByUserID(ctx context.Context, userID uint) (*models.Profile, error) {
+	ctx, span := tracing.Start(ctx, "ProfileUsecase.GetProfileByUserID")
+	defer span.End()
+
+	profile, err := uc.repo.GetProfileByUserID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, "profile")
+	}
+	return profile, nil
 }
 
-func (uc *profileUsecase) UpdateProfileByID(req *models.ProfileRequest, userID uint, file *multipart.FileHeader) error {
-	profile, err := uc.repo.GetProfileByUserID(userID)
+func (uc *profileUsecase) UpdateProfileByID(ctx context.Context, req *models.ProfileRequest, userID uint, file *multipart.FileHeader) error {
+	ctx, span := tracing.Start(ctx, "ProfileUsecase.UpdateProfileByID")
+	defer span.End()
+
+	profile, err := uc.GetProfileByUserID(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	avatarURL, err := utils.UploadToCloudinary(file)
+	avatarURL, err := uc.storage.Upload(ctx, file)
 	if err != nil {
 		return err
 	}
@@ -60,5 +94,33 @@ func (uc *profileUsecase) UpdateProfileByID(req *models.ProfileRequest, userID u
 	profile.Bio = req.Bio
 	profile.AvatarURL = avatarURL
 
-	return uc.repo.UpdateProfile(profile)
+	return uc.repo.UpdateProfile(ctx, profile)
+}
+
+// UpdateDietaryPreferences replaces the diets and allergens the recipe listing of the user is
+// filtered by.
+func (uc *profileUsecase) UpdateDietaryPreferences(ctx context.Context, req *models.DietaryPreferencesRequest, userID uint) (*models.Profile, error) {
+	ctx, span := tracing.Start(ctx, "ProfileUsecase.UpdateDietaryPreferences")
+	defer span.End()
+
+	profile, err := uc.GetProfileByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	diets, err := models.ParseDiets(req.Diets)
+	if err != nil {
+		return nil, apperror.Validation("invalid diets", apperror.FieldError{Field: "diets", Message: err.Error()})
+	}
+	allergens, err := models.ParseAllergens(req.AvoidAllergens)
+	if err != nil {
+		return nil, apperror.Validation("invalid allergens", apperror.FieldError{Field: "avoid_allergens", Message: err.Error()})
+	}
+
+	profile.Diets = diets
+	profile.AvoidAllergens = allergens
+	if err := uc.repo.UpdateProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
 }
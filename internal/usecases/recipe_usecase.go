@@ -3,59 +3,165 @@ package usecases
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/repositories"
-	"api-culinary-review/pkg/utils"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/contentfilter"
+	"api-culinary-review/pkg/metrics"
+	"api-culinary-review/pkg/nutrition"
+	"api-culinary-review/pkg/storage"
+	"api-culinary-review/pkg/tracing"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"mime/multipart"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrRecipeNotFound = apperror.NotFound("recipe not found")
+	ErrNotRecipeOwner = apperror.Forbidden("you can only modify your own recipes")
 )
 
 type RecipeUsecase interface {
-	CreateRecipe(images []*multipart.FileHeader, recipe *models.RecipeRequest, userID uint) (*models.Recipe, error)
-	GetRecipeByID(id uint) (*models.Recipe, error)
-	GetRecipes() ([]*models.Recipe, error)
-	UpdateRecipe(id uint, images []*multipart.FileHeader, recipe *models.RecipeRequest) (*models.Recipe, error)
-	DeleteRecipe(id uint) error
+	CreateRecipe(ctx context.Context, images []*multipart.FileHeader, recipe *models.RecipeRequest, userID uint) (*models.Recipe, error)
+	// GetRecipeByID returns a NotFound error for a hidden recipe unless viewerID is its author.
+	GetRecipeByID(ctx context.Context, id, viewerID uint) (*models.Recipe, error)
+	GetRecipes(ctx context.Context, filter models.RecipeFilter, userID uint) ([]*models.Recipe, error)
+	UpdateRecipe(ctx context.Context, id, userID uint, images []*multipart.FileHeader, recipe *models.RecipeRequest) (*models.Recipe, error)
+	DeleteRecipe(ctx context.Context, id, userID uint) error
+}
+
+// dietConflicts are the allergens that contradict a diet label.
+var dietConflicts = map[models.Diets]models.Allergens{
+	models.DietVegan:      models.AllergenDairy | models.AllergenEgg | models.AllergenShellfish,
+	models.DietVegetarian: models.AllergenShellfish,
 }
 
 type recipeUsecase struct {
-	recipeRepository repositories.RecipeRepository
+	recipeRepository  repositories.RecipeRepository
+	profileRepository repositories.ProfileRepository
+	favorites         repositories.FavoriteRepository
+	tags              TagUsecase
+	storage           storage.Storage
+	nutrients         *nutrition.Database
+	screen            contentScreen
+	logger            *slog.Logger
 }
 
-func NewRecipeUsecase(recipeRepository repositories.RecipeRepository) RecipeUsecase {
-	return &recipeUsecase{recipeRepository: recipeRepository}
+// NewRecipeUsecase creates a RecipeUsecase that hides recipes matching filter and reports them
+// to moderators. The tag names of a recipe are resolved by tags.
+func NewRecipeUsecase(recipeRepository repositories.RecipeRepository, profileRepository repositories.ProfileRepository, reportRepository repositories.ReportRepository,
+	favoriteRepository repositories.FavoriteRepository, tags TagUsecase, storage storage.Storage, nutrients *nutrition.Database, filter *contentfilter.Filter, logger *slog.Logger) RecipeUsecase {
+	return &recipeUsecase{
+		recipeRepository:  recipeRepository,
+		profileRepository: profileRepository,
+		favorites:         favoriteRepository,
+		tags:              tags,
+		storage:           storage,
+		nutrients:         nutrients,
+		screen:            contentScreen{filter: filter, reports: reportRepository, logger: logger},
+		logger:            logger,
+	}
 }
 
-func (r *recipeUsecase) GetRecipeByID(id uint) (*models.Recipe, error) {
-	return r.recipeRepository.GetRecipeByID(id)
+func (r *recipeUsecase) GetRecipeByID(ctx context.Context, id, viewerID uint) (*models.Recipe, error) {
+	ctx, span := tracing.Start(ctx, "RecipeUsecase.GetRecipeByID")
+	defer span.End()
+
+	recipe, err := r.recipeRepository.GetRecipeByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, "recipe")
+	}
+	if !visibleTo(recipe.Hidden, recipe.UserID, viewerID) {
+		return nil, ErrRecipeNotFound
+	}
+	if err := loadFavorites(ctx, r.favorites, viewerID, []*models.Recipe{recipe}); err != nil {
+		return nil, err
+	}
+	return recipe, nil
 }
 
-func (r *recipeUsecase) GetRecipes() ([]*models.Recipe, error) {
-	return r.recipeRepository.GetRecipes()
+// GetRecipes returns the recipes matching filter. When userID is not 0 the dietary preferences
+// in the profile of the user are added to filter.
+func (r *recipeUsecase) GetRecipes(ctx context.Context, filter models.RecipeFilter, userID uint) ([]*models.Recipe, error) {
+	ctx, span := tracing.Start(ctx, "RecipeUsecase.GetRecipes")
+	defer span.End()
+
+	if userID != 0 {
+		profile, err := r.profileRepository.GetProfileByUserID(ctx, userID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			filter.ExcludeAllergens |= profile.AvoidAllergens
+			filter.Diets |= profile.Diets
+		}
+	}
+
+	recipes, err := r.recipeRepository.GetRecipes(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadFavorites(ctx, r.favorites, filter.ViewerID, recipes); err != nil {
+		return nil, err
+	}
+	return recipes, nil
 }
 
-func (r *recipeUsecase) CreateRecipe(images []*multipart.FileHeader, recipe *models.RecipeRequest, userID uint) (*models.Recipe, error) {
+func (r *recipeUsecase) CreateRecipe(ctx context.Context, images []*multipart.FileHeader, recipe *models.RecipeRequest, userID uint) (*models.Recipe, error) {
+	ctx, span := tracing.Start(ctx, "RecipeUsecase.CreateRecipe")
+	defer span.End()
+
+	allergens, diets, err := recipeLabels(recipe)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkFoods(recipe.IngredientList); err != nil {
+		return nil, err
+	}
+
 	newRecipe := &models.Recipe{
-		Title:        recipe.Title,
-		Description:  recipe.Description,
-		Ingredients:  recipe.Ingredients,
-		Instructions: recipe.Instructions,
-		UserID:       userID,
+		Title:          recipe.Title,
+		Description:    recipe.Description,
+		Ingredients:    recipe.Ingredients,
+		Instructions:   recipe.Instructions,
+		IngredientList: recipe.IngredientList,
+		Allergens:      allergens,
+		Diets:          diets,
+		Servings:       servings(recipe.Servings),
+		UserID:         userID,
+	}
+	r.estimateNutrition(newRecipe)
+	rule, flagged := r.screen.match(recipeTexts(recipe)...)
+	newRecipe.Hidden = flagged
+
+	// Resolve the tag names only now, so an invalid recipe creates no pending tags
+	tagIDs, err := r.resolveTags(ctx, recipe.TagNames)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create recipe first to get a valid ID
-	createdRecipe, err := r.recipeRepository.CreateRecipe(newRecipe)
+	createdRecipe, err := r.recipeRepository.CreateRecipe(ctx, newRecipe)
 	if err != nil {
 		return nil, err
 	}
 
+	if flagged {
+		r.screen.flag(ctx, models.ReportTargetRecipe, createdRecipe.ID, rule)
+	}
+
 	// Create recipe tags
-	if err := r.createRecipeTags(createdRecipe.ID, recipe.TagIDs); err != nil {
+	if err := r.createRecipeTags(ctx, createdRecipe.ID, tagIDs); err != nil {
 		return nil, err
 	}
 
 	// Upload images and set to createdRecipe
 	for _, image := range images {
-		uploadedImage, err := utils.UploadToCloudinary(image)
+		uploadedImage, err := r.storage.Upload(ctx, image)
 		if err != nil {
 			return nil, err
 		}
@@ -64,88 +170,139 @@ func (r *recipeUsecase) CreateRecipe(images []*multipart.FileHeader, recipe *mod
 		newRecipe.Images = append(newRecipe.Images, models.Image{URL: uploadedImage, RecipeID: createdRecipe.ID})
 	}
 
-	return r.recipeRepository.UpdateRecipe(createdRecipe)
+	updatedRecipe, err := r.recipeRepository.UpdateRecipe(ctx, createdRecipe)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RecipeCreated()
+	return updatedRecipe, nil
 }
 
-func (r *recipeUsecase) UpdateRecipe(id uint, images []*multipart.FileHeader, recipe *models.RecipeRequest) (*models.Recipe, error) {
-	existingRecipe, err := r.recipeRepository.GetRecipeByID(id)
+func (r *recipeUsecase) UpdateRecipe(ctx context.Context, id, userID uint, images []*multipart.FileHeader, recipe *models.RecipeRequest) (*models.Recipe, error) {
+	ctx, span := tracing.Start(ctx, "RecipeUsecase.UpdateRecipe")
+	defer span.End()
+
+	existingRecipe, err := r.GetRecipeByID(ctx, id, userID)
 	if err != nil {
 		return nil, err
 	}
+	if existingRecipe.UserID != userID {
+		return nil, ErrNotRecipeOwner
+	}
+
+	allergens, diets, err := recipeLabels(recipe)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkFoods(recipe.IngredientList); err != nil {
+		return nil, err
+	}
 
 	// Update recipe fields
 	existingRecipe.Title = recipe.Title
 	existingRecipe.Description = recipe.Description
 	existingRecipe.Ingredients = recipe.Ingredients
 	existingRecipe.Instructions = recipe.Instructions
+	existingRecipe.Allergens = allergens
+	existingRecipe.Diets = diets
+	existingRecipe.Servings = servings(recipe.Servings)
 
-	// Clear existing tags and create new ones
-	if err := r.updateRecipeTags(id, recipe.TagIDs); err != nil {
-		return nil, err
+	// Content that matches the filter stays hidden until a moderator approves it; content that
+	// no longer matches is not shown again without one.
+	rule, flagged := r.screen.match(recipeTexts(recipe)...)
+	flagged = flagged && !existingRecipe.Hidden
+	if flagged {
+		existingRecipe.Hidden = true
 	}
 
-	// Clear existing images and upload new ones
-	if err := r.recipeRepository.DeleteRecipeImages(id); err != nil {
-		return nil, err
+	// Images sent replace the stored ones and are uploaded first, so a failed upload leaves the
+	// recipe as it was. Without images the recipe keeps the ones it has.
+	var uploaded, replaced []models.Image
+	if len(images) > 0 {
+		for _, image := range images {
+			uploadedImage, err := r.storage.Upload(ctx, image)
+			if err != nil {
+				r.deleteImages(ctx, uploaded, id, "failed to delete image of unsaved recipe")
+				return nil, err
+			}
+			uploaded = append(uploaded, models.Image{URL: uploadedImage, RecipeID: existingRecipe.ID})
+		}
+		replaced, existingRecipe.Images = existingRecipe.Images, uploaded
 	}
 
-	for _, image := range images {
-		uploadedImage, err := utils.UploadToCloudinary(image)
-		if err != nil {
-			return nil, err
-		}
+	existingRecipe.IngredientList = recipe.IngredientList
+	r.estimateNutrition(existingRecipe)
 
-		// Add new image URLs to the recipe
-		log.Printf("Uploading image URL: %s", uploadedImage)
-		existingRecipe.Images = append(existingRecipe.Images, models.Image{URL: uploadedImage, RecipeID: existingRecipe.ID})
+	// Resolve the tag names last, so a request that fails any check creates no pending tags
+	tagIDs, err := r.resolveTags(ctx, recipe.TagNames)
+	if err != nil {
+		r.deleteImages(ctx, uploaded, id, "failed to delete image of unsaved recipe")
+		return nil, err
 	}
 
-	return r.recipeRepository.UpdateRecipe(existingRecipe)
+	// The ingredients, tags and images are replaced together with the recipe
+	updatedRecipe, err := r.recipeRepository.ReplaceRecipe(ctx, existingRecipe, tagIDs)
+	if err != nil {
+		r.deleteImages(ctx, uploaded, id, "failed to delete image of unsaved recipe")
+		return nil, err
+	}
+	r.deleteImages(ctx, replaced, id, "failed to delete replaced image of recipe")
+	if flagged {
+		r.screen.flag(ctx, models.ReportTargetRecipe, id, rule)
+	}
+	if err := loadFavorites(ctx, r.favorites, userID, []*models.Recipe{updatedRecipe}); err != nil {
+		return nil, err
+	}
+	return updatedRecipe, nil
 }
 
 // Helper function to create recipe tags
-func (r *recipeUsecase) createRecipeTags(recipeID uint, tagIds []uint) error {
+func (r *recipeUsecase) createRecipeTags(ctx context.Context, recipeID uint, tagIds []uint) error {
 	for _, tagId := range tagIds {
 		// Validate tagId exists in the tags table
-		if exists, err := r.recipeRepository.RecipeTagExists(tagId); err != nil {
+		if exists, err := r.recipeRepository.RecipeTagExists(ctx, tagId); err != nil {
 			return err
 		} else if !exists {
-			return fmt.Errorf("tag with ID %d does not exist", tagId)
+			return unknownTag(tagId)
 		}
 
-		if err := r.recipeRepository.CreateRecipeTag(recipeID, tagId); err != nil {
+		if err := r.recipeRepository.CreateRecipeTag(ctx, recipeID, tagId); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *recipeUsecase) updateRecipeTags(recipeID uint, tagIds []uint) error {
-	// Hapus tag yang ada untuk resep tertentu
-	if err := r.recipeRepository.DeleteRecipeTagsByRecipeID(recipeID); err != nil {
-		return err
+// resolveTags returns the IDs of the tags named by tagNames, creating pending tags for unknown
+// names when the tag policy allows it.
+func (r *recipeUsecase) resolveTags(ctx context.Context, tagNames []string) ([]uint, error) {
+	tags, err := r.tags.ResolveTags(ctx, tagNames)
+	if err != nil {
+		return nil, err
 	}
+	tagIDs := make([]uint, 0, len(tags))
+	for _, tag := range tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	return tagIDs, nil
+}
 
-	// Buat tag baru untuk resep tertentu
-	for _, tagId := range tagIds {
-		// Validasi tagId ada di tabel tags
-		if exists, err := r.recipeRepository.RecipeTagExists(tagId); err != nil {
-			return err
-		} else if !exists {
-			return fmt.Errorf("tag dengan ID %d tidak ada", tagId)
-		}
-
-		if err := r.recipeRepository.CreateRecipeTag(recipeID, tagId); err != nil {
-			return err
+// deleteImages removes images of the recipe that are no longer saved from storage. A failure is
+// only logged with msg.
+func (r *recipeUsecase) deleteImages(ctx context.Context, images []models.Image, recipeID uint, msg string) {
+	for _, image := range images {
+		if err := r.storage.Delete(ctx, image.URL); err != nil {
+			r.logger.WarnContext(ctx, msg,
+				slog.String("url", image.URL), slog.Uint64("recipe_id", uint64(recipeID)), slog.Any("error", err))
 		}
 	}
-	return nil
 }
 
 // Helper function to upload images and set to recipe
-func (r *recipeUsecase) uploadRecipeImages(images []*multipart.FileHeader, recipe *models.Recipe) error {
+func (r *recipeUsecase) uploadRecipeImages(ctx context.Context, images []*multipart.FileHeader, recipe *models.Recipe) error {
 	for _, image := range images {
-		uploadedImage, err := utils.UploadToCloudinary(image)
+		uploadedImage, err := r.storage.Upload(ctx, image)
 		if err != nil {
 			return err
 		}
@@ -155,14 +312,124 @@ func (r *recipeUsecase) uploadRecipeImages(images []*multipart.FileHeader, recip
 	}
 
 	// Simpan resep dengan URL gambar yang sudah diupdate ke database
-	_, err := r.recipeRepository.UpdateRecipe(recipe)
+	_, err := r.recipeRepository.UpdateRecipe(ctx, recipe)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *recipeUsecase) DeleteRecipe(ctx context.Context, id, userID uint) error {
+	ctx, span := tracing.Start(ctx, "RecipeUsecase.DeleteRecipe")
+	defer span.End()
+
+	recipe, err := r.GetRecipeByID(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if recipe.UserID != userID {
+		return ErrNotRecipeOwner
+	}
+
+	imageURLs, err := r.recipeRepository.DeleteRecipe(ctx, id)
 	if err != nil {
 		return err
 	}
+	for _, url := range imageURLs {
+		if err := r.storage.Delete(ctx, url); err != nil {
+			r.logger.WarnContext(ctx, "failed to delete image of deleted recipe",
+				slog.String("url", url), slog.Uint64("recipe_id", uint64(id)), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// recipeTexts returns the free text of recipe that is run through the content filter.
+func recipeTexts(recipe *models.RecipeRequest) []string {
+	texts := []string{recipe.Title, recipe.Description, recipe.Ingredients, recipe.Instructions}
+	for _, ingredient := range recipe.IngredientList {
+		texts = append(texts, ingredient.Name)
+	}
+	return texts
+}
 
+// recipeLabels returns the allergens declared in recipe together with the ones derived from its
+// ingredient list, and its diet labels, which must not contradict the allergens.
+func recipeLabels(recipe *models.RecipeRequest) (models.Allergens, models.Diets, error) {
+	allergens, err := models.ParseAllergens(recipe.Allergens)
+	if err != nil {
+		return 0, 0, apperror.Validation("invalid allergens", apperror.FieldError{Field: "allergens", Message: err.Error()})
+	}
+	diets, err := models.ParseDiets(recipe.Diets)
+	if err != nil {
+		return 0, 0, apperror.Validation("invalid diets", apperror.FieldError{Field: "diets", Message: err.Error()})
+	}
+
+	allergens |= deriveAllergens(recipe.IngredientList)
+	for diet, conflicts := range dietConflicts {
+		if diets&diet != 0 && allergens&conflicts != 0 {
+			return 0, 0, apperror.Validation("diet labels contradict the allergens", apperror.FieldError{
+				Field:   "diets",
+				Message: fmt.Sprintf("%s does not allow %s", diet.Names()[0], strings.Join((allergens&conflicts).Names(), ", ")),
+			})
+		}
+	}
+	return allergens, diets, nil
+}
+
+// checkFoods checks that the foods the ingredients name are in the nutrient database.
+func (r *recipeUsecase) checkFoods(ingredients []models.RecipeIngredient) error {
+	for _, ingredient := range ingredients {
+		if _, ok := r.nutrients.Food(ingredient.Food); ingredient.Food != "" && !ok {
+			return apperror.Validation("unknown food", apperror.FieldError{
+				Field:   "ingredient_list",
+				Message: fmt.Sprintf("food %q of ingredient %q is not in the nutrient database", ingredient.Food, ingredient.Name),
+			})
+		}
+	}
 	return nil
 }
 
-func (r *recipeUsecase) DeleteRecipe(id uint) error {
-	return r.recipeRepository.DeleteRecipe(id)
+// estimateNutrition caches the nutrients of the ingredient list on the recipe, in total and per
+// serving.
+func (r *recipeUsecase) estimateNutrition(recipe *models.Recipe) {
+	ingredients := make([]nutrition.Ingredient, 0, len(recipe.IngredientList))
+	for _, ingredient := range recipe.IngredientList {
+		ingredients = append(ingredients, nutrition.Ingredient{
+			Name:     ingredient.Name,
+			Food:     ingredient.Food,
+			Quantity: ingredient.Quantity,
+			Unit:     ingredient.Unit,
+		})
+	}
+
+	estimate := r.nutrients.Estimate(ingredients)
+	recipe.Nutrition = nutritionFacts(estimate.Total, 1)
+	recipe.NutritionPerServing = nutritionFacts(estimate.Total, float64(servings(recipe.Servings)))
+	recipe.NutritionComplete = len(ingredients) > 0 && estimate.Complete()
+}
+
+// nutritionFacts returns facts divided by servings, rounded to one decimal.
+func nutritionFacts(facts nutrition.Facts, servings float64) models.NutritionFacts {
+	round := func(v float64) float64 { return math.Round(v/servings*10) / 10 }
+	return models.NutritionFacts{
+		Calories: round(facts.Calories),
+		Protein:  round(facts.Protein),
+		Carbs:    round(facts.Carbs),
+		Fat:      round(facts.Fat),
+	}
+}
+
+// servings returns the number of servings of a recipe, 1 when it is not given.
+func servings(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func unknownTag(tagID uint) error {
+	return apperror.Validation(fmt.Sprintf("tag with ID %d does not exist", tagID),
+		apperror.FieldError{Field: "tag_names", Message: "contains an unknown tag"})
 }
@@ -0,0 +1,61 @@
+package usecases_test
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories/memory"
+	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/nutrition"
+	"api-culinary-review/pkg/storage"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestRecipeTagsResolvedAfterChecks(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewSet()
+	recipes := usecases.NewRecipeUsecase(repos.Recipes, repos.Profiles, repos.Reports, repos.Favorites,
+		usecases.NewtagUsecase(repos.Tags, clock.System{}, usecases.UnknownTagsCreate), storage.NewMemory(),
+		nutrition.Default(), nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	alice := createUser(t, repos, "alice", "secret-password")
+	bob := createUser(t, repos, "bob", "secret-password")
+	soup := func(tagNames ...string) *models.RecipeRequest {
+		return &models.RecipeRequest{Title: "soup", Description: "warm", Ingredients: "water", Instructions: "boil", TagNames: tagNames}
+	}
+	created, err := recipes.CreateRecipe(ctx, nil, soup("soto"), alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		userID   uint
+		recipe   *models.RecipeRequest
+		wantKind apperror.Kind
+	}{
+		{"update by another user", bob.ID, soup("rawon"), apperror.KindForbidden},
+		{"update with invalid allergens", alice.ID, &models.RecipeRequest{Title: "soup", Allergens: []string{"sand"}, TagNames: []string{"rawon"}}, apperror.KindValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := recipes.UpdateRecipe(ctx, created.ID, tt.userID, nil, tt.recipe); !isKind(err, tt.wantKind) {
+				t.Fatalf("UpdateRecipe() error = %v, want a %s error", err, tt.wantKind)
+			}
+		})
+	}
+	if _, err := recipes.CreateRecipe(ctx, nil, &models.RecipeRequest{Title: "salad", Diets: []string{"raw"}, TagNames: []string{"rawon"}}, alice.ID); !isKind(err, apperror.KindValidation) {
+		t.Fatalf("CreateRecipe() with invalid diets error = %v, want a validation error", err)
+	}
+
+	tags, err := repos.Tags.GetAllTags(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Name != "soto" {
+		t.Errorf("tags = %+v, want only soto; a failed request must not create pending tags", tags)
+	}
+}
@@ -3,58 +3,493 @@ package usecases
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/repositories"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/contentfilter"
+	"api-culinary-review/pkg/metrics"
+	"api-culinary-review/pkg/storage"
+	"api-culinary-review/pkg/tracing"
+	"context"
 	"errors"
-	"time"
+	"fmt"
+	"log/slog"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrReviewNotFound  = apperror.NotFound("review not found")
+	ErrNotReviewAuthor = apperror.Forbidden("you can only modify your own review")
+	ErrNotRecipeAuthor = apperror.Forbidden("only the author of the recipe can respond to its reviews")
+	ErrOwnReviewVote   = apperror.Forbidden("you cannot vote on your own review")
+	ErrNotTopReview    = apperror.Validation("the author of the recipe can only respond to reviews, not to replies")
+	ErrReplyTooDeep    = apperror.Validation(fmt.Sprintf("replies can only be nested %d levels deep", maxReplyDepth))
 )
 
+// maxReplyDepth is the number of levels of replies below a review.
+const maxReplyDepth = 3
+
 type ReviewUsecase interface {
-	GetAllReviews() ([]models.Review, error)
-	GetReviewByID(id uint) (*models.Review, error)
-	CreateReview(req *models.ReviewRequest) (*models.Review, error)
-	UpdateReviewByID(req *models.ReviewRequest, id uint) error
-	DeleteReviewByID(id uint) error
+	// GetAllReviews and the other getters leave out hidden reviews and reviews of hidden recipes,
+	// except for their author viewerID. A viewerID of 0 is an anonymous viewer.
+	GetAllReviews(ctx context.Context, page models.PageRequest, viewerID uint) (*models.ReviewPage, error)
+	GetReviewByID(ctx context.Context, id, viewerID uint) (*models.ReviewResponse, error)
+	// CreateReview posts a review of req.UserID on a recipe that exists and is shown to them.
+	CreateReview(ctx context.Context, req *models.ReviewRequest) (*models.ReviewResponse, error)
+	UpdateReviewByID(ctx context.Context, req *models.ReviewUpdateRequest, id, userID uint) error
+	DeleteReviewByID(ctx context.Context, id, userID uint) error
+	GetRecipeReviews(ctx context.Context, recipeID uint, req *models.RecipeReviewsRequest, viewerID uint) (*models.ReviewPage, error)
+	// GetUserReviews returns the reviews and replies written by the user, newest first.
+	GetUserReviews(ctx context.Context, userID uint, page models.PageRequest, viewerID uint) (*models.ReviewPage, error)
+	ReplyToReview(ctx context.Context, req *models.ReplyRequest, id, userID uint) (*models.ReviewResponse, error)
+	SetAuthorResponse(ctx context.Context, req *models.AuthorResponseRequest, id, userID uint) (*models.ReviewResponse, error)
+	DeleteAuthorResponse(ctx context.Context, id, userID uint) error
+	VoteReview(ctx context.Context, req *models.ReviewVoteRequest, id, userID uint) (*models.ReviewResponse, error)
+	DeleteReviewVote(ctx context.Context, id, userID uint) (*models.ReviewResponse, error)
+	GetRecipePhotos(ctx context.Context, recipeID, viewerID uint) ([]models.ReviewPhoto, error)
 }
 
 type reviewUsecase struct {
-	repo repositories.ReviewRepository
+	repo       repositories.ReviewRepository
+	recipeRepo repositories.RecipeRepository
+	userRepo   repositories.UserRepository
+	storage    storage.Storage
+	clock      clock.Clock
+	photoLimit int
+	screen     contentScreen
+	logger     *slog.Logger
 }
 
-func NewReviewUsecase(repo repositories.ReviewRepository) ReviewUsecase {
+// NewReviewUsecase creates a ReviewUsecase that hides reviews and replies matching filter and
+// reports them to moderators.
+func NewReviewUsecase(repo repositories.ReviewRepository, recipeRepo repositories.RecipeRepository, userRepo repositories.UserRepository,
+	reportRepo repositories.ReportRepository, storage storage.Storage, clock clock.Clock, filter *contentfilter.Filter, photoLimit int,
+	logger *slog.Logger) ReviewUsecase {
 	return &reviewUsecase{
-		repo: repo,
+		repo:       repo,
+		recipeRepo: recipeRepo,
+		userRepo:   userRepo,
+		storage:    storage,
+		clock:      clock,
+		photoLimit: photoLimit,
+		screen:     contentScreen{filter: filter, reports: reportRepo, logger: logger},
+		logger:     logger,
 	}
 }
 
-func (uc *reviewUsecase) GetAllReviews() ([]models.Review, error) {
-	return uc.repo.FindAll()
+func (uc *reviewUsecase) GetAllReviews(ctx context.Context, page models.PageRequest, viewerID uint) (*models.ReviewPage, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.GetAllReviews")
+	defer span.End()
+
+	page = page.WithDefaults()
+	rows, total, err := uc.repo.FindAll(ctx, viewerID, page)
+	if err != nil {
+		return nil, err
+	}
+	return reviewPage(rows, page, total), nil
 }
 
-func (uc *reviewUsecase) GetReviewByID(id uint) (*models.Review, error) {
-	return uc.repo.FindByID(id)
+func (uc *reviewUsecase) GetUserReviews(ctx context.Context, userID uint, page models.PageRequest, viewerID uint) (*models.ReviewPage, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.GetUserReviews")
+	defer span.End()
+
+	if _, err := uc.userRepo.FindByID(ctx, userID); err != nil {
+		return nil, notFound(err, "user")
+	}
+
+	page = page.WithDefaults()
+	rows, total, err := uc.repo.FindByUserID(ctx, userID, viewerID, page)
+	if err != nil {
+		return nil, err
+	}
+	return reviewPage(rows, page, total), nil
 }
 
-func (uc *reviewUsecase) CreateReview(req *models.ReviewRequest) (*models.Review, error) {
-	return uc.repo.Create(req)
+func (uc *reviewUsecase) GetReviewByID(ctx context.Context, id, viewerID uint) (*models.ReviewResponse, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.GetReviewByID")
+	defer span.End()
+
+	review, err := uc.findReview(ctx, id, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	response := reviewResponse(*review)
+	return &response, nil
 }
 
-func (uc *reviewUsecase) UpdateReviewByID(req *models.ReviewRequest, id uint) error {
+// findReview returns the review or reply id when it and its recipe are shown to viewerID.
+func (uc *reviewUsecase) findReview(ctx context.Context, id, viewerID uint) (*models.Review, error) {
+	review, err := uc.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if review == nil || !visibleTo(review.Hidden, review.UserID, viewerID) {
+		return nil, ErrReviewNotFound
+	}
+	recipe, err := uc.recipeRepo.GetRecipeByID(ctx, review.RecipeID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err != nil || !visibleTo(recipe.Hidden, recipe.UserID, viewerID) {
+		return nil, ErrReviewNotFound
+	}
+	return review, nil
+}
+
+func (uc *reviewUsecase) CreateReview(ctx context.Context, req *models.ReviewRequest) (*models.ReviewResponse, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.CreateReview")
+	defer span.End()
+
+	if len(req.Photos) > uc.photoLimit {
+		return nil, apperror.Validation("too many photos", apperror.FieldError{
+			Field:   "photos",
+			Message: fmt.Sprintf("a review can have at most %d photos", uc.photoLimit),
+		})
+	}
+	if err := uc.checkRecipe(ctx, req.RecipeID, req.UserID); err != nil {
+		return nil, err
+	}
+	author, err := uc.userRepo.FindByID(ctx, req.UserID)
+	if err != nil {
+		return nil, notFound(err, "user")
+	}
+
+	// Upload the photos first, so the review is saved with them or not at all
+	req.PhotoURLs = nil
+	for _, file := range req.Photos {
+		url, err := uc.storage.Upload(ctx, file)
+		if err != nil {
+			uc.deletePhotos(ctx, req.PhotoURLs, "failed to delete photo of unsaved review", slog.Uint64("recipe_id", uint64(req.RecipeID)))
+			return nil, err
+		}
+		req.PhotoURLs = append(req.PhotoURLs, url)
+	}
+
+	rule, flagged := uc.screen.match(req.Content)
+	req.Hidden = flagged
+	review, err := uc.repo.Create(ctx, req)
+	if err != nil {
+		uc.deletePhotos(ctx, req.PhotoURLs, "failed to delete photo of unsaved review", slog.Uint64("recipe_id", uint64(req.RecipeID)))
+		return nil, err
+	}
+	if flagged {
+		uc.screen.flag(ctx, models.ReportTargetReview, review.ID, rule)
+	}
+
+	metrics.ReviewPosted()
+	review.User = *author
+	response := reviewResponse(*review)
+	return &response, nil
+}
+
+func (uc *reviewUsecase) UpdateReviewByID(ctx context.Context, req *models.ReviewUpdateRequest, id, userID uint) error {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.UpdateReviewByID")
+	defer span.End()
+
+	existing, err := uc.findReview(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if existing.UserID != userID {
+		return ErrNotReviewAuthor
+	}
+
+	rule, flagged := uc.screen.match(req.Content)
 	review := &models.Review{
-		UserID:    req.UserID,
-		RecipeID:  req.RecipeID,
+		UserID:    userID,
+		RecipeID:  existing.RecipeID,
 		Content:   req.Content,
-		UpdatedAt: time.Now(),
+		Hidden:    flagged,
+		UpdatedAt: uc.clock.Now(),
+	}
+	if err := uc.repo.UpdateReviewByID(ctx, review, id); err != nil {
+		return err
+	}
+	if flagged && !existing.Hidden {
+		uc.screen.flag(ctx, models.ReportTargetReview, id, rule)
+	}
+	return nil
+}
+
+func (uc *reviewUsecase) DeleteReviewByID(ctx context.Context, id, userID uint) error {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.DeleteReviewByID")
+	defer span.End()
+
+	review, err := uc.findReview(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if review.UserID != userID {
+		return ErrNotReviewAuthor
+	}
+
+	photoURLs, err := uc.repo.DeleteReviewByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	uc.deletePhotos(ctx, photoURLs, "failed to delete photo of deleted review", slog.Uint64("review_id", uint64(id)))
+	return nil
+}
+
+// deletePhotos removes the photos at urls from storage. A failure is only logged with msg and
+// attrs, as the review is already deleted or was never saved.
+func (uc *reviewUsecase) deletePhotos(ctx context.Context, urls []string, msg string, attrs ...any) {
+	for _, url := range urls {
+		if err := uc.storage.Delete(ctx, url); err != nil {
+			uc.logger.WarnContext(ctx, msg, append([]any{slog.String("url", url), slog.Any("error", err)}, attrs...)...)
+		}
+	}
+}
+
+// GetRecipePhotos returns the photos of the reviews of the recipe, newest first.
+func (uc *reviewUsecase) GetRecipePhotos(ctx context.Context, recipeID, viewerID uint) ([]models.ReviewPhoto, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.GetRecipePhotos")
+	defer span.End()
+
+	if err := uc.checkRecipe(ctx, recipeID, viewerID); err != nil {
+		return nil, err
+	}
+
+	photos, err := uc.repo.FindPhotosByRecipeID(ctx, recipeID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if photos == nil {
+		photos = []models.ReviewPhoto{}
+	}
+	return photos, nil
+}
+
+// GetRecipeReviews returns the reviews of the recipe in the order of sortBy, each with the thread
+// of replies to it in the order they were posted.
+func (uc *reviewUsecase) GetRecipeReviews(ctx context.Context, recipeID uint, req *models.RecipeReviewsRequest, viewerID uint) (*models.ReviewPage, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.GetRecipeReviews")
+	defer span.End()
+
+	if err := uc.checkRecipe(ctx, recipeID, viewerID); err != nil {
+		return nil, err
+	}
+
+	page := req.PageRequest.WithDefaults()
+	rows, total, err := uc.repo.FindByRecipeID(ctx, recipeID, viewerID, req.Sort, page)
+	if err != nil {
+		return nil, err
+	}
+	return reviewPage(reviewThreads(rows), page, total), nil
+}
+
+// ReplyToReview posts a reply to the review or reply id, in the thread of its recipe.
+func (uc *reviewUsecase) ReplyToReview(ctx context.Context, req *models.ReplyRequest, id, userID uint) (*models.ReviewResponse, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.ReplyToReview")
+	defer span.End()
+
+	parent, err := uc.findReview(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.Depth >= maxReplyDepth {
+		return nil, ErrReplyTooDeep
 	}
-	return uc.repo.UpdateReviewByID(review, id)
+	author, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, "user")
+	}
+
+	rule, flagged := uc.screen.match(req.Content)
+	reply, err := uc.repo.Create(ctx, &models.ReviewRequest{
+		UserID:   userID,
+		RecipeID: parent.RecipeID,
+		ParentID: &parent.ID,
+		Depth:    parent.Depth + 1,
+		Content:  req.Content,
+		Hidden:   flagged,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if flagged {
+		uc.screen.flag(ctx, models.ReportTargetReview, reply.ID, rule)
+	}
+	reply.User = *author
+	response := reviewResponse(*reply)
+	return &response, nil
 }
 
-func (uc *reviewUsecase) DeleteReviewByID(id uint) error {
-	review, err := uc.repo.FindByID(id)
+// SetAuthorResponse sets the response of the author of the recipe to one of its reviews. A
+// response that matches the content filter is held for moderation like a review; it is returned
+// to its author but left out of the review for everyone until a moderator approves it.
+func (uc *reviewUsecase) SetAuthorResponse(ctx context.Context, req *models.AuthorResponseRequest, id, userID uint) (*models.ReviewResponse, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.SetAuthorResponse")
+	defer span.End()
+
+	review, err := uc.recipeAuthorReview(ctx, id, userID)
 	if err != nil {
+		return nil, err
+	}
+
+	rule, flagged := uc.screen.match(req.Content)
+	hidden := flagged || review.AuthorResponseHidden
+	at := uc.clock.Now()
+	if err := uc.repo.SetAuthorResponse(ctx, id, req.Content, &at, hidden); err != nil {
+		return nil, err
+	}
+	if flagged && !review.AuthorResponseHidden {
+		uc.screen.flag(ctx, models.ReportTargetAuthorResponse, id, rule)
+	}
+	review.AuthorResponseHidden = false
+	review.AuthorResponse = req.Content
+	review.AuthorResponseAt = &at
+	response := reviewResponse(*review)
+	return &response, nil
+}
+
+func (uc *reviewUsecase) DeleteAuthorResponse(ctx context.Context, id, userID uint) error {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.DeleteAuthorResponse")
+	defer span.End()
+
+	if _, err := uc.recipeAuthorReview(ctx, id, userID); err != nil {
 		return err
 	}
-	if review == nil {
-		return errors.New("review not found")
+	return uc.repo.SetAuthorResponse(ctx, id, "", nil, false)
+}
+
+// VoteReview records whether the user finds the review or reply helpful, replacing an earlier
+// vote of theirs.
+func (uc *reviewUsecase) VoteReview(ctx context.Context, req *models.ReviewVoteRequest, id, userID uint) (*models.ReviewResponse, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.VoteReview")
+	defer span.End()
+
+	review, err := uc.findReview(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if review.UserID == userID {
+		return nil, ErrOwnReviewVote
+	}
+
+	if err := uc.repo.SaveVote(ctx, &models.ReviewVote{ReviewID: id, UserID: userID, Helpful: *req.Helpful}); err != nil {
+		return nil, err
 	}
+	return uc.GetReviewByID(ctx, id, userID)
+}
 
-	return uc.repo.DeleteReviewByID(id)
+func (uc *reviewUsecase) DeleteReviewVote(ctx context.Context, id, userID uint) (*models.ReviewResponse, error) {
+	ctx, span := tracing.Start(ctx, "ReviewUsecase.DeleteReviewVote")
+	defer span.End()
+
+	if _, err := uc.findReview(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	if err := uc.repo.DeleteVote(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	return uc.GetReviewByID(ctx, id, userID)
+}
+
+// recipeAuthorReview returns the review id when it is a review, not a reply, of a recipe by the
+// user.
+func (uc *reviewUsecase) recipeAuthorReview(ctx context.Context, id, userID uint) (*models.Review, error) {
+	review, err := uc.findReview(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if review.ParentID != nil {
+		return nil, ErrNotTopReview
+	}
+
+	recipe, err := uc.recipeRepo.GetRecipeByID(ctx, review.RecipeID)
+	if err != nil {
+		return nil, notFound(err, "recipe")
+	}
+	if recipe.UserID != userID {
+		return nil, ErrNotRecipeAuthor
+	}
+	return review, nil
+}
+
+// checkRecipe returns a NotFound error unless the recipe exists and is shown to viewerID.
+func (uc *reviewUsecase) checkRecipe(ctx context.Context, recipeID, viewerID uint) error {
+	recipe, err := uc.recipeRepo.GetRecipeByID(ctx, recipeID)
+	if err != nil {
+		return notFound(err, "recipe")
+	}
+	if !visibleTo(recipe.Hidden, recipe.UserID, viewerID) {
+		return ErrRecipeNotFound
+	}
+	return nil
+}
+
+// reviewPage returns the reviews on the page as compact responses, with total reviews on all
+// pages.
+func reviewPage(reviews []models.Review, page models.PageRequest, total int) *models.ReviewPage {
+	response := &models.ReviewPage{
+		Data: make([]models.ReviewResponse, 0, len(reviews)),
+		Meta: pageMeta(page, total),
+	}
+	for _, review := range reviews {
+		response.Data = append(response.Data, reviewResponse(review))
+	}
+	return response
+}
+
+// pageMeta describes the page of a listing of total items.
+func pageMeta(page models.PageRequest, total int) models.PageMeta {
+	page = page.WithDefaults()
+	return models.PageMeta{Page: page.Page, PageSize: page.PageSize, Total: total, TotalPages: (total + page.PageSize - 1) / page.PageSize}
+}
+
+// reviewResponse returns review, with the thread of replies to it, as listed by the API.
+func reviewResponse(review models.Review) models.ReviewResponse {
+	response := models.ReviewResponse{
+		ID:             review.ID,
+		RecipeID:       review.RecipeID,
+		ParentID:       review.ParentID,
+		Depth:          review.Depth,
+		Content:        review.Content,
+		HelpfulCount:   review.HelpfulCount,
+		UnhelpfulCount: review.UnhelpfulCount,
+		CreatedAt:      review.CreatedAt,
+		UpdatedAt:      review.UpdatedAt,
+		User:           models.UserResponse{ID: review.UserID, Username: review.User.Username},
+		Photos:         review.Photos,
+	}
+	if !review.AuthorResponseHidden {
+		response.AuthorResponse, response.AuthorResponseAt = review.AuthorResponse, review.AuthorResponseAt
+	}
+	if response.Photos == nil {
+		response.Photos = []models.ReviewPhoto{}
+	}
+	for _, reply := range review.Replies {
+		response.Replies = append(response.Replies, reviewResponse(reply))
+	}
+	return response
+}
+
+// reviewThreads nests the replies among rows, which are sorted by ID, under the reviews they
+// reply to and returns the reviews.
+func reviewThreads(rows []models.Review) []models.Review {
+	children := make(map[uint][]models.Review)
+	var reviews []models.Review
+	for _, row := range rows {
+		if row.ParentID == nil {
+			reviews = append(reviews, row)
+		} else {
+			children[*row.ParentID] = append(children[*row.ParentID], row)
+		}
+	}
+
+	var nest func(review *models.Review)
+	nest = func(review *models.Review) {
+		review.Replies = children[review.ID]
+		for i := range review.Replies {
+			nest(&review.Replies[i])
+		}
+	}
+	for i := range reviews {
+		nest(&reviews[i])
+	}
+	if reviews == nil {
+		reviews = []models.Review{}
+	}
+	return reviews
 }
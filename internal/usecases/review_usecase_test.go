@@ -0,0 +1,79 @@
+package usecases_test
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories/memory"
+	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/contentfilter"
+	"api-culinary-review/pkg/storage"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestAuthorResponseContentFilter(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewSet()
+	filter, err := contentfilter.New([]string{"spam"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviews := usecases.NewReviewUsecase(repos.Reviews, repos.Recipes, repos.Users, repos.Reports, storage.NewMemory(), clock.System{}, filter,
+		5, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	moderation := usecases.NewModerationUsecase(repos.Reports, repos.Reviews, repos.Recipes, clock.System{})
+
+	alice := createUser(t, repos, "alice", "secret-password")
+	bob := createUser(t, repos, "bob", "secret-password")
+	soup, err := repos.Recipes.CreateRecipe(ctx, &models.Recipe{Title: "soup", Ingredients: "water", Instructions: "boil", UserID: alice.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := reviews.CreateReview(ctx, &models.ReviewRequest{UserID: bob.ID, RecipeID: soup.ID, Content: "tasty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shown := func() string {
+		t.Helper()
+		found, err := reviews.GetReviewByID(ctx, review.ID, bob.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return found.AuthorResponse
+	}
+
+	response, err := reviews.SetAuthorResponse(ctx, &models.AuthorResponseRequest{Content: "buy my spam"}, review.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.AuthorResponse != "buy my spam" {
+		t.Errorf("SetAuthorResponse() returned the response %q, want it shown to its author", response.AuthorResponse)
+	}
+	if got := shown(); got != "" {
+		t.Errorf("author response shown as %q, want it held for moderation", got)
+	}
+	queue, err := moderation.GetQueue(ctx, &models.ReportQueueRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports := queue.Data; len(reports) != 1 || reports[0].TargetType != models.ReportTargetAuthorResponse || reports[0].TargetID != review.ID ||
+		reports[0].Excerpt != "buy my spam" {
+		t.Fatalf("GetQueue() = %+v, want the flagged author response", reports)
+	}
+
+	// A clean response does not show a response held for moderation without a moderator.
+	if _, err := reviews.SetAuthorResponse(ctx, &models.AuthorResponseRequest{Content: "thank you"}, review.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := shown(); got != "" {
+		t.Errorf("author response shown as %q after an edit, want it still held for moderation", got)
+	}
+	if _, err := moderation.Resolve(ctx, &models.ResolveReportRequest{Decision: models.ModerationApprove}, queue.Data[0].ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := shown(); got != "thank you" {
+		t.Errorf("author response shown as %q after approval, want %q", got, "thank you")
+	}
+}
@@ -3,52 +3,693 @@ package usecases
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/repositories"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/tracing"
+	"api-culinary-review/pkg/utils"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrTagNotFound = apperror.NotFound("tag not found")
+	ErrTagInUse    = apperror.Conflict("tag is used by recipes; merge it into another tag or delete it with replace_with")
 )
 
 type TagUsecase interface {
-	CreateTag(name string) (*models.Tag, error)
-	GetAllTags() ([]models.Tag, error)
-	GetTagsByNames(tagNames []string) ([]models.Tag, error)
-	UpdateTag(tag *models.Tag) error
-	DeleteTag(id uint) error
+	CreateTag(ctx context.Context, req *models.TagRequest) (*models.TagResponse, error)
+	GetAllTags(ctx context.Context, category string) ([]models.TagGroup, error)
+	GetTagBySlug(ctx context.Context, slug string) (*models.TagResponse, error)
+	GetTagsByNames(ctx context.Context, tagNames []string) ([]models.Tag, error)
+	ResolveTags(ctx context.Context, tagNames []string) ([]models.Tag, error)
+	SuggestTags(ctx context.Context, prefix string, limit int) ([]models.TagResponse, error)
+	GetPendingTags(ctx context.Context) ([]models.TagResponse, error)
+	// ApproveTag, UpdateTag, MergeTags and DeleteTag record the decision of the moderator
+	// moderatorID in the audit trail.
+	ApproveTag(ctx context.Context, id, moderatorID uint) (*models.TagResponse, error)
+	UpdateTag(ctx context.Context, id uint, req *models.TagRequest, moderatorID uint) error
+	MergeTags(ctx context.Context, targetID uint, sourceIDs []uint, moderatorID uint) (*models.TagResponse, error)
+	DeleteTag(ctx context.Context, id, replaceWith, moderatorID uint) error
 }
 
+// What ResolveTags does with tag names that match no tag.
+const (
+	UnknownTagsCreate = "create"
+	UnknownTagsIgnore = "ignore"
+	UnknownTagsReject = "reject"
+)
+
+const (
+	maxRecipeTags      = 20
+	maxTagNameLength   = 50
+	defaultSuggestions = 10
+)
+
+// reservedTagSlugs are the slugs that would be shadowed by other /api/tags routes.
+var reservedTagSlugs = map[string]bool{"suggest": true}
+
 type tagUsecase struct {
 	TagRepository repositories.TagRepository
+	clock         clock.Clock
+	unknownTags   string
 }
 
-func NewtagUsecase(tagRepo repositories.TagRepository) TagUsecase {
+// NewtagUsecase creates a TagUsecase handling unknown tag names of recipes as unknownTags says,
+// one of UnknownTagsCreate, UnknownTagsIgnore and UnknownTagsReject.
+func NewtagUsecase(tagRepo repositories.TagRepository, clock clock.Clock, unknownTags string) TagUsecase {
 	return &tagUsecase{
 		TagRepository: tagRepo,
+		clock:         clock,
+		unknownTags:   unknownTags,
+	}
+}
+
+func (uc *tagUsecase) CreateTag(ctx context.Context, req *models.TagRequest) (*models.TagResponse, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.CreateTag")
+	defer span.End()
+
+	tags, err := uc.TagRepository.GetAllTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &models.Tag{Status: models.TagStatusApproved}
+	if err := applyTagRequest(tag, req, tags); err != nil {
+		return nil, err
+	}
+
+	if err := uc.TagRepository.Create(ctx, tag); err != nil {
+		return nil, err
+	}
+
+	response := tagResponse(*tag, nil)
+	return &response, nil
+}
+
+// GetAllTags returns the tags grouped by category, optionally only the given category.
+func (uc *tagUsecase) GetAllTags(ctx context.Context, category string) ([]models.TagGroup, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.GetAllTags")
+	defer span.End()
+
+	if category != "" && !isTagCategory(category) {
+		return nil, apperror.Validation("unknown tag category",
+			apperror.FieldError{Field: "category", Message: "must be one of " + strings.Join(models.TagCategories, ", ")})
+	}
+
+	tags, counts, err := uc.tagsWithCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := tagTree(approvedTags(tags), counts)
+	groups := []models.TagGroup{}
+	for _, c := range models.TagCategories {
+		if category != "" && c != category {
+			continue
+		}
+		var group []models.TagResponse
+		for _, root := range roots {
+			if tagCategory(root.Category) == c {
+				group = append(group, root)
+			}
+		}
+		if len(group) > 0 {
+			groups = append(groups, models.TagGroup{Category: c, Tags: group})
+		}
 	}
+	return groups, nil
 }
 
-func (uc *tagUsecase) CreateTag(name string) (*models.Tag, error) {
-	tag := &models.Tag{
-		Name: name,
+// GetTagBySlug returns the approved tag with its approved sub-tags.
+func (uc *tagUsecase) GetTagBySlug(ctx context.Context, slug string) (*models.TagResponse, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.GetTagBySlug")
+	defer span.End()
+
+	tags, counts, err := uc.tagsWithCounts(ctx)
+	if err != nil {
+		return nil, err
 	}
+	tags = approvedTags(tags)
 
-	err := uc.TagRepository.Create(tag)
+	for _, tag := range tags {
+		if tag.Slug == slug {
+			response := tagSubtree(tag, childrenOf(tags), counts)
+			return &response, nil
+		}
+	}
+	return nil, ErrTagNotFound
+}
+
+// GetTagsByNames returns the tags named or known by a synonym in tagNames.
+func (uc *tagUsecase) GetTagsByNames(ctx context.Context, tagNames []string) ([]models.Tag, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.GetTagsByNames")
+	defer span.End()
+
+	return uc.TagRepository.GetTagsByNames(ctx, tagNames)
+}
+
+// ResolveTags returns the tags of a recipe submitted with tagNames. Names that match no tag by
+// name, synonym or slug are normalized and then created as pending tags, ignored or rejected,
+// depending on the configured policy.
+func (uc *tagUsecase) ResolveTags(ctx context.Context, tagNames []string) ([]models.Tag, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.ResolveTags")
+	defer span.End()
+
+	if len(tagNames) > maxRecipeTags {
+		return nil, apperror.Validation("too many tags",
+			apperror.FieldError{Field: "tag_names", Message: fmt.Sprintf("must contain at most %d tags", maxRecipeTags)})
+	}
+
+	tags, err := uc.TagRepository.GetAllTags(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	var resolved []models.Tag
+	var unknown []string
+	added := map[uint]bool{}
+	pending := map[string]bool{}
+	for _, name := range tagNames {
+		name = normalizeTagName(name)
+		if name == "" {
+			continue
+		}
+		if tag := matchTag(tags, name); tag != nil {
+			if !added[tag.ID] {
+				added[tag.ID] = true
+				resolved = append(resolved, *tag)
+			}
+			continue
+		}
+		if slug := utils.Slugify(name); !pending[slug] {
+			pending[slug] = true
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) == 0 || uc.unknownTags == UnknownTagsIgnore {
+		return resolved, nil
+	}
+	if uc.unknownTags == UnknownTagsReject {
+		return nil, apperror.Validation("unknown tags",
+			apperror.FieldError{Field: "tag_names", Message: "unknown tags: " + strings.Join(unknown, ", ")})
+	}
+
+	// Every name is checked before any tag is created.
+	newTags := make([]*models.Tag, 0, len(unknown))
+	for _, name := range unknown {
+		tag, err := pendingTag(name, tags)
+		if err != nil {
+			return nil, err
+		}
+		newTags = append(newTags, tag)
+	}
+
+	for _, tag := range newTags {
+		if err := uc.TagRepository.Create(ctx, tag); err != nil {
+			// The tag may have been created concurrently by another submission.
+			existing, findErr := uc.TagRepository.GetTagsByNames(ctx, []string{tag.Name})
+			if findErr != nil || len(existing) == 0 {
+				return nil, err
+			}
+			tag = &existing[0]
+		}
+		if !added[tag.ID] {
+			added[tag.ID] = true
+			resolved = append(resolved, *tag)
+		}
+	}
+	return resolved, nil
+}
+
+// pendingTag returns a tag named name pending moderation, checked against the existing tags.
+func pendingTag(name string, tags []models.Tag) (*models.Tag, error) {
+	invalid := apperror.Validation("invalid tag name",
+		apperror.FieldError{Field: "tag_names", Message: fmt.Sprintf("%q is not a valid tag name", name)})
+	if utf8.RuneCountInString(name) > maxTagNameLength {
+		return nil, invalid
+	}
+
+	tag := &models.Tag{Status: models.TagStatusPending}
+	if err := applyTagRequest(tag, &models.TagRequest{Name: name}, tags); err != nil {
+		var appErr *apperror.Error
+		if errors.As(err, &appErr) && appErr.Kind == apperror.KindValidation {
+			return nil, invalid
+		}
+		return nil, err
+	}
 	return tag, nil
 }
 
-func (uc *tagUsecase) GetAllTags() ([]models.Tag, error) {
-	return uc.TagRepository.GetAllTags()
+// SuggestTags returns up to limit approved tags whose name or a synonym starts with prefix,
+// ignoring case, with the most used tags first.
+func (uc *tagUsecase) SuggestTags(ctx context.Context, prefix string, limit int) ([]models.TagResponse, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.SuggestTags")
+	defer span.End()
+
+	if limit <= 0 {
+		limit = defaultSuggestions
+	}
+
+	tags, counts, err := uc.TagRepository.Suggest(ctx, normalizeTagName(prefix), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]models.TagResponse, 0, len(tags))
+	for _, tag := range tags {
+		suggestions = append(suggestions, tagResponse(tag, counts))
+	}
+	return suggestions, nil
+}
+
+// GetPendingTags returns the tags waiting for moderation, oldest first.
+func (uc *tagUsecase) GetPendingTags(ctx context.Context) ([]models.TagResponse, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.GetPendingTags")
+	defer span.End()
+
+	tags, counts, err := uc.tagsWithCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []models.TagResponse{}
+	for _, tag := range tags {
+		if tag.Status == models.TagStatusPending {
+			pending = append(pending, tagResponse(tag, counts))
+		}
+	}
+	return pending, nil
+}
+
+// ApproveTag makes a pending tag visible in tag listings and suggestions.
+func (uc *tagUsecase) ApproveTag(ctx context.Context, id, moderatorID uint) (*models.TagResponse, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.ApproveTag")
+	defer span.End()
+
+	tags, counts, err := uc.tagsWithCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := findTag(tags, id)
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+
+	if tag.Status != models.TagStatusApproved {
+		action := uc.action(models.ModerationApprove, tag.ID, moderatorID, fmt.Sprintf("approved tag %q", tag.Name))
+		if err := uc.TagRepository.Approve(ctx, tag.ID, &action); err != nil {
+			return nil, err
+		}
+		tag.Status = models.TagStatusApproved
+	}
+
+	response := tagResponse(*tag, counts)
+	return &response, nil
+}
+
+// UpdateTag replaces the name, category, parent and synonyms of the tag.
+func (uc *tagUsecase) UpdateTag(ctx context.Context, id uint, req *models.TagRequest, moderatorID uint) error {
+	ctx, span := tracing.Start(ctx, "TagUsecase.UpdateTag")
+	defer span.End()
+
+	tags, err := uc.TagRepository.GetAllTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag := findTag(tags, id)
+	if tag == nil {
+		return ErrTagNotFound
+	}
+
+	name := tag.Name
+	if err := applyTagRequest(tag, req, tags); err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("updated tag %q", name)
+	if tag.Name != name {
+		reason += fmt.Sprintf(", renamed to %q", tag.Name)
+	}
+	action := uc.action(models.ModerationUpdate, tag.ID, moderatorID, reason)
+	return uc.TagRepository.Update(ctx, tag, &action)
+}
+
+// MergeTags merges the source tags into the target: their recipes and sub-tags move to the
+// target, their names and synonyms become synonyms of the target and they are deleted.
+func (uc *tagUsecase) MergeTags(ctx context.Context, targetID uint, sourceIDs []uint, moderatorID uint) (*models.TagResponse, error) {
+	ctx, span := tracing.Start(ctx, "TagUsecase.MergeTags")
+	defer span.End()
+
+	tags, err := uc.TagRepository.GetAllTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target := findTag(tags, targetID)
+	if target == nil {
+		return nil, ErrTagNotFound
+	}
+
+	var sources []models.Tag
+	seen := map[uint]bool{}
+	for _, id := range sourceIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if id == targetID {
+			return nil, apperror.Validation("a tag cannot be merged into itself",
+				apperror.FieldError{Field: "source_ids", Message: "must not contain the target tag"})
+		}
+		source := findTag(tags, id)
+		if source == nil {
+			return nil, apperror.NotFound(fmt.Sprintf("tag with ID %d not found", id))
+		}
+		sources = append(sources, *source)
+	}
+
+	names := map[string]bool{strings.ToLower(target.Name): true}
+	for _, synonym := range target.Synonyms {
+		names[strings.ToLower(synonym.Name)] = true
+	}
+	for _, source := range sources {
+		for _, name := range append([]string{source.Name}, synonymNames(source)...) {
+			if !names[strings.ToLower(name)] {
+				names[strings.ToLower(name)] = true
+				target.Synonyms = append(target.Synonyms, models.TagSynonym{Name: name})
+			}
+		}
+	}
+
+	actions := make([]models.ModerationAction, 0, len(sources))
+	for _, source := range sources {
+		actions = append(actions, uc.action(models.ModerationMerge, source.ID, moderatorID,
+			fmt.Sprintf("merged tag %q into tag %q (ID %d)", source.Name, target.Name, target.ID)))
+	}
+	if err := uc.mergeInto(ctx, tags, target, sources, actions); err != nil {
+		return nil, err
+	}
+	return uc.GetTagBySlug(ctx, target.Slug)
+}
+
+// DeleteTag deletes the tag. A tag that is in use can only be deleted by moving its recipes
+// to the replacement tag replaceWith.
+func (uc *tagUsecase) DeleteTag(ctx context.Context, id, replaceWith, moderatorID uint) error {
+	ctx, span := tracing.Start(ctx, "TagUsecase.DeleteTag")
+	defer span.End()
+
+	tags, err := uc.TagRepository.GetAllTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag := findTag(tags, id)
+	if tag == nil {
+		return ErrTagNotFound
+	}
+
+	if replaceWith != 0 {
+		replacement := findTag(tags, replaceWith)
+		if replacement == nil || replaceWith == id {
+			return apperror.Validation("invalid replacement tag",
+				apperror.FieldError{Field: "replace_with", Message: "must be the ID of another tag"})
+		}
+		action := uc.action(models.ModerationDelete, id, moderatorID,
+			fmt.Sprintf("deleted tag %q, replaced by tag %q (ID %d)", tag.Name, replacement.Name, replacement.ID))
+		return uc.mergeInto(ctx, tags, replacement, []models.Tag{*tag}, []models.ModerationAction{action})
+	}
+
+	action := uc.action(models.ModerationDelete, id, moderatorID, fmt.Sprintf("deleted tag %q", tag.Name))
+	if err := uc.TagRepository.Delete(ctx, id, &action); err != nil {
+		if errors.Is(err, repositories.ErrTagInUse) {
+			return ErrTagInUse
+		}
+		return err
+	}
+	return nil
+}
+
+// mergeInto moves the recipes and sub-tags of sources to target, deletes the sources and records
+// actions. A target below one of the sources takes the place of the source in the hierarchy.
+func (uc *tagUsecase) mergeInto(ctx context.Context, tags []models.Tag, target *models.Tag, sources []models.Tag,
+	actions []models.ModerationAction) error {
+	merged := make(map[uint]models.Tag, len(sources))
+	ids := make([]uint, 0, len(sources))
+	for _, source := range sources {
+		merged[source.ID] = source
+		ids = append(ids, source.ID)
+	}
+	for i := 0; target.ParentID != nil && i <= len(tags); i++ {
+		source, ok := merged[*target.ParentID]
+		if !ok {
+			break
+		}
+		target.ParentID = source.ParentID
+	}
+
+	return uc.TagRepository.Merge(ctx, target, ids, actions)
+}
+
+// action returns the entry of the audit trail of a decision of the moderator on the tag.
+func (uc *tagUsecase) action(decision string, tagID, moderatorID uint, reason string) models.ModerationAction {
+	return models.ModerationAction{
+		ModeratorID: moderatorID,
+		Action:      decision,
+		TargetType:  models.ModerationTargetTag,
+		TargetID:    tagID,
+		Reason:      reason,
+		CreatedAt:   uc.clock.Now(),
+	}
+}
+
+func (uc *tagUsecase) tagsWithCounts(ctx context.Context) ([]models.Tag, map[uint]int, error) {
+	tags, err := uc.TagRepository.GetAllTags(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	counts, err := uc.TagRepository.CountRecipes(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tags, counts, nil
+}
+
+// applyTagRequest validates req against the existing tags and applies it to tag. Names and
+// synonyms must be unique among all tags, and a parent must be in the same category and must
+// not be the tag itself or one of its sub-tags.
+func applyTagRequest(tag *models.Tag, req *models.TagRequest, tags []models.Tag) error {
+	name := strings.TrimSpace(req.Name)
+	slug := utils.Slugify(name)
+	if slug == "" {
+		return apperror.Validation("invalid tag name", apperror.FieldError{Field: "name", Message: "must contain a letter or a digit"})
+	}
+	if reservedTagSlugs[slug] {
+		return apperror.Validation("invalid tag name", apperror.FieldError{Field: "name", Message: fmt.Sprintf("%q is reserved", name)})
+	}
+
+	category := req.Category
+	if category == "" {
+		category = models.TagCategoryOther
+	}
+
+	// Names are unique ignoring case, so "Vegan" cannot be added next to "vegan".
+	var synonyms []models.TagSynonym
+	seen := map[string]bool{strings.ToLower(name): true}
+	for _, synonym := range req.Synonyms {
+		synonym = strings.TrimSpace(synonym)
+		if synonym != "" && !seen[strings.ToLower(synonym)] {
+			seen[strings.ToLower(synonym)] = true
+			synonyms = append(synonyms, models.TagSynonym{Name: synonym})
+		}
+	}
+
+	for _, other := range tags {
+		if other.ID == tag.ID {
+			continue
+		}
+		if other.Slug == slug || seen[strings.ToLower(other.Name)] {
+			return apperror.Conflict(fmt.Sprintf("tag %q already exists", other.Name))
+		}
+		for _, synonym := range other.Synonyms {
+			if seen[strings.ToLower(synonym.Name)] {
+				return apperror.Conflict(fmt.Sprintf("%q is already a synonym of tag %q", synonym.Name, other.Name))
+			}
+		}
+	}
+
+	if req.ParentID != nil {
+		parents := make(map[uint]models.Tag, len(tags))
+		for _, t := range tags {
+			parents[t.ID] = t
+		}
+		parent, ok := parents[*req.ParentID]
+		if !ok {
+			return apperror.Validation("parent tag does not exist", apperror.FieldError{Field: "parent_id", Message: "is not a tag"})
+		}
+		if parent.Category != category {
+			return apperror.Validation("invalid parent tag", apperror.FieldError{Field: "parent_id", Message: "must be in the same category"})
+		}
+		ancestor := parent
+		for i := 0; ok && tag.ID != 0 && i <= len(tags); i++ {
+			if ancestor.ID == tag.ID {
+				return apperror.Validation("invalid parent tag", apperror.FieldError{Field: "parent_id", Message: "must not be the tag itself or one of its sub-tags"})
+			}
+			ancestor, ok = parents[derefID(ancestor.ParentID)]
+		}
+	}
+
+	if tag.ID != 0 && category != tag.Category && len(childrenOf(tags)[tag.ID]) > 0 {
+		return apperror.Validation("invalid tag category", apperror.FieldError{Field: "category", Message: "cannot change while the tag has sub-tags"})
+	}
+
+	tag.Name = name
+	tag.Slug = slug
+	tag.Category = category
+	tag.ParentID = req.ParentID
+	tag.Synonyms = synonyms
+	return nil
+}
+
+// tagTree returns the top-level tags with their sub-tags, sorted by name. A tag whose parent is
+// missing or in another category is shown at the top level.
+func tagTree(tags []models.Tag, counts map[uint]int) []models.TagResponse {
+	byID := make(map[uint]models.Tag, len(tags))
+	for _, tag := range tags {
+		byID[tag.ID] = tag
+	}
+	children := childrenOf(tags)
+
+	var roots []models.TagResponse
+	for _, tag := range tags {
+		if parent, ok := byID[derefID(tag.ParentID)]; ok && parent.Category == tag.Category {
+			continue
+		}
+		roots = append(roots, tagSubtree(tag, children, counts))
+	}
+	sortTagResponses(roots)
+	return roots
+}
+
+func tagSubtree(tag models.Tag, children map[uint][]models.Tag, counts map[uint]int) models.TagResponse {
+	response := tagResponse(tag, counts)
+	for _, child := range children[tag.ID] {
+		if child.Category == tag.Category {
+			response.Children = append(response.Children, tagSubtree(child, children, counts))
+		}
+	}
+	sortTagResponses(response.Children)
+	return response
+}
+
+func approvedTags(tags []models.Tag) []models.Tag {
+	approved := make([]models.Tag, 0, len(tags))
+	for _, tag := range tags {
+		if tag.Status != models.TagStatusPending {
+			approved = append(approved, tag)
+		}
+	}
+	return approved
+}
+
+// matchTag returns the tag named name or known by it as a synonym, ignoring case, or else the
+// tag with the same slug.
+func matchTag(tags []models.Tag, name string) *models.Tag {
+	lower := strings.ToLower(name)
+	for i := range tags {
+		if strings.ToLower(tags[i].Name) == lower {
+			return &tags[i]
+		}
+		for _, synonym := range tags[i].Synonyms {
+			if strings.ToLower(synonym.Name) == lower {
+				return &tags[i]
+			}
+		}
+	}
+	slug := utils.Slugify(name)
+	for i := range tags {
+		if slug != "" && tags[i].Slug == slug {
+			return &tags[i]
+		}
+	}
+	return nil
+}
+
+// normalizeTagName lower-cases the name and collapses its whitespace, so " Nasi  Goreng"
+// becomes "nasi goreng".
+func normalizeTagName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
 }
 
-func (uc *tagUsecase) GetTagsByNames(tagNames []string) ([]models.Tag, error) {
-	return uc.TagRepository.GetTagsByNames(tagNames)
+func childrenOf(tags []models.Tag) map[uint][]models.Tag {
+	children := make(map[uint][]models.Tag)
+	for _, tag := range tags {
+		if tag.ParentID != nil {
+			children[*tag.ParentID] = append(children[*tag.ParentID], tag)
+		}
+	}
+	return children
+}
+
+func findTag(tags []models.Tag, id uint) *models.Tag {
+	for i := range tags {
+		if tags[i].ID == id {
+			return &tags[i]
+		}
+	}
+	return nil
 }
 
-func (uc *tagUsecase) UpdateTag(tag *models.Tag) error {
-	return uc.TagRepository.Update(tag)
+func synonymNames(tag models.Tag) []string {
+	names := make([]string, 0, len(tag.Synonyms))
+	for _, synonym := range tag.Synonyms {
+		names = append(names, synonym.Name)
+	}
+	return names
 }
 
-func (uc *tagUsecase) DeleteTag(id uint) error {
-	// Implement deletion logic as needed
-	return uc.TagRepository.Delete(id)
+func tagResponse(tag models.Tag, counts map[uint]int) models.TagResponse {
+	return models.TagResponse{
+		ID:          tag.ID,
+		Name:        tag.Name,
+		Slug:        tag.Slug,
+		Category:    tag.Category,
+		ParentID:    tag.ParentID,
+		Status:      tag.Status,
+		Synonyms:    synonymNames(tag),
+		RecipeCount: counts[tag.ID],
+	}
+}
+
+func sortTagResponses(tags []models.TagResponse) {
+	sort.Slice(tags, func(i, j int) bool { return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name) })
+}
+
+func isTagCategory(category string) bool {
+	for _, c := range models.TagCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// tagCategory returns the category tags of an unknown category are grouped in.
+func tagCategory(category string) string {
+	if isTagCategory(category) {
+		return category
+	}
+	return models.TagCategoryOther
+}
+
+func derefID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
 }
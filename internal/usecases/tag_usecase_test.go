@@ -0,0 +1,171 @@
+package usecases_test
+
+import (
+	"api-culinary-review/internal/models"
+	"api-culinary-review/internal/repositories/memory"
+	"api-culinary-review/internal/usecases"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tagTree formats groups as "category: name(child, child(grandchild))" lines, one per category.
+func tagTree(groups []models.TagGroup) string {
+	var format func(tags []models.TagResponse) string
+	format = func(tags []models.TagResponse) string {
+		names := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			name := tag.Name
+			if len(tag.Children) > 0 {
+				name += "(" + format(tag.Children) + ")"
+			}
+			names = append(names, name)
+		}
+		return strings.Join(names, ", ")
+	}
+
+	lines := make([]string, 0, len(groups))
+	for _, group := range groups {
+		lines = append(lines, group.Category+": "+format(group.Tags))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTagTree(t *testing.T) {
+	ctx := context.Background()
+	uc := usecases.NewtagUsecase(memory.NewSet().Tags, clock.System{}, usecases.UnknownTagsCreate)
+
+	create := func(name, category string, parent *models.TagResponse) *models.TagResponse {
+		t.Helper()
+		req := &models.TagRequest{Name: name, Category: category}
+		if parent != nil {
+			req.ParentID = &parent.ID
+		}
+		tag, err := uc.CreateTag(ctx, req)
+		if err != nil {
+			t.Fatalf("create tag %s: %v", name, err)
+		}
+		return tag
+	}
+	asian := create("Asian", models.TagCategoryCuisine, nil)
+	indonesian := create("Indonesian", models.TagCategoryCuisine, asian)
+	javanese := create("Javanese", models.TagCategoryCuisine, indonesian)
+	create("Balinese", models.TagCategoryCuisine, indonesian)
+	create("Japanese", models.TagCategoryCuisine, asian)
+	create("Vegan", models.TagCategoryDiet, nil)
+	create("Italian", models.TagCategoryCuisine, nil)
+
+	tree := func() string {
+		t.Helper()
+		groups, err := uc.GetAllTags(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tagTree(groups)
+	}
+	want := "diet: Vegan\ncuisine: Asian(Indonesian(Balinese, Javanese), Japanese), Italian"
+	if got := tree(); got != want {
+		t.Fatalf("tree =\n%s\nwant\n%s", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		id       uint
+		req      models.TagRequest
+		wantKind apperror.Kind
+		wantTree string
+	}{
+		{"tag as its own parent", asian.ID,
+			models.TagRequest{Name: "Asian", Category: models.TagCategoryCuisine, ParentID: &asian.ID},
+			apperror.KindValidation, want},
+		{"tag below its child", asian.ID,
+			models.TagRequest{Name: "Asian", Category: models.TagCategoryCuisine, ParentID: &indonesian.ID},
+			apperror.KindValidation, want},
+		{"tag below its grandchild", asian.ID,
+			models.TagRequest{Name: "Asian", Category: models.TagCategoryCuisine, ParentID: &javanese.ID},
+			apperror.KindValidation, want},
+		{"parent in another category", javanese.ID,
+			models.TagRequest{Name: "Javanese", Category: models.TagCategoryDiet, ParentID: &indonesian.ID},
+			apperror.KindValidation, want},
+		{"category of a tag with sub-tags", indonesian.ID,
+			models.TagRequest{Name: "Indonesian", Category: models.TagCategoryDiet},
+			apperror.KindValidation, want},
+		{"name of another tag ignoring case", javanese.ID,
+			models.TagRequest{Name: "balinese", Category: models.TagCategoryCuisine, ParentID: &indonesian.ID},
+			apperror.KindConflict, want},
+		{"move a subtree to the top level", indonesian.ID,
+			models.TagRequest{Name: "Indonesian", Category: models.TagCategoryCuisine},
+			"", "diet: Vegan\ncuisine: Asian(Japanese), Indonesian(Balinese, Javanese), Italian"},
+		{"move a tag below a former descendant of the parent", asian.ID,
+			models.TagRequest{Name: "Asian", Category: models.TagCategoryCuisine, ParentID: &javanese.ID},
+			"", "diet: Vegan\ncuisine: Indonesian(Balinese, Javanese(Asian(Japanese))), Italian"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := uc.UpdateTag(ctx, tt.id, &tt.req, 1)
+			if tt.wantKind == "" && err != nil {
+				t.Fatalf("UpdateTag() error = %v", err)
+			}
+			if tt.wantKind != "" && !isKind(err, tt.wantKind) {
+				t.Fatalf("UpdateTag() error = %v, want a %s error", err, tt.wantKind)
+			}
+			if got := tree(); got != tt.wantTree {
+				t.Errorf("tree =\n%s\nwant\n%s", got, tt.wantTree)
+			}
+		})
+	}
+}
+
+func TestTagModerationActions(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewSet()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	uc := usecases.NewtagUsecase(repos.Tags, clock.NewFake(at), usecases.UnknownTagsCreate)
+	const moderatorID = 7
+
+	pending, err := uc.ResolveTags(ctx, []string{"sambal", "pedas", "rendang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sambal, pedas, rendang := pending[0], pending[1], pending[2]
+	if _, err := uc.ApproveTag(ctx, sambal.ID, moderatorID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.MergeTags(ctx, sambal.ID, []uint{pedas.ID}, moderatorID); err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.UpdateTag(ctx, sambal.ID, &models.TagRequest{Name: "Sambal", Category: models.TagCategoryIngredient}, moderatorID); err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.DeleteTag(ctx, rendang.ID, 0, moderatorID); err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.DeleteTag(ctx, 999, 0, moderatorID); !isKind(err, apperror.KindNotFound) {
+		t.Fatalf("DeleteTag() of a missing tag error = %v, want a not found error", err)
+	}
+
+	actions, _, err := repos.Reports.FindActions(ctx, models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, action := range actions {
+		if action.ModeratorID != moderatorID || action.TargetType != models.ModerationTargetTag || !action.CreatedAt.Equal(at) {
+			t.Errorf("action = %+v, want a decision of moderator %d on a tag at %v", action, moderatorID, at)
+		}
+		got = append(got, fmt.Sprintf("%s %d: %s", action.Action, action.TargetID, action.Reason))
+	}
+	want := []string{
+		fmt.Sprintf(`delete %d: deleted tag "rendang"`, rendang.ID),
+		fmt.Sprintf(`update %d: updated tag "sambal", renamed to "Sambal"`, sambal.ID),
+		fmt.Sprintf(`merge %d: merged tag "pedas" into tag "sambal" (ID %d)`, pedas.ID, sambal.ID),
+		fmt.Sprintf(`approve %d: approved tag "sambal"`, sambal.ID),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("audit trail =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
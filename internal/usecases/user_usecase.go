@@ -3,32 +3,76 @@ package usecases
 import (
 	"api-culinary-review/internal/models"
 	"api-culinary-review/internal/repositories"
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/storage"
+	"api-culinary-review/pkg/tracing"
 	"api-culinary-review/pkg/utils"
+	"context"
+	"errors"
+	"log/slog"
 	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ErrEmailTaken               = apperror.Conflict("Email already registered")
+	ErrDeletionAlreadyScheduled = apperror.Conflict("account deletion is already scheduled")
+	ErrDeletionNotScheduled     = apperror.NotFound("no account deletion is scheduled")
+	ErrInvalidTransferTarget    = apperror.Validation("recipes can only be transferred to another active account",
+		apperror.FieldError{Field: "transfer_to_user_id", Message: "must be the ID of another active account"})
+	ErrIncorrectPassword = apperror.Validation("old password is incorrect",
+		apperror.FieldError{Field: "old_password", Message: "is incorrect"})
 )
 
 type UserUsecase interface {
-	CreateUser(username, password, email string) (*models.User, error)
-	GetUserByID(id uint) (*models.User, error)
-	GetUserByEmailOrUsername(emailOrUsername string) (*models.User, error)
-	CheckUserEmail(email string) (*models.User, error)
-	UpdateUser(user *models.User) error
-	DeleteUser(id uint) error
+	CreateUser(ctx context.Context, username, password, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	GetRole(ctx context.Context, userID uint) (string, error)
+	// IsActive reports whether the user exists and has not been anonymized, for authentication.
+	IsActive(ctx context.Context, userID uint) (bool, error)
+	GetUserByEmailOrUsername(ctx context.Context, emailOrUsername string) (*models.User, error)
+	CheckUserEmail(ctx context.Context, email string) (*models.User, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	ChangePassword(ctx context.Context, userID uint, oldPassword, newPassword string) error
+	ScheduleDeletion(ctx context.Context, userID uint, req *models.DeleteAccountRequest) (*models.AccountDeletion, error)
+	CancelDeletion(ctx context.Context, userID uint) error
+	PurgeDueAccounts(ctx context.Context) (int, error)
 }
 
 type userUsecase struct {
 	UserRepository    repositories.UserRepository
 	ProfileRepository repositories.ProfileRepository
+	storage           storage.Storage
+	clock             clock.Clock
+	deletionGrace     time.Duration
+	logger            *slog.Logger
 }
 
-func NewUserUsecase(userRepo repositories.UserRepository, profileRepo repositories.ProfileRepository) UserUsecase {
+func NewUserUsecase(userRepo repositories.UserRepository, profileRepo repositories.ProfileRepository, storage storage.Storage, clock clock.Clock, deletionGrace time.Duration, logger *slog.Logger) UserUsecase {
 	return &userUsecase{
 		UserRepository:    userRepo,
 		ProfileRepository: profileRepo,
+		storage:           storage,
+		clock:             clock,
+		deletionGrace:     deletionGrace,
+		logger:            logger,
 	}
 }
 
-func (uc *userUsecase) CreateUser(username, password, email string) (*models.User, error) {
+func (uc *userUsecase) CreateUser(ctx context.Context, username, password, email string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.CreateUser")
+	defer span.End()
+
+	existingUser, err := uc.UserRepository.CheckUserEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if existingUser != nil {
+		return nil, ErrEmailTaken
+	}
+
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
 		return nil, err
@@ -38,10 +82,10 @@ func (uc *userUsecase) CreateUser(username, password, email string) (*models.Use
 		Username:  username,
 		Password:  hashedPassword,
 		Email:     email,
-		CreatedAt: time.Now(),
+		CreatedAt: uc.clock.Now(),
 	}
 
-	err = uc.UserRepository.Create(user)
+	err = uc.UserRepository.Create(ctx, user)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +95,7 @@ func (uc *userUsecase) CreateUser(username, password, email string) (*models.Use
 		FullName: username,
 	}
 
-	err = uc.ProfileRepository.CreateProfile(&profile)
+	err = uc.ProfileRepository.CreateProfile(ctx, &profile)
 	if err != nil {
 		return nil, err
 	}
@@ -59,22 +103,194 @@ func (uc *userUsecase) CreateUser(username, password, email string) (*models.Use
 	return user, nil
 }
 
-func (uc *userUsecase) GetUserByID(id uint) (*models.User, error) {
-	return uc.UserRepository.FindByID(id)
+func (uc *userUsecase) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.GetUserByID")
+	defer span.End()
+
+	user, err := uc.UserRepository.FindByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, "user")
+	}
+	return user, nil
 }
 
-func (uc *userUsecase) UpdateUser(user *models.User) error {
-	return uc.UserRepository.Update(user)
+// GetRole returns the role of the user, for authorization.
+func (uc *userUsecase) GetRole(ctx context.Context, userID uint) (string, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.GetRole")
+	defer span.End()
+
+	user, err := uc.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Role, nil
 }
 
-func (uc *userUsecase) DeleteUser(id uint) error {
-	return uc.UserRepository.Delete(id)
+func (uc *userUsecase) IsActive(ctx context.Context, userID uint) (bool, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.IsActive")
+	defer span.End()
+
+	user, err := uc.UserRepository.FindByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !user.IsAnonymized(), nil
 }
 
-func (uc *userUsecase) GetUserByEmailOrUsername(emailOrUsername string) (*models.User, error) {
-	return uc.UserRepository.GetUserByEmailOrUsername(emailOrUsername)
+func (uc *userUsecase) UpdateUser(ctx context.Context, user *models.User) error {
+	ctx, span := tracing.Start(ctx, "UserUsecase.UpdateUser")
+	defer span.End()
+
+	return uc.UserRepository.Update(ctx, user)
+}
+
+func (uc *userUsecase) ChangePassword(ctx context.Context, userID uint, oldPassword, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "UserUsecase.ChangePassword")
+	defer span.End()
+
+	user, err := uc.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !utils.CheckPasswordHash(oldPassword, user.Password) {
+		return ErrIncorrectPassword
+	}
+
+	user.Password, err = utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return uc.UserRepository.Update(ctx, user)
 }
 
-func (uc *userUsecase) CheckUserEmail(email string) (*models.User, error) {
-	return uc.UserRepository.CheckUserEmail(email)
+// ScheduleDeletion records a request to delete the account once the grace period has passed.
+// Recipes are deleted with the account unless the request asks for them to be transferred.
+func (uc *userUsecase) ScheduleDeletion(ctx context.Context, userID uint, req *models.DeleteAccountRequest) (*models.AccountDeletion, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.ScheduleDeletion")
+	defer span.End()
+
+	existing, err := uc.UserRepository.FindDeletionRequest(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrDeletionAlreadyScheduled
+	}
+
+	deletion := &models.AccountDeletion{
+		UserID:       userID,
+		ScheduledFor: uc.clock.Now().Add(uc.deletionGrace),
+		RecipeAction: models.RecipeActionDelete,
+	}
+
+	if req.RecipeAction == models.RecipeActionTransfer {
+		if req.TransferToUserID == 0 || req.TransferToUserID == userID {
+			return nil, ErrInvalidTransferTarget
+		}
+		target, err := uc.UserRepository.FindByID(ctx, req.TransferToUserID)
+		if err != nil || target.IsAnonymized() {
+			return nil, ErrInvalidTransferTarget
+		}
+		deletion.RecipeAction = models.RecipeActionTransfer
+		deletion.TransferToUserID = &target.ID
+	}
+
+	if err := uc.UserRepository.SaveDeletionRequest(ctx, deletion); err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+func (uc *userUsecase) CancelDeletion(ctx context.Context, userID uint) error {
+	ctx, span := tracing.Start(ctx, "UserUsecase.CancelDeletion")
+	defer span.End()
+
+	existing, err := uc.UserRepository.FindDeletionRequest(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrDeletionNotScheduled
+	}
+
+	return uc.UserRepository.DeleteDeletionRequest(ctx, userID)
+}
+
+// PurgeDueAccounts anonymizes every account whose grace period is over and returns how many
+// were processed. Failing to remove an image from storage does not undo the anonymization.
+func (uc *userUsecase) PurgeDueAccounts(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.PurgeDueAccounts")
+	defer span.End()
+
+	deletions, err := uc.UserRepository.FindDueDeletionRequests(ctx, uc.clock.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for i := range deletions {
+		deletion := &deletions[i]
+
+		if deletion.RecipeAction == models.RecipeActionTransfer {
+			target, err := uc.UserRepository.FindByID(ctx, *deletion.TransferToUserID)
+			if err != nil || target.IsAnonymized() {
+				// The recipient is gone, so the recipes go with the account.
+				deletion.RecipeAction = models.RecipeActionDelete
+			}
+		}
+
+		imageURLs, err := uc.UserRepository.Anonymize(ctx, deletion)
+		if err != nil {
+			return purged, err
+		}
+		purged++
+
+		for _, url := range imageURLs {
+			if err := uc.storage.Delete(ctx, url); err != nil {
+				uc.logger.WarnContext(ctx, "failed to delete image of deleted account",
+					slog.String("url", url), slog.Uint64("user_id", uint64(deletion.UserID)), slog.Any("error", err))
+			}
+		}
+	}
+
+	return purged, nil
+}
+
+// RunAccountPurger calls PurgeDueAccounts every interval until ctx is done.
+func RunAccountPurger(ctx context.Context, uc UserUsecase, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := uc.PurgeDueAccounts(ctx); err != nil {
+				logger.ErrorContext(ctx, "failed to purge deleted accounts", slog.Any("error", err))
+			} else if n > 0 {
+				logger.InfoContext(ctx, "anonymized deleted accounts", slog.Int("count", n))
+			}
+		}
+	}
+}
+
+func (uc *userUsecase) GetUserByEmailOrUsername(ctx context.Context, emailOrUsername string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.GetUserByEmailOrUsername")
+	defer span.End()
+
+	return uc.UserRepository.GetUserByEmailOrUsername(ctx, emailOrUsername)
+}
+
+func (uc *userUsecase) CheckUserEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.CheckUserEmail")
+	defer span.End()
+
+	return uc.UserRepository.CheckUserEmail(ctx, email)
 }
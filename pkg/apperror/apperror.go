@@ -0,0 +1,109 @@
+// Package apperror defines the typed errors returned by the usecases and their rendering as
+// RFC 7807 problem details.
+package apperror
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Kind classifies an error and decides its HTTP status.
+type Kind string
+
+const (
+	KindValidation      Kind = "validation"
+	KindUnauthorized    Kind = "unauthorized"
+	KindForbidden       Kind = "forbidden"
+	KindNotFound        Kind = "not_found"
+	KindConflict        Kind = "conflict"
+	KindTooManyRequests Kind = "too_many_requests"
+	KindInternal        Kind = "internal"
+)
+
+var kindStatus = map[Kind]int{
+	KindValidation:      http.StatusBadRequest,
+	KindUnauthorized:    http.StatusUnauthorized,
+	KindForbidden:       http.StatusForbidden,
+	KindNotFound:        http.StatusNotFound,
+	KindConflict:        http.StatusConflict,
+	KindTooManyRequests: http.StatusTooManyRequests,
+	KindInternal:        http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status code of the kind.
+func (k Kind) Status() int {
+	if status, ok := kindStatus[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error with a kind and a message that is safe to show to clients.
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	// RetryAfter tells clients when to try again, for KindTooManyRequests.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func Validation(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+func Unauthorized(message string) *Error {
+	return New(KindUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(KindForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, message)
+}
+
+func TooManyRequests(message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindTooManyRequests, Message: message, RetryAfter: retryAfter}
+}
+
+// Internal wraps an unexpected error. Its cause is logged but never shown to clients.
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Message: "an unexpected error occurred", Err: err}
+}
+
+// As returns the *Error in err's chain, treating any other error as internal.
+func As(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err)
+}
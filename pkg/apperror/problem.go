@@ -0,0 +1,33 @@
+package apperror
+
+import "net/http"
+
+// ProblemContentType is the media type of problem details responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response body.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem builds the problem details of err for the request path.
+func NewProblem(err error, instance, requestID string) Problem {
+	appErr := As(err)
+	status := appErr.Kind.Status()
+
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    appErr.Message,
+		Instance:  instance,
+		RequestID: requestID,
+		Errors:    appErr.Fields,
+	}
+}
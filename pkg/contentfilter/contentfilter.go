@@ -0,0 +1,92 @@
+// Package contentfilter flags text that contains blocked words or matches blocked patterns.
+package contentfilter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// patternPrefix marks the lines of a rule file that are regular expressions.
+const patternPrefix = "re:"
+
+// Filter is a list of rules. The zero Filter and a nil *Filter match nothing.
+type Filter struct {
+	rules []rule
+}
+
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// New returns a filter of words, matched as whole words ignoring case, and of patterns, regular
+// expressions matched anywhere in the text.
+func New(words, patterns []string) (*Filter, error) {
+	f := &Filter{}
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word == "" {
+			continue
+		}
+		f.rules = append(f.rules, rule{
+			name: word,
+			re:   regexp.MustCompile(`(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(word) + `($|[^\pL\pN])`),
+		})
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("contentfilter: pattern %q: %w", pattern, err)
+		}
+		f.rules = append(f.rules, rule{name: patternPrefix + pattern, re: re})
+	}
+	return f, nil
+}
+
+// Load returns a filter of words and of the rules in the file at path, if any. The file has one
+// rule per line: a word, or a regular expression after "re:". Blank lines and lines starting
+// with "#" are skipped.
+func Load(path string, words []string) (*Filter, error) {
+	var patterns []string
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("contentfilter: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			switch {
+			case line == "" || strings.HasPrefix(line, "#"):
+			case strings.HasPrefix(line, patternPrefix):
+				patterns = append(patterns, strings.TrimPrefix(line, patternPrefix))
+			default:
+				words = append(words, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("contentfilter: %s: %w", path, err)
+		}
+	}
+	return New(words, patterns)
+}
+
+// Match returns the first rule that any of texts matches: the word, or the pattern after "re:".
+func (f *Filter) Match(texts ...string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	for _, r := range f.rules {
+		for _, text := range texts {
+			if r.re.MatchString(text) {
+				return r.name, true
+			}
+		}
+	}
+	return "", false
+}
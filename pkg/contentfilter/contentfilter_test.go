@@ -0,0 +1,116 @@
+package contentfilter_test
+
+import (
+	"api-culinary-review/pkg/contentfilter"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	filter, err := contentfilter.New([]string{"spam", " ", "jual murah", "c++", "Bodoh"}, []string{`(?i)https?://\S+`, `\d{10,}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		texts    []string
+		wantRule string
+	}{
+		{"no text", nil, ""},
+		{"clean text", []string{"Enak sekali, bumbunya pas"}, ""},
+		{"whole word", []string{"this is spam"}, "spam"},
+		{"word at the start and end", []string{"spam"}, "spam"},
+		{"word between punctuation", []string{"(spam)!"}, "spam"},
+		{"word in another case", []string{"SPAM here"}, "spam"},
+		{"rule in another case", []string{"dasar bodoh"}, "Bodoh"},
+		{"word inside another word", []string{"spammer and antispam"}, ""},
+		{"word next to a digit", []string{"spam2"}, ""},
+		{"word next to a non-ASCII letter", []string{"spamé"}, ""},
+		{"phrase", []string{"Jual Murah, hubungi saya"}, "jual murah"},
+		{"phrase with other spacing", []string{"jual  murah"}, ""},
+		{"word with regular expression characters", []string{"I code in c++."}, "c++"},
+		{"regular expression characters are literal", []string{"I code in cxx"}, ""},
+		{"pattern", []string{"see HTTPS://example.com"}, `re:(?i)https?://\S+`},
+		{"pattern inside a word", []string{"wa0812345678901"}, `re:\d{10,}`},
+		{"pattern too short", []string{"call 08123"}, ""},
+		{"second text", []string{"a nice title", "buy spam"}, "spam"},
+		{"first rule wins", []string{"spam at http://example.com"}, "spam"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := filter.Match(tt.texts...)
+			if got != tt.wantRule || ok != (tt.wantRule != "") {
+				t.Errorf("Match(%q) = %q, %v, want %q", tt.texts, got, ok, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestMatchEmptyFilter(t *testing.T) {
+	var zero contentfilter.Filter
+	var none *contentfilter.Filter
+	for _, filter := range []*contentfilter.Filter{&zero, none} {
+		if got, ok := filter.Match("spam"); ok {
+			t.Errorf("Match() of an empty filter = %q, want no match", got)
+		}
+	}
+}
+
+func TestNewInvalidPattern(t *testing.T) {
+	if _, err := contentfilter.New(nil, []string{"(unclosed"}); err == nil || !strings.Contains(err.Error(), `"(unclosed"`) {
+		t.Errorf("New() error = %v, want the invalid pattern", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	rules := `# blocked words
+  scam
+
+re:(?i)free\s+money
+#re:ignored
+`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := contentfilter.Load(path, []string{"spam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text     string
+		wantRule string
+	}{
+		{"spam", "spam"},
+		{"a Scam!", "scam"},
+		{"FREE  money", `re:(?i)free\s+money`},
+		{"ignored", ""},
+		{"# blocked words", ""},
+	}
+	for _, tt := range tests {
+		if got, ok := filter.Match(tt.text); got != tt.wantRule || ok != (tt.wantRule != "") {
+			t.Errorf("Match(%q) = %q, %v, want %q", tt.text, got, ok, tt.wantRule)
+		}
+	}
+
+	if filter, err := contentfilter.Load("", []string{"spam"}); err != nil {
+		t.Errorf("Load without a file error = %v", err)
+	} else if _, ok := filter.Match("spam"); !ok {
+		t.Error("Load without a file dropped the words")
+	}
+
+	if _, err := contentfilter.Load(filepath.Join(t.TempDir(), "missing.txt"), nil); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+
+	if err := os.WriteFile(path, []byte("re:[z-a]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := contentfilter.Load(path, nil); err == nil || !strings.Contains(err.Error(), "[z-a]") {
+		t.Errorf("Load of an invalid pattern error = %v, want the pattern", err)
+	}
+}
@@ -3,38 +3,111 @@ package database
 import (
 	"api-culinary-review/config"
 	"api-culinary-review/internal/models"
+	"api-culinary-review/pkg/utils"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 )
 
-func ConnectDB(cfg config.Config) *gorm.DB {
+// ConnectDB opens the PostgreSQL database described by cfg, instruments it and migrates its schema.
+func ConnectDB(cfg config.Config, logger *slog.Logger) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%v sslmode=disable TimeZone=Asia/Jakarta", cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
 
 	db, err := gorm.Open("postgres", dsn)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return nil, fmt.Errorf("connect to database: %w", err)
 	}
 
-	db.LogMode(true)
+	Instrument(db, logger, cfg.SlowQueryThreshold)
 
-	// Auto Migrate models
-	err = db.AutoMigrate(
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	logger.Info("connected to database", slog.String("host", cfg.DBHost), slog.String("database", cfg.DBName))
+	return db, nil
+}
+
+// Instrument logs and traces the queries run on db. Queries slower than slowQueryThreshold
+// are logged as warnings.
+func Instrument(db *gorm.DB, logger *slog.Logger, slowQueryThreshold time.Duration) {
+	// Queries are logged by registerQueryLogger instead of gorm
+	db.LogMode(false)
+	registerQueryLogger(db, logger, slowQueryThreshold)
+	registerQueryTracing(db)
+}
+
+// Migrate creates or updates the tables of the models.
+func Migrate(db *gorm.DB) error {
+	if err := dedupeFavorites(db); err != nil {
+		return fmt.Errorf("migrate database: %w", err)
+	}
+
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.Profile{},
 		&models.Recipe{},
+		&models.RecipeIngredient{},
 		&models.Review{},
+		&models.ReviewVote{},
+		&models.ReviewPhoto{},
+		&models.Report{},
+		&models.ModerationAction{},
 		&models.Image{},
 		&models.Tag{},
+		&models.TagSynonym{},
 		&models.Favorite{},
+		&models.AccountDeletion{},
+		&models.UserToken{},
+		&models.UserIdentity{},
+		&models.LoginAttempt{},
+		&models.RecoveryCode{},
 	).Error
 
+	if err == nil {
+		err = migrateTagSlugs(db)
+	}
+
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return fmt.Errorf("migrate database: %w", err)
+	}
+	return nil
+}
+
+// dedupeFavorites deletes the favorites saved twice before favorites were unique per user and
+// recipe, so the unique index can be created.
+func dedupeFavorites(db *gorm.DB) error {
+	if !db.HasTable(&models.Favorite{}) {
+		return nil
 	}
+	return db.Exec("DELETE FROM favorites WHERE id NOT IN (SELECT MIN(id) FROM favorites GROUP BY user_id, recipe_id)").Error
+}
 
-	log.Println("Connected to database")
-	return db
+// migrateTagSlugs gives the tags created before slugs existed a slug, then makes slugs unique.
+func migrateTagSlugs(db *gorm.DB) error {
+	var tags []models.Tag
+	if err := db.Where("slug = '' OR slug IS NULL").Find(&tags).Error; err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		slug := utils.Slugify(tag.Name)
+		if slug == "" {
+			slug = "tag"
+		}
+		var taken int
+		if err := db.Model(&models.Tag{}).Where("slug = ?", slug).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 || slug == "tag" {
+			slug = fmt.Sprintf("%s-%d", slug, tag.ID)
+		}
+		if err := db.Model(&tag).UpdateColumn("slug", slug).Error; err != nil {
+			return err
+		}
+	}
+	return db.Model(&models.Tag{}).AddUniqueIndex("idx_tags_slug", "slug").Error
 }
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	contextKey   = "app:context"
+	startedAtKey = "app:started_at"
+)
+
+// WithContext returns db bound to ctx, so the queries it runs are logged with the request of ctx.
+func WithContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return db.Set(contextKey, ctx)
+}
+
+func contextOf(scope *gorm.Scope) context.Context {
+	if value, ok := scope.Get(contextKey); ok {
+		if ctx, ok := value.(context.Context); ok {
+			return ctx
+		}
+	}
+	return context.Background()
+}
+
+// registerQueryLogger logs failed queries and queries slower than slowThreshold. Every query is
+// logged at debug level.
+func registerQueryLogger(db *gorm.DB, logger *slog.Logger, slowThreshold time.Duration) {
+	db.SetLogger(gormLogger{logger})
+
+	start := func(scope *gorm.Scope) {
+		scope.InstanceSet(startedAtKey, time.Now())
+	}
+
+	finish := func(scope *gorm.Scope) {
+		value, ok := scope.InstanceGet(startedAtKey)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(value.(time.Time))
+
+		ctx := contextOf(scope)
+		attrs := []any{
+			slog.String("table", scope.TableName()),
+			slog.String("sql", scope.SQL),
+			slog.Duration("duration", elapsed),
+			slog.Int64("rows", scope.DB().RowsAffected),
+		}
+
+		switch err := scope.DB().Error; {
+		case err != nil && !gorm.IsRecordNotFoundError(err):
+			logger.WarnContext(ctx, "query failed", append(attrs, slog.Any("error", err))...)
+		case slowThreshold > 0 && elapsed >= slowThreshold:
+			logger.WarnContext(ctx, "slow query", attrs...)
+		default:
+			logger.DebugContext(ctx, "query", attrs...)
+		}
+	}
+
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:create").Register("app:query_start", start)
+	callbacks.Create().After("gorm:create").Register("app:query_finish", finish)
+	callbacks.Query().Before("gorm:query").Register("app:query_start", start)
+	callbacks.Query().After("gorm:query").Register("app:query_finish", finish)
+	callbacks.Update().Before("gorm:update").Register("app:query_start", start)
+	callbacks.Update().After("gorm:update").Register("app:query_finish", finish)
+	callbacks.Delete().Before("gorm:delete").Register("app:query_start", start)
+	callbacks.Delete().After("gorm:delete").Register("app:query_finish", finish)
+	callbacks.RowQuery().Before("gorm:row_query").Register("app:query_start", start)
+	callbacks.RowQuery().After("gorm:row_query").Register("app:query_finish", finish)
+}
+
+// gormLogger sends the messages gorm logs by itself, such as callback registrations, to logger.
+type gormLogger struct {
+	logger *slog.Logger
+}
+
+func (l gormLogger) Print(values ...interface{}) {
+	l.logger.Debug(fmt.Sprint(values...), slog.String("component", "gorm"))
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"api-culinary-review/pkg/tracing"
+
+	"github.com/jinzhu/gorm"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const spanKey = "app:span"
+
+// registerQueryTracing records every query as a client span of the request bound to the
+// query with WithContext.
+func registerQueryTracing(db *gorm.DB) {
+	start := func(operation string) func(scope *gorm.Scope) {
+		return func(scope *gorm.Scope) {
+			table := scope.TableName()
+			_, span := tracing.Start(contextOf(scope), operation+" "+table,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemPostgreSQL,
+					semconv.DBOperationName(operation),
+					semconv.DBCollectionName(table),
+				))
+			scope.InstanceSet(spanKey, span)
+		}
+	}
+
+	finish := func(scope *gorm.Scope) {
+		value, ok := scope.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		span.SetAttributes(semconv.DBQueryTextKey.String(scope.SQL))
+
+		err := scope.DB().Error
+		if gorm.IsRecordNotFoundError(err) {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:create").Register("app:trace_start", start("INSERT"))
+	callbacks.Create().After("gorm:create").Register("app:trace_finish", finish)
+	callbacks.Query().Before("gorm:query").Register("app:trace_start", start("SELECT"))
+	callbacks.Query().After("gorm:query").Register("app:trace_finish", finish)
+	callbacks.Update().Before("gorm:update").Register("app:trace_start", start("UPDATE"))
+	callbacks.Update().After("gorm:update").Register("app:trace_finish", finish)
+	callbacks.Delete().Before("gorm:delete").Register("app:trace_start", start("DELETE"))
+	callbacks.Delete().After("gorm:delete").Register("app:trace_finish", finish)
+	callbacks.RowQuery().Before("gorm:row_query").Register("app:trace_start", start("SELECT"))
+	callbacks.RowQuery().After("gorm:row_query").Register("app:trace_finish", finish)
+}
@@ -1,10 +0,0 @@
-package helper
-
-import "os"
-
-func Getenv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	}
-	return fallback
-}
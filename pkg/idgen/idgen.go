@@ -0,0 +1,40 @@
+// Package idgen generates the random identifiers and secrets of the application.
+package idgen
+
+import (
+	"api-culinary-review/pkg/utils"
+	"fmt"
+	"sync/atomic"
+)
+
+// Generator creates unique identifiers and secrets.
+type Generator interface {
+	// NewID returns a short random identifier, such as a request ID.
+	NewID() (string, error)
+	// NewToken returns a random URL-safe secret suitable for links sent by email.
+	NewToken() (string, error)
+}
+
+// Random generates identifiers and secrets from crypto/rand.
+type Random struct{}
+
+func (Random) NewID() (string, error) {
+	return utils.GenerateUid()
+}
+
+func (Random) NewToken() (string, error) {
+	return utils.GenerateToken()
+}
+
+// Sequence generates predictable identifiers and secrets, "id-1", "token-2" and so on, for tests.
+type Sequence struct {
+	n atomic.Uint64
+}
+
+func (s *Sequence) NewID() (string, error) {
+	return fmt.Sprintf("id-%d", s.n.Add(1)), nil
+}
+
+func (s *Sequence) NewToken() (string, error) {
+	return fmt.Sprintf("token-%d", s.n.Add(1)), nil
+}
@@ -1,36 +1,90 @@
 package jwt
 
 import (
-	"api-culinary-review/config"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var secretKey = []byte(config.LoadConfig().JWTSecret)
+// PurposeTwoFactor marks a short-lived token that only proves the password was checked and
+// must be exchanged for an access token with a TOTP or recovery code.
+const PurposeTwoFactor = "2fa"
 
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID  uint   `json:"user_id"`
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint) (string, error) {
+// Manager signs and parses the tokens of the API with a shared secret.
+type Manager struct {
+	secretKey      []byte
+	accessTokenTTL time.Duration
+}
+
+// NewManager creates a Manager signing with secret and issuing access tokens valid for accessTokenTTL.
+func NewManager(secret string, accessTokenTTL time.Duration) *Manager {
+	return &Manager{
+		secretKey:      []byte(secret),
+		accessTokenTTL: accessTokenTTL,
+	}
+}
+
+func (m *Manager) GenerateToken(userID uint) (string, error) {
 	claims := &Claims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 12)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.accessTokenTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(m.secretKey)
+}
+
+// GenerateChallengeToken returns a token for the second step of a two-factor login.
+func (m *Manager) GenerateChallengeToken(userID uint, ttl time.Duration) (string, error) {
+	claims := &Claims{
+		UserID:  userID,
+		Purpose: PurposeTwoFactor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
+	return token.SignedString(m.secretKey)
+}
+
+// ParseToken parses an access token. Tokens issued for another purpose are rejected.
+func (m *Manager) ParseToken(tokenString string) (*Claims, error) {
+	claims, err := m.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// ParseChallengeToken parses a token issued by GenerateChallengeToken.
+func (m *Manager) ParseChallengeToken(tokenString string) (*Claims, error) {
+	claims, err := m.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeTwoFactor {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
 }
 
-func ParseToken(tokenString string) (*Claims, error) {
+func (m *Manager) parse(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
+		return m.secretKey, nil
 	})
 
 	if err != nil {
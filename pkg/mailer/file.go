@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+type fileMailer struct {
+	dir    string
+	logger *slog.Logger
+}
+
+// NewFileMailer returns a Mailer for development and tests that writes every message as an
+// HTML file into dir and logs where it was written instead of sending it.
+func NewFileMailer(dir string, logger *slog.Logger) Mailer {
+	return &fileMailer{dir: dir, logger: logger}
+}
+
+func (m *fileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d_%s.html", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	path := filepath.Join(m.dir, name)
+
+	content := fmt.Sprintf("<!-- To: %s -->\n<!-- Subject: %s -->\n%s", msg.To, msg.Subject, msg.HTML)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	m.logger.Info("email written to file", slog.String("subject", msg.Subject), slog.String("to", msg.To), slog.String("path", path))
+	return nil
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// Message is a single HTML email.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+}
+
+// Mailer delivers email messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Render executes the named template from the templates directory with data.
+func Render(name string, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a Mailer that delivers through an SMTP server. Authentication is
+// skipped when username is empty.
+func NewSMTPMailer(host, port, username, password, from string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &smtpMailer{
+		addr: net.JoinHostPort(host, port),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", m.from)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	body.WriteString(msg.HTML)
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(body.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
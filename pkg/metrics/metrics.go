@@ -0,0 +1,116 @@
+// Package metrics defines the Prometheus metrics of the application and serves them.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "culinary"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	uploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "image_upload_duration_seconds",
+		Help:      "Duration of image uploads to the storage provider.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"provider"})
+
+	uploadFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_upload_failures_total",
+		Help:      "Image uploads that failed, by storage provider.",
+	}, []string{"provider"})
+
+	recipesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "recipes_created_total",
+		Help:      "Recipes created.",
+	})
+
+	reviewsPosted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reviews_posted_total",
+		Help:      "Reviews posted.",
+	})
+
+	loginsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_failed_total",
+		Help:      "Failed login attempts by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		uploadDuration,
+		uploadFailures,
+		recipesCreated,
+		reviewsPosted,
+		loginsFailed,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// RegisterDB exposes the connection pool statistics of db.
+func RegisterDB(db *sql.DB, name string) error {
+	return registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveHTTPRequest records a handled request. route is the route template, such as
+// /api/recipes/:id, so that requests for different IDs are counted together.
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveUpload records an image upload to provider and whether it failed.
+func ObserveUpload(provider string, duration time.Duration, err error) {
+	uploadDuration.WithLabelValues(provider).Observe(duration.Seconds())
+	if err != nil {
+		uploadFailures.WithLabelValues(provider).Inc()
+	}
+}
+
+func RecipeCreated() {
+	recipesCreated.Inc()
+}
+
+func ReviewPosted() {
+	reviewsPosted.Inc()
+}
+
+// LoginFailed counts a failed login attempt, reason being its login attempt result.
+func LoginFailed(reason string) {
+	loginsFailed.WithLabelValues(reason).Inc()
+}
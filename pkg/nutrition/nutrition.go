@@ -0,0 +1,281 @@
+// Package nutrition estimates the nutrients of recipes from a local database of foods, by
+// matching ingredient names to foods and converting their quantities to grams.
+package nutrition
+
+import (
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed data/foods.csv
+var dataFS embed.FS
+
+// Facts are amounts of energy in kcal and of macronutrients in grams.
+type Facts struct {
+	Calories float64 `json:"calories"`
+	Protein  float64 `json:"protein"`
+	Carbs    float64 `json:"carbs"`
+	Fat      float64 `json:"fat"`
+}
+
+// Add returns f plus other scaled by factor.
+func (f Facts) Add(other Facts, factor float64) Facts {
+	return Facts{
+		Calories: f.Calories + other.Calories*factor,
+		Protein:  f.Protein + other.Protein*factor,
+		Carbs:    f.Carbs + other.Carbs*factor,
+		Fat:      f.Fat + other.Fat*factor,
+	}
+}
+
+// Food is an entry of the database. Per100g are its nutrients in 100 grams and PieceGrams the
+// weight of one piece, like an egg, or 0 when it is not counted in pieces.
+type Food struct {
+	Name       string   `json:"name"`
+	Aliases    []string `json:"aliases"`
+	Per100g    Facts    `json:"per_100g"`
+	PieceGrams float64  `json:"piece_grams"`
+}
+
+// Ingredient is an amount of an ingredient. Food names the food of the database to use instead
+// of matching Name.
+type Ingredient struct {
+	Name     string
+	Food     string
+	Quantity float64
+	Unit     string
+}
+
+// Estimate is the sum of the nutrients of the ingredients that could be matched to a food and
+// converted to grams.
+type Estimate struct {
+	Total       Facts
+	Matched     int
+	Ingredients int
+}
+
+// Complete reports whether every ingredient counts in the estimate.
+func (e Estimate) Complete() bool {
+	return e.Matched == e.Ingredients
+}
+
+// Database is a set of foods looked up by name or alias, ignoring case.
+type Database struct {
+	foods    []Food
+	byName   map[string]*Food
+	maxWords int
+}
+
+// unitGrams converts quantities in weight and volume units to grams. Volumes are weighed as
+// water.
+var unitGrams = map[string]float64{
+	"g": 1, "gr": 1, "gram": 1, "grams": 1, "kg": 1000, "mg": 0.001, "ons": 100,
+	"ml": 1, "l": 1000, "liter": 1000, "litre": 1000,
+	"sdt": 5, "tsp": 5, "teaspoon": 5, "teaspoons": 5,
+	"sdm": 15, "tbsp": 15, "tablespoon": 15, "tablespoons": 15,
+	"cup": 240, "cups": 240, "gelas": 240,
+}
+
+// pieceUnits count pieces of a food. A quantity without a unit is a number of pieces too.
+var pieceUnits = map[string]bool{
+	"": true, "butir": true, "buah": true, "siung": true, "lembar": true, "batang": true, "biji": true, "ekor": true,
+	"piece": true, "pieces": true, "pcs": true, "pc": true, "slice": true, "slices": true, "clove": true, "cloves": true,
+}
+
+var (
+	defaultOnce sync.Once
+	defaultDB   *Database
+)
+
+// Default returns the database of the bundled dataset.
+func Default() *Database {
+	defaultOnce.Do(func() {
+		f, err := dataFS.Open("data/foods.csv")
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		if defaultDB, err = LoadCSV(f); err != nil {
+			panic(fmt.Sprintf("nutrition: bundled dataset: %v", err))
+		}
+	})
+	return defaultDB
+}
+
+// LoadFile loads a dataset from a .csv or .json file.
+func LoadFile(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return LoadCSV(f)
+	case ".json":
+		return LoadJSON(f)
+	default:
+		return nil, fmt.Errorf("nutrition: unsupported dataset %s, want a .csv or .json file", path)
+	}
+}
+
+// LoadCSV loads a dataset with the columns name, aliases (separated by ";"), calories, protein,
+// carbs and fat per 100 grams, and piece_grams.
+func LoadCSV(r io.Reader) (*Database, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("nutrition: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("nutrition: empty dataset")
+	}
+
+	foods := make([]Food, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		if len(row) != 7 {
+			return nil, fmt.Errorf("nutrition: line %d: want 7 columns, got %d", i+2, len(row))
+		}
+		var values [5]float64
+		for j := range values {
+			if values[j], err = strconv.ParseFloat(strings.TrimSpace(row[j+2]), 64); err != nil {
+				return nil, fmt.Errorf("nutrition: line %d: %s: %w", i+2, rows[0][j+2], err)
+			}
+		}
+		var aliases []string
+		if row[1] != "" {
+			aliases = strings.Split(row[1], ";")
+		}
+		foods = append(foods, Food{
+			Name:       row[0],
+			Aliases:    aliases,
+			Per100g:    Facts{Calories: values[0], Protein: values[1], Carbs: values[2], Fat: values[3]},
+			PieceGrams: values[4],
+		})
+	}
+	return New(foods)
+}
+
+// LoadJSON loads a dataset that is a JSON array of foods.
+func LoadJSON(r io.Reader) (*Database, error) {
+	var foods []Food
+	if err := json.NewDecoder(r).Decode(&foods); err != nil {
+		return nil, fmt.Errorf("nutrition: %w", err)
+	}
+	return New(foods)
+}
+
+// New returns a database of foods. Names and aliases must be unique ignoring case.
+func New(foods []Food) (*Database, error) {
+	db := &Database{foods: foods, byName: make(map[string]*Food)}
+	for i := range foods {
+		food := &foods[i]
+		for _, name := range append([]string{food.Name}, food.Aliases...) {
+			key := normalize(name)
+			if key == "" {
+				return nil, fmt.Errorf("nutrition: food %q has an empty name", food.Name)
+			}
+			if other, ok := db.byName[key]; ok {
+				return nil, fmt.Errorf("nutrition: %q names both %q and %q", name, other.Name, food.Name)
+			}
+			db.byName[key] = food
+			if words := len(strings.Fields(key)); words > db.maxWords {
+				db.maxWords = words
+			}
+		}
+	}
+	return db, nil
+}
+
+// Food returns the food named name or known by it as an alias.
+func (db *Database) Food(name string) (*Food, bool) {
+	food, ok := db.byName[normalize(name)]
+	return food, ok
+}
+
+// Match returns the food of an ingredient name: the food with that name, or else the food whose
+// name appears in it with the most words, like "daging sapi" in "daging sapi cincang".
+func (db *Database) Match(name string) (*Food, bool) {
+	words := strings.Fields(normalize(name))
+	for n := min(db.maxWords, len(words)); n >= 1; n-- {
+		for i := 0; i+n <= len(words); i++ {
+			if food, ok := db.byName[strings.Join(words[i:i+n], " ")]; ok {
+				return food, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Search returns the foods whose name or an alias contains query, sorted by name.
+func (db *Database) Search(query string) []Food {
+	query = normalize(query)
+	var foods []Food
+	for _, food := range db.foods {
+		for _, name := range append([]string{food.Name}, food.Aliases...) {
+			if strings.Contains(normalize(name), query) {
+				foods = append(foods, food)
+				break
+			}
+		}
+	}
+	sort.Slice(foods, func(i, j int) bool { return foods[i].Name < foods[j].Name })
+	return foods
+}
+
+// Estimate sums the nutrients of the ingredients. Ingredients without a matching food, without
+// a quantity or in a unit that cannot be converted to grams are left out.
+func (db *Database) Estimate(ingredients []Ingredient) Estimate {
+	estimate := Estimate{Ingredients: len(ingredients)}
+	for _, ingredient := range ingredients {
+		var food *Food
+		var ok bool
+		if ingredient.Food != "" {
+			food, ok = db.Food(ingredient.Food)
+		} else {
+			food, ok = db.Match(ingredient.Name)
+		}
+		if !ok {
+			continue
+		}
+		grams, ok := Grams(food, ingredient.Quantity, ingredient.Unit)
+		if !ok {
+			continue
+		}
+		estimate.Total = estimate.Total.Add(food.Per100g, grams/100)
+		estimate.Matched++
+	}
+	return estimate
+}
+
+// Grams converts a quantity of food to grams.
+func Grams(food *Food, quantity float64, unit string) (float64, bool) {
+	if quantity <= 0 {
+		return 0, false
+	}
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if grams, ok := unitGrams[unit]; ok {
+		return quantity * grams, true
+	}
+	if pieceUnits[unit] && food.PieceGrams > 0 {
+		return quantity * food.PieceGrams, true
+	}
+	return 0, false
+}
+
+// normalize lower-cases name and keeps only its words, so "Daging  Sapi," becomes "daging sapi".
+func normalize(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r == '-' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}), " ")
+}
@@ -0,0 +1,250 @@
+package nutrition_test
+
+import (
+	"api-culinary-review/pkg/nutrition"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const dataset = `name,aliases,calories,protein,carbs,fat,piece_grams
+telur ayam,telur;egg,143,12.6,0.7,9.5,50
+nasi putih,nasi;rice,130,2.7,28.2,0.3,0
+daging sapi,beef,250,26,0,15,0
+`
+
+func newDatabase(t *testing.T) *nutrition.Database {
+	t.Helper()
+	db, err := nutrition.LoadCSV(strings.NewReader(dataset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestGrams(t *testing.T) {
+	db := newDatabase(t)
+	egg, _ := db.Food("egg")
+	rice, _ := db.Food("rice")
+
+	tests := []struct {
+		name     string
+		food     *nutrition.Food
+		quantity float64
+		unit     string
+		want     float64
+		wantOK   bool
+	}{
+		{"grams", rice, 200, "g", 200, true},
+		{"kilograms", rice, 1.5, "kg", 1500, true},
+		{"ons", rice, 2, "ons", 200, true},
+		{"unit in another case and with spaces", rice, 2, " Cups ", 480, true},
+		{"tablespoons", rice, 3, "sdm", 45, true},
+		{"teaspoons", rice, 2, "tsp", 10, true},
+		{"millilitres weigh as water", rice, 250, "ml", 250, true},
+		{"pieces", egg, 3, "butir", 150, true},
+		{"quantity without a unit is pieces", egg, 2, "", 100, true},
+		{"weight unit of a food counted in pieces", egg, 100, "gram", 100, true},
+		{"pieces of a food not counted in pieces", rice, 2, "buah", 0, false},
+		{"unknown unit", egg, 2, "handful", 0, false},
+		{"zero quantity", rice, 0, "g", 0, false},
+		{"negative quantity", rice, -1, "g", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := nutrition.Grams(tt.food, tt.quantity, tt.unit)
+			if ok != tt.wantOK || !near(got, tt.want) {
+				t.Errorf("Grams(%s, %v, %q) = %v, %v, want %v, %v", tt.food.Name, tt.quantity, tt.unit, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	db := newDatabase(t)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"telur ayam", "telur ayam"},
+		{"Telur", "telur ayam"},
+		{"2 butir telur ayam kampung", "telur ayam"},
+		{"Daging  Sapi, cincang", "daging sapi"},
+		{"nasi goreng", "nasi putih"},
+		{"tempe", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			food, ok := db.Match(tt.name)
+			got := ""
+			if ok {
+				got = food.Name
+			}
+			if got != tt.want {
+				t.Errorf("Match(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	db := newDatabase(t)
+
+	tests := []struct {
+		name         string
+		ingredients  []nutrition.Ingredient
+		wantCalories float64
+		wantMatched  int
+		wantComplete bool
+	}{
+		{"no ingredients", nil, 0, 0, true},
+		{"matched by name", []nutrition.Ingredient{
+			{Name: "nasi", Quantity: 200, Unit: "g"},
+			{Name: "telur ayam", Quantity: 2},
+		}, 260 + 143, 2, true},
+		{"food overrides the name", []nutrition.Ingredient{
+			{Name: "isian", Food: "beef", Quantity: 100, Unit: "g"},
+		}, 250, 1, true},
+		{"unknown food", []nutrition.Ingredient{
+			{Name: "nasi", Quantity: 100, Unit: "g"},
+			{Name: "tempe", Quantity: 100, Unit: "g"},
+		}, 130, 1, false},
+		{"unknown food named explicitly", []nutrition.Ingredient{
+			{Name: "nasi", Food: "tempe", Quantity: 100, Unit: "g"},
+		}, 0, 0, false},
+		{"unknown unit", []nutrition.Ingredient{
+			{Name: "telur", Quantity: 1, Unit: "handful"},
+		}, 0, 0, false},
+		{"no quantity", []nutrition.Ingredient{
+			{Name: "garam secukupnya"},
+			{Name: "nasi"},
+		}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := db.Estimate(tt.ingredients)
+			if !near(got.Total.Calories, tt.wantCalories) || got.Matched != tt.wantMatched ||
+				got.Ingredients != len(tt.ingredients) || got.Complete() != tt.wantComplete {
+				t.Errorf("Estimate() = %+v, complete %v, want %v kcal from %d of %d ingredients, complete %v",
+					got, got.Complete(), tt.wantCalories, tt.wantMatched, len(tt.ingredients), tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestLoadCSV(t *testing.T) {
+	const header = "name,aliases,calories,protein,carbs,fat,piece_grams\n"
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"header only", header, ""},
+		{"without aliases", header + "tempe,,192,20.3,7.6,10.8,0\n", ""},
+		{"empty", "", "empty dataset"},
+		{"missing column", header + "tempe,,192,20.3,7.6,10.8\n", "wrong number of fields"},
+		{"extra columns in every row", "name,aliases,calories,protein,carbs,fat,piece_grams,source\n" +
+			"tempe,,192,20.3,7.6,10.8,0,tkpi\n", "line 2: want 7 columns, got 8"},
+		{"unparsable number", header + "tempe,,lots,20.3,7.6,10.8,0\n", "line 2: calories"},
+		{"empty name", header + ",tempeh,192,20.3,7.6,10.8,0\n", "empty name"},
+		{"duplicate name ignoring case", header + "tempe,,192,20.3,7.6,10.8,0\nTempe,,150,10,5,5,0\n", `"Tempe" names both`},
+		{"alias of another food", header + "tempe,,192,20.3,7.6,10.8,0\ntahu,tempe,76,8,1.9,4.8,0\n", `"tempe" names both`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := nutrition.LoadCSV(strings.NewReader(tt.data))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("LoadCSV() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("LoadCSV() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	db := newDatabase(t)
+	egg, ok := db.Food("TELUR")
+	if !ok || egg.Name != "telur ayam" || strings.Join(egg.Aliases, ";") != "telur;egg" ||
+		egg.Per100g != (nutrition.Facts{Calories: 143, Protein: 12.6, Carbs: 0.7, Fat: 9.5}) || egg.PieceGrams != 50 {
+		t.Errorf("Food(TELUR) = %+v, %v, want telur ayam as in the dataset", egg, ok)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"empty array", `[]`, ""},
+		{"foods", `[{"name": "tempe", "aliases": ["tempeh"], "per_100g": {"calories": 192}}]`, ""},
+		{"not an array", `{"name": "tempe"}`, "cannot unmarshal"},
+		{"malformed", `[{"name": "tempe"`, "unexpected EOF"},
+		{"empty alias", `[{"name": "tempe", "aliases": [" "]}]`, "empty name"},
+		{"duplicate name", `[{"name": "tempe"}, {"name": "tahu", "aliases": ["Tempe"]}]`, `"Tempe" names both`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := nutrition.LoadJSON(strings.NewReader(tt.data))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("LoadJSON() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("LoadJSON() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	csvPath := write("foods.CSV", dataset)
+	jsonPath := write("foods.json", `[{"name": "tempe", "per_100g": {"calories": 192}, "piece_grams": 25}]`)
+	for _, path := range []string{csvPath, jsonPath} {
+		if _, err := nutrition.LoadFile(path); err != nil {
+			t.Errorf("LoadFile(%s) error = %v", filepath.Base(path), err)
+		}
+	}
+
+	db, _ := nutrition.LoadFile(jsonPath)
+	if tempe, ok := db.Food("tempe"); !ok || tempe.Per100g.Calories != 192 || tempe.PieceGrams != 25 {
+		t.Errorf("Food(tempe) = %+v, %v, want the food of the JSON file", tempe, ok)
+	}
+
+	if _, err := nutrition.LoadFile(write("foods.txt", dataset)); err == nil || !strings.Contains(err.Error(), "unsupported dataset") {
+		t.Errorf("LoadFile of a .txt file = %v, want an unsupported dataset error", err)
+	}
+	if _, err := nutrition.LoadFile(filepath.Join(dir, "missing.csv")); !os.IsNotExist(err) {
+		t.Errorf("LoadFile of a missing file = %v, want a not exist error", err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	db := nutrition.Default()
+	for _, name := range []string{"nasi putih", "egg", "garlic"} {
+		if _, ok := db.Food(name); !ok {
+			t.Errorf("bundled dataset has no food %q", name)
+		}
+	}
+	if got := db.Estimate([]nutrition.Ingredient{{Name: "telur", Quantity: 2, Unit: "butir"}}); !near(got.Total.Calories, 143) {
+		t.Errorf("Estimate(2 butir telur) = %v kcal, want 143", got.Total.Calories)
+	}
+}
@@ -0,0 +1,245 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// KindOIDC is a provider speaking OpenID Connect, with a standard userinfo endpoint.
+	KindOIDC = "oidc"
+	// KindGitHub is GitHub's OAuth2 API, which has no OpenID Connect support.
+	KindGitHub = "github"
+)
+
+var ErrNoEmail = errors.New("identity provider did not return an email address")
+
+// Config describes an external identity provider. Endpoints left empty are discovered from
+// IssuerURL via /.well-known/openid-configuration, which lets tests point a provider at a
+// local mock server.
+type Config struct {
+	Name         string
+	Kind         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	IssuerURL    string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	Scopes       []string
+}
+
+// Identity is the user as reported by the provider.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow against a single identity provider.
+type Provider struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu         sync.Mutex
+	discovered bool
+}
+
+func NewProvider(cfg Config) *Provider {
+	if cfg.Kind == "" {
+		cfg.Kind = KindOIDC
+	}
+	return &Provider{cfg: cfg, httpClient: http.DefaultClient}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to in order to sign in.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string) (string, error) {
+	oauthCfg, err := p.oauthConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauthCfg.AuthCodeURL(state), nil
+}
+
+// Exchange trades the authorization code for an access token and fetches the user's identity.
+func (p *Provider) Exchange(ctx context.Context, code string) (*Identity, error) {
+	oauthCfg, err := p.oauthConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	token, err := oauthCfg.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	client := oauthCfg.Client(ctx, token)
+
+	var identity *Identity
+	if p.cfg.Kind == KindGitHub {
+		identity, err = p.githubIdentity(client)
+	} else {
+		identity, err = p.oidcIdentity(client)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if identity.Email == "" {
+		return nil, ErrNoEmail
+	}
+	return identity, nil
+}
+
+func (p *Provider) oauthConfig(ctx context.Context) (*oauth2.Config, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.cfg.AuthURL,
+			TokenURL: p.cfg.TokenURL,
+		},
+	}, nil
+}
+
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered || p.cfg.IssuerURL == "" || (p.cfg.AuthURL != "" && p.cfg.TokenURL != "" && p.cfg.UserInfoURL != "") {
+		return nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := p.getJSON(p.httpClient, req, &doc); err != nil {
+		return fmt.Errorf("failed to discover %s: %w", p.cfg.Name, err)
+	}
+
+	if p.cfg.AuthURL == "" {
+		p.cfg.AuthURL = doc.AuthorizationEndpoint
+	}
+	if p.cfg.TokenURL == "" {
+		p.cfg.TokenURL = doc.TokenEndpoint
+	}
+	if p.cfg.UserInfoURL == "" {
+		p.cfg.UserInfoURL = doc.UserInfoEndpoint
+	}
+	p.discovered = true
+	return nil
+}
+
+func (p *Provider) oidcIdentity(client *http.Client) (*Identity, error) {
+	req, err := http.NewRequest(http.MethodGet, p.cfg.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Subject       string      `json:"sub"`
+		Email         string      `json:"email"`
+		EmailVerified interface{} `json:"email_verified"`
+		Name          string      `json:"name"`
+	}
+	if err := p.getJSON(client, req, &info); err != nil {
+		return nil, fmt.Errorf("failed to fetch user info: %w", err)
+	}
+	if info.Subject == "" {
+		return nil, errors.New("user info has no subject")
+	}
+
+	// Some providers send email_verified as a string.
+	verified := false
+	switch v := info.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified, _ = strconv.ParseBool(v)
+	}
+
+	return &Identity{Subject: info.Subject, Email: info.Email, EmailVerified: verified, Name: info.Name}, nil
+}
+
+func (p *Provider) githubIdentity(client *http.Client) (*Identity, error) {
+	req, err := http.NewRequest(http.MethodGet, p.cfg.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(client, req, &user); err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, strings.TrimSuffix(p.cfg.UserInfoURL, "/")+"/emails", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(client, req, &emails); err != nil {
+		return nil, fmt.Errorf("failed to fetch emails: %w", err)
+	}
+
+	identity := &Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+			break
+		}
+	}
+	return identity, nil
+}
+
+func (p *Provider) getJSON(client *http.Client, req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"api-culinary-review/pkg/clock"
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// MemoryStore keeps buckets in process memory. It is only suitable for a single instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	clock     clock.Clock
+	lastSweep time.Time
+}
+
+func NewMemoryStore(clock clock.Clock) *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		clock:   clock,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Limit), last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = refill(b.tokens, now.Sub(b.last), policy)
+	// A clock set back does not move the bucket back in time, or it would be refilled twice.
+	if now.After(b.last) {
+		b.last = now
+	}
+	b.period = policy.Period
+
+	result := Result{Limit: policy.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rate(policy))
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = secondsToDuration((float64(policy.Limit) - b.tokens) / rate(policy))
+
+	return result, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again, at most once a minute.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.period {
+			delete(s.buckets, key)
+		}
+	}
+}
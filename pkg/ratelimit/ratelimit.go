@@ -0,0 +1,96 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows bursts of up to Limit requests, refilled at Limit requests per Period.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Disabled reports whether the policy lets every request through.
+func (p Policy) Disabled() bool {
+	return p.Limit <= 0 || p.Period <= 0
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%d/%s", p.Limit, p.Period)
+}
+
+// ParsePolicy parses a policy written as "<limit>/<period>", e.g. "10/1m". An empty string or
+// a limit of 0 yields a disabled policy.
+func ParsePolicy(s string) (Policy, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Policy{}, nil
+	}
+
+	limitStr, periodStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("invalid rate limit policy %q, expected <limit>/<period>", s)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limit < 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q", limitStr)
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(periodStr))
+	if err != nil || period <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit period %q", periodStr)
+	}
+
+	return Policy{Limit: limit, Period: period}, nil
+}
+
+// UnmarshalText parses a policy written as accepted by ParsePolicy.
+func (p *Policy) UnmarshalText(text []byte) error {
+	policy, err := ParsePolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is how long until a token is available when the request was not allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets. Take consumes one token from the bucket of key if one is available.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// refill returns the tokens in a bucket after elapsed time, capped at the limit.
+func refill(tokens float64, elapsed time.Duration, policy Policy) float64 {
+	if elapsed > 0 {
+		tokens += elapsed.Seconds() * rate(policy)
+	}
+	if tokens > float64(policy.Limit) {
+		tokens = float64(policy.Limit)
+	}
+	return tokens
+}
+
+// rate is the number of tokens added per second.
+func rate(policy Policy) float64 {
+	return float64(policy.Limit) / policy.Period.Seconds()
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,129 @@
+package ratelimit_test
+
+import (
+	"api-culinary-review/pkg/clock"
+	"api-culinary-review/pkg/ratelimit"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ratelimit.Policy
+		wantErr bool
+	}{
+		{"10/1m", ratelimit.Policy{Limit: 10, Period: time.Minute}, false},
+		{" 300 / 1h ", ratelimit.Policy{Limit: 300, Period: time.Hour}, false},
+		{"0/1m", ratelimit.Policy{Period: time.Minute}, false},
+		{"", ratelimit.Policy{}, false},
+		{"10", ratelimit.Policy{}, true},
+		{"ten/1m", ratelimit.Policy{}, true},
+		{"-1/1m", ratelimit.Policy{}, true},
+		{"10/soon", ratelimit.Policy{}, true},
+		{"10/0s", ratelimit.Policy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ratelimit.ParsePolicy(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %v, %v, want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if !(ratelimit.Policy{Period: time.Minute}).Disabled() || (ratelimit.Policy{Limit: 1, Period: time.Minute}).Disabled() {
+		t.Error("Disabled is only true for policies without a limit or period")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	// 3 requests per 30s: a token every 10s.
+	policy := ratelimit.Policy{Limit: 3, Period: 30 * time.Second}
+
+	type take struct {
+		// advance moves the clock before taking, backwards when negative.
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{"burst up to the limit", []take{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, 10 * time.Second},
+		}},
+		{"partial refill", []take{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{4 * time.Second, false, 0, 6 * time.Second},
+			{6 * time.Second, true, 0, 0},
+			{0, false, 0, 10 * time.Second},
+		}},
+		{"refill is capped at the limit", []take{
+			{0, true, 2, 0},
+			{time.Hour, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, 10 * time.Second},
+		}},
+		{"a clock set back adds no tokens", []take{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{-time.Minute, false, 0, 10 * time.Second},
+			// Back to the time of the last take: no time has passed for the bucket.
+			{time.Minute, false, 0, 10 * time.Second},
+			{10 * time.Second, true, 0, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+			store := ratelimit.NewMemoryStore(clk)
+			for i, take := range tt.takes {
+				clk.Advance(take.advance)
+				got, err := store.Take(context.Background(), "key", policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Allowed != take.wantAllowed || got.Remaining != take.wantRemaining || !near(got.RetryAfter, take.wantRetry) || got.Limit != policy.Limit {
+					t.Errorf("take %d = %+v, want allowed %v, remaining %d, retry after %v", i, got, take.wantAllowed, take.wantRemaining, take.wantRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	store := ratelimit.NewMemoryStore(clk)
+	policy := ratelimit.Policy{Limit: 1, Period: time.Minute}
+
+	if got, _ := store.Take(context.Background(), "alice", policy); !got.Allowed || got.ResetAfter != time.Minute {
+		t.Errorf("first take of alice = %+v, want allowed with a reset after 1m", got)
+	}
+	if got, _ := store.Take(context.Background(), "alice", policy); got.Allowed {
+		t.Errorf("second take of alice = %+v, want refused", got)
+	}
+	if got, _ := store.Take(context.Background(), "bob", policy); !got.Allowed {
+		t.Errorf("first take of bob = %+v, want allowed", got)
+	}
+
+	// Idle buckets are dropped once full again, which starts them over.
+	clk.Advance(2 * time.Minute)
+	if got, _ := store.Take(context.Background(), "alice", policy); !got.Allowed || got.Remaining != 0 {
+		t.Errorf("take of alice after 2m = %+v, want allowed", got)
+	}
+}
+
+// near reports whether durations computed from fractional tokens are equal to the millisecond.
+func near(a, b time.Duration) bool {
+	d := a - b
+	return d > -time.Millisecond && d < time.Millisecond
+}
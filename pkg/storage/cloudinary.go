@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"api-culinary-review/pkg/metrics"
+	"api-culinary-review/pkg/tracing"
+	"api-culinary-review/pkg/utils"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const uploadDir = "internal/assets/uploads/"
+
+// Cloudinary stores images in Cloudinary.
+type Cloudinary struct {
+	cld *cloudinary.Cloudinary
+}
+
+// NewCloudinary creates a Cloudinary storage from a CLOUDINARY_URL,
+// cloudinary://<api_key>:<api_secret>@<cloud_name>.
+func NewCloudinary(cloudinaryURL string) (*Cloudinary, error) {
+	cld, err := cloudinary.NewFromURL(cloudinaryURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Cloudinary{cld: cld}, nil
+}
+
+func (s *Cloudinary) Upload(ctx context.Context, file *multipart.FileHeader) (string, error) {
+	ctx, span := tracing.Start(ctx, "cloudinary.Upload", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int64("file.size", file.Size)))
+	start := time.Now()
+	url, err := s.upload(ctx, file)
+	metrics.ObserveUpload("cloudinary", time.Since(start), err)
+	tracing.End(span, err)
+	return url, err
+}
+
+func (s *Cloudinary) upload(ctx context.Context, file *multipart.FileHeader) (string, error) {
+	// Buat direktori assets/uploads/ jika belum ada
+	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
+		err = os.MkdirAll(uploadDir, os.ModePerm)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Simpan file sementara di assets/uploads/
+	tempFilePath := filepath.Join(uploadDir, file.Filename)
+	out, err := os.Create(tempFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	in, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return "", err
+	}
+
+	// Remove file setelah upload ke Cloudinary
+	defer func() {
+		os.Remove(tempFilePath)
+	}()
+
+	uid, err := utils.GenerateUid()
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.cld.Upload.Upload(ctx, tempFilePath, uploader.UploadParams{PublicID: "image-" + file.Filename + "-" + uid})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.SecureURL, nil
+}
+
+func (s *Cloudinary) Delete(ctx context.Context, imageURL string) (err error) {
+	ctx, span := tracing.Start(ctx, "cloudinary.Destroy", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { tracing.End(span, err) }()
+
+	_, err = s.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: imageURL})
+	return err
+}
+
+func (s *Cloudinary) Ping(ctx context.Context) error {
+	res, err := s.cld.Admin.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if res.Error.Message != "" {
+		return errors.New(res.Error.Message)
+	}
+	return nil
+}
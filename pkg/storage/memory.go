@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"sync"
+)
+
+// Memory keeps uploaded files in memory, for tests and local development without a provider.
+type Memory struct {
+	mu    sync.Mutex
+	n     int
+	files map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string][]byte)}
+}
+
+func (s *Memory) Upload(_ context.Context, file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	url := fmt.Sprintf("memory://%d/%s", s.n, file.Filename)
+	s.files[url] = data
+	return url, nil
+}
+
+func (s *Memory) Delete(_ context.Context, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, url)
+	return nil
+}
+
+func (s *Memory) Ping(context.Context) error {
+	return nil
+}
+
+// File returns the content stored under url.
+func (s *Memory) File(url string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[url]
+	return data, ok
+}
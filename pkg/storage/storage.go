@@ -0,0 +1,23 @@
+// Package storage keeps uploaded images with an external provider.
+package storage
+
+import (
+	"context"
+	"mime/multipart"
+)
+
+// Drivers supported by the configuration.
+const (
+	DriverCloudinary = "cloudinary"
+	DriverSupabase   = "supabase"
+)
+
+// Storage stores uploaded files and serves them from public URLs.
+type Storage interface {
+	// Upload stores file and returns its public URL.
+	Upload(ctx context.Context, file *multipart.FileHeader) (string, error)
+	// Delete removes the file stored under url.
+	Delete(ctx context.Context, url string) error
+	// Ping checks that the provider is reachable with the configured credentials.
+	Ping(ctx context.Context) error
+}
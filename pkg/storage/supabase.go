@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"api-culinary-review/pkg/metrics"
+	"api-culinary-review/pkg/tracing"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"time"
+
+	supabasestorageuploader "github.com/adityarizkyramadhan/supabase-storage-uploader"
+	storage_go "github.com/supabase-community/storage-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supabase stores images in a Supabase Storage bucket.
+type Supabase struct {
+	url      string
+	bucket   string
+	client   *storage_go.Client
+	uploader *supabasestorageuploader.Client
+}
+
+func NewSupabase(url, key, bucket string) *Supabase {
+	return &Supabase{
+		url:      url,
+		bucket:   bucket,
+		client:   storage_go.NewClient(url, key, nil),
+		uploader: supabasestorageuploader.New(url, key, bucket),
+	}
+}
+
+func (s *Supabase) Upload(ctx context.Context, file *multipart.FileHeader) (string, error) {
+	_, span := tracing.Start(ctx, "supabase.UploadFile", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int64("file.size", file.Size)))
+	start := time.Now()
+	url, err := s.upload(file)
+	metrics.ObserveUpload("supabase", time.Since(start), err)
+	tracing.End(span, err)
+	return url, err
+}
+
+func (s *Supabase) upload(file *multipart.FileHeader) (string, error) {
+	fileName := fmt.Sprintf("%d_%s", time.Now().Unix(), file.Filename)
+	filePath := path.Join("public", fileName)
+
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	buffer := make([]byte, file.Size)
+	if _, err = io.ReadFull(src, buffer); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	reader := bytes.NewReader(buffer)
+
+	res, err := s.client.UploadFile(s.bucket, filePath, reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	if res.Error != "" {
+		return "", fmt.Errorf("upload error: %v", res.Error)
+	}
+
+	url := fmt.Sprintf("https://%s.storage.supabase.co/storage/v1/object/%s/%s", s.url, s.bucket, filePath)
+	return url, nil
+}
+
+func (s *Supabase) Delete(ctx context.Context, imagePath string) (err error) {
+	_, span := tracing.Start(ctx, "supabase.Delete", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.uploader.Delete(imagePath); err != nil {
+		return fmt.Errorf("failed to delete image: %v", err)
+	}
+	return nil
+}
+
+func (s *Supabase) Ping(ctx context.Context) error {
+	_, err := s.client.GetBucket(s.bucket)
+	return err
+}
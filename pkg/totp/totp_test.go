@@ -0,0 +1,104 @@
+package totp_test
+
+import (
+	"api-culinary-review/pkg/totp"
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of the test vectors in RFC 6238, appendix B.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; 6 digit codes are their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := totp.Code(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.code[2:]; got != want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestCodeNormalizesSecrets(t *testing.T) {
+	if got, err := totp.Code(" "+strings.ToLower(rfcSecret)+" ", 1); err != nil || got != "287082" {
+		t.Errorf("Code of a lowercase secret = %s, %v, want 287082", got, err)
+	}
+	if _, err := totp.Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := totp.Step(now)
+	code := func(step int64) string {
+		c, err := totp.Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(step), 1, step, true},
+		{"previous step within skew", code(step - 1), 1, step - 1, true},
+		{"next step within skew", code(step + 1), 1, step + 1, true},
+		{"previous step without skew", code(step - 1), 0, 0, false},
+		{"two steps back with a skew of one", code(step - 2), 1, 0, false},
+		{"two steps ahead with a skew of two", code(step + 2), 2, step + 2, true},
+		{"surrounding spaces", " " + code(step) + " ", 0, step, true},
+		{"wrong code", "000000", 1, 0, false},
+		{"too short", code(step)[:5], 1, 0, false},
+		{"too long", code(step) + "0", 1, 0, false},
+		{"empty", "", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := totp.Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate(%q, skew %d) = %d, %v, want %d, %v", tt.code, tt.skew, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := totp.GenerateSecret()
+	if a == b {
+		t.Error("GenerateSecret returned the same secret twice")
+	}
+	if _, err := totp.Code(a, 0); err != nil {
+		t.Errorf("Code of a generated secret: %v", err)
+	}
+
+	uri := totp.ProvisioningURI("Culinary Review", "alice@example.com", a)
+	if !strings.HasPrefix(uri, "otpauth://totp/Culinary%20Review:alice@example.com?") || !strings.Contains(uri, "secret="+a) {
+		t.Errorf("ProvisioningURI = %s", uri)
+	}
+}
@@ -0,0 +1,30 @@
+package utils_test
+
+import (
+	"api-culinary-review/pkg/utils"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Vegan", "vegan"},
+		{"Nasi Goreng", "nasi-goreng"},
+		{"  Gluten-free  ", "gluten-free"},
+		{"Low   carb / keto", "low-carb-keto"},
+		{"Crème brûlée", "creme-brulee"},
+		{"Jalapeño", "jalapeno"},
+		{"ﬁsh", "fish"},
+		{"5 minute meals", "5-minute-meals"},
+		{"Rock 'n' roll!", "rock-n-roll"},
+		{"日本料理", "日本料理"},
+		{"--", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := utils.Slugify(tt.in); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,15 +1,179 @@
 package utils
 
 import (
+	"api-culinary-review/pkg/apperror"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	id_translations "github.com/go-playground/validator/v10/translations/id"
+)
+
+// Keys of the messages that are not produced by a validation rule.
+const (
+	MsgValidationFailed = "validation_failed"
+	MsgMalformedBody    = "malformed_body"
+	MsgInvalidID        = "invalid_id"
+	MsgInvalidJSONArray = "invalid_json_array"
 )
 
-var validate *validator.Validate
+var messages = map[string]map[string]string{
+	"en": {
+		MsgValidationFailed: "request validation failed",
+		MsgMalformedBody:    "malformed request body",
+		MsgInvalidID:        "{0} must be a positive integer",
+		MsgInvalidJSONArray: "{0} must be a JSON array",
+	},
+	"id": {
+		MsgValidationFailed: "validasi permintaan gagal",
+		MsgMalformedBody:    "isi permintaan tidak valid",
+		MsgInvalidID:        "{0} harus berupa bilangan bulat positif",
+		MsgInvalidJSONArray: "{0} harus berupa array JSON",
+	},
+}
+
+var (
+	validate    *validator.Validate
+	translators *ut.UniversalTranslator
+)
 
 func init() {
 	validate = validator.New()
+	UseJSONFieldNames(validate)
+
+	english := en.New()
+	translators = ut.New(english, english, id.New())
+
+	registerTranslations := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": en_translations.RegisterDefaultTranslations,
+		"id": id_translations.RegisterDefaultTranslations,
+	}
+	for lang, register := range registerTranslations {
+		trans, _ := translators.GetTranslator(lang)
+		if err := register(validate, trans); err != nil {
+			panic(err)
+		}
+		for key, text := range messages[lang] {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(err)
+			}
+		}
+	}
 }
 
 func ValidateStruct(s interface{}) error {
 	return validate.Struct(s)
 }
+
+// StructValidator checks request bodies bound by gin with the `validate` tags, so every request
+// model is validated the same way. It implements gin's binding.StructValidator.
+type StructValidator struct{}
+
+func (StructValidator) ValidateStruct(obj interface{}) error {
+	value := reflect.ValueOf(obj)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	return validate.Struct(value.Interface())
+}
+
+func (StructValidator) Engine() interface{} {
+	return validate
+}
+
+// UseJSONFieldNames makes v report fields by the name the client sent them with, their form or
+// json name, instead of the Go field name.
+func UseJSONFieldNames(v *validator.Validate) {
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"form", "json"} {
+			name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+			if name == "-" {
+				continue
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+}
+
+// Translator returns the translator of the supported language (en or id) preferred by an
+// Accept-Language header, falling back to English.
+func Translator(acceptLanguage string) ut.Translator {
+	trans, _ := translators.FindTranslator(preferredLanguages(acceptLanguage)...)
+	return trans
+}
+
+// Translate returns the message with the given key in the language of trans.
+func Translate(trans ut.Translator, key string, params ...string) string {
+	text, err := trans.T(key, params...)
+	if err != nil {
+		return key
+	}
+	return text
+}
+
+// FieldErrors translates validation errors into field errors.
+func FieldErrors(errs validator.ValidationErrors, trans ut.Translator) []apperror.FieldError {
+	fields := make([]apperror.FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, apperror.FieldError{
+			Field:   fe.Field(),
+			Message: fe.Translate(trans),
+		})
+	}
+	return fields
+}
+
+// preferredLanguages lists the languages of an Accept-Language header by decreasing quality,
+// each region-specific tag followed by its base language.
+func preferredLanguages(header string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality > 0 {
+			languages = append(languages, language{tag: tag, quality: quality})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	locales := make([]string, 0, len(languages)*2)
+	for _, lang := range languages {
+		locale := strings.ToLower(strings.ReplaceAll(lang.tag, "-", "_"))
+		locales = append(locales, locale)
+		if base, _, found := strings.Cut(locale, "_"); found {
+			locales = append(locales, base)
+		}
+	}
+	return locales
+}
@@ -0,0 +1,107 @@
+package utils_test
+
+import (
+	"api-culinary-review/pkg/apperror"
+	"api-culinary-review/pkg/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestTranslator(t *testing.T) {
+	tests := []struct {
+		acceptLanguage, want string
+	}{
+		{"id", "id"},
+		{"id-ID;q=0.9", "id"},
+		{"ID", "id"},
+		{"en-US,id;q=0.8", "en"},
+		{"en;q=0.5, id-ID;q=0.8", "id"},
+		{"id;q=0, en;q=0.5", "en"},
+		{"fr-FR", "en"},
+		{"fr, id;q=0.7", "id"},
+		{"*", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		if got := utils.Translator(tt.acceptLanguage).Locale(); got != tt.want {
+			t.Errorf("Translator(%q) = %q, want %q", tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+func TestFieldErrorsProblem(t *testing.T) {
+	type request struct {
+		Name     string `json:"name" validate:"required"`
+		Rating   int    `form:"rating" json:"rating_value" validate:"min=1,max=5"`
+		Decision string `json:"decision" validate:"oneof=approve reject"`
+		Secret   string `json:"-" validate:"required"`
+	}
+	var errs validator.ValidationErrors
+	if err := utils.ValidateStruct(request{Rating: 9, Decision: "maybe"}); !errors.As(err, &errs) {
+		t.Fatalf("ValidateStruct() error = %v, want validation errors", err)
+	}
+
+	tests := []struct {
+		acceptLanguage string
+		want           apperror.Problem
+	}{
+		{"en", apperror.Problem{Detail: "request validation failed", Errors: []apperror.FieldError{
+			{Field: "name", Message: "name is a required field"},
+			{Field: "rating", Message: "rating must be 5 or less"},
+			{Field: "decision", Message: "decision must be one of [approve reject]"},
+			{Field: "Secret", Message: "Secret is a required field"},
+		}}},
+		{"id-ID;q=0.9", apperror.Problem{Detail: "validasi permintaan gagal", Errors: []apperror.FieldError{
+			{Field: "name", Message: "name wajib diisi"},
+			{Field: "rating", Message: "rating harus 5 atau kurang"},
+			{Field: "decision", Message: "decision harus berupa salah satu dari [approve reject]"},
+			{Field: "Secret", Message: "Secret wajib diisi"},
+		}}},
+		{"", apperror.Problem{Detail: "request validation failed", Errors: []apperror.FieldError{
+			{Field: "name", Message: "name is a required field"},
+			{Field: "rating", Message: "rating must be 5 or less"},
+			{Field: "decision", Message: "decision must be one of [approve reject]"},
+			{Field: "Secret", Message: "Secret is a required field"},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			trans := utils.Translator(tt.acceptLanguage)
+			validationErr := apperror.Validation(utils.Translate(trans, utils.MsgValidationFailed), utils.FieldErrors(errs, trans)...)
+
+			body, err := json.Marshal(apperror.NewProblem(validationErr, "/api/reviews", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got apperror.Problem
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != http.StatusBadRequest || got.Detail != tt.want.Detail || !reflect.DeepEqual(got.Errors, tt.want.Errors) {
+				t.Errorf("problem = %s, want status 400, detail %q and errors %+v", body, tt.want.Detail, tt.want.Errors)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		acceptLanguage, key, want string
+	}{
+		{"en", utils.MsgInvalidID, "id must be a positive integer"},
+		{"id", utils.MsgInvalidID, "id harus berupa bilangan bulat positif"},
+		{"id", utils.MsgMalformedBody, "isi permintaan tidak valid"},
+		{"fr", utils.MsgInvalidJSONArray, "id must be a JSON array"},
+		{"id", "unknown_key", "unknown_key"},
+	}
+	for _, tt := range tests {
+		if got := utils.Translate(utils.Translator(tt.acceptLanguage), tt.key, "id"); got != tt.want {
+			t.Errorf("Translate(%q, %q) = %q, want %q", tt.acceptLanguage, tt.key, got, tt.want)
+		}
+	}
+}